@@ -1,23 +1,53 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"go.uber.org/zap"
 
 	"github.com/myacey/redditclone/internal/apiserver"
+	"github.com/myacey/redditclone/internal/export"
+	"github.com/myacey/redditclone/internal/handlers"
 	"github.com/myacey/redditclone/internal/logging"
+	"github.com/myacey/redditclone/internal/mailer"
+	"github.com/myacey/redditclone/internal/oidc"
+	"github.com/myacey/redditclone/internal/password/argonhasher"
 	"github.com/myacey/redditclone/internal/repository/mongorepo"
 	"github.com/myacey/redditclone/internal/repository/postgresrepo"
 	"github.com/myacey/redditclone/internal/repository/redisrepo"
 	"github.com/myacey/redditclone/internal/service"
+	"github.com/myacey/redditclone/internal/token"
 	"github.com/myacey/redditclone/internal/token/jwttoken"
+	"github.com/myacey/redditclone/internal/token/pasetotoken"
+)
+
+const (
+	mongoDatabaseName      = "redditclone"
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultAppURL          = "http://localhost:8080"
+	defaultOutboxDir       = "./outbox"
+	defaultExportDir       = "./exports"
+	defaultExportTTL       = 24 * time.Hour
+	exportCleanupInterval  = time.Hour
+	contentPurgeInterval   = time.Hour
+	deletionResumeInterval = 5 * time.Minute
+	exportResumeInterval   = 5 * time.Minute
+	// the same as HMAC-SHA256 output
+	minExportSigningKeyLength = 32
 )
 
 func main() {
+	reconcileComments := flag.Bool("reconcile-comments", false, "recount comments of all posts, fix drifted counts and exit")
+	dryRun := flag.Bool("dry-run", false, "only report drifted comment counts with -reconcile-comments")
 	flag.Parse()
 
 	if err := godotenv.Load(); err != nil {
@@ -37,6 +67,9 @@ func main() {
 		logger.Fatal(err)
 	}
 	logger.Info("mongo initialized")
+	if err = mongorepo.Migrate(context.Background(), mongoClient, mongoDatabaseName); err != nil {
+		logger.Fatal(err)
+	}
 
 	rdb, err := redisrepo.ConfigureRedisClient()
 	if err != nil {
@@ -56,10 +89,279 @@ func main() {
 	}
 	logger.Info("postgres initialized")
 
-	tokenMaker := jwttoken.NewJWTToken([]byte(os.Getenv("JWT_SECRET_KEY")))
+	accessTokenTTL, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL"))
+	if err != nil {
+		logger.Warnf("invalid ACCESS_TOKEN_TTL, using default %v: %v", defaultAccessTokenTTL, err)
+		accessTokenTTL = defaultAccessTokenTTL
+	}
+
+	tokenMaker, err := configureTokenMaker(os.Getenv("TOKEN_TYPE"), accessTokenTTL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	passwordHasher := argonhasher.NewArgon2idHasher(argonhasher.DefaultParams())
+
+	oauthProviders := configureOAuthProviders(logger)
+
+	mail, err := configureMailer()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = defaultAppURL
+	}
+
+	exports, err := configureExports(logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	comments := configureComments(logger)
+
+	contentRetention := service.DefaultContentRetention
+	if value := os.Getenv("CONTENT_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention <= 0 {
+			logger.Warnf("invalid CONTENT_RETENTION, using default %v", service.DefaultContentRetention)
+		} else {
+			contentRetention = retention
+		}
+	}
 
-	service := service.NewService(postgresDB, mongoClient, "redditclone", rdb, tokenMaker, logger)
+	service := service.NewService(postgresDB, mongoClient, mongoDatabaseName, rdb, tokenMaker, passwordHasher, oauthProviders, mail, strings.TrimSuffix(appURL, "/"), exports, comments, contentRetention, logger)
 
-	server := apiserver.NewServer(logger, service, tokenMaker)
+	if *reconcileComments {
+		reconcileCommentCounts(service, *dryRun, logger)
+		return
+	}
+
+	// account deletions interrupted by shutdown of this or another instance
+	go func() {
+		for ; ; time.Sleep(deletionResumeInterval) {
+			if err := service.ResumeDeletionJobs(context.Background()); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+	// exports are checked the same way, stale ones are built again
+	go func() {
+		for ; ; time.Sleep(exportResumeInterval) {
+			if err := service.ResumeExportJobs(context.Background()); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+	go func() {
+		for ; ; time.Sleep(exportCleanupInterval) {
+			if err := service.CleanupExports(context.Background()); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+
+	// deleted content is kept for restore only during retention
+	go func() {
+		for ; ; time.Sleep(contentPurgeInterval) {
+			if err := service.PurgeDeletedContent(context.Background()); err != nil {
+				logger.Error(err)
+			}
+		}
+	}()
+
+	// X-Forwarded-For is ignored unless request came from one of them
+	trustedProxies, err := handlers.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	server := apiserver.NewServer(logger, service, tokenMaker, trustedProxies)
 	server.Start()
 }
+
+// reconcileCommentCounts logs every post whose comment count drifted.
+func reconcileCommentCounts(svc service.ServiceInterface, dryRun bool, logger *zap.SugaredLogger) {
+	drifts, err := svc.ReconcileCommentCounts(context.Background(), dryRun)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, drift := range drifts {
+		logger.Infow("comment count drifted",
+			"post_id", drift.PostID,
+			"stored", drift.Stored,
+			"actual", drift.Actual,
+			"fixed", drift.Fixed,
+		)
+	}
+	logger.Infof("comment counts reconciled, %d posts drifted", len(drifts))
+}
+
+// configureTokenMaker selects access token format, JWT is used by default.
+func configureTokenMaker(tokenType string, accessTokenTTL time.Duration) (token.TokenMaker, error) {
+	switch tokenType {
+	case "", "jwt":
+		// asymmetric keys are used if signing key is configured,
+		// old public keys are kept in JWT_VERIFICATION_KEY_FILES during rotation
+		signingKeyFile := os.Getenv("JWT_SIGNING_KEY_FILE")
+		if signingKeyFile == "" {
+			return jwttoken.NewJWTToken([]byte(os.Getenv("JWT_SECRET_KEY")), accessTokenTTL), nil
+		}
+
+		var verificationKeyFiles []string
+		if files := os.Getenv("JWT_VERIFICATION_KEY_FILES"); files != "" {
+			verificationKeyFiles = strings.Split(files, ",")
+		}
+
+		keyring, err := jwttoken.LoadKeyring(signingKeyFile, verificationKeyFiles...)
+		if err != nil {
+			return nil, err
+		}
+		return jwttoken.NewJWTTokenWithKeyring(keyring, accessTokenTTL), nil
+	case "paseto-local":
+		key, err := hex.DecodeString(os.Getenv("PASETO_LOCAL_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid PASETO_LOCAL_KEY: %v", err)
+		}
+		return pasetotoken.NewPasetoLocal(key, accessTokenTTL)
+	case "paseto-public":
+		privateKey, err := pasetotoken.LoadPrivateKey(os.Getenv("PASETO_PRIVATE_KEY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		return pasetotoken.NewPasetoPublic(privateKey, accessTokenTTL)
+	default:
+		return nil, fmt.Errorf("unknown TOKEN_TYPE %q", tokenType)
+	}
+}
+
+// configureMailer uses SMTP server if SMTP_HOST is set,
+// otherwise emails are written to MAIL_OUTBOX_DIR for local development.
+func configureMailer() (mailer.Mailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = defaultOutboxDir
+		}
+		return mailer.NewFileOutbox(dir)
+	}
+
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PORT: %v", err)
+	}
+
+	return mailer.NewSMTPMailer(mailer.SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}), nil
+}
+
+// configureExports keeps archives in EXPORT_DIR for EXPORT_TTL.
+// EXPORT_SIGNING_KEY is required: random key would break links after restart
+// and on other instances.
+func configureExports(logger *zap.SugaredLogger) (service.ExportConfig, error) {
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = defaultExportDir
+	}
+	store, err := export.NewFileStore(dir)
+	if err != nil {
+		return service.ExportConfig{}, err
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("EXPORT_TTL"))
+	if err != nil {
+		logger.Warnf("invalid EXPORT_TTL, using default %v: %v", defaultExportTTL, err)
+		ttl = defaultExportTTL
+	}
+
+	key := []byte(os.Getenv("EXPORT_SIGNING_KEY"))
+	if len(key) < minExportSigningKeyLength {
+		return service.ExportConfig{}, fmt.Errorf("EXPORT_SIGNING_KEY has to be at least %d bytes", minExportSigningKeyLength)
+	}
+
+	return service.ExportConfig{
+		Store:      store,
+		SigningKey: key,
+		TTL:        ttl,
+	}, nil
+}
+
+// configureComments reads COMMENT_MAX_DEPTH and COMMENT_EDIT_GRACE,
+// invalid values are replaced by defaults.
+func configureComments(logger *zap.SugaredLogger) service.CommentConfig {
+	config := service.CommentConfig{
+		MaxDepth:  service.DefaultCommentMaxDepth,
+		EditGrace: service.DefaultCommentEditGrace,
+	}
+
+	if value := os.Getenv("COMMENT_MAX_DEPTH"); value != "" {
+		maxDepth, err := strconv.Atoi(value)
+		if err != nil || maxDepth < 1 {
+			logger.Warnf("invalid COMMENT_MAX_DEPTH, using default %d", service.DefaultCommentMaxDepth)
+		} else {
+			config.MaxDepth = maxDepth
+		}
+	}
+
+	if value := os.Getenv("COMMENT_EDIT_GRACE"); value != "" {
+		grace, err := time.ParseDuration(value)
+		if err != nil || grace < 0 {
+			logger.Warnf("invalid COMMENT_EDIT_GRACE, using default %v", service.DefaultCommentEditGrace)
+		} else {
+			config.EditGrace = grace
+		}
+	}
+
+	return config
+}
+
+// configureOAuthProviders reads external login providers from env.
+// OIDC_PROVIDERS lists OpenID Connect providers, each configured with
+// OIDC_{NAME}_ISSUER, OIDC_{NAME}_CLIENT_ID, OIDC_{NAME}_CLIENT_SECRET and OIDC_{NAME}_REDIRECT_URL.
+// GitHub is enabled by GITHUB_CLIENT_ID.
+// Unavailable provider is skipped, so it cant stop the app from starting.
+func configureOAuthProviders(logger *zap.SugaredLogger) map[string]oidc.Provider {
+	providers := map[string]oidc.Provider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		provider, err := oidc.NewOIDCProvider(ctx, oidc.Config{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       []string{"email", "profile"},
+		}, nil)
+		cancel()
+		if err != nil {
+			logger.Warnf("cant configure oidc provider %s: %v", name, err)
+			continue
+		}
+		providers[name] = provider
+	}
+
+	if clientID := os.Getenv("GITHUB_CLIENT_ID"); clientID != "" {
+		providers["github"] = oidc.NewGitHubProvider(oidc.GitHubConfig{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+		}, nil)
+	}
+
+	return providers
+}
@@ -1,10 +1,12 @@
 package apiserver
 
 import (
+	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/myacey/redditclone/internal/handlers"
+	"github.com/myacey/redditclone/internal/models"
 	"github.com/myacey/redditclone/internal/service"
 	"github.com/myacey/redditclone/internal/token"
 
@@ -18,13 +20,13 @@ type Server struct {
 	Handler *handlers.Handler
 }
 
-func NewServer(logger *zap.SugaredLogger, service service.ServiceInterface, tokenMaker token.TokenMaker) *Server {
+func NewServer(logger *zap.SugaredLogger, service service.ServiceInterface, tokenMaker token.TokenMaker, trustedProxies []*net.IPNet) *Server {
 	server := Server{
 		Logger:  logger,
 		Service: service,
 	}
 
-	server.Handler = handlers.NewHandler(server.Service, server.Logger, tokenMaker) // Создаем Handler ПОСЛЕ инициализации Service и JWTMaker
+	server.Handler = handlers.NewHandler(server.Service, server.Logger, tokenMaker, trustedProxies) // Создаем Handler ПОСЛЕ инициализации Service и JWTMaker
 
 	server.configureRouter()
 
@@ -47,24 +49,68 @@ func (s *Server) configureRouter() {
 	// protected, need auth
 	protected := s.Router.PathPrefix("/api").Subrouter()
 	protected.Use(func(h http.Handler) http.Handler { return s.Handler.AuthMiddleware(h, s.Logger) })
-	protected.HandleFunc("/posts", s.Handler.AddPost).Methods("POST")
-	protected.HandleFunc("/post/{id}", s.Handler.AddComment).Methods("POST")
-	protected.HandleFunc("/post/{id}", s.Handler.DeletePost).Methods("DELETE")
-	protected.HandleFunc("/post/{postID}/{commentID}", s.Handler.DeleteComment).Methods("DELETE")
-	protected.HandleFunc("/post/{id}/unvote", s.Handler.UnvotePost).Methods("GET")
-	protected.HandleFunc("/post/{id}/upvote", s.Handler.VotePost).Methods("GET")
-	protected.HandleFunc("/post/{id}/downvote", s.Handler.DownvotePost).Methods("GET")
+	protected.HandleFunc("/posts", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.AddPost)).Methods("POST")
+	protected.HandleFunc("/post/{id}", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.AddComment)).Methods("POST")
+	protected.HandleFunc("/post/{id}", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.DeletePost)).Methods("DELETE")
+	protected.HandleFunc("/post/{id}", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.EditPost)).Methods("PATCH")
+	protected.HandleFunc("/post/{id}/restore", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.RestorePost)).Methods("POST")
+	protected.HandleFunc("/post/{postID}/{commentID}", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.DeleteComment)).Methods("DELETE")
+	protected.HandleFunc("/post/{postID}/{commentID}", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.EditComment)).Methods("PATCH")
+	protected.HandleFunc("/post/{postID}/{commentID}/revisions", s.Handler.RequireScope(models.ScopeRead, s.Handler.GetCommentRevisions)).Methods("GET")
+	protected.HandleFunc("/post/{postID}/{commentID}/restore", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.RestoreComment)).Methods("POST")
+	protected.HandleFunc("/post/{id}/comment/{commentID}/reply", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.ReplyComment)).Methods("POST")
+	protected.HandleFunc("/post/{id}/unvote", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.UnvotePost)).Methods("GET")
+	protected.HandleFunc("/post/{id}/upvote", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.VotePost)).Methods("GET")
+	protected.HandleFunc("/post/{id}/downvote", s.Handler.RequireScope(models.ScopePostsWrite, s.Handler.DownvotePost)).Methods("GET")
+	protected.HandleFunc("/post/{postID}/{commentID}/unvote", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.UnvoteComment)).Methods("GET")
+	protected.HandleFunc("/post/{postID}/{commentID}/upvote", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.VoteComment)).Methods("GET")
+	protected.HandleFunc("/post/{postID}/{commentID}/downvote", s.Handler.RequireScope(models.ScopeCommentsWrite, s.Handler.DownvoteComment)).Methods("GET")
+	protected.HandleFunc("/sessions", s.Handler.RequireSession(s.Handler.GetSessions)).Methods("GET")
+	protected.HandleFunc("/sessions/{id}", s.Handler.RequireSession(s.Handler.DeleteSession)).Methods("DELETE")
+	protected.HandleFunc("/logout", s.Handler.RequireSession(s.Handler.Logout)).Methods("POST")
+	protected.HandleFunc("/tokens", s.Handler.RequireSession(s.Handler.CreateAccessToken)).Methods("POST")
+	protected.HandleFunc("/tokens", s.Handler.RequireSession(s.Handler.GetAccessTokens)).Methods("GET")
+	protected.HandleFunc("/tokens/{id}", s.Handler.RequireSession(s.Handler.DeleteAccessToken)).Methods("DELETE")
+	protected.HandleFunc("/2fa/enroll", s.Handler.RequireSession(s.Handler.EnrollTOTP)).Methods("POST")
+	protected.HandleFunc("/2fa/confirm", s.Handler.RequireSession(s.Handler.ConfirmTOTP)).Methods("POST")
+	protected.HandleFunc("/oauth/identities", s.Handler.RequireSession(s.Handler.GetExternalIdentities)).Methods("GET")
+	protected.HandleFunc("/oauth/{provider}/link", s.Handler.RequireSession(s.Handler.OAuthLink)).Methods("POST")
+	protected.HandleFunc("/admin/roles", s.Handler.RequireSession(s.Handler.GrantRole)).Methods("POST")
+	protected.HandleFunc("/admin/roles/{id}", s.Handler.RequireSession(s.Handler.RevokeRole)).Methods("DELETE")
+	protected.HandleFunc("/admin/users/{username}/roles", s.Handler.RequireSession(s.Handler.GetUserRoles)).Methods("GET")
+	protected.HandleFunc("/admin/unlock", s.Handler.RequireSession(s.Handler.UnlockLogin)).Methods("POST")
+	protected.HandleFunc("/email", s.Handler.RequireSession(s.Handler.ChangeEmail)).Methods("POST")
+	protected.HandleFunc("/me", s.Handler.RequireSession(s.Handler.UpdateProfile)).Methods("PATCH")
+	protected.HandleFunc("/me", s.Handler.RequireSession(s.Handler.DeleteAccount)).Methods("DELETE")
+	protected.HandleFunc("/me/export", s.Handler.RequireSession(s.Handler.RequestExport)).Methods("POST")
+	protected.HandleFunc("/me/export/{id}", s.Handler.RequireSession(s.Handler.GetExport)).Methods("GET")
 
 	s.Router.HandleFunc("/api/register", s.Handler.RegisterUser).Methods("POST")
 	s.Router.HandleFunc("/api/login", s.Handler.LoginUser).Methods("POST")
-
-	s.Router.HandleFunc("/api/posts/", s.Handler.GetPosts).Methods("GET")
-
-	s.Router.HandleFunc("/api/post/{id}", s.Handler.GetPost).Methods("GET")
-
-	s.Router.HandleFunc("/api/posts/{category}", s.Handler.GetPostsByCategory).Methods("GET")
-
-	s.Router.HandleFunc("/api/user/{username}", s.Handler.GetUserPosts).Methods("GET")
+	s.Router.HandleFunc("/api/login/2fa", s.Handler.VerifyMFA).Methods("POST")
+	s.Router.HandleFunc("/api/password/forgot", s.Handler.ForgotPassword).Methods("POST")
+	s.Router.HandleFunc("/api/password/reset", s.Handler.ResetPassword).Methods("POST")
+	s.Router.HandleFunc("/api/email/verify", s.Handler.VerifyEmail).Methods("POST")
+	s.Router.HandleFunc("/api/login/email", s.Handler.EmailLogin).Methods("POST")
+	s.Router.HandleFunc("/api/login/email/verify", s.Handler.EmailLoginVerify).Methods("POST")
+	s.Router.HandleFunc("/api/account-deletions/{id}", s.Handler.GetDeletionJob).Methods("GET")
+	s.Router.HandleFunc("/api/exports/{id}/download", s.Handler.DownloadExport).Methods("GET")
+	s.Router.HandleFunc("/api/oauth/{provider}/login", s.Handler.OAuthLogin).Methods("GET")
+	s.Router.HandleFunc("/api/oauth/{provider}/callback", s.Handler.OAuthCallback).Methods("GET")
+	s.Router.HandleFunc("/api/token/refresh", s.Handler.RefreshToken).Methods("POST")
+	s.Router.HandleFunc("/.well-known/jwks.json", s.Handler.GetJWKS).Methods("GET")
+
+	s.Router.HandleFunc("/api/posts/", s.Handler.OptionalAuth(s.Handler.GetPosts)).Methods("GET")
+
+	s.Router.HandleFunc("/api/post/{id}", s.Handler.OptionalAuth(s.Handler.GetPost)).Methods("GET")
+	s.Router.HandleFunc("/api/post/{id}/revisions", s.Handler.GetPostRevisions).Methods("GET")
+	s.Router.HandleFunc("/api/post/{id}/revisions/diff", s.Handler.GetPostDiff).Methods("GET")
+	s.Router.HandleFunc("/api/post/{id}/comment/{commentID}/replies", s.Handler.OptionalAuth(s.Handler.GetCommentReplies)).Methods("GET")
+
+	s.Router.HandleFunc("/api/posts/{category}", s.Handler.OptionalAuth(s.Handler.GetPostsByCategory)).Methods("GET")
+
+	s.Router.HandleFunc("/api/user/{username}", s.Handler.OptionalAuth(s.Handler.GetUserPosts)).Methods("GET")
+	s.Router.HandleFunc("/api/user/{username}/profile", s.Handler.GetProfile).Methods("GET")
 
 }
 
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+// Archive is everything stored about one user.
+type Archive struct {
+	User     *UserRecord
+	Sessions []*models.SessionInfo
+	Posts    []*models.Post
+	Comments []*models.Comment
+	Votes    []*VoteRecord
+}
+
+// UserRecord has private fields which arent shown anywhere else.
+type UserRecord struct {
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"emailVerified"`
+	ExportedAt    time.Time `json:"exportedAt"`
+}
+
+// VoteRecord is user's vote on a post or on a comment of the post.
+type VoteRecord struct {
+	PostID    string `json:"postID"`
+	CommentID string `json:"commentID,omitempty"`
+	Vote      int8   `json:"vote"`
+}
+
+// Write writes zip with user.json and sessions.json, posts, comments and votes
+// are NDJSON (object per line), so big files can be read line by line.
+func Write(w io.Writer, archive *Archive) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeJSON(zw, "user.json", archive.User); err != nil {
+		return err
+	}
+	sessions := archive.Sessions
+	if sessions == nil {
+		sessions = []*models.SessionInfo{}
+	}
+	if err := writeJSON(zw, "sessions.json", sessions); err != nil {
+		return err
+	}
+
+	posts := make([]interface{}, 0, len(archive.Posts))
+	for _, p := range archive.Posts {
+		// votes of other users arent user's data
+		post := *p
+		post.Votes = nil
+		post.Comments = nil
+		posts = append(posts, &post)
+	}
+	if err := writeNDJSON(zw, "posts.ndjson", posts); err != nil {
+		return err
+	}
+
+	comments := make([]interface{}, 0, len(archive.Comments))
+	for _, c := range archive.Comments {
+		comments = append(comments, c)
+	}
+	if err := writeNDJSON(zw, "comments.ndjson", comments); err != nil {
+		return err
+	}
+
+	votes := make([]interface{}, 0, len(archive.Votes))
+	for _, v := range archive.Votes {
+		votes = append(votes, v)
+	}
+	if err := writeNDJSON(zw, "votes.ndjson", votes); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeNDJSON(zw *zip.Writer, name string, items []interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	// Encode adds newline after every value
+	enc := json.NewEncoder(f)
+	for _, item := range items {
+		if err = enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
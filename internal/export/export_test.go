@@ -0,0 +1,132 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/export"
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	mockUser = models.NewUser("testuser", "qwerty123")
+	mockPost = models.NewPost(mockUser, "music", "mock title", "text", "mock text", "")
+)
+
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = content
+	}
+	return files
+}
+
+func countLines(t *testing.T, data []byte) int {
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		assert.True(t, json.Valid(scanner.Bytes()), "line is not json: %s", scanner.Text())
+		n++
+	}
+	return n
+}
+
+func TestWrite(t *testing.T) {
+	post := *mockPost
+	post.Votes = []*models.Vote{models.NewVote(mockUser.ID, 1), models.NewVote("otheruser", -1)}
+
+	var buf bytes.Buffer
+	err := export.Write(&buf, &export.Archive{
+		User:     &export.UserRecord{ID: mockUser.ID, Username: mockUser.Username, Email: "test@example.com"},
+		Posts:    []*models.Post{&post, &post},
+		Comments: []*models.Comment{models.NewComment("comment", mockUser, post.ID)},
+		Votes:    []*export.VoteRecord{{PostID: post.ID, Vote: 1}},
+	})
+	require.NoError(t, err)
+
+	files := readZip(t, buf.Bytes())
+	assert.Len(t, files, 5)
+
+	var usr export.UserRecord
+	require.NoError(t, json.Unmarshal(files["user.json"], &usr))
+	assert.Equal(t, "test@example.com", usr.Email)
+	assert.JSONEq(t, `[]`, string(files["sessions.json"]))
+
+	assert.Equal(t, 2, countLines(t, files["posts.ndjson"]))
+	assert.Equal(t, 1, countLines(t, files["comments.ndjson"]))
+	assert.Equal(t, 1, countLines(t, files["votes.ndjson"]))
+
+	// other users' votes arent exported
+	assert.NotContains(t, string(files["posts.ndjson"]), "otheruser")
+	assert.Len(t, post.Votes, 2)
+}
+
+func TestSigner(t *testing.T) {
+	signer := export.NewSigner([]byte("secret"))
+	expires := time.Now().Add(time.Hour)
+
+	sig := signer.Sign("job1", expires)
+	assert.True(t, signer.Verify("job1", expires, sig))
+	assert.False(t, signer.Verify("job2", expires, sig))
+	assert.False(t, signer.Verify("job1", expires.Add(time.Second), sig))
+	assert.False(t, signer.Verify("job1", expires, "not base64!"))
+	assert.False(t, export.NewSigner([]byte("other")).Verify("job1", expires, sig))
+}
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "exports")
+	store, err := export.NewFileStore(dir)
+	require.NoError(t, err)
+
+	err = store.Save("job1", func(w io.Writer) error {
+		_, err := w.Write([]byte("archive"))
+		return err
+	})
+	require.NoError(t, err)
+
+	rc, err := store.Open("job1")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, "archive", string(data))
+
+	// failed archive leaves nothing behind
+	err = store.Save("job2", func(w io.Writer) error {
+		_, _ = w.Write([]byte("half"))
+		return errors.New("some error")
+	})
+	assert.Error(t, err)
+	_, err = store.Open("job2")
+	assert.ErrorIs(t, err, export.ErrArchiveDontExists)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = store.Open("../job1")
+	assert.ErrorIs(t, err, export.ErrArchiveDontExists)
+
+	require.NoError(t, store.Remove("job1"))
+	require.NoError(t, store.Remove("job1"))
+	_, err = store.Open("job1")
+	assert.ErrorIs(t, err, export.ErrArchiveDontExists)
+}
@@ -0,0 +1,41 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// Signer signs download links, so archive can be downloaded
+// without login session but only with the link given to its owner.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+func (s *Signer) mac(id string, expires time.Time) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(expires.Unix(), 10)))
+	return h.Sum(nil)
+}
+
+// Sign returns signature of archive id and link expiration time.
+func (s *Signer) Sign(id string, expires time.Time) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac(id, expires))
+}
+
+// Verify checks signature, expiration time is checked by caller.
+func (s *Signer) Verify(id string, expires time.Time, signature string) bool {
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, s.mac(id, expires))
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrArchiveDontExists = errors.New("archive dont exists")
+
+// Store keeps ready archives until they are downloaded or expire.
+type Store interface {
+	// Save writes archive with id, partially written archive is removed on error
+	Save(id string, write func(w io.Writer) error) error
+	Open(id string) (io.ReadCloser, error)
+	// Remove doesnt fail if archive doesnt exist
+	Remove(id string) error
+}
+
+// FileStore keeps archives as zip files in dir.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cant create export dir: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// path doesnt trust id, it must be plain file name
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id {
+		return "", ErrArchiveDontExists
+	}
+	return filepath.Join(s.dir, id+".zip"), nil
+}
+
+func (s *FileStore) Save(id string, write func(w io.Writer) error) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	// archive appears only when it is complete
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Open(id string) (io.ReadCloser, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrArchiveDontExists
+	}
+	return f, err
+}
+
+func (s *FileStore) Remove(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MemoryStore keeps archives in memory, for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	archives map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{archives: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Save(id string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.archives[id] = buf.Bytes()
+	return nil
+}
+
+func (s *MemoryStore) Open(id string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.archives[id]
+	if !ok {
+		return nil, ErrArchiveDontExists
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.archives, id)
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type CreateAccessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// 0 means token never expires
+	ExpiresInDays int `json:"expiresInDays,omitempty"`
+}
+
+func (h *Handler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var createRequest CreateAccessTokenRequest
+	err = json.NewDecoder(r.Body).Decode(&createRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	expiresIn := time.Duration(createRequest.ExpiresInDays) * 24 * time.Hour
+	accessToken, err := h.service.CreateAccessToken(ctx, usr.ID, createRequest.Name, createRequest.Scopes, expiresIn)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledToken, err := json.Marshal(accessToken)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal token", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusCreated, marshalledToken)
+}
+
+func (h *Handler) GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	tokens, err := h.service.GetAccessTokens(ctx, usr.ID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledTokens, err := json.Marshal(tokens)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal tokens", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledTokens)
+}
+
+func (h *Handler) DeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	tokenID := mux.Vars(r)["id"]
+	if tokenID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid tokenID", "tokenID is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.RevokeAccessToken(ctx, usr.ID, tokenID); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+// DeleteAccount deletes current user, ?mode=purge removes his posts
+// and comments instead of keeping them with "[deleted]" author.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	job, err := h.service.DeleteAccount(ctx, userID, r.URL.Query().Get("mode"))
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledJob, err := json.Marshal(job)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusAccepted, marshalledJob)
+}
+
+func (h *Handler) GetDeletionJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jobID := mux.Vars(r)["id"]
+	if jobID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid job id", "job id is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	job, err := h.service.GetDeletionJob(ctx, jobID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledJob, err := json.Marshal(job)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledJob)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type UnlockLoginRequest struct {
+	Username string `json:"username,omitempty"`
+	IP       string `json:"ip,omitempty"`
+}
+
+func (h *Handler) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	adminID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var unlockRequest UnlockLoginRequest
+	err = json.NewDecoder(r.Body).Decode(&unlockRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.UnlockLogin(ctx, adminID, unlockRequest.Username, unlockRequest.IP); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
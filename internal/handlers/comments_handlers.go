@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -41,7 +42,12 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	updatedPost, err := h.service.AddCommentToPost(ctx, postID, *newComment)
 	if err != nil {
-		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid args", "cant add comment to post: "+err.Error(), nil))
+		// policy errors already have status code
+		var scErr *errhandler.StatusCodedError
+		if !errors.As(err, &scErr) {
+			err = errhandler.New(http.StatusBadRequest, "invalid args", "cant add comment to post: "+err.Error(), nil)
+		}
+		h.jsonError(w, err)
 		return
 	}
 
@@ -54,17 +60,147 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	h.WriteToResponse(w, http.StatusCreated, marshalledPost)
 }
 
-func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ReplyComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	postID := mux.Vars(r)["id"]
+	commentID := mux.Vars(r)["commentID"]
+	if postID == "" || commentID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid args", "postID or commentID is empty", nil))
+		return
+	}
+
+	var addCommentRequest AddCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&addCommentRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid json", "invalid addCommentRequest: "+err.Error(), nil))
+		return
+	}
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	newComment := models.NewComment(addCommentRequest.Comment, usr, postID)
+
+	ctx := r.Context()
+	updatedPost, err := h.service.ReplyToComment(ctx, postID, commentID, *newComment)
+	if err != nil {
+		var scErr *errhandler.StatusCodedError
+		if !errors.As(err, &scErr) {
+			err = errhandler.New(http.StatusBadRequest, "invalid args", "cant reply to comment: "+err.Error(), nil)
+		}
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := updatedPost.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusCreated, marshalledPost)
+}
+
+// GetCommentReplies continues thread from moreReplies cursor of a comment.
+func (h *Handler) GetCommentReplies(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	viewerID, _ := h.extractUserIDFromRequestContext(r)
+
+	ctx := r.Context()
+	comment, err := h.service.GetCommentReplies(ctx, viewerID, mux.Vars(r)["id"], mux.Vars(r)["commentID"])
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
 
-	userIDCtx := r.Context().Value(UserIDCtxKeyValue)
-	if userIDCtx == nil {
-		h.jsonError(w, errhandler.New(http.StatusUnauthorized, "nil userID", "userID not found in context", nil))
+	data, err := json.Marshal(comment)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal error", err.Error(), err))
 		return
 	}
-	_, ok := userIDCtx.(string)
-	if !ok {
-		h.jsonError(w, errhandler.New(http.StatusUnauthorized, "invalid user ID type", "userID is not a string", nil))
+
+	h.WriteToResponse(w, http.StatusOK, data)
+}
+
+// EditComment changes body of user's own comment.
+func (h *Handler) EditComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	postID := mux.Vars(r)["postID"]
+	commentID := mux.Vars(r)["commentID"]
+	if postID == "" || commentID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid args", "postID or commentID is empty", nil))
+		return
+	}
+
+	var editRequest AddCommentRequest
+	if err = json.NewDecoder(r.Body).Decode(&editRequest); err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid json", "invalid edit comment request: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	updatedPost, err := h.service.EditComment(ctx, userID, postID, commentID, editRequest.Comment)
+	if err != nil {
+		var scErr *errhandler.StatusCodedError
+		if !errors.As(err, &scErr) {
+			err = errhandler.New(http.StatusBadRequest, "invalid args", "cant edit comment: "+err.Error(), nil)
+		}
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := updatedPost.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal error", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
+
+// GetCommentRevisions returns edit history of the comment.
+func (h *Handler) GetCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	revisions, err := h.service.GetCommentRevisions(ctx, userID, mux.Vars(r)["postID"], mux.Vars(r)["commentID"])
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal error", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, data)
+}
+
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
 		return
 	}
 
@@ -81,7 +217,33 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	updatedPost, err := h.service.RemoveComment(ctx, postID, commentID)
+	updatedPost, err := h.service.RemoveComment(ctx, userID, postID, commentID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := updatedPost.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal error", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
+
+// RestoreComment brings back deleted comment.
+func (h *Handler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	updatedPost, err := h.service.RestoreComment(ctx, userID, mux.Vars(r)["postID"], mux.Vars(r)["commentID"])
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var changeRequest ChangeEmailRequest
+	err = json.NewDecoder(r.Body).Decode(&changeRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.ChangeEmail(ctx, userID, changeRequest.Email); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "verification link was sent"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+type EmailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var verifyRequest EmailTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&verifyRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.VerifyEmail(ctx, verifyRequest.Token); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+type EmailLoginRequest struct {
+	Email string `json:"email"`
+}
+
+func (h *Handler) EmailLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var loginRequest EmailLoginRequest
+	err := json.NewDecoder(r.Body).Decode(&loginRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.StartEmailLogin(ctx, loginRequest.Email, h.clientInfoFromRequest(r, "")); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	// same answer for unknown emails
+	ans := map[string]string{"message": "if the email is registered and verified, login link was sent"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+func (h *Handler) EmailLoginVerify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var verifyRequest EmailTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&verifyRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	session, mfaPending, err := h.service.FinishEmailLogin(ctx, verifyRequest.Token, h.clientInfoFromRequest(r, ""))
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var answer interface{} = session
+	if mfaPending != nil {
+		answer = mfaPending
+	}
+
+	marshalledAns, err := json.Marshal(answer)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
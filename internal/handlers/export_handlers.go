@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+func (h *Handler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	job, err := h.service.RequestExport(ctx, userID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledJob, err := json.Marshal(job)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusAccepted, marshalledJob)
+}
+
+func (h *Handler) GetExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	jobID := mux.Vars(r)["id"]
+	if jobID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid export id", "export id is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	job, err := h.service.GetExport(ctx, userID, jobID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledJob, err := json.Marshal(job)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledJob)
+}
+
+// DownloadExport serves archive by signed link, no login is needed.
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	ctx := r.Context()
+	archive, err := h.service.DownloadExport(ctx, jobID, query.Get("expires"), query.Get("sig"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		h.jsonError(w, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="redditclone-export-`+jobID+`.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, archive); err != nil {
+		// archive is kept, so download can be started again
+		h.logger.Errorw("cant send export",
+			"job_id", jobID,
+			"err", err,
+		)
+		return
+	}
+
+	if err = h.service.CompleteExportDownload(ctx, jobID); err != nil {
+		h.logger.Errorw("cant complete export download",
+			"job_id", jobID,
+			"err", err,
+		)
+	}
+}
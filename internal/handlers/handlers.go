@@ -3,12 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/myacey/redditclone/internal/customerror/errhandler"
 	"github.com/myacey/redditclone/internal/models"
 	"github.com/myacey/redditclone/internal/service"
 	"github.com/myacey/redditclone/internal/token"
+	"github.com/myacey/redditclone/internal/validation"
 	"go.uber.org/zap"
 )
 
@@ -16,16 +22,45 @@ type Handler struct {
 	service    service.ServiceInterface
 	logger     *zap.SugaredLogger
 	tokenMaker token.TokenMaker
+	// X-Forwarded-For is read only from these proxies
+	trustedProxies []*net.IPNet
 }
 
-func NewHandler(s service.ServiceInterface, l *zap.SugaredLogger, tm token.TokenMaker) *Handler {
+func NewHandler(s service.ServiceInterface, l *zap.SugaredLogger, tm token.TokenMaker, trustedProxies []*net.IPNet) *Handler {
 	return &Handler{
-		service:    s,
-		logger:     l,
-		tokenMaker: tm,
+		service:        s,
+		logger:         l,
+		tokenMaker:     tm,
+		trustedProxies: trustedProxies,
 	}
 }
 
+// ParseTrustedProxies parses comma separated list of proxy CIDRs,
+// single IP is allowed too.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy ip: %s", item)
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy cidr: %s", item)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
 func (h *Handler) jsonError(w http.ResponseWriter, err error) {
 	statusCode := errhandler.GetStatusCode(err)
 
@@ -44,11 +79,24 @@ func (h *Handler) jsonError(w http.ResponseWriter, err error) {
 		message = err.Error()
 	}
 
+	// client should wait before the next attempt
+	var tooManyErr *service.TooManyAttemptsError
+	if errors.As(err, &tooManyErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooManyErr.RetryAfter.Seconds()))))
+	}
+
 	// log about internal errors
 
-	resp, err := json.Marshal(map[string]interface{}{
+	body := map[string]interface{}{
 		"message": message,
-	})
+	}
+	// invalid fields of the request
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		body["errors"] = validationErr.Fields
+	}
+
+	resp, err := json.Marshal(body)
 	if err != nil {
 		h.logger.Errorw("cant marshal error to json",
 			"err", err.Error(),
@@ -95,3 +143,82 @@ func (h *Handler) extractUserFromRequestContext(r *http.Request) (*models.User,
 
 	return usr, nil
 }
+
+// clientInfoFromRequest collects info about user's device for session list.
+// If device label wasnt passed, user agent is used instead.
+func (h *Handler) clientInfoFromRequest(r *http.Request, device string) *models.ClientInfo {
+	ip := h.clientIP(r)
+
+	userAgent := r.UserAgent()
+	if device == "" {
+		device = userAgent
+	}
+
+	return &models.ClientInfo{
+		Device:    device,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+}
+
+// clientIP is address of the connection. Behind trusted proxies it is the
+// right-most X-Forwarded-For hop which isnt trusted proxy, entries left of
+// it are set by client and can be anything.
+func (h *Handler) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !h.isTrustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !h.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (h *Handler) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range h.trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractUserIDFromRequestContext is for handlers which dont need whole user from db.
+func (h *Handler) extractUserIDFromRequestContext(r *http.Request) (string, error) {
+	userIDCtx := r.Context().Value(UserIDCtxKeyValue)
+	if userIDCtx == nil {
+		return "", errhandler.New(http.StatusUnauthorized, "nil userID", "userID not found in context", nil)
+	}
+	userID, ok := userIDCtx.(string)
+	if !ok {
+		return "", errhandler.New(http.StatusUnauthorized, "invalid user ID type", "userID is not a string", nil)
+	}
+
+	return userID, nil
+}
+
+func (h *Handler) extractSessionIDFromRequestContext(r *http.Request) (string, error) {
+	sessionID, ok := r.Context().Value(SessionIDCtxKeyValue).(string)
+	if !ok || sessionID == "" {
+		return "", errhandler.New(http.StatusUnauthorized, "invalid session", "sessionID not found in context", nil)
+	}
+
+	return sessionID, nil
+}
@@ -17,9 +17,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
 	"github.com/myacey/redditclone/internal/handlers"
 	"github.com/myacey/redditclone/internal/mocks"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/service"
+	"github.com/myacey/redditclone/internal/validation"
 )
 
 var ErrBasic = errors.New("some error")
@@ -40,7 +43,7 @@ func TestRegisterUser(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	testCases := []struct {
 		name           string
@@ -56,7 +59,7 @@ func TestRegisterUser(t *testing.T) {
 				Password: "qwerty123",
 			},
 			mockSetup: func() {
-				mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any()).Return(mockSession, nil)
+				mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockSession, nil)
 			},
 			expectedStatus: http.StatusCreated,
 			expectedBody:   `{"token":"token"}`,
@@ -65,7 +68,7 @@ func TestRegisterUser(t *testing.T) {
 			name:    "Invalid JSON",
 			reqBody: "invalid",
 			mockSetup: func() {
-				// mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any()).Return(mockSession, nil)
+				// mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockSession, nil)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"bad json"}`,
@@ -77,11 +80,28 @@ func TestRegisterUser(t *testing.T) {
 				Password: "qwerty123",
 			},
 			mockSetup: func() {
-				mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
 		},
+		{
+			name: "Validation error",
+			reqBody: handlers.RegisterRequest{
+				Username: "ab",
+				Password: "qwerty123",
+			},
+			mockSetup: func() {
+				mockService.EXPECT().CreateNewUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, validation.NewError(
+					validation.FieldError{Field: "username", Code: validation.CodeTooShort, Message: "username must be at least 3 characters"},
+					validation.FieldError{Field: "password", Code: validation.CodeCommonPassword, Message: "password is too common"},
+				))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"message":"validation failed","errors":[` +
+				`{"field":"username","code":"too_short","message":"username must be at least 3 characters"},` +
+				`{"field":"password","code":"common_password","message":"password is too common"}]}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -121,7 +141,7 @@ func TestLoginRequest(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	testCases := []struct {
 		name           string
@@ -129,6 +149,7 @@ func TestLoginRequest(t *testing.T) {
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
+		expRetryAfter  string
 	}{
 		{
 			name: "Successful register",
@@ -137,16 +158,28 @@ func TestLoginRequest(t *testing.T) {
 				Password: "qwerty123",
 			},
 			mockSetup: func() {
-				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(mockSession, nil)
+				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockSession, nil, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"token":"token"}`,
 		},
+		{
+			name: "2FA required",
+			reqBody: handlers.LoginRequest{
+				Username: "testuser",
+				Password: "qwerty123",
+			},
+			mockSetup: func() {
+				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, models.NewMFAPending("mfatoken"), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"mfaRequired":true,"mfaToken":"mfatoken"}`,
+		},
 		{
 			name:    "Invalid JSON",
 			reqBody: "invalid",
 			mockSetup: func() {
-				// mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(mockSession, nil)
+				// mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockSession, nil)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"bad json"}`,
@@ -158,11 +191,27 @@ func TestLoginRequest(t *testing.T) {
 				Password: "qwerty123",
 			},
 			mockSetup: func() {
-				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
 		},
+		{
+			name: "Locked out",
+			reqBody: handlers.LoginRequest{
+				Username: "testuser",
+				Password: "qwerty123",
+			},
+			mockSetup: func() {
+				mockService.EXPECT().LoginUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, &service.TooManyAttemptsError{
+					StatusCodedError: &errhandler.StatusCodedError{StatusCode: http.StatusTooManyRequests, UserAnswer: "too many attempts"},
+					RetryAfter:       89500 * time.Millisecond,
+				})
+			},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   `{"message":"too many attempts"}`,
+			expRetryAfter:  "90",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -181,6 +230,7 @@ func TestLoginRequest(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.expRetryAfter, resp.Header.Get("Retry-After"))
 
 			var respBody map[string]interface{}
 			err = json.NewDecoder(resp.Body).Decode(&respBody)
@@ -194,6 +244,72 @@ func TestLoginRequest(t *testing.T) {
 	}
 }
 
+func TestLoginClientIP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockServiceInterface(ctrl)
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	proxies, err := handlers.ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	assert.NoError(t, err)
+	handler := handlers.NewHandler(mockService, zap.NewNop().Sugar(), mockTokenMaker, proxies)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expIP      string
+	}{
+		{
+			name:       "Spoofed header from client",
+			remoteAddr: "203.0.113.7:4321",
+			forwarded:  "1.2.3.4",
+			expIP:      "203.0.113.7",
+		},
+		{
+			name:       "Behind trusted proxy",
+			remoteAddr: "10.0.0.5:4321",
+			forwarded:  "198.51.100.9",
+			expIP:      "198.51.100.9",
+		},
+		{
+			name:       "Spoofed hop before trusted proxies",
+			remoteAddr: "10.0.0.5:4321",
+			forwarded:  "1.2.3.4, 198.51.100.9, 192.168.1.1",
+			expIP:      "198.51.100.9",
+		},
+		{
+			name:       "Invalid hop",
+			remoteAddr: "10.0.0.5:4321",
+			forwarded:  "198.51.100.9, garbage",
+			expIP:      "10.0.0.5",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.EXPECT().LoginUser(gomock.Any(), "testuser", "qwerty123", gomock.Any()).DoAndReturn(
+				func(_ context.Context, _, _ string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error) {
+					assert.Equal(t, tc.expIP, client.IP)
+					return mockSession, nil, nil
+				})
+
+			var reqBody bytes.Buffer
+			err := json.NewEncoder(&reqBody).Encode(handlers.LoginRequest{Username: "testuser", Password: "qwerty123"})
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/login", &reqBody)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+			w := httptest.NewRecorder()
+			handler.LoginUser(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
 func TestGetPosts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -202,13 +318,14 @@ func TestGetPosts(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	allPosts, err := json.Marshal(mockPosts)
 	assert.NoError(t, err)
 
 	testCases := []struct {
 		name           string
+		authHeader     string
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
@@ -216,7 +333,28 @@ func TestGetPosts(t *testing.T) {
 		{
 			name: "Successful get posts",
 			mockSetup: func() {
-				mockService.EXPECT().GetAllPosts(gomock.Any()).Return(mockPosts, nil)
+				mockService.EXPECT().GetAllPosts(gomock.Any(), "").Return(mockPosts, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(allPosts),
+		},
+		{
+			name:       "Logged in viewer",
+			authHeader: "Bearer " + mockToken,
+			mockSetup: func() {
+				mockTokenMaker.EXPECT().ExtractUserID(mockToken).Return(mockUser.ID, nil)
+				mockService.EXPECT().CheckUserSession(gomock.Any(), mockUser.ID, mockToken).Return(models.NewSessionInfo(mockUser.ID, "hash", nil), nil)
+				mockService.EXPECT().GetAllPosts(gomock.Any(), mockUser.ID).Return(mockPosts, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(allPosts),
+		},
+		{
+			name:       "Invalid token is anonymous",
+			authHeader: "Bearer " + mockToken,
+			mockSetup: func() {
+				mockTokenMaker.EXPECT().ExtractUserID(mockToken).Return("", ErrBasic)
+				mockService.EXPECT().GetAllPosts(gomock.Any(), "").Return(mockPosts, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   string(allPosts),
@@ -224,7 +362,7 @@ func TestGetPosts(t *testing.T) {
 		{
 			name: "Invalid Service",
 			mockSetup: func() {
-				mockService.EXPECT().GetAllPosts(gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().GetAllPosts(gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
@@ -236,8 +374,11 @@ func TestGetPosts(t *testing.T) {
 			tc.mockSetup()
 
 			req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
 			w := httptest.NewRecorder()
-			handler.GetPosts(w, req)
+			handler.OptionalAuth(handler.GetPosts)(w, req)
 
 			resp := w.Result()
 			defer resp.Body.Close()
@@ -259,7 +400,7 @@ func TestAddPosts(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	expPost, err := json.Marshal(mockPost)
 	assert.NoError(t, err)
@@ -317,6 +458,24 @@ func TestAddPosts(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"failed to add post"}`,
 		},
+		{
+			name:  "Email not verified",
+			token: mockToken,
+			reqBody: handlers.AddPostRequest{
+				Category: mockPost.Category,
+				Title:    mockPost.Title,
+				Type:     mockPost.Type,
+				Text:     mockPost.Text,
+				URL:      mockPost.URL,
+			},
+			mockSetup: func() {
+				mockService.EXPECT().AddPost(gomock.Any(), gomock.Any()).
+					Return(errhandler.New(http.StatusForbidden, "email not verified", "", nil))
+				mockService.EXPECT().GetUserFromDBByID(gomock.Any(), gomock.Any()).Return(mockUser, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"email not verified"}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -376,7 +535,7 @@ func TestGetSinglePost(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	marshalledPost, err := json.Marshal(mockPost)
 	assert.NoError(t, err)
@@ -390,7 +549,7 @@ func TestGetSinglePost(t *testing.T) {
 		{
 			name: "Successful get post",
 			mockSetup: func() {
-				mockService.EXPECT().GetPostByID(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPost, nil)
+				mockService.EXPECT().GetPostByID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPost, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   string(marshalledPost),
@@ -398,7 +557,7 @@ func TestGetSinglePost(t *testing.T) {
 		{
 			name: "Service err",
 			mockSetup: func() {
-				mockService.EXPECT().GetPostByID(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().GetPostByID(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
@@ -434,7 +593,7 @@ func TestGetPostsByCategory(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	allPosts, err := json.Marshal(mockPosts)
 	assert.NoError(t, err)
@@ -448,7 +607,7 @@ func TestGetPostsByCategory(t *testing.T) {
 		{
 			name: "Successful get posts",
 			mockSetup: func() {
-				mockService.EXPECT().GetPostsByCategory(gomock.Any(), gomock.Any()).Return(mockPosts, nil)
+				mockService.EXPECT().GetPostsByCategory(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPosts, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   string(allPosts),
@@ -456,7 +615,7 @@ func TestGetPostsByCategory(t *testing.T) {
 		{
 			name: "Service error",
 			mockSetup: func() {
-				mockService.EXPECT().GetPostsByCategory(gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().GetPostsByCategory(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
@@ -491,30 +650,50 @@ func TestDeletePost(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	testCases := []struct {
 		name           string
+		userID         interface{}
 		mockSetup      func()
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
-			name: "Successful delete",
+			name:   "Successful delete",
+			userID: mockUser.ID,
 			mockSetup: func() {
-				mockService.EXPECT().DeletePostWithID(gomock.Any(), gomock.Any()).Return(nil)
+				mockService.EXPECT().DeletePostWithID(gomock.Any(), mockUser.ID, gomock.Any()).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"message":"success"}`,
 		},
 		{
-			name: "Service error",
+			name:   "Service error",
+			userID: mockUser.ID,
 			mockSetup: func() {
-				mockService.EXPECT().DeletePostWithID(gomock.Any(), gomock.Any()).Return(ErrBasic)
+				mockService.EXPECT().DeletePostWithID(gomock.Any(), mockUser.ID, gomock.Any()).Return(ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
 		},
+		{
+			name:   "Forbidden",
+			userID: mockUser.ID,
+			mockSetup: func() {
+				mockService.EXPECT().DeletePostWithID(gomock.Any(), mockUser.ID, gomock.Any()).
+					Return(errhandler.New(http.StatusForbidden, "forbidden", "not an author", nil))
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"forbidden"}`,
+		},
+		{
+			name:           "No user in context",
+			userID:         nil,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"nil userID"}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -522,6 +701,9 @@ func TestDeletePost(t *testing.T) {
 			tc.mockSetup()
 
 			req := httptest.NewRequest(http.MethodDelete, "/api/post/0", nil)
+			if tc.userID != nil {
+				req = req.WithContext(context.WithValue(req.Context(), handlers.UserIDCtxKeyValue, tc.userID))
+			}
 			w := httptest.NewRecorder()
 			handler.DeletePost(w, req)
 
@@ -545,7 +727,7 @@ func TestGetUserPosts(t *testing.T) {
 	mockLogger := zap.NewNop().Sugar()
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 
-	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker)
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
 
 	allPosts, err := json.Marshal(mockPosts)
 	assert.NoError(t, err)
@@ -561,7 +743,7 @@ func TestGetUserPosts(t *testing.T) {
 			name:             "Successful get posts",
 			usernameToSearch: mockUser.Username,
 			mockSetup: func() {
-				mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any()).Return(mockPosts, nil)
+				mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPosts, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   string(allPosts),
@@ -570,7 +752,7 @@ func TestGetUserPosts(t *testing.T) {
 			name:             "Invalid username",
 			usernameToSearch: "",
 			mockSetup: func() {
-				// mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any()).Return(mockPosts, nil)
+				// mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPosts, nil)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"invalid username"}`,
@@ -579,7 +761,7 @@ func TestGetUserPosts(t *testing.T) {
 			name:             "Service error",
 			usernameToSearch: mockUser.Username,
 			mockSetup: func() {
-				mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
+				mockService.EXPECT().GetPostsByAuthor(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, ErrBasic)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"message":"some error"}`,
@@ -608,3 +790,306 @@ func TestGetUserPosts(t *testing.T) {
 		})
 	}
 }
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockServiceInterface(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
+
+	testCases := []struct {
+		name           string
+		sessionID      interface{}
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:      "Success",
+			sessionID: "sessionid",
+			mockSetup: func() {
+				mockService.EXPECT().GetUserFromDBByID(gomock.Any(), gomock.Any()).Return(mockUser, nil)
+				mockService.EXPECT().RevokeSession(gomock.Any(), mockUser.ID, "sessionid").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"success"}`,
+		},
+		{
+			name:      "No session in context",
+			sessionID: nil,
+			mockSetup: func() {
+				mockService.EXPECT().GetUserFromDBByID(gomock.Any(), gomock.Any()).Return(mockUser, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"invalid session"}`,
+		},
+		{
+			name:      "Service error",
+			sessionID: "sessionid",
+			mockSetup: func() {
+				mockService.EXPECT().GetUserFromDBByID(gomock.Any(), gomock.Any()).Return(mockUser, nil)
+				mockService.EXPECT().RevokeSession(gomock.Any(), mockUser.ID, "sessionid").Return(ErrBasic)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"some error"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			ctx := context.Background()
+			ctx = context.WithValue(ctx, handlers.UserIDCtxKeyValue, mockUser.ID)
+			if tc.sessionID != nil {
+				ctx = context.WithValue(ctx, handlers.SessionIDCtxKeyValue, tc.sessionID)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/logout", nil).WithContext(ctx)
+
+			w := httptest.NewRecorder()
+			handler.Logout(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestAccessTokenScopes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockServiceInterface(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
+
+	plainToken := models.AccessTokenPrefix + "token"
+	writeToken := models.NewAccessToken(mockUser.ID, "bot", "hash", []string{models.ScopePostsWrite}, nil)
+	readToken := models.NewAccessToken(mockUser.ID, "bot", "hash", []string{models.ScopeRead}, nil)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, mockUser.ID, r.Context().Value(handlers.UserIDCtxKeyValue))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"message":"success"}`))
+	}
+
+	testCases := []struct {
+		name           string
+		authHeader     string
+		route          http.HandlerFunc
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:       "Access token with scope",
+			authHeader: "Bearer " + plainToken,
+			route:      handler.RequireScope(models.ScopePostsWrite, ok),
+			mockSetup: func() {
+				mockService.EXPECT().CheckAccessToken(gomock.Any(), plainToken).Return(writeToken, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"success"}`,
+		},
+		{
+			name:       "Access token without scope",
+			authHeader: "Bearer " + plainToken,
+			route:      handler.RequireScope(models.ScopePostsWrite, ok),
+			mockSetup: func() {
+				mockService.EXPECT().CheckAccessToken(gomock.Any(), plainToken).Return(readToken, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"insufficient scope"}`,
+		},
+		{
+			name:       "Access token on session route",
+			authHeader: "Bearer " + plainToken,
+			route:      handler.RequireSession(ok),
+			mockSetup: func() {
+				mockService.EXPECT().CheckAccessToken(gomock.Any(), plainToken).Return(writeToken, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"personal access token not allowed"}`,
+		},
+		{
+			name:       "Invalid access token",
+			authHeader: "Bearer " + plainToken,
+			route:      handler.RequireScope(models.ScopePostsWrite, ok),
+			mockSetup: func() {
+				mockService.EXPECT().CheckAccessToken(gomock.Any(), plainToken).Return(nil, ErrBasic)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"some error"}`,
+		},
+		{
+			name:       "Session token has all scopes",
+			authHeader: "Bearer " + mockToken,
+			route:      handler.RequireScope(models.ScopePostsWrite, ok),
+			mockSetup: func() {
+				mockTokenMaker.EXPECT().ExtractUserID(mockToken).Return(mockUser.ID, nil)
+				mockService.EXPECT().CheckUserSession(gomock.Any(), mockUser.ID, mockToken).Return(models.NewSessionInfo(mockUser.ID, "hash", nil), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"success"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+			req.Header.Set("Authorization", tc.authHeader)
+
+			w := httptest.NewRecorder()
+			handler.AuthMiddleware(tc.route, mockLogger).ServeHTTP(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockServiceInterface(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
+
+	testCases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		reqBody        string
+		mockSetup      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:    "Forgot",
+			handler: handler.ForgotPassword,
+			reqBody: `{"email":"test@example.com"}`,
+			mockSetup: func() {
+				mockService.EXPECT().ForgotPassword(gomock.Any(), "test@example.com", gomock.Any()).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"if the email is registered, reset link was sent"}`,
+		},
+		{
+			name:           "Forgot bad json",
+			handler:        handler.ForgotPassword,
+			reqBody:        `{`,
+			mockSetup:      func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"bad json"}`,
+		},
+		{
+			name:    "Reset",
+			handler: handler.ResetPassword,
+			reqBody: `{"token":"resettoken","password":"newpassword"}`,
+			mockSetup: func() {
+				mockService.EXPECT().ResetPassword(gomock.Any(), "resettoken", "newpassword").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"message":"success"}`,
+		},
+		{
+			name:    "Reset invalid token",
+			handler: handler.ResetPassword,
+			reqBody: `{"token":"resettoken","password":"newpassword"}`,
+			mockSetup: func() {
+				mockService.EXPECT().ResetPassword(gomock.Any(), "resettoken", "newpassword").
+					Return(errhandler.New(http.StatusBadRequest, "invalid or expired token", "not found", nil))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"invalid or expired token"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/password", strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+			tc.handler(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+// brokenWriter loses connection on the first write of body
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func (w brokenWriter) WriteString(string) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestDownloadExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockServiceInterface(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	handler := handlers.NewHandler(mockService, mockLogger, mockTokenMaker, nil)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/exports/job1/download?expires=1&sig=sig", nil)
+		return mux.SetURLVars(req, map[string]string{"id": "job1"})
+	}
+
+	t.Run("Completed", func(t *testing.T) {
+		mockService.EXPECT().DownloadExport(gomock.Any(), "job1", "1", "sig").Return(io.NopCloser(strings.NewReader("zip")), nil)
+		mockService.EXPECT().CompleteExportDownload(gomock.Any(), "job1").Return(nil)
+
+		w := httptest.NewRecorder()
+		handler.DownloadExport(w, newRequest())
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "zip", w.Body.String())
+	})
+
+	t.Run("Interrupted", func(t *testing.T) {
+		// export isnt completed, so link works again
+		mockService.EXPECT().DownloadExport(gomock.Any(), "job1", "1", "sig").Return(io.NopCloser(strings.NewReader("zip")), nil)
+
+		handler.DownloadExport(brokenWriter{httptest.NewRecorder()}, newRequest())
+	})
+}
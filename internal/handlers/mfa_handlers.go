@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfaToken"`
+	// code from authenticator app or recovery code
+	Code string `json:"code"`
+}
+
+// EnrollTOTP returns otpauth URI and recovery codes.
+// 2FA starts working only after ConfirmTOTP.
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	enrollment, err := h.service.EnrollTOTP(ctx, usr.ID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledEnrollment, err := json.Marshal(enrollment)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledEnrollment)
+}
+
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var confirmRequest ConfirmTOTPRequest
+	err = json.NewDecoder(r.Body).Decode(&confirmRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.ConfirmTOTP(ctx, usr.ID, confirmRequest.Code); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+// VerifyMFA is the second login step for users with 2FA.
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var verifyRequest VerifyMFARequest
+	err := json.NewDecoder(r.Body).Decode(&verifyRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+	if verifyRequest.MFAToken == "" || verifyRequest.Code == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "mfa token and code are required", "mfa token or code is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	session, err := h.service.VerifyMFA(ctx, verifyRequest.MFAToken, verifyRequest.Code)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledSession, err := session.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal session", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledSession)
+}
@@ -3,13 +3,20 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
 	"go.uber.org/zap"
 )
 
-var UserIDCtxKeyValue = "userID"
+var (
+	UserIDCtxKeyValue    = "userID"
+	SessionIDCtxKeyValue = "sessionID"
+	// set only for requests made with personal access token
+	AccessTokenScopesCtxKeyValue = "accessTokenScopes"
+)
 
 func (h *Handler) JSONMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -20,42 +27,113 @@ func (h *Handler) JSONMiddleware(next http.Handler) http.Handler {
 
 func (h *Handler) AuthMiddleware(next http.Handler, logger *zap.SugaredLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := strings.Fields(r.Header.Get("authorization"))
 		logger.Infow("check auth token",
 			"method", r.Method,
 			"url", r.URL.String(),
 			"remote_addr", r.RemoteAddr,
 		)
 
-		// token should be "Bearer {token}", so check len
-		if len(authHeader) != 2 {
-			h.jsonError(w, errhandler.New(http.StatusUnauthorized, "invalid token length", "authHeader length is not 2", nil))
+		ctx, err := h.authenticate(r)
+		if err != nil {
+			h.jsonError(w, err)
 			return
 		}
 
-		logger.Infow("token",
-			"authHeader", authHeader,
-		)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		userID, err := h.tokenMaker.ExtractUserID(authHeader[1])
+// OptionalAuth lets public routes know who requested them. Request without
+// token or with invalid one is served as anonymous.
+func (h *Handler) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") == "" {
+			next(w, r)
+			return
+		}
+
+		ctx, err := h.authenticate(r)
 		if err != nil {
-			h.jsonError(w, errhandler.New(http.StatusUnauthorized, "token verification failed", err.Error(), err))
+			h.logger.Infow("serving as anonymous",
+				"url", r.URL.String(),
+				"err", err,
+			)
+			next(w, r)
 			return
 		}
-		h.logger.Infow("extracted token info",
-			"userID", userID,
-		)
 
-		if err = h.service.CheckUserSession(context.Background(), userID, authHeader[1]); err != nil {
-			h.jsonError(w, err)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// authenticate checks session or personal access token of the request
+// and returns context with user of the token.
+func (h *Handler) authenticate(r *http.Request) (context.Context, error) {
+	authHeader := strings.Fields(r.Header.Get("authorization"))
+
+	// token should be "Bearer {token}", so check len
+	if len(authHeader) != 2 {
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid token length", "authHeader length is not 2", nil)
+	}
+
+	if strings.HasPrefix(authHeader[1], models.AccessTokenPrefix) {
+		accessToken, err := h.service.CheckAccessToken(r.Context(), authHeader[1])
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := context.WithValue(r.Context(), UserIDCtxKeyValue, accessToken.UserID)
+		ctx = context.WithValue(ctx, AccessTokenScopesCtxKeyValue, []string(accessToken.Scopes))
+		return ctx, nil
+	}
+
+	h.logger.Infow("token",
+		"authHeader", authHeader,
+	)
+
+	userID, err := h.tokenMaker.ExtractUserID(authHeader[1])
+	if err != nil {
+		return nil, errhandler.New(http.StatusUnauthorized, "token verification failed", err.Error(), err)
+	}
+	h.logger.Infow("extracted token info",
+		"userID", userID,
+	)
+
+	session, err := h.service.CheckUserSession(context.Background(), userID, authHeader[1])
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(r.Context(), UserIDCtxKeyValue, userID)
+	ctx = context.WithValue(ctx, SessionIDCtxKeyValue, session.ID)
+	return ctx, nil
+}
+
+// RequireScope allows personal access tokens only with given scope.
+// Session tokens have all scopes.
+func (h *Handler) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := r.Context().Value(AccessTokenScopesCtxKeyValue).([]string)
+		if ok && !slices.Contains(scopes, scope) {
+			h.jsonError(w, errhandler.New(http.StatusForbidden, "insufficient scope", "access token doesnt have scope "+scope, nil))
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), UserIDCtxKeyValue, userID)
-		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireSession doesnt allow personal access tokens at all,
+// e.g. token can't be used to create more tokens.
+func (h *Handler) RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(AccessTokenScopesCtxKeyValue).([]string); ok {
+			h.jsonError(w, errhandler.New(http.StatusForbidden, "personal access token not allowed", "route requires session token", nil))
+			return
+		}
 
 		next.ServeHTTP(w, r)
-	})
+	}
 }
 
 func (h *Handler) LoggingMiddleware(next http.Handler, logger *zap.SugaredLogger) http.Handler {
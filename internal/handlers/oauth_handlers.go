@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+// OAuthLogin redirects user to external provider's login page.
+func (h *Handler) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+
+	ctx := r.Context()
+	authURL, err := h.service.StartOAuthLogin(ctx, provider, "", h.clientInfoFromRequest(r, r.URL.Query().Get("device")))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		h.jsonError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OAuthLink gives provider's URL for linking external account to the current user.
+// It cant be redirect, because browser wont send Authorization header.
+func (h *Handler) OAuthLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+
+	ctx := r.Context()
+	authURL, err := h.service.StartOAuthLogin(ctx, provider, usr.ID, nil)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"url": authURL}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+// OAuthCallback is where provider redirects user back with authorization code.
+func (h *Handler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	provider := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		h.jsonError(w, errhandler.New(http.StatusUnauthorized, "external login failed", "provider returned error: "+providerErr, nil))
+		return
+	}
+	if query.Get("code") == "" || query.Get("state") == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "code and state are required", "code or state is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	session, mfaPending, err := h.service.FinishOAuthLogin(ctx, provider, query.Get("state"), query.Get("code"))
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var answer interface{}
+	switch {
+	case session != nil:
+		answer = session
+	case mfaPending != nil:
+		answer = mfaPending
+	default:
+		// linking flow
+		answer = map[string]string{"message": "success"}
+	}
+
+	marshalledAns, err := json.Marshal(answer)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+func (h *Handler) GetExternalIdentities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	identities, err := h.service.GetExternalIdentities(ctx, usr.ID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledIdentities, err := json.Marshal(identities)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal identities", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledIdentities)
+}
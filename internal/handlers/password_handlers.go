@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var forgotRequest ForgotPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&forgotRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.ForgotPassword(ctx, forgotRequest.Email, h.clientInfoFromRequest(r, "")); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	// same answer for unknown emails
+	ans := map[string]string{"message": "if the email is registered, reset link was sent"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var resetRequest ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&resetRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.ResetPassword(ctx, resetRequest.Token, resetRequest.Password); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
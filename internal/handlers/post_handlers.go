@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/myacey/redditclone/internal/customerror/errhandler"
@@ -11,8 +13,11 @@ import (
 
 func (h *Handler) GetPosts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	// anonymous viewer just doesnt see own votes
+	viewerID, _ := h.extractUserIDFromRequestContext(r)
+
 	ctx := r.Context()
-	posts, err := h.service.GetAllPosts(ctx)
+	posts, err := h.service.GetAllPosts(ctx, viewerID)
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -64,7 +69,12 @@ func (h *Handler) AddPost(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	err = h.service.AddPost(ctx, post)
 	if err != nil {
-		h.jsonError(w, errhandler.New(http.StatusBadRequest, "failed to add post", err.Error(), nil))
+		// policy errors already have status code
+		var scErr *errhandler.StatusCodedError
+		if !errors.As(err, &scErr) {
+			err = errhandler.New(http.StatusBadRequest, "failed to add post", err.Error(), nil)
+		}
+		h.jsonError(w, err)
 		return
 	}
 
@@ -81,9 +91,10 @@ func (h *Handler) GetPost(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	postID := mux.Vars(r)["id"]
+	viewerID, _ := h.extractUserIDFromRequestContext(r)
 
 	ctx := r.Context()
-	post, err := h.service.GetPostByID(ctx, postID, true)
+	post, err := h.service.GetPostByID(ctx, viewerID, postID, true)
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -102,9 +113,10 @@ func (h *Handler) GetPostsByCategory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	category := mux.Vars(r)["category"]
+	viewerID, _ := h.extractUserIDFromRequestContext(r)
 
 	ctx := r.Context()
-	posts, err := h.service.GetPostsByCategory(ctx, category)
+	posts, err := h.service.GetPostsByCategory(ctx, viewerID, category)
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -122,9 +134,15 @@ func (h *Handler) GetPostsByCategory(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
 	postID := mux.Vars(r)["id"]
 	ctx := r.Context()
-	err := h.service.DeletePostWithID(ctx, postID)
+	err = h.service.DeletePostWithID(ctx, userID, postID)
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -140,6 +158,118 @@ func (h *Handler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	h.WriteToResponse(w, http.StatusOK, marshalledAns)
 }
 
+// RestorePost brings back deleted post.
+func (h *Handler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	post, err := h.service.RestorePost(ctx, userID, mux.Vars(r)["id"])
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := post.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal post", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
+
+// EditPost changes content of user's own post, absent fields are kept.
+func (h *Handler) EditPost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var edit models.PostEdit
+	if err = json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	post, err := h.service.EditPost(ctx, userID, mux.Vars(r)["id"], &edit)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := post.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal post", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
+
+func (h *Handler) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+	revisions, err := h.service.GetPostRevisions(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal revisions", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, data)
+}
+
+// GetPostDiff compares revisions from and to given by query,
+// by default the last edit is shown.
+func (h *Handler) GetPostDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	numbers := [2]int{}
+	for i, name := range []string{"from", "to"} {
+		if query.Get(name) == "" {
+			continue
+		}
+		number, err := strconv.Atoi(query.Get(name))
+		if err != nil || number < 1 {
+			h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid revision", "invalid "+name+": "+query.Get(name), nil))
+			return
+		}
+		numbers[i] = number
+	}
+
+	ctx := r.Context()
+	diff, err := h.service.GetPostDiff(ctx, mux.Vars(r)["id"], numbers[0], numbers[1])
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal diff", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, data)
+}
+
 func (h *Handler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
@@ -149,8 +279,10 @@ func (h *Handler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, _ := h.extractUserIDFromRequestContext(r)
+
 	ctx := r.Context()
-	posts, err := h.service.GetPostsByAuthor(ctx, username)
+	posts, err := h.service.GetPostsByAuthor(ctx, viewerID, username)
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+)
+
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	username := mux.Vars(r)["username"]
+	if username == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid username", "username is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	profile, err := h.service.GetProfile(ctx, username)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledProfile, err := json.Marshal(profile)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal profile", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledProfile)
+}
+
+// UpdateProfile changes only fields present in request body.
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	userID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var update models.ProfileUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	profile, err := h.service.UpdateProfile(ctx, userID, &update)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledProfile, err := json.Marshal(profile)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal profile", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledProfile)
+}
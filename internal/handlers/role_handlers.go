@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+type GrantRoleRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// only for moderators
+	Category string `json:"category,omitempty"`
+}
+
+func (h *Handler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	adminID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	var grantRequest GrantRoleRequest
+	err = json.NewDecoder(r.Body).Decode(&grantRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+
+	ctx := r.Context()
+	role, err := h.service.GrantRole(ctx, adminID, grantRequest.Username, grantRequest.Role, grantRequest.Category)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledRole, err := json.Marshal(role)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal role", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusCreated, marshalledRole)
+}
+
+func (h *Handler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	adminID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	roleID := mux.Vars(r)["id"]
+	if roleID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid roleID", "roleID is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.RevokeRole(ctx, adminID, roleID); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	adminID, err := h.extractUserIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	if username == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid username", "username is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	roles, err := h.service.GetUserRoles(ctx, adminID, username)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledRoles, err := json.Marshal(roles)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal roles", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledRoles)
+}
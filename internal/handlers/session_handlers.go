@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/token"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken gives new access and refresh tokens.
+// Used refresh token becomes invalid.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var refreshRequest RefreshTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&refreshRequest)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "bad json", "failed to decode request body: "+err.Error(), nil))
+		return
+	}
+	if refreshRequest.RefreshToken == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid refresh token", "refresh token is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	session, err := h.service.RefreshSession(ctx, refreshRequest.RefreshToken)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledSession, err := session.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal session", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledSession)
+}
+
+// GetJWKS serves public keys access tokens are signed with.
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	provider, ok := h.tokenMaker.(token.KeySetProvider)
+	if !ok {
+		h.jsonError(w, errhandler.New(http.StatusNotFound, "no public keys", "token maker doesnt provide public keys", nil))
+		return
+	}
+
+	jwks, err := provider.JWKS()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "internal error", "cant get jwks", err))
+		return
+	}
+
+	// verifiers refetch keys after rotation anyway
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	h.WriteToResponse(w, http.StatusOK, jwks)
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	sessionID, err := h.extractSessionIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	sessions, err := h.service.GetUserSessions(ctx, usr.ID, sessionID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledSessions, err := json.Marshal(sessions)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal sessions", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledSessions)
+}
+
+func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	sessionID := mux.Vars(r)["id"]
+	if sessionID == "" {
+		h.jsonError(w, errhandler.New(http.StatusBadRequest, "invalid sessionID", "sessionID is empty", nil))
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.RevokeSession(ctx, usr.ID, sessionID); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
+
+// Logout revokes session the request was made with.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	sessionID, err := h.extractSessionIDFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err = h.service.RevokeSession(ctx, usr.ID, sessionID); err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	ans := map[string]string{"message": "success"}
+	marshalledAns, err := json.Marshal(ans)
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledAns)
+}
@@ -11,6 +11,8 @@ import (
 type RegisterRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Device   string `json:"device,omitempty"`
 }
 
 func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
@@ -25,9 +27,10 @@ func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	usr := models.NewUser(registerRequest.Username, registerRequest.Password)
+	usr.Email = registerRequest.Email
 
 	ctx := r.Context()
-	session, err := h.service.CreateNewUser(ctx, usr)
+	session, err := h.service.CreateNewUser(ctx, usr, h.clientInfoFromRequest(r, registerRequest.Device))
 	if err != nil {
 		h.jsonError(w, err)
 		return
@@ -45,6 +48,7 @@ func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Device   string `json:"device,omitempty"`
 }
 
 func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
@@ -58,12 +62,24 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	session, err := h.service.LoginUser(ctx, loginRequest.Username)
+	session, mfaPending, err := h.service.LoginUser(ctx, loginRequest.Username, loginRequest.Password, h.clientInfoFromRequest(r, loginRequest.Device))
 	if err != nil {
 		h.jsonError(w, err)
 		return
 	}
 
+	// second step is at /api/login/2fa
+	if mfaPending != nil {
+		marshalledPending, err := json.Marshal(mfaPending)
+		if err != nil {
+			h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal response", err.Error(), err))
+			return
+		}
+
+		h.WriteToResponse(w, http.StatusOK, marshalledPending)
+		return
+	}
+
 	marshalledSession, err := session.GetMarshal()
 	if err != nil {
 		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal session", err.Error(), err))
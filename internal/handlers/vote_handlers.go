@@ -90,3 +90,66 @@ func (h *Handler) DownvotePost(w http.ResponseWriter, r *http.Request) {
 
 	h.WriteToResponse(w, http.StatusOK, marshalledPost)
 }
+
+func (h *Handler) UnvoteComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	changedPost, err := h.service.UnvoteCommentWithID(ctx, vars["postID"], vars["commentID"], usr.ID)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := changedPost.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal posts", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
+
+func (h *Handler) VoteComment(w http.ResponseWriter, r *http.Request) {
+	h.voteComment(w, r, 1)
+}
+
+func (h *Handler) DownvoteComment(w http.ResponseWriter, r *http.Request) {
+	h.voteComment(w, r, -1)
+}
+
+func (h *Handler) voteComment(w http.ResponseWriter, r *http.Request, vote int8) {
+	w.Header().Set("Content-Type", "application/json")
+
+	usr, err := h.extractUserFromRequestContext(r)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+	newVote := models.NewVote(usr.ID, vote)
+	changedPost, err := h.service.VoteCommentWithID(ctx, vars["postID"], vars["commentID"], newVote)
+	if err != nil {
+		h.jsonError(w, err)
+		return
+	}
+
+	marshalledPost, err := changedPost.GetMarshal()
+	if err != nil {
+		h.jsonError(w, errhandler.New(http.StatusInternalServerError, "failed to marshal posts", err.Error(), err))
+		return
+	}
+
+	h.WriteToResponse(w, http.StatusOK, marshalledPost)
+}
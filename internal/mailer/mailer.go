@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid email address")
+	ErrInvalidHeader  = errors.New("invalid email header")
+)
+
+// Message is plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// ValidateAddress checks that address is a single bare email, without display name.
+func ValidateAddress(address string) error {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Address != address {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// format builds RFC 5322 message. Header values with line breaks
+// are rejected, otherwise they could inject other headers.
+func (m *Message) format(from string, date time.Time) ([]byte, error) {
+	if err := ValidateAddress(m.To); err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") || strings.ContainsAny(from, "\r\n") {
+		return nil, ErrInvalidHeader
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	// SMTP needs CRLF line endings
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,158 @@
+package mailer_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/mailer"
+)
+
+var mockMessage = &mailer.Message{
+	To:      "user@example.com",
+	Subject: "Password reset",
+	Body:    "line 1\nline 2",
+}
+
+func TestMemoryOutbox(t *testing.T) {
+	outbox := mailer.NewMemoryOutbox()
+
+	require.NoError(t, outbox.Send(context.Background(), mockMessage))
+	err := outbox.Send(context.Background(), &mailer.Message{To: "not an email", Subject: "x"})
+	assert.ErrorIs(t, err, mailer.ErrInvalidAddress)
+
+	messages := outbox.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, mockMessage, messages[0])
+}
+
+func TestFileOutbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	outbox, err := mailer.NewFileOutbox(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, outbox.Send(context.Background(), mockMessage))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: user@example.com\r\n")
+	assert.Contains(t, string(data), "Subject: Password reset\r\n")
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nline 1\r\nline 2"))
+}
+
+func TestHeaderInjection(t *testing.T) {
+	outbox := mailer.NewMemoryOutbox()
+
+	testCases := []struct {
+		name   string
+		msg    *mailer.Message
+		expErr error
+	}{
+		{
+			name:   "Newline in subject",
+			msg:    &mailer.Message{To: "user@example.com", Subject: "hi\r\nBcc: victim@example.com"},
+			expErr: mailer.ErrInvalidHeader,
+		},
+		{
+			name:   "Newline in address",
+			msg:    &mailer.Message{To: "user@example.com\r\nBcc: victim@example.com"},
+			expErr: mailer.ErrInvalidAddress,
+		},
+		{
+			name:   "Display name",
+			msg:    &mailer.Message{To: "User <user@example.com>"},
+			expErr: mailer.ErrInvalidAddress,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, outbox.Send(context.Background(), tc.msg), tc.expErr)
+		})
+	}
+	assert.Empty(t, outbox.Messages())
+}
+
+// fakeSMTPServer accepts one message without tls and auth
+// and sends its DATA to the channel.
+func fakeSMTPServer(t *testing.T) (port int, received chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received = make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		write := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		write("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				write("250 OK")
+			case cmd == "DATA":
+				write("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				write("250 OK")
+			case cmd == "QUIT":
+				write("221 bye")
+				return
+			default:
+				write("500 unknown command")
+			}
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	port, received := fakeSMTPServer(t)
+
+	m := mailer.NewSMTPMailer(mailer.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "noreply@example.com",
+	})
+	require.NoError(t, m.Send(context.Background(), mockMessage))
+
+	data := <-received
+	assert.Contains(t, data, "From: noreply@example.com\r\n")
+	assert.Contains(t, data, "To: user@example.com\r\n")
+	assert.Contains(t, data, "line 1\r\nline 2")
+}
@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const outboxFrom = "redditclone@localhost"
+
+// FileOutbox writes every message to its own .eml file instead of sending it,
+// for local development.
+type FileOutbox struct {
+	dir string
+}
+
+func NewFileOutbox(dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cant create outbox dir: %w", err)
+	}
+
+	return &FileOutbox{dir: dir}, nil
+}
+
+func (o *FileOutbox) Send(_ context.Context, msg *Message) error {
+	now := time.Now()
+	data, err := msg.format(outboxFrom, now)
+	if err != nil {
+		return err
+	}
+
+	// sortable by time, uuid keeps names unique
+	name := now.UTC().Format("20060102T150405.000000000") + "-" + uuid.NewString() + ".eml"
+	return os.WriteFile(filepath.Join(o.dir, name), data, 0o640)
+}
+
+// MemoryOutbox keeps sent messages in memory, for tests.
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{}
+}
+
+func (o *MemoryOutbox) Send(_ context.Context, msg *Message) error {
+	// same checks as real mailers
+	if _, err := msg.format(outboxFrom, time.Now()); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	copied := *msg
+	o.messages = append(o.messages, &copied)
+	return nil
+}
+
+// Messages returns copy of sent messages in order they were sent.
+func (o *MemoryOutbox) Messages() []*Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]*Message{}, o.messages...)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/myacey/redditclone/internal/models"
@@ -35,6 +36,36 @@ func (m *MockCommentRepository) EXPECT() *MockCommentRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CountCommentsByAuthor mocks base method.
+func (m *MockCommentRepository) CountCommentsByAuthor(ctx context.Context, authorID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountCommentsByAuthor", ctx, authorID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountCommentsByAuthor indicates an expected call of CountCommentsByAuthor.
+func (mr *MockCommentRepositoryMockRecorder) CountCommentsByAuthor(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountCommentsByAuthor", reflect.TypeOf((*MockCommentRepository)(nil).CountCommentsByAuthor), ctx, authorID)
+}
+
+// CountCommentsByPost mocks base method.
+func (m *MockCommentRepository) CountCommentsByPost(ctx context.Context) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountCommentsByPost", ctx)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountCommentsByPost indicates an expected call of CountCommentsByPost.
+func (mr *MockCommentRepositoryMockRecorder) CountCommentsByPost(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountCommentsByPost", reflect.TypeOf((*MockCommentRepository)(nil).CountCommentsByPost), ctx)
+}
+
 // CreateComment mocks base method.
 func (m *MockCommentRepository) CreateComment(ctx context.Context, newComment *models.Comment) error {
 	m.ctrl.T.Helper()
@@ -63,6 +94,50 @@ func (mr *MockCommentRepositoryMockRecorder) DeleteComment(ctx, commentID interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockCommentRepository)(nil).DeleteComment), ctx, commentID)
 }
 
+// DeleteCommentsByAuthor mocks base method.
+func (m *MockCommentRepository) DeleteCommentsByAuthor(ctx context.Context, authorID string, limit int64) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCommentsByAuthor", ctx, authorID, limit)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCommentsByAuthor indicates an expected call of DeleteCommentsByAuthor.
+func (mr *MockCommentRepositoryMockRecorder) DeleteCommentsByAuthor(ctx, authorID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCommentsByAuthor", reflect.TypeOf((*MockCommentRepository)(nil).DeleteCommentsByAuthor), ctx, authorID, limit)
+}
+
+// DeleteCommentsByPostID mocks base method.
+func (m *MockCommentRepository) DeleteCommentsByPostID(ctx context.Context, postID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCommentsByPostID", ctx, postID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteCommentsByPostID indicates an expected call of DeleteCommentsByPostID.
+func (mr *MockCommentRepositoryMockRecorder) DeleteCommentsByPostID(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCommentsByPostID", reflect.TypeOf((*MockCommentRepository)(nil).DeleteCommentsByPostID), ctx, postID)
+}
+
+// EditComment mocks base method.
+func (m *MockCommentRepository) EditComment(ctx context.Context, comment *models.Comment, prevBody string, revision *models.CommentRevision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditComment", ctx, comment, prevBody, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EditComment indicates an expected call of EditComment.
+func (mr *MockCommentRepositoryMockRecorder) EditComment(ctx, comment, prevBody, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditComment", reflect.TypeOf((*MockCommentRepository)(nil).EditComment), ctx, comment, prevBody, revision)
+}
+
 // GetCommentByID mocks base method.
 func (m *MockCommentRepository) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
 	m.ctrl.T.Helper()
@@ -78,6 +153,36 @@ func (mr *MockCommentRepositoryMockRecorder) GetCommentByID(ctx, commentID inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentByID", reflect.TypeOf((*MockCommentRepository)(nil).GetCommentByID), ctx, commentID)
 }
 
+// GetCommentThread mocks base method.
+func (m *MockCommentRepository) GetCommentThread(ctx context.Context, postID, rootPath string, maxDepth int) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentThread", ctx, postID, rootPath, maxDepth)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentThread indicates an expected call of GetCommentThread.
+func (mr *MockCommentRepositoryMockRecorder) GetCommentThread(ctx, postID, rootPath, maxDepth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentThread", reflect.TypeOf((*MockCommentRepository)(nil).GetCommentThread), ctx, postID, rootPath, maxDepth)
+}
+
+// GetCommentsByAuthorID mocks base method.
+func (m *MockCommentRepository) GetCommentsByAuthorID(ctx context.Context, authorID string) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsByAuthorID", ctx, authorID)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentsByAuthorID indicates an expected call of GetCommentsByAuthorID.
+func (mr *MockCommentRepositoryMockRecorder) GetCommentsByAuthorID(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByAuthorID", reflect.TypeOf((*MockCommentRepository)(nil).GetCommentsByAuthorID), ctx, authorID)
+}
+
 // GetCommentsByPostID mocks base method.
 func (m *MockCommentRepository) GetCommentsByPostID(ctx context.Context, postID string) ([]*models.Comment, error) {
 	m.ctrl.T.Helper()
@@ -92,3 +197,76 @@ func (mr *MockCommentRepositoryMockRecorder) GetCommentsByPostID(ctx, postID int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByPostID", reflect.TypeOf((*MockCommentRepository)(nil).GetCommentsByPostID), ctx, postID)
 }
+
+// GetCommentsDeletedBefore mocks base method.
+func (m *MockCommentRepository) GetCommentsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsDeletedBefore", ctx, before, limit)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentsDeletedBefore indicates an expected call of GetCommentsDeletedBefore.
+func (mr *MockCommentRepositoryMockRecorder) GetCommentsDeletedBefore(ctx, before, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsDeletedBefore", reflect.TypeOf((*MockCommentRepository)(nil).GetCommentsDeletedBefore), ctx, before, limit)
+}
+
+// ReplaceCommentsAuthor mocks base method.
+func (m *MockCommentRepository) ReplaceCommentsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceCommentsAuthor", ctx, authorID, author, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplaceCommentsAuthor indicates an expected call of ReplaceCommentsAuthor.
+func (mr *MockCommentRepositoryMockRecorder) ReplaceCommentsAuthor(ctx, authorID, author, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceCommentsAuthor", reflect.TypeOf((*MockCommentRepository)(nil).ReplaceCommentsAuthor), ctx, authorID, author, limit)
+}
+
+// RestoreComment mocks base method.
+func (m *MockCommentRepository) RestoreComment(ctx context.Context, commentID string, deletedAfter time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreComment", ctx, commentID, deletedAfter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreComment indicates an expected call of RestoreComment.
+func (mr *MockCommentRepositoryMockRecorder) RestoreComment(ctx, commentID, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreComment", reflect.TypeOf((*MockCommentRepository)(nil).RestoreComment), ctx, commentID, deletedAfter)
+}
+
+// SoftDeleteComment mocks base method.
+func (m *MockCommentRepository) SoftDeleteComment(ctx context.Context, commentID, deletedBy string, deletedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteComment", ctx, commentID, deletedBy, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteComment indicates an expected call of SoftDeleteComment.
+func (mr *MockCommentRepositoryMockRecorder) SoftDeleteComment(ctx, commentID, deletedBy, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteComment", reflect.TypeOf((*MockCommentRepository)(nil).SoftDeleteComment), ctx, commentID, deletedBy, deletedAt)
+}
+
+// SumScoreByAuthor mocks base method.
+func (m *MockCommentRepository) SumScoreByAuthor(ctx context.Context, authorID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumScoreByAuthor", ctx, authorID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumScoreByAuthor indicates an expected call of SumScoreByAuthor.
+func (mr *MockCommentRepositoryMockRecorder) SumScoreByAuthor(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumScoreByAuthor", reflect.TypeOf((*MockCommentRepository)(nil).SumScoreByAuthor), ctx, authorID)
+}
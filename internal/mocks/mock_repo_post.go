@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/myacey/redditclone/internal/models"
@@ -35,6 +36,21 @@ func (m *MockPostRepository) EXPECT() *MockPostRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CountPostsByAuthor mocks base method.
+func (m *MockPostRepository) CountPostsByAuthor(ctx context.Context, authorID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPostsByAuthor", ctx, authorID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPostsByAuthor indicates an expected call of CountPostsByAuthor.
+func (mr *MockPostRepositoryMockRecorder) CountPostsByAuthor(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPostsByAuthor", reflect.TypeOf((*MockPostRepository)(nil).CountPostsByAuthor), ctx, authorID)
+}
+
 // CreatePost mocks base method.
 func (m *MockPostRepository) CreatePost(ctx context.Context, newPost *models.Post) error {
 	m.ctrl.T.Helper()
@@ -78,6 +94,36 @@ func (mr *MockPostRepositoryMockRecorder) GetAllPosts(ctx interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockPostRepository)(nil).GetAllPosts), ctx)
 }
 
+// GetCommentCounts mocks base method.
+func (m *MockPostRepository) GetCommentCounts(ctx context.Context) (map[string]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentCounts", ctx)
+	ret0, _ := ret[0].(map[string]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentCounts indicates an expected call of GetCommentCounts.
+func (mr *MockPostRepositoryMockRecorder) GetCommentCounts(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentCounts", reflect.TypeOf((*MockPostRepository)(nil).GetCommentCounts), ctx)
+}
+
+// GetDeletedPostByID mocks base method.
+func (m *MockPostRepository) GetDeletedPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedPostByID", ctx, postID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedPostByID indicates an expected call of GetDeletedPostByID.
+func (mr *MockPostRepositoryMockRecorder) GetDeletedPostByID(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedPostByID", reflect.TypeOf((*MockPostRepository)(nil).GetDeletedPostByID), ctx, postID)
+}
+
 // GetPostByID mocks base method.
 func (m *MockPostRepository) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
 	m.ctrl.T.Helper()
@@ -93,6 +139,138 @@ func (mr *MockPostRepositoryMockRecorder) GetPostByID(ctx, postID interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockPostRepository)(nil).GetPostByID), ctx, postID)
 }
 
+// GetPostIDsByAuthor mocks base method.
+func (m *MockPostRepository) GetPostIDsByAuthor(ctx context.Context, authorID string, limit int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostIDsByAuthor", ctx, authorID, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostIDsByAuthor indicates an expected call of GetPostIDsByAuthor.
+func (mr *MockPostRepositoryMockRecorder) GetPostIDsByAuthor(ctx, authorID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostIDsByAuthor", reflect.TypeOf((*MockPostRepository)(nil).GetPostIDsByAuthor), ctx, authorID, limit)
+}
+
+// GetPostIDsDeletedBefore mocks base method.
+func (m *MockPostRepository) GetPostIDsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostIDsDeletedBefore", ctx, before, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostIDsDeletedBefore indicates an expected call of GetPostIDsDeletedBefore.
+func (mr *MockPostRepositoryMockRecorder) GetPostIDsDeletedBefore(ctx, before, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostIDsDeletedBefore", reflect.TypeOf((*MockPostRepository)(nil).GetPostIDsDeletedBefore), ctx, before, limit)
+}
+
+// GetPostsByAuthorID mocks base method.
+func (m *MockPostRepository) GetPostsByAuthorID(ctx context.Context, authorID string) ([]*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostsByAuthorID", ctx, authorID)
+	ret0, _ := ret[0].([]*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostsByAuthorID indicates an expected call of GetPostsByAuthorID.
+func (mr *MockPostRepositoryMockRecorder) GetPostsByAuthorID(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthorID", reflect.TypeOf((*MockPostRepository)(nil).GetPostsByAuthorID), ctx, authorID)
+}
+
+// IncrementViews mocks base method.
+func (m *MockPostRepository) IncrementViews(ctx context.Context, postID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementViews", ctx, postID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementViews indicates an expected call of IncrementViews.
+func (mr *MockPostRepositoryMockRecorder) IncrementViews(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementViews", reflect.TypeOf((*MockPostRepository)(nil).IncrementViews), ctx, postID)
+}
+
+// ReplacePostsAuthor mocks base method.
+func (m *MockPostRepository) ReplacePostsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplacePostsAuthor", ctx, authorID, author, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplacePostsAuthor indicates an expected call of ReplacePostsAuthor.
+func (mr *MockPostRepositoryMockRecorder) ReplacePostsAuthor(ctx, authorID, author, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplacePostsAuthor", reflect.TypeOf((*MockPostRepository)(nil).ReplacePostsAuthor), ctx, authorID, author, limit)
+}
+
+// RestorePost mocks base method.
+func (m *MockPostRepository) RestorePost(ctx context.Context, postID string, deletedAfter time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestorePost", ctx, postID, deletedAfter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestorePost indicates an expected call of RestorePost.
+func (mr *MockPostRepositoryMockRecorder) RestorePost(ctx, postID, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePost", reflect.TypeOf((*MockPostRepository)(nil).RestorePost), ctx, postID, deletedAfter)
+}
+
+// SetCommentCount mocks base method.
+func (m *MockPostRepository) SetCommentCount(ctx context.Context, postID string, stored, count int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCommentCount", ctx, postID, stored, count)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCommentCount indicates an expected call of SetCommentCount.
+func (mr *MockPostRepositoryMockRecorder) SetCommentCount(ctx, postID, stored, count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCommentCount", reflect.TypeOf((*MockPostRepository)(nil).SetCommentCount), ctx, postID, stored, count)
+}
+
+// SoftDeletePost mocks base method.
+func (m *MockPostRepository) SoftDeletePost(ctx context.Context, postID, deletedBy string, deletedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeletePost", ctx, postID, deletedBy, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeletePost indicates an expected call of SoftDeletePost.
+func (mr *MockPostRepositoryMockRecorder) SoftDeletePost(ctx, postID, deletedBy, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeletePost", reflect.TypeOf((*MockPostRepository)(nil).SoftDeletePost), ctx, postID, deletedBy, deletedAt)
+}
+
+// SumScoreByAuthor mocks base method.
+func (m *MockPostRepository) SumScoreByAuthor(ctx context.Context, authorID string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumScoreByAuthor", ctx, authorID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumScoreByAuthor indicates an expected call of SumScoreByAuthor.
+func (mr *MockPostRepositoryMockRecorder) SumScoreByAuthor(ctx, authorID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumScoreByAuthor", reflect.TypeOf((*MockPostRepository)(nil).SumScoreByAuthor), ctx, authorID)
+}
+
 // UpdatePostInfo mocks base method.
 func (m *MockPostRepository) UpdatePostInfo(ctx context.Context, updatedPost *models.Post) error {
 	m.ctrl.T.Helper()
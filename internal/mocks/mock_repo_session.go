@@ -36,45 +36,277 @@ func (m *MockSessionRepository) EXPECT() *MockSessionRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CreateEmailToken mocks base method.
+func (m *MockSessionRepository) CreateEmailToken(ctx context.Context, emailToken *models.EmailToken, expirationTime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEmailToken", ctx, emailToken, expirationTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateEmailToken indicates an expected call of CreateEmailToken.
+func (mr *MockSessionRepositoryMockRecorder) CreateEmailToken(ctx, emailToken, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEmailToken", reflect.TypeOf((*MockSessionRepository)(nil).CreateEmailToken), ctx, emailToken, expirationTime)
+}
+
+// CreateMFAChallenge mocks base method.
+func (m *MockSessionRepository) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge, expirationTime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFAChallenge", ctx, challenge, expirationTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMFAChallenge indicates an expected call of CreateMFAChallenge.
+func (mr *MockSessionRepositoryMockRecorder) CreateMFAChallenge(ctx, challenge, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFAChallenge", reflect.TypeOf((*MockSessionRepository)(nil).CreateMFAChallenge), ctx, challenge, expirationTime)
+}
+
+// CreateOAuthState mocks base method.
+func (m *MockSessionRepository) CreateOAuthState(ctx context.Context, state *models.OAuthState, expirationTime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthState", ctx, state, expirationTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthState indicates an expected call of CreateOAuthState.
+func (mr *MockSessionRepositoryMockRecorder) CreateOAuthState(ctx, state, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthState", reflect.TypeOf((*MockSessionRepository)(nil).CreateOAuthState), ctx, state, expirationTime)
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockSessionRepository) CreateRefreshToken(ctx context.Context, refreshToken *models.RefreshToken, expirationTime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, refreshToken, expirationTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockSessionRepositoryMockRecorder) CreateRefreshToken(ctx, refreshToken, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockSessionRepository)(nil).CreateRefreshToken), ctx, refreshToken, expirationTime)
+}
+
 // CreateSession mocks base method.
-func (m *MockSessionRepository) CreateSession(ctx context.Context, session *models.Session, username string, expirationTime time.Duration) error {
+func (m *MockSessionRepository) CreateSession(ctx context.Context, session *models.SessionInfo, expirationTime time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", ctx, session, username, expirationTime)
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session, expirationTime)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateSession indicates an expected call of CreateSession.
-func (mr *MockSessionRepositoryMockRecorder) CreateSession(ctx, session, username, expirationTime interface{}) *gomock.Call {
+func (mr *MockSessionRepositoryMockRecorder) CreateSession(ctx, session, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionRepository)(nil).CreateSession), ctx, session, expirationTime)
+}
+
+// DeleteMFAChallenge mocks base method.
+func (m *MockSessionRepository) DeleteMFAChallenge(ctx context.Context, tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMFAChallenge", ctx, tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMFAChallenge indicates an expected call of DeleteMFAChallenge.
+func (mr *MockSessionRepositoryMockRecorder) DeleteMFAChallenge(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMFAChallenge", reflect.TypeOf((*MockSessionRepository)(nil).DeleteMFAChallenge), ctx, tokenHash)
+}
+
+// DeleteSession mocks base method.
+func (m *MockSessionRepository) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockSessionRepositoryMockRecorder) DeleteSession(ctx, userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockSessionRepository)(nil).DeleteSession), ctx, userID, sessionID)
+}
+
+// DeleteSessionsByUserID mocks base method.
+func (m *MockSessionRepository) DeleteSessionsByUserID(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSessionsByUserID", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSessionsByUserID indicates an expected call of DeleteSessionsByUserID.
+func (mr *MockSessionRepositoryMockRecorder) DeleteSessionsByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSessionsByUserID", reflect.TypeOf((*MockSessionRepository)(nil).DeleteSessionsByUserID), ctx, userID)
+}
+
+// GetMFAChallenge mocks base method.
+func (m *MockSessionRepository) GetMFAChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMFAChallenge", ctx, tokenHash)
+	ret0, _ := ret[0].(*models.MFAChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMFAChallenge indicates an expected call of GetMFAChallenge.
+func (mr *MockSessionRepositoryMockRecorder) GetMFAChallenge(ctx, tokenHash interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionRepository)(nil).CreateSession), ctx, session, username, expirationTime)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMFAChallenge", reflect.TypeOf((*MockSessionRepository)(nil).GetMFAChallenge), ctx, tokenHash)
 }
 
-// GetSessionTokenByUsername mocks base method.
-func (m *MockSessionRepository) GetSessionTokenByUsername(ctx context.Context, username string) (string, error) {
+// GetRefreshToken mocks base method.
+func (m *MockSessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSessionTokenByUsername", ctx, username)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "GetRefreshToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*models.RefreshToken)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetSessionTokenByUsername indicates an expected call of GetSessionTokenByUsername.
-func (mr *MockSessionRepositoryMockRecorder) GetSessionTokenByUsername(ctx, username interface{}) *gomock.Call {
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockSessionRepositoryMockRecorder) GetRefreshToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockSessionRepository)(nil).GetRefreshToken), ctx, tokenHash)
+}
+
+// GetSession mocks base method.
+func (m *MockSessionRepository) GetSession(ctx context.Context, userID, sessionID string) (*models.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(*models.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockSessionRepositoryMockRecorder) GetSession(ctx, userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockSessionRepository)(nil).GetSession), ctx, userID, sessionID)
+}
+
+// GetSessionByTokenHash mocks base method.
+func (m *MockSessionRepository) GetSessionByTokenHash(ctx context.Context, userID, tokenHash string) (*models.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByTokenHash", ctx, userID, tokenHash)
+	ret0, _ := ret[0].(*models.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByTokenHash indicates an expected call of GetSessionByTokenHash.
+func (mr *MockSessionRepositoryMockRecorder) GetSessionByTokenHash(ctx, userID, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByTokenHash", reflect.TypeOf((*MockSessionRepository)(nil).GetSessionByTokenHash), ctx, userID, tokenHash)
+}
+
+// GetSessionsByUserID mocks base method.
+func (m *MockSessionRepository) GetSessionsByUserID(ctx context.Context, userID string) ([]*models.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]*models.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionsByUserID indicates an expected call of GetSessionsByUserID.
+func (mr *MockSessionRepositoryMockRecorder) GetSessionsByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionsByUserID", reflect.TypeOf((*MockSessionRepository)(nil).GetSessionsByUserID), ctx, userID)
+}
+
+// IncrMFAChallengeAttempts mocks base method.
+func (m *MockSessionRepository) IncrMFAChallengeAttempts(ctx context.Context, tokenHash string, expirationTime time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrMFAChallengeAttempts", ctx, tokenHash, expirationTime)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrMFAChallengeAttempts indicates an expected call of IncrMFAChallengeAttempts.
+func (mr *MockSessionRepositoryMockRecorder) IncrMFAChallengeAttempts(ctx, tokenHash, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrMFAChallengeAttempts", reflect.TypeOf((*MockSessionRepository)(nil).IncrMFAChallengeAttempts), ctx, tokenHash, expirationTime)
+}
+
+// MarkRefreshTokenUsed mocks base method.
+func (m *MockSessionRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string, expirationTime time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRefreshTokenUsed", ctx, tokenHash, expirationTime)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkRefreshTokenUsed indicates an expected call of MarkRefreshTokenUsed.
+func (mr *MockSessionRepositoryMockRecorder) MarkRefreshTokenUsed(ctx, tokenHash, expirationTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRefreshTokenUsed", reflect.TypeOf((*MockSessionRepository)(nil).MarkRefreshTokenUsed), ctx, tokenHash, expirationTime)
+}
+
+// TakeEmailToken mocks base method.
+func (m *MockSessionRepository) TakeEmailToken(ctx context.Context, purpose, tokenHash string) (*models.EmailToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeEmailToken", ctx, purpose, tokenHash)
+	ret0, _ := ret[0].(*models.EmailToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeEmailToken indicates an expected call of TakeEmailToken.
+func (mr *MockSessionRepositoryMockRecorder) TakeEmailToken(ctx, purpose, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeEmailToken", reflect.TypeOf((*MockSessionRepository)(nil).TakeEmailToken), ctx, purpose, tokenHash)
+}
+
+// TakeOAuthState mocks base method.
+func (m *MockSessionRepository) TakeOAuthState(ctx context.Context, stateHash string) (*models.OAuthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeOAuthState", ctx, stateHash)
+	ret0, _ := ret[0].(*models.OAuthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeOAuthState indicates an expected call of TakeOAuthState.
+func (mr *MockSessionRepositoryMockRecorder) TakeOAuthState(ctx, stateHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeOAuthState", reflect.TypeOf((*MockSessionRepository)(nil).TakeOAuthState), ctx, stateHash)
+}
+
+// UpdateSession mocks base method.
+func (m *MockSessionRepository) UpdateSession(ctx context.Context, session *models.SessionInfo, expirationTime time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSession", ctx, session, expirationTime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSession indicates an expected call of UpdateSession.
+func (mr *MockSessionRepositoryMockRecorder) UpdateSession(ctx, session, expirationTime interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionTokenByUsername", reflect.TypeOf((*MockSessionRepository)(nil).GetSessionTokenByUsername), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSession", reflect.TypeOf((*MockSessionRepository)(nil).UpdateSession), ctx, session, expirationTime)
 }
 
-// UpdateSessionToken mocks base method.
-func (m *MockSessionRepository) UpdateSessionToken(ctx context.Context, newSession *models.Session, username string, expirationTime time.Duration) error {
+// UpdateSessionLastSeen mocks base method.
+func (m *MockSessionRepository) UpdateSessionLastSeen(ctx context.Context, session *models.SessionInfo) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateSessionToken", ctx, newSession, username, expirationTime)
+	ret := m.ctrl.Call(m, "UpdateSessionLastSeen", ctx, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateSessionToken indicates an expected call of UpdateSessionToken.
-func (mr *MockSessionRepositoryMockRecorder) UpdateSessionToken(ctx, newSession, username, expirationTime interface{}) *gomock.Call {
+// UpdateSessionLastSeen indicates an expected call of UpdateSessionLastSeen.
+func (mr *MockSessionRepositoryMockRecorder) UpdateSessionLastSeen(ctx, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSessionToken", reflect.TypeOf((*MockSessionRepository)(nil).UpdateSessionToken), ctx, newSession, username, expirationTime)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSessionLastSeen", reflect.TypeOf((*MockSessionRepository)(nil).UpdateSessionLastSeen), ctx, session)
 }
@@ -6,7 +6,9 @@ package mocks
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/myacey/redditclone/internal/models"
@@ -64,107 +66,450 @@ func (mr *MockServiceInterfaceMockRecorder) AddPost(ctx, newPost interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockServiceInterface)(nil).AddPost), ctx, newPost)
 }
 
-// CheckUserSession mocks base method.
-func (m *MockServiceInterface) CheckUserSession(ctx context.Context, userID, token string) error {
+// ChangeEmail mocks base method.
+func (m *MockServiceInterface) ChangeEmail(ctx context.Context, userID, email string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckUserSession", ctx, userID, token)
+	ret := m.ctrl.Call(m, "ChangeEmail", ctx, userID, email)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// ChangeEmail indicates an expected call of ChangeEmail.
+func (mr *MockServiceInterfaceMockRecorder) ChangeEmail(ctx, userID, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeEmail", reflect.TypeOf((*MockServiceInterface)(nil).ChangeEmail), ctx, userID, email)
+}
+
+// CheckAccessToken mocks base method.
+func (m *MockServiceInterface) CheckAccessToken(ctx context.Context, plainToken string) (*models.AccessToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckAccessToken", ctx, plainToken)
+	ret0, _ := ret[0].(*models.AccessToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckAccessToken indicates an expected call of CheckAccessToken.
+func (mr *MockServiceInterfaceMockRecorder) CheckAccessToken(ctx, plainToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAccessToken", reflect.TypeOf((*MockServiceInterface)(nil).CheckAccessToken), ctx, plainToken)
+}
+
+// CheckUserSession mocks base method.
+func (m *MockServiceInterface) CheckUserSession(ctx context.Context, userID, token string) (*models.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckUserSession", ctx, userID, token)
+	ret0, _ := ret[0].(*models.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // CheckUserSession indicates an expected call of CheckUserSession.
 func (mr *MockServiceInterfaceMockRecorder) CheckUserSession(ctx, userID, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckUserSession", reflect.TypeOf((*MockServiceInterface)(nil).CheckUserSession), ctx, userID, token)
 }
 
+// CleanupExports mocks base method.
+func (m *MockServiceInterface) CleanupExports(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanupExports", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CleanupExports indicates an expected call of CleanupExports.
+func (mr *MockServiceInterfaceMockRecorder) CleanupExports(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupExports", reflect.TypeOf((*MockServiceInterface)(nil).CleanupExports), ctx)
+}
+
+// CompleteExportDownload mocks base method.
+func (m *MockServiceInterface) CompleteExportDownload(ctx context.Context, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteExportDownload", ctx, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteExportDownload indicates an expected call of CompleteExportDownload.
+func (mr *MockServiceInterfaceMockRecorder) CompleteExportDownload(ctx, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteExportDownload", reflect.TypeOf((*MockServiceInterface)(nil).CompleteExportDownload), ctx, jobID)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockServiceInterface) ConfirmTOTP(ctx context.Context, userID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockServiceInterfaceMockRecorder) ConfirmTOTP(ctx, userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockServiceInterface)(nil).ConfirmTOTP), ctx, userID, code)
+}
+
+// CreateAccessToken mocks base method.
+func (m *MockServiceInterface) CreateAccessToken(ctx context.Context, userID, name string, scopes []string, expiresIn time.Duration) (*models.CreatedAccessToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccessToken", ctx, userID, name, scopes, expiresIn)
+	ret0, _ := ret[0].(*models.CreatedAccessToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccessToken indicates an expected call of CreateAccessToken.
+func (mr *MockServiceInterfaceMockRecorder) CreateAccessToken(ctx, userID, name, scopes, expiresIn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccessToken", reflect.TypeOf((*MockServiceInterface)(nil).CreateAccessToken), ctx, userID, name, scopes, expiresIn)
+}
+
 // CreateNewUser mocks base method.
-func (m *MockServiceInterface) CreateNewUser(ctx context.Context, user *models.User) (*models.Session, error) {
+func (m *MockServiceInterface) CreateNewUser(ctx context.Context, user *models.User, client *models.ClientInfo) (*models.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateNewUser", ctx, user)
+	ret := m.ctrl.Call(m, "CreateNewUser", ctx, user, client)
 	ret0, _ := ret[0].(*models.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateNewUser indicates an expected call of CreateNewUser.
-func (mr *MockServiceInterfaceMockRecorder) CreateNewUser(ctx, user interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) CreateNewUser(ctx, user, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewUser", reflect.TypeOf((*MockServiceInterface)(nil).CreateNewUser), ctx, user, client)
+}
+
+// DeleteAccount mocks base method.
+func (m *MockServiceInterface) DeleteAccount(ctx context.Context, userID, mode string) (*models.DeletionJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", ctx, userID, mode)
+	ret0, _ := ret[0].(*models.DeletionJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockServiceInterfaceMockRecorder) DeleteAccount(ctx, userID, mode interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewUser", reflect.TypeOf((*MockServiceInterface)(nil).CreateNewUser), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockServiceInterface)(nil).DeleteAccount), ctx, userID, mode)
 }
 
 // DeletePostWithID mocks base method.
-func (m *MockServiceInterface) DeletePostWithID(ctx context.Context, postID string) error {
+func (m *MockServiceInterface) DeletePostWithID(ctx context.Context, userID, postID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePostWithID", ctx, postID)
+	ret := m.ctrl.Call(m, "DeletePostWithID", ctx, userID, postID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePostWithID indicates an expected call of DeletePostWithID.
-func (mr *MockServiceInterfaceMockRecorder) DeletePostWithID(ctx, postID interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) DeletePostWithID(ctx, userID, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePostWithID", reflect.TypeOf((*MockServiceInterface)(nil).DeletePostWithID), ctx, userID, postID)
+}
+
+// DownloadExport mocks base method.
+func (m *MockServiceInterface) DownloadExport(ctx context.Context, jobID, expires, signature string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadExport", ctx, jobID, expires, signature)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DownloadExport indicates an expected call of DownloadExport.
+func (mr *MockServiceInterfaceMockRecorder) DownloadExport(ctx, jobID, expires, signature interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadExport", reflect.TypeOf((*MockServiceInterface)(nil).DownloadExport), ctx, jobID, expires, signature)
+}
+
+// EditComment mocks base method.
+func (m *MockServiceInterface) EditComment(ctx context.Context, userID, postID, commentID, body string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditComment", ctx, userID, postID, commentID, body)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditComment indicates an expected call of EditComment.
+func (mr *MockServiceInterfaceMockRecorder) EditComment(ctx, userID, postID, commentID, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditComment", reflect.TypeOf((*MockServiceInterface)(nil).EditComment), ctx, userID, postID, commentID, body)
+}
+
+// EditPost mocks base method.
+func (m *MockServiceInterface) EditPost(ctx context.Context, userID, postID string, edit *models.PostEdit) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditPost", ctx, userID, postID, edit)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditPost indicates an expected call of EditPost.
+func (mr *MockServiceInterfaceMockRecorder) EditPost(ctx, userID, postID, edit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditPost", reflect.TypeOf((*MockServiceInterface)(nil).EditPost), ctx, userID, postID, edit)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockServiceInterface) EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userID)
+	ret0, _ := ret[0].(*models.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockServiceInterfaceMockRecorder) EnrollTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockServiceInterface)(nil).EnrollTOTP), ctx, userID)
+}
+
+// FinishEmailLogin mocks base method.
+func (m *MockServiceInterface) FinishEmailLogin(ctx context.Context, plainToken string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishEmailLogin", ctx, plainToken, client)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(*models.MFAPending)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FinishEmailLogin indicates an expected call of FinishEmailLogin.
+func (mr *MockServiceInterfaceMockRecorder) FinishEmailLogin(ctx, plainToken, client interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePostWithID", reflect.TypeOf((*MockServiceInterface)(nil).DeletePostWithID), ctx, postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishEmailLogin", reflect.TypeOf((*MockServiceInterface)(nil).FinishEmailLogin), ctx, plainToken, client)
+}
+
+// FinishOAuthLogin mocks base method.
+func (m *MockServiceInterface) FinishOAuthLogin(ctx context.Context, providerName, state, code string) (*models.Session, *models.MFAPending, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishOAuthLogin", ctx, providerName, state, code)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(*models.MFAPending)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FinishOAuthLogin indicates an expected call of FinishOAuthLogin.
+func (mr *MockServiceInterfaceMockRecorder) FinishOAuthLogin(ctx, providerName, state, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishOAuthLogin", reflect.TypeOf((*MockServiceInterface)(nil).FinishOAuthLogin), ctx, providerName, state, code)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockServiceInterface) ForgotPassword(ctx context.Context, email string, client *models.ClientInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", ctx, email, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockServiceInterfaceMockRecorder) ForgotPassword(ctx, email, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockServiceInterface)(nil).ForgotPassword), ctx, email, client)
+}
+
+// GetAccessTokens mocks base method.
+func (m *MockServiceInterface) GetAccessTokens(ctx context.Context, userID string) ([]*models.AccessToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccessTokens", ctx, userID)
+	ret0, _ := ret[0].([]*models.AccessToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccessTokens indicates an expected call of GetAccessTokens.
+func (mr *MockServiceInterfaceMockRecorder) GetAccessTokens(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccessTokens", reflect.TypeOf((*MockServiceInterface)(nil).GetAccessTokens), ctx, userID)
 }
 
 // GetAllPosts mocks base method.
-func (m *MockServiceInterface) GetAllPosts(ctx context.Context) ([]*models.Post, error) {
+func (m *MockServiceInterface) GetAllPosts(ctx context.Context, userID string) ([]*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllPosts", ctx)
+	ret := m.ctrl.Call(m, "GetAllPosts", ctx, userID)
 	ret0, _ := ret[0].([]*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllPosts indicates an expected call of GetAllPosts.
-func (mr *MockServiceInterfaceMockRecorder) GetAllPosts(ctx interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) GetAllPosts(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockServiceInterface)(nil).GetAllPosts), ctx, userID)
+}
+
+// GetCommentReplies mocks base method.
+func (m *MockServiceInterface) GetCommentReplies(ctx context.Context, userID, postID, commentID string) (*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentReplies", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentReplies indicates an expected call of GetCommentReplies.
+func (mr *MockServiceInterfaceMockRecorder) GetCommentReplies(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentReplies", reflect.TypeOf((*MockServiceInterface)(nil).GetCommentReplies), ctx, userID, postID, commentID)
+}
+
+// GetCommentRevisions mocks base method.
+func (m *MockServiceInterface) GetCommentRevisions(ctx context.Context, userID, postID, commentID string) ([]*models.CommentRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentRevisions", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].([]*models.CommentRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentRevisions indicates an expected call of GetCommentRevisions.
+func (mr *MockServiceInterfaceMockRecorder) GetCommentRevisions(ctx, userID, postID, commentID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockServiceInterface)(nil).GetAllPosts), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentRevisions", reflect.TypeOf((*MockServiceInterface)(nil).GetCommentRevisions), ctx, userID, postID, commentID)
+}
+
+// GetDeletionJob mocks base method.
+func (m *MockServiceInterface) GetDeletionJob(ctx context.Context, jobID string) (*models.DeletionProgress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletionJob", ctx, jobID)
+	ret0, _ := ret[0].(*models.DeletionProgress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletionJob indicates an expected call of GetDeletionJob.
+func (mr *MockServiceInterfaceMockRecorder) GetDeletionJob(ctx, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletionJob", reflect.TypeOf((*MockServiceInterface)(nil).GetDeletionJob), ctx, jobID)
+}
+
+// GetExport mocks base method.
+func (m *MockServiceInterface) GetExport(ctx context.Context, userID, jobID string) (*models.ExportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExport", ctx, userID, jobID)
+	ret0, _ := ret[0].(*models.ExportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExport indicates an expected call of GetExport.
+func (mr *MockServiceInterfaceMockRecorder) GetExport(ctx, userID, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExport", reflect.TypeOf((*MockServiceInterface)(nil).GetExport), ctx, userID, jobID)
+}
+
+// GetExternalIdentities mocks base method.
+func (m *MockServiceInterface) GetExternalIdentities(ctx context.Context, userID string) ([]*models.ExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExternalIdentities", ctx, userID)
+	ret0, _ := ret[0].([]*models.ExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExternalIdentities indicates an expected call of GetExternalIdentities.
+func (mr *MockServiceInterfaceMockRecorder) GetExternalIdentities(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExternalIdentities", reflect.TypeOf((*MockServiceInterface)(nil).GetExternalIdentities), ctx, userID)
 }
 
 // GetPostByID mocks base method.
-func (m *MockServiceInterface) GetPostByID(ctx context.Context, postID string, increateVote bool) (*models.Post, error) {
+func (m *MockServiceInterface) GetPostByID(ctx context.Context, userID, postID string, increateVote bool) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostByID", ctx, postID, increateVote)
+	ret := m.ctrl.Call(m, "GetPostByID", ctx, userID, postID, increateVote)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostByID indicates an expected call of GetPostByID.
-func (mr *MockServiceInterfaceMockRecorder) GetPostByID(ctx, postID, increateVote interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) GetPostByID(ctx, userID, postID, increateVote interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockServiceInterface)(nil).GetPostByID), ctx, userID, postID, increateVote)
+}
+
+// GetPostDiff mocks base method.
+func (m *MockServiceInterface) GetPostDiff(ctx context.Context, postID string, from, to int) (*models.PostDiff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostDiff", ctx, postID, from, to)
+	ret0, _ := ret[0].(*models.PostDiff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostDiff indicates an expected call of GetPostDiff.
+func (mr *MockServiceInterfaceMockRecorder) GetPostDiff(ctx, postID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostDiff", reflect.TypeOf((*MockServiceInterface)(nil).GetPostDiff), ctx, postID, from, to)
+}
+
+// GetPostRevisions mocks base method.
+func (m *MockServiceInterface) GetPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostRevisions", ctx, postID)
+	ret0, _ := ret[0].([]*models.PostRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostRevisions indicates an expected call of GetPostRevisions.
+func (mr *MockServiceInterfaceMockRecorder) GetPostRevisions(ctx, postID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockServiceInterface)(nil).GetPostByID), ctx, postID, increateVote)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostRevisions", reflect.TypeOf((*MockServiceInterface)(nil).GetPostRevisions), ctx, postID)
 }
 
 // GetPostsByAuthor mocks base method.
-func (m *MockServiceInterface) GetPostsByAuthor(ctx context.Context, username string) ([]*models.Post, error) {
+func (m *MockServiceInterface) GetPostsByAuthor(ctx context.Context, userID, username string) ([]*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostsByAuthor", ctx, username)
+	ret := m.ctrl.Call(m, "GetPostsByAuthor", ctx, userID, username)
 	ret0, _ := ret[0].([]*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostsByAuthor indicates an expected call of GetPostsByAuthor.
-func (mr *MockServiceInterfaceMockRecorder) GetPostsByAuthor(ctx, username interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) GetPostsByAuthor(ctx, userID, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthor", reflect.TypeOf((*MockServiceInterface)(nil).GetPostsByAuthor), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthor", reflect.TypeOf((*MockServiceInterface)(nil).GetPostsByAuthor), ctx, userID, username)
 }
 
 // GetPostsByCategory mocks base method.
-func (m *MockServiceInterface) GetPostsByCategory(ctx context.Context, category string) ([]*models.Post, error) {
+func (m *MockServiceInterface) GetPostsByCategory(ctx context.Context, userID, category string) ([]*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostsByCategory", ctx, category)
+	ret := m.ctrl.Call(m, "GetPostsByCategory", ctx, userID, category)
 	ret0, _ := ret[0].([]*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostsByCategory indicates an expected call of GetPostsByCategory.
-func (mr *MockServiceInterfaceMockRecorder) GetPostsByCategory(ctx, category interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) GetPostsByCategory(ctx, userID, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByCategory", reflect.TypeOf((*MockServiceInterface)(nil).GetPostsByCategory), ctx, userID, category)
+}
+
+// GetProfile mocks base method.
+func (m *MockServiceInterface) GetProfile(ctx context.Context, username string) (*models.UserProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfile", ctx, username)
+	ret0, _ := ret[0].(*models.UserProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfile indicates an expected call of GetProfile.
+func (mr *MockServiceInterfaceMockRecorder) GetProfile(ctx, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByCategory", reflect.TypeOf((*MockServiceInterface)(nil).GetPostsByCategory), ctx, category)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockServiceInterface)(nil).GetProfile), ctx, username)
 }
 
 // GetUserFromDBByID mocks base method.
@@ -197,34 +542,326 @@ func (mr *MockServiceInterfaceMockRecorder) GetUserFromDBByUsername(ctx, usernam
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFromDBByUsername", reflect.TypeOf((*MockServiceInterface)(nil).GetUserFromDBByUsername), ctx, username)
 }
 
+// GetUserRoles mocks base method.
+func (m *MockServiceInterface) GetUserRoles(ctx context.Context, adminID, username string) ([]*models.RoleAssignment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRoles", ctx, adminID, username)
+	ret0, _ := ret[0].([]*models.RoleAssignment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRoles indicates an expected call of GetUserRoles.
+func (mr *MockServiceInterfaceMockRecorder) GetUserRoles(ctx, adminID, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRoles", reflect.TypeOf((*MockServiceInterface)(nil).GetUserRoles), ctx, adminID, username)
+}
+
+// GetUserSessions mocks base method.
+func (m *MockServiceInterface) GetUserSessions(ctx context.Context, userID, currentSessionID string) ([]*models.SessionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserSessions", ctx, userID, currentSessionID)
+	ret0, _ := ret[0].([]*models.SessionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserSessions indicates an expected call of GetUserSessions.
+func (mr *MockServiceInterfaceMockRecorder) GetUserSessions(ctx, userID, currentSessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserSessions", reflect.TypeOf((*MockServiceInterface)(nil).GetUserSessions), ctx, userID, currentSessionID)
+}
+
+// GrantRole mocks base method.
+func (m *MockServiceInterface) GrantRole(ctx context.Context, adminID, username, role, category string) (*models.RoleAssignment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", ctx, adminID, username, role, category)
+	ret0, _ := ret[0].(*models.RoleAssignment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockServiceInterfaceMockRecorder) GrantRole(ctx, adminID, username, role, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockServiceInterface)(nil).GrantRole), ctx, adminID, username, role, category)
+}
+
 // LoginUser mocks base method.
-func (m *MockServiceInterface) LoginUser(ctx context.Context, username string) (*models.Session, error) {
+func (m *MockServiceInterface) LoginUser(ctx context.Context, username, password string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoginUser", ctx, username)
+	ret := m.ctrl.Call(m, "LoginUser", ctx, username, password, client)
 	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(*models.MFAPending)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoginUser indicates an expected call of LoginUser.
+func (mr *MockServiceInterfaceMockRecorder) LoginUser(ctx, username, password, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockServiceInterface)(nil).LoginUser), ctx, username, password, client)
+}
+
+// PurgeDeletedContent mocks base method.
+func (m *MockServiceInterface) PurgeDeletedContent(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedContent", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeDeletedContent indicates an expected call of PurgeDeletedContent.
+func (mr *MockServiceInterfaceMockRecorder) PurgeDeletedContent(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedContent", reflect.TypeOf((*MockServiceInterface)(nil).PurgeDeletedContent), ctx)
+}
+
+// ReconcileCommentCounts mocks base method.
+func (m *MockServiceInterface) ReconcileCommentCounts(ctx context.Context, dryRun bool) ([]*models.CommentCountDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileCommentCounts", ctx, dryRun)
+	ret0, _ := ret[0].([]*models.CommentCountDrift)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// LoginUser indicates an expected call of LoginUser.
-func (mr *MockServiceInterfaceMockRecorder) LoginUser(ctx, username interface{}) *gomock.Call {
+// ReconcileCommentCounts indicates an expected call of ReconcileCommentCounts.
+func (mr *MockServiceInterfaceMockRecorder) ReconcileCommentCounts(ctx, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileCommentCounts", reflect.TypeOf((*MockServiceInterface)(nil).ReconcileCommentCounts), ctx, dryRun)
+}
+
+// RefreshSession mocks base method.
+func (m *MockServiceInterface) RefreshSession(ctx context.Context, refreshToken string) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSession", ctx, refreshToken)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSession indicates an expected call of RefreshSession.
+func (mr *MockServiceInterfaceMockRecorder) RefreshSession(ctx, refreshToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockServiceInterface)(nil).LoginUser), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockServiceInterface)(nil).RefreshSession), ctx, refreshToken)
 }
 
 // RemoveComment mocks base method.
-func (m *MockServiceInterface) RemoveComment(ctx context.Context, postID, commentID string) (*models.Post, error) {
+func (m *MockServiceInterface) RemoveComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RemoveComment", ctx, postID, commentID)
+	ret := m.ctrl.Call(m, "RemoveComment", ctx, userID, postID, commentID)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // RemoveComment indicates an expected call of RemoveComment.
-func (mr *MockServiceInterfaceMockRecorder) RemoveComment(ctx, postID, commentID interface{}) *gomock.Call {
+func (mr *MockServiceInterfaceMockRecorder) RemoveComment(ctx, userID, postID, commentID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveComment", reflect.TypeOf((*MockServiceInterface)(nil).RemoveComment), ctx, postID, commentID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveComment", reflect.TypeOf((*MockServiceInterface)(nil).RemoveComment), ctx, userID, postID, commentID)
+}
+
+// ReplyToComment mocks base method.
+func (m *MockServiceInterface) ReplyToComment(ctx context.Context, postID, parentID string, newComment models.Comment) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplyToComment", ctx, postID, parentID, newComment)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplyToComment indicates an expected call of ReplyToComment.
+func (mr *MockServiceInterfaceMockRecorder) ReplyToComment(ctx, postID, parentID, newComment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplyToComment", reflect.TypeOf((*MockServiceInterface)(nil).ReplyToComment), ctx, postID, parentID, newComment)
+}
+
+// RequestExport mocks base method.
+func (m *MockServiceInterface) RequestExport(ctx context.Context, userID string) (*models.ExportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestExport", ctx, userID)
+	ret0, _ := ret[0].(*models.ExportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestExport indicates an expected call of RequestExport.
+func (mr *MockServiceInterfaceMockRecorder) RequestExport(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestExport", reflect.TypeOf((*MockServiceInterface)(nil).RequestExport), ctx, userID)
+}
+
+// ResetPassword mocks base method.
+func (m *MockServiceInterface) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, resetToken, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockServiceInterfaceMockRecorder) ResetPassword(ctx, resetToken, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockServiceInterface)(nil).ResetPassword), ctx, resetToken, newPassword)
+}
+
+// RestoreComment mocks base method.
+func (m *MockServiceInterface) RestoreComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreComment", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreComment indicates an expected call of RestoreComment.
+func (mr *MockServiceInterfaceMockRecorder) RestoreComment(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreComment", reflect.TypeOf((*MockServiceInterface)(nil).RestoreComment), ctx, userID, postID, commentID)
+}
+
+// RestorePost mocks base method.
+func (m *MockServiceInterface) RestorePost(ctx context.Context, userID, postID string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestorePost", ctx, userID, postID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestorePost indicates an expected call of RestorePost.
+func (mr *MockServiceInterfaceMockRecorder) RestorePost(ctx, userID, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePost", reflect.TypeOf((*MockServiceInterface)(nil).RestorePost), ctx, userID, postID)
+}
+
+// ResumeDeletionJobs mocks base method.
+func (m *MockServiceInterface) ResumeDeletionJobs(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeDeletionJobs", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeDeletionJobs indicates an expected call of ResumeDeletionJobs.
+func (mr *MockServiceInterfaceMockRecorder) ResumeDeletionJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeDeletionJobs", reflect.TypeOf((*MockServiceInterface)(nil).ResumeDeletionJobs), ctx)
+}
+
+// ResumeExportJobs mocks base method.
+func (m *MockServiceInterface) ResumeExportJobs(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeExportJobs", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeExportJobs indicates an expected call of ResumeExportJobs.
+func (mr *MockServiceInterfaceMockRecorder) ResumeExportJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeExportJobs", reflect.TypeOf((*MockServiceInterface)(nil).ResumeExportJobs), ctx)
+}
+
+// RevokeAccessToken mocks base method.
+func (m *MockServiceInterface) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAccessToken", ctx, userID, tokenID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAccessToken indicates an expected call of RevokeAccessToken.
+func (mr *MockServiceInterfaceMockRecorder) RevokeAccessToken(ctx, userID, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAccessToken", reflect.TypeOf((*MockServiceInterface)(nil).RevokeAccessToken), ctx, userID, tokenID)
+}
+
+// RevokeRole mocks base method.
+func (m *MockServiceInterface) RevokeRole(ctx context.Context, adminID, roleID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", ctx, adminID, roleID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockServiceInterfaceMockRecorder) RevokeRole(ctx, adminID, roleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockServiceInterface)(nil).RevokeRole), ctx, adminID, roleID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockServiceInterface) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockServiceInterfaceMockRecorder) RevokeSession(ctx, userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockServiceInterface)(nil).RevokeSession), ctx, userID, sessionID)
+}
+
+// StartEmailLogin mocks base method.
+func (m *MockServiceInterface) StartEmailLogin(ctx context.Context, email string, client *models.ClientInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartEmailLogin", ctx, email, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartEmailLogin indicates an expected call of StartEmailLogin.
+func (mr *MockServiceInterfaceMockRecorder) StartEmailLogin(ctx, email, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartEmailLogin", reflect.TypeOf((*MockServiceInterface)(nil).StartEmailLogin), ctx, email, client)
+}
+
+// StartOAuthLogin mocks base method.
+func (m *MockServiceInterface) StartOAuthLogin(ctx context.Context, providerName, userID string, client *models.ClientInfo) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOAuthLogin", ctx, providerName, userID, client)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartOAuthLogin indicates an expected call of StartOAuthLogin.
+func (mr *MockServiceInterfaceMockRecorder) StartOAuthLogin(ctx, providerName, userID, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOAuthLogin", reflect.TypeOf((*MockServiceInterface)(nil).StartOAuthLogin), ctx, providerName, userID, client)
+}
+
+// UnlockLogin mocks base method.
+func (m *MockServiceInterface) UnlockLogin(ctx context.Context, adminID, username, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockLogin", ctx, adminID, username, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockLogin indicates an expected call of UnlockLogin.
+func (mr *MockServiceInterfaceMockRecorder) UnlockLogin(ctx, adminID, username, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockLogin", reflect.TypeOf((*MockServiceInterface)(nil).UnlockLogin), ctx, adminID, username, ip)
+}
+
+// UnvoteCommentWithID mocks base method.
+func (m *MockServiceInterface) UnvoteCommentWithID(ctx context.Context, postID, commentID, userID string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnvoteCommentWithID", ctx, postID, commentID, userID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnvoteCommentWithID indicates an expected call of UnvoteCommentWithID.
+func (mr *MockServiceInterfaceMockRecorder) UnvoteCommentWithID(ctx, postID, commentID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnvoteCommentWithID", reflect.TypeOf((*MockServiceInterface)(nil).UnvoteCommentWithID), ctx, postID, commentID, userID)
 }
 
 // UnvotePostWithID mocks base method.
@@ -242,6 +879,65 @@ func (mr *MockServiceInterfaceMockRecorder) UnvotePostWithID(ctx, postID, userID
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnvotePostWithID", reflect.TypeOf((*MockServiceInterface)(nil).UnvotePostWithID), ctx, postID, userID)
 }
 
+// UpdateProfile mocks base method.
+func (m *MockServiceInterface) UpdateProfile(ctx context.Context, userID string, update *models.ProfileUpdate) (*models.UserProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", ctx, userID, update)
+	ret0, _ := ret[0].(*models.UserProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockServiceInterfaceMockRecorder) UpdateProfile(ctx, userID, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockServiceInterface)(nil).UpdateProfile), ctx, userID, update)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockServiceInterface) VerifyEmail(ctx context.Context, plainToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, plainToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockServiceInterfaceMockRecorder) VerifyEmail(ctx, plainToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockServiceInterface)(nil).VerifyEmail), ctx, plainToken)
+}
+
+// VerifyMFA mocks base method.
+func (m *MockServiceInterface) VerifyMFA(ctx context.Context, mfaToken, code string) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyMFA", ctx, mfaToken, code)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyMFA indicates an expected call of VerifyMFA.
+func (mr *MockServiceInterfaceMockRecorder) VerifyMFA(ctx, mfaToken, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyMFA", reflect.TypeOf((*MockServiceInterface)(nil).VerifyMFA), ctx, mfaToken, code)
+}
+
+// VoteCommentWithID mocks base method.
+func (m *MockServiceInterface) VoteCommentWithID(ctx context.Context, postID, commentID string, newVote *models.Vote) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoteCommentWithID", ctx, postID, commentID, newVote)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoteCommentWithID indicates an expected call of VoteCommentWithID.
+func (mr *MockServiceInterfaceMockRecorder) VoteCommentWithID(ctx, postID, commentID, newVote interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoteCommentWithID", reflect.TypeOf((*MockServiceInterface)(nil).VoteCommentWithID), ctx, postID, commentID, newVote)
+}
+
 // VotePostWithID mocks base method.
 func (m *MockServiceInterface) VotePostWithID(ctx context.Context, postID string, newVote *models.Vote) (*models.Post, error) {
 	m.ctrl.T.Helper()
@@ -63,3 +63,41 @@ func (mr *MockTokenMakerMockRecorder) ExtractUserID(tokenString interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractUserID", reflect.TypeOf((*MockTokenMaker)(nil).ExtractUserID), tokenString)
 }
+
+// MockKeySetProvider is a mock of KeySetProvider interface.
+type MockKeySetProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockKeySetProviderMockRecorder
+}
+
+// MockKeySetProviderMockRecorder is the mock recorder for MockKeySetProvider.
+type MockKeySetProviderMockRecorder struct {
+	mock *MockKeySetProvider
+}
+
+// NewMockKeySetProvider creates a new mock instance.
+func NewMockKeySetProvider(ctrl *gomock.Controller) *MockKeySetProvider {
+	mock := &MockKeySetProvider{ctrl: ctrl}
+	mock.recorder = &MockKeySetProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKeySetProvider) EXPECT() *MockKeySetProviderMockRecorder {
+	return m.recorder
+}
+
+// JWKS mocks base method.
+func (m *MockKeySetProvider) JWKS() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockKeySetProviderMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockKeySetProvider)(nil).JWKS))
+}
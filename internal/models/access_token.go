@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Scopes of personal access tokens.
+const (
+	ScopeRead          = "read"
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsWrite = "comments:write"
+)
+
+var AccessTokenScopes = []string{ScopeRead, ScopePostsWrite, ScopeCommentsWrite}
+
+// AccessTokenPrefix tells personal access tokens apart from session JWTs.
+const AccessTokenPrefix = "rcpat_"
+
+// AccessToken is long-lived personal access token for scripts and bots.
+// Only hash of the token is stored, token itself is shown once on creation.
+type AccessToken struct {
+	ID         string         `json:"id" gorm:"primaryKey"`
+	UserID     string         `json:"-" gorm:"index"`
+	Name       string         `json:"name"`
+	TokenHash  string         `json:"-" gorm:"uniqueIndex"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	CreatedAt  time.Time      `json:"created"`
+	LastUsedAt *time.Time     `json:"lastUsed"`
+	ExpiresAt  *time.Time     `json:"expires"`
+}
+
+func NewAccessToken(userID, name, tokenHash string, scopes []string, expiresAt *time.Time) *AccessToken {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &AccessToken{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (t *AccessToken) Expired() bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now())
+}
+
+// CreatedAccessToken is returned once, when token is created.
+type CreatedAccessToken struct {
+	*AccessToken
+	Token string `json:"token"`
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
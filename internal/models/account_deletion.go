@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeletedUsername replaces author of posts and comments of deleted users.
+const DeletedUsername = "[deleted]"
+
+const (
+	// DeletionModeAnonymize keeps posts and comments with "[deleted]" author
+	DeletionModeAnonymize = "anonymize"
+	// DeletionModePurge removes posts (with all their comments), comments and votes
+	DeletionModePurge = "purge"
+)
+
+const (
+	DeletionStatusRunning = "running"
+	DeletionStatusDone    = "done"
+	DeletionStatusFailed  = "failed"
+)
+
+// DeletedAuthor is copied to posts and comments instead of deleted user.
+func DeletedAuthor() *User {
+	return &User{Username: DeletedUsername}
+}
+
+// DeletionJob rewrites content of deleted user in background. Authors are
+// copied into every post and comment, so it can take a while; progress is saved
+// after every batch and unfinished jobs are resumed after restart.
+// ID is random and is the only way to see the progress, the account is gone by then.
+type DeletionJob struct {
+	ID     string `json:"id" gorm:"primaryKey"`
+	UserID string `json:"-" gorm:"index"`
+	Mode   string `json:"mode"`
+	Status string `json:"status" gorm:"index"`
+
+	PostsProcessed    int64 `json:"postsProcessed"`
+	CommentsProcessed int64 `json:"commentsProcessed"`
+	// LastError isnt shown to client
+	LastError string `json:"-"`
+
+	CreatedAt  time.Time  `json:"created"`
+	UpdatedAt  time.Time  `json:"updated"`
+	FinishedAt *time.Time `json:"finished,omitempty"`
+}
+
+// DeletionProgress is the part of DeletionJob shown to anyone who has its id,
+// the owner cant log in anymore to prove the job is theirs.
+type DeletionProgress struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	FinishedAt *time.Time `json:"finished,omitempty"`
+}
+
+func (j *DeletionJob) Progress() *DeletionProgress {
+	return &DeletionProgress{
+		ID:         j.ID,
+		Status:     j.Status,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+func NewDeletionJob(userID, mode string) *DeletionJob {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &DeletionJob{
+		ID:        id,
+		UserID:    userID,
+		Mode:      mode,
+		Status:    DeletionStatusRunning,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func ValidateDeletionMode(mode string) bool {
+	return mode == DeletionModeAnonymize || mode == DeletionModePurge
+}
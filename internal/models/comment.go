@@ -7,13 +7,54 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	// PathSeparator joins ids in Comment.Path
+	PathSeparator = "/"
+	// DeletedBody is shown instead of body of deleted comment
+	DeletedBody = "[deleted]"
+)
+
 type Comment struct {
 	ID        string    `json:"id" bson:"_id,omitempty"`
 	Body      string    `json:"body" bson:"body"`
 	Author    *User     `json:"author" bson:"author"`
 	CreatedAt time.Time `json:"created" bson:"created"`
+	// Edited is time of the last recorded edit
+	Edited *time.Time `json:"edited,omitempty" bson:"edited,omitempty"`
+	// Revisions are previous bodies, oldest first. Only author and
+	// moderators can see them, so they arent loaded with threads.
+	Revisions []*CommentRevision `json:"-" bson:"revisions,omitempty"`
+
+	// counters of comment votes, the same as in post
+	Score            int `json:"score" bson:"score"`
+	Upvotes          int `json:"upvotes" bson:"upvotes"`
+	Downvotes        int `json:"downvotes" bson:"downvotes"`
+	UpvotePercentage int `json:"upvotePercentage" bson:"upvote_percantage"`
+	// Votes has only vote of the user who requested the comment
+	Votes []*Vote `json:"votes" bson:"-"`
 
 	RelatedPostID string `bson:"post_id"`
+
+	// ParentID is empty for comments on the post itself. Path is ids from
+	// top-level comment down to this one, so a thread is found by its prefix.
+	ParentID string `json:"parentID,omitempty" bson:"parent_id,omitempty"`
+	Path     string `json:"-" bson:"path"`
+	Depth    int    `json:"depth" bson:"depth"`
+
+	Replies []*Comment `json:"replies" bson:"-"`
+	// MoreReplies is cursor of replies cut by max depth
+	MoreReplies string `json:"moreReplies,omitempty" bson:"-"`
+
+	// deleted comment is shown as tombstone while it has replies
+	DeletedAt *time.Time `json:"-" bson:"deleted_at,omitempty"`
+	DeletedBy string     `json:"-" bson:"deleted_by,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty" bson:"-"`
+}
+
+// CommentRevision is body comment had since CreatedAt.
+type CommentRevision struct {
+	Body      string    `json:"body" bson:"body"`
+	CreatedAt time.Time `json:"created" bson:"created"`
 }
 
 func NewComment(text string, author *User, postID string) *Comment {
@@ -25,6 +66,36 @@ func NewComment(text string, author *User, postID string) *Comment {
 		Body:          text,
 		Author:        author,
 		CreatedAt:     time.Now(),
+		Votes:         []*Vote{},
 		RelatedPostID: postID,
+		Path:          id,
+		Replies:       []*Comment{},
+	}
+}
+
+// SetParent makes c a reply to parent.
+func (c *Comment) SetParent(parent *Comment) {
+	c.ParentID = parent.ID
+	c.Path = parent.Path + PathSeparator + c.ID
+	c.Depth = parent.Depth + 1
+}
+
+// Ancestors returns ids of comments above c, nearest first.
+func (c *Comment) Ancestors() []string {
+	ids := strings.Split(c.Path, PathSeparator)
+	ancestors := make([]string, 0, len(ids))
+	for i := len(ids) - 2; i >= 0; i-- {
+		ancestors = append(ancestors, ids[i])
 	}
+	return ancestors
+}
+
+// Tombstone hides content of deleted comment, it is shown only to keep
+// its replies in place.
+func (c *Comment) Tombstone() {
+	c.Deleted = true
+	c.Body = DeletedBody
+	c.Author = DeletedAuthor()
+	c.Edited = nil
+	c.Revisions = nil
 }
@@ -0,0 +1,27 @@
+package models
+
+// purposes of email tokens, token of one purpose cant be used for another
+const (
+	EmailTokenPasswordReset = "password_reset"
+	EmailTokenVerify        = "verify"
+	EmailTokenLogin         = "login"
+)
+
+// EmailToken is single-use token sent to user's email. It is kept in redis
+// until it is used or expires, plain token is only in the email.
+// Email is the address token was sent to.
+type EmailToken struct {
+	TokenHash string `json:"token_hash"`
+	Purpose   string `json:"purpose"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+}
+
+func NewEmailToken(tokenHash, purpose, userID, email string) *EmailToken {
+	return &EmailToken{
+		TokenHash: tokenHash,
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+	}
+}
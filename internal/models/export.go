@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ExportStatusRunning    = "running"
+	ExportStatusReady      = "ready"
+	ExportStatusDownloaded = "downloaded"
+	ExportStatusExpired    = "expired"
+	ExportStatusFailed     = "failed"
+)
+
+// ExportJob builds archive of user's personal data in background.
+// Ready archive can be downloaded once until ExpiresAt.
+type ExportJob struct {
+	ID     string `json:"id" gorm:"primaryKey"`
+	UserID string `json:"-" gorm:"index"`
+	Status string `json:"status" gorm:"index"`
+	// LastError isnt shown to client
+	LastError string `json:"-"`
+
+	CreatedAt time.Time  `json:"created"`
+	UpdatedAt time.Time  `json:"updated"`
+	ExpiresAt *time.Time `json:"expires,omitempty"`
+
+	// DownloadURL is signed link to ready archive, it isnt stored
+	DownloadURL string `json:"downloadURL,omitempty" gorm:"-"`
+}
+
+func NewExportJob(userID string) *ExportJob {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &ExportJob{
+		ID:        id,
+		UserID:    userID,
+		Status:    ExportStatusRunning,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links account at external provider (Keycloak, GitHub...)
+// to the user. One user can have many of them.
+type ExternalIdentity struct {
+	ID       string `json:"id" gorm:"primaryKey"`
+	UserID   string `json:"-" gorm:"index"`
+	Provider string `json:"provider" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	// Subject is user id at the provider
+	Subject   string    `json:"-" gorm:"uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created"`
+}
+
+func NewExternalIdentity(userID, provider, subject, email string) *ExternalIdentity {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &ExternalIdentity{
+		ID:        id,
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// OAuthState is kept between redirect to provider and callback.
+// If UserID is set, identity is linked to existing user instead of login.
+type OAuthState struct {
+	StateHash    string      `json:"state_hash"`
+	Provider     string      `json:"provider"`
+	CodeVerifier string      `json:"code_verifier"`
+	Nonce        string      `json:"nonce"`
+	UserID       string      `json:"user_id,omitempty"`
+	Client       *ClientInfo `json:"client"`
+}
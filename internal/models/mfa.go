@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPConfig is user's authenticator app. It is created on enrollment
+// and gets enabled only after user confirms he can generate codes.
+type TOTPConfig struct {
+	UserID  string `gorm:"primaryKey"`
+	Secret  string
+	Enabled bool
+	// time step of the last accepted code, the same code cant be used twice
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+func NewTOTPConfig(userID, secret string) *TOTPConfig {
+	return &TOTPConfig{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+}
+
+// RecoveryCode is single-use code for logging in without authenticator app.
+type RecoveryCode struct {
+	ID       string `gorm:"primaryKey"`
+	UserID   string `gorm:"index"`
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+func NewRecoveryCode(userID, codeHash string) *RecoveryCode {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &RecoveryCode{
+		ID:       id,
+		UserID:   userID,
+		CodeHash: codeHash,
+	}
+}
+
+// TOTPEnrollment is shown to user once, when 2FA is being set up.
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAChallenge is login that passed password check
+// and waits for the second factor.
+type MFAChallenge struct {
+	TokenHash string      `json:"token_hash"`
+	UserID    string      `json:"user_id"`
+	Client    *ClientInfo `json:"client"`
+}
+
+func NewMFAChallenge(tokenHash, userID string, client *ClientInfo) *MFAChallenge {
+	return &MFAChallenge{
+		TokenHash: tokenHash,
+		UserID:    userID,
+		Client:    client,
+	}
+}
+
+// MFAPending is returned by login instead of Session
+// when user has 2FA enabled.
+type MFAPending struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+func NewMFAPending(mfaToken string) *MFAPending {
+	return &MFAPending{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	}
+}
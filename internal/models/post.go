@@ -21,28 +21,45 @@ type Post struct {
 	ID        string    `json:"id" bson:"_id,omitempty"`
 	Author    *User     `json:"author" bson:"author"`
 	CreatedAt time.Time `json:"created" bson:"created"`
+	// Edited is time of the last edit, previous content is in revisions
+	Edited *time.Time `json:"edited,omitempty" bson:"edited,omitempty"`
 
 	Title    string `json:"title" bson:"title"`
 	Category string `json:"category" bson:"category"`
 	Text     string `json:"text,omitempty" bson:"text,omitempty"`
 	URL      string `json:"url,omitempty" bson:"url,omitempty"`
 
-	Views            int     `json:"views" bson:"views"`
-	Score            int     `json:"score" bson:"score"`
-	Type             string  `json:"type" bson:"type"`
-	Votes            []*Vote `json:"votes" votes:"votes"`
-	UpvotePercentage int     `json:"upvotePercentage" bson:"upvote_percantage"`
+	Views int    `json:"views" bson:"views"`
+	Type  string `json:"type" bson:"type"`
 
+	// counters of votes collection, score and percentage are derived from them
+	Score            int `json:"score" bson:"score"`
+	Upvotes          int `json:"upvotes" bson:"upvotes"`
+	Downvotes        int `json:"downvotes" bson:"downvotes"`
+	UpvotePercentage int `json:"upvotePercentage" bson:"upvote_percantage"`
+	// Votes has only vote of the user who requested the post
+	Votes []*Vote `json:"votes" bson:"-"`
+
+	// CommentCount counts comments which arent deleted
 	CommentCount int        `json:"-" bson:"comment_count"`
 	Comments     []*Comment `json:"comments" bson:"-"`
+
+	// Version grows with every change, post is saved only if it wasnt
+	// changed since it was read
+	Version int64 `json:"-" bson:"version"`
+
+	// deleted post is hidden until it is restored or purged
+	DeletedAt *time.Time `json:"-" bson:"deleted_at,omitempty"`
+	DeletedBy string     `json:"-" bson:"deleted_by,omitempty"`
 }
 
 func NewPost(user *User, category, title, postType, postText, postURL string) *Post {
-	newVote := NewVote(user.ID, 1)
-
 	idWithHyphens := uuid.New().String()
 	id := strings.ReplaceAll(idWithHyphens, "-", "")
 
+	newVote := NewVote(user.ID, 1)
+	newVote.PostID = id
+
 	return &Post{
 		ID:        id,
 		Author:    user,
@@ -54,11 +71,13 @@ func NewPost(user *User, category, title, postType, postText, postURL string) *P
 		Text: postText,
 		URL:  postURL,
 
-		Views:            0,
+		Views: 0,
+		Type:  postType,
+
 		Score:            1,
-		Type:             postType,
-		Votes:            []*Vote{newVote},
+		Upvotes:          1,
 		UpvotePercentage: 100,
+		Votes:            []*Vote{newVote},
 
 		Comments:     []*Comment{},
 		CommentCount: 0,
@@ -74,15 +93,35 @@ func (p *Post) GetMarshal() ([]byte, error) {
 	return data, nil
 }
 
-// ValidatePost checks post type and post category
+// MaxPostTextLength is max size of post text in bytes
+const MaxPostTextLength = 40000
+
+// ValidatePost checks post type, post category and text length
 func ValidatePost(newPost Post) bool {
-	return slices.Contains(postCategories, newPost.Category) && slices.Contains(postTypes, newPost.Type)
+	return slices.Contains(postCategories, newPost.Category) && slices.Contains(postTypes, newPost.Type) &&
+		len(newPost.Text) <= MaxPostTextLength
 }
 
 func GetCategories() []string {
 	return postCategories
 }
 
+// SetVoteCounters sets counters and recomputes score and upvote percentage.
+func (p *Post) SetVoteCounters(upvotes, downvotes int) {
+	p.Upvotes = upvotes
+	p.Downvotes = downvotes
+	p.Score = upvotes - downvotes
+	p.UpvotePercentage = UpvotePercentage(upvotes, downvotes)
+}
+
+// UpvotePercentage is rounded down, so 29 of 100 upvotes isnt 28.999..%
+func UpvotePercentage(upvotes, downvotes int) int {
+	if upvotes+downvotes == 0 {
+		return 0
+	}
+	return upvotes * 100 / (upvotes + downvotes)
+}
+
 func AddNilComments(posts ...*Post) {
 	for i := range posts {
 		posts[i].Comments = make([]*Comment, posts[i].CommentCount)
@@ -91,3 +130,13 @@ func AddNilComments(posts ...*Post) {
 		}
 	}
 }
+
+// CommentCountDrift is post whose stored comment count differs from
+// number of its comments.
+type CommentCountDrift struct {
+	PostID string
+	Stored int
+	Actual int
+	// Fixed is false on dry run or if count was changed during reconciliation
+	Fixed bool
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/myacey/redditclone/internal/textdiff"
+)
+
+// PostEdit has fields author changes, nil ones are left as they are.
+type PostEdit struct {
+	Title *string `json:"title"`
+	Type  *string `json:"type"`
+	Text  *string `json:"text"`
+	URL   *string `json:"url"`
+}
+
+// PostRevision is content post had since CreatedAt.
+type PostRevision struct {
+	ID     string `json:"-" bson:"_id,omitempty"`
+	PostID string `json:"-" bson:"post_id"`
+	// Version is version of the post which was replaced by edit,
+	// revisions are ordered by it
+	Version int64 `json:"-" bson:"version"`
+	// Number counts revisions of the post from 1, current content is the last one
+	Number int `json:"number" bson:"-"`
+
+	Title string `json:"title" bson:"title"`
+	Type  string `json:"type" bson:"type"`
+	Text  string `json:"text,omitempty" bson:"text,omitempty"`
+	URL   string `json:"url,omitempty" bson:"url,omitempty"`
+
+	CreatedAt time.Time `json:"created" bson:"created"`
+}
+
+// NewPostRevision saves current content of the post.
+func NewPostRevision(post *Post) *PostRevision {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	created := post.CreatedAt
+	if post.Edited != nil {
+		created = *post.Edited
+	}
+
+	return &PostRevision{
+		ID:        id,
+		PostID:    post.ID,
+		Version:   post.Version,
+		Title:     post.Title,
+		Type:      post.Type,
+		Text:      post.Text,
+		URL:       post.URL,
+		CreatedAt: created,
+	}
+}
+
+// PostDiff is line-level difference of fields between two revisions.
+type PostDiff struct {
+	From  int             `json:"from"`
+	To    int             `json:"to"`
+	Title []textdiff.Line `json:"title"`
+	Type  []textdiff.Line `json:"type"`
+	Text  []textdiff.Line `json:"text"`
+	URL   []textdiff.Line `json:"url"`
+}
+
+func NewPostDiff(from, to *PostRevision) *PostDiff {
+	return &PostDiff{
+		From:  from.Number,
+		To:    to.Number,
+		Title: textdiff.Lines(from.Title, to.Title),
+		Type:  textdiff.Lines(from.Type, to.Type),
+		Text:  textdiff.Lines(from.Text, to.Text),
+		URL:   textdiff.Lines(from.URL, to.URL),
+	}
+}
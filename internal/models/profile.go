@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 500
+)
+
+// Profile is public part of the user kept in postgres.
+// CreatedAt is join date of the user.
+type Profile struct {
+	UserID      string `json:"-" gorm:"primaryKey"`
+	DisplayName string `json:"displayName"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatarURL"`
+
+	// karma is sum of votes other users gave to user's posts and comments
+	PostKarma    int `json:"postKarma"`
+	CommentKarma int `json:"commentKarma"`
+
+	CreatedAt time.Time `json:"joined"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func NewProfile(userID string) *Profile {
+	return &Profile{
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// ProfileUpdate has only fields user can edit, nil ones stay as they are.
+type ProfileUpdate struct {
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatarURL"`
+}
+
+// UserProfile is profile with counters shown to everyone.
+type UserProfile struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	*Profile
+
+	Karma        int `json:"karma"`
+	PostCount    int `json:"postCount"`
+	CommentCount int `json:"commentCount"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Permission is an action role allows.
+type Permission string
+
+const (
+	// PermissionDeleteContent allows deleting posts and comments of other users
+	PermissionDeleteContent Permission = "content:delete"
+	// PermissionManageRoles allows granting and revoking roles
+	PermissionManageRoles Permission = "roles:manage"
+	// PermissionManageUsers allows unlocking locked out accounts
+	PermissionManageUsers Permission = "users:manage"
+	// PermissionViewHistory allows viewing edit history of content of other users
+	PermissionViewHistory Permission = "content:history"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:     {PermissionDeleteContent, PermissionViewHistory, PermissionManageRoles, PermissionManageUsers},
+	RoleModerator: {PermissionDeleteContent, PermissionViewHistory},
+}
+
+// RoleAssignment gives role to the user. Moderators are bound to category,
+// empty Category means role works in every category (always so for admins).
+type RoleAssignment struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"userID" gorm:"uniqueIndex:idx_role_user_role_category"`
+	Role      string    `json:"role" gorm:"uniqueIndex:idx_role_user_role_category"`
+	Category  string    `json:"category,omitempty" gorm:"uniqueIndex:idx_role_user_role_category"`
+	GrantedBy string    `json:"grantedBy,omitempty"`
+	CreatedAt time.Time `json:"created"`
+}
+
+func NewRoleAssignment(userID, role, category, grantedBy string) *RoleAssignment {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	return &RoleAssignment{
+		ID:        id,
+		UserID:    userID,
+		Role:      role,
+		Category:  category,
+		GrantedBy: grantedBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Allows reports if assignment gives permission in category.
+func (r *RoleAssignment) Allows(permission Permission, category string) bool {
+	if !slices.Contains(rolePermissions[r.Role], permission) {
+		return false
+	}
+
+	return r.Category == "" || r.Category == category
+}
+
+// ValidateRole checks role name and its category:
+// admin is global, moderator needs existing category.
+func ValidateRole(role, category string) bool {
+	switch role {
+	case RoleAdmin:
+		return category == ""
+	case RoleModerator:
+		return slices.Contains(postCategories, category)
+	default:
+		return false
+	}
+}
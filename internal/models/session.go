@@ -3,18 +3,26 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 var (
 	ErrCantMarshal = errors.New("cant marshal token")
 )
 
+// Session is what user gets after register/login.
+// Token is short-lived access token, RefreshToken is used
+// to get new pair at /api/token/refresh.
 type Session struct {
-	Token string `json:"token" redis:"token"`
+	Token        string `json:"token" redis:"token"`
+	RefreshToken string `json:"refreshToken,omitempty" redis:"refresh_token"`
 }
 
 func NewSession(token string) *Session {
-	return &Session{token}
+	return &Session{Token: token}
 }
 
 func (s *Session) GetMarshal() ([]byte, error) {
@@ -25,3 +33,65 @@ func (s *Session) GetMarshal() ([]byte, error) {
 
 	return data, nil
 }
+
+// ClientInfo describes device the user logs in from.
+type ClientInfo struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+// SessionInfo is a single logged in device of the user.
+// User can have many of them at the same time.
+type SessionInfo struct {
+	ID               string `json:"id"`
+	UserID           string `json:"-"`
+	TokenHash        string `json:"-"`
+	RefreshTokenHash string `json:"-"`
+
+	Device    string    `json:"device"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"created"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	Current bool `json:"current"`
+}
+
+func NewSessionInfo(userID, tokenHash string, client *ClientInfo) *SessionInfo {
+	idWithHyphens := uuid.New().String()
+	id := strings.ReplaceAll(idWithHyphens, "-", "")
+
+	now := time.Now()
+	info := &SessionInfo{
+		ID:        id,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		LastSeen:  now,
+	}
+	if client != nil {
+		info.Device = client.Device
+		info.IP = client.IP
+		info.UserAgent = client.UserAgent
+	}
+
+	return info
+}
+
+// RefreshToken links refresh token to the session it was issued for.
+// All refresh tokens of one session are a family: reusing any
+// already rotated token revokes the whole session.
+type RefreshToken struct {
+	TokenHash string `json:"token_hash"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+func NewRefreshToken(tokenHash string, session *SessionInfo) *RefreshToken {
+	return &RefreshToken{
+		TokenHash: tokenHash,
+		UserID:    session.UserID,
+		SessionID: session.ID,
+	}
+}
@@ -4,20 +4,31 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/myacey/redditclone/internal/validation"
 )
 
 type User struct {
 	ID       string `json:"id" gorm:"primaryKey"`
 	Username string `json:"username" gorm:"unique"`
-	Password string `json:"-"`
+	// UsernameKey makes usernames unique regardless of case
+	// and look-alike letters, see validation.UsernameKey
+	UsernameKey string `json:"-" bson:"-" gorm:"uniqueIndex:idx_users_username_key,where:username_key <> ''"`
+	// Password is hash, it isnt copied to posts and comments either
+	Password string `json:"-" bson:"-"`
+	// Email is private, it isnt copied to posts and tokens.
+	// It is optional, so uniqueness is checked only for non-empty ones.
+	Email         string `json:"-" bson:"-" gorm:"uniqueIndex:idx_users_email,where:email <> ''"`
+	EmailVerified bool   `json:"-" bson:"-"`
 }
 
 func NewUser(username, password string) *User {
 	idWithHyphens := uuid.New().String()
 	id := strings.ReplaceAll(idWithHyphens, "-", "")
 	return &User{
-		ID:       id,
-		Username: username,
-		Password: password,
+		ID:          id,
+		Username:    username,
+		UsernameKey: validation.UsernameKey(username),
+		Password:    password,
 	}
 }
@@ -1,10 +1,25 @@
 package models
 
+// Vote is stored in its own collection, user has at most one vote per post
+// and one per comment. Comment votes keep post of the comment too.
 type Vote struct {
-	UserID string `json:"user"`
-	Vote   int8   `json:"vote"`
+	PostID    string `json:"-" bson:"post_id"`
+	CommentID string `json:"-" bson:"comment_id,omitempty"`
+	UserID    string `json:"user" bson:"user_id"`
+	Vote      int8   `json:"vote" bson:"vote"`
 }
 
 func NewVote(userID string, vote int8) *Vote {
-	return &Vote{userID, vote}
+	return &Vote{UserID: userID, Vote: vote}
+}
+
+// VoteCounters returns how vote changes number of upvotes and downvotes.
+func VoteCounters(vote int8) (upvotes, downvotes int) {
+	switch vote {
+	case 1:
+		return 1, 0
+	case -1:
+		return 0, 1
+	}
+	return 0, 0
 }
@@ -0,0 +1,66 @@
+// Package oidc implements login through external identity providers
+// with authorization code flow and PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrExchangeFailed    = errors.New("cant exchange authorization code")
+	ErrInvalidIDToken    = errors.New("invalid id token")
+	ErrDiscoveryFailed   = errors.New("cant fetch provider configuration")
+	ErrUserInfoFailed    = errors.New("cant fetch user info")
+	ErrUnknownSigningKey = errors.New("unknown id token signing key")
+)
+
+// Identity is user's account at external provider.
+type Identity struct {
+	Provider string
+	// Subject is stable user id at provider, unlike email or username
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// Provider is external identity provider.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where user is redirected to log in
+	AuthCodeURL(state, codeChallenge, nonce string) string
+	// Exchange trades authorization code for user's identity
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewPKCE returns code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns random value for state or nonce parameter.
+func NewState() (string, error) {
+	return randomString(24)
+}
@@ -0,0 +1,240 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/oidc"
+	"github.com/myacey/redditclone/internal/oidc/oidctest"
+)
+
+var stubUser = oidctest.User{
+	Subject:           "subject-1",
+	Email:             "user@example.com",
+	PreferredUsername: "stubuser",
+	Name:              "Stub User",
+}
+
+func newProvider(t *testing.T, server *oidctest.Server) oidc.Provider {
+	provider, err := oidc.NewOIDCProvider(context.Background(), oidc.Config{
+		Name:         "stub",
+		IssuerURL:    server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  "http://localhost:8080/api/oauth/stub/callback",
+		Scopes:       []string{"email", "profile"},
+	}, server.Client())
+	require.NoError(t, err)
+	return provider
+}
+
+func TestOIDCProvider(t *testing.T) {
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+
+	provider := newProvider(t, server)
+
+	t.Run("Success", func(t *testing.T) {
+		verifier, challenge, err := oidc.NewPKCE()
+		require.NoError(t, err)
+
+		authURL := provider.AuthCodeURL("state", challenge, "nonce")
+		parsed, err := url.Parse(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+		assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+
+		code, state, err := server.Authorize(authURL, stubUser)
+		require.NoError(t, err)
+		assert.Equal(t, "state", state)
+
+		identity, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+		require.NoError(t, err)
+		assert.Equal(t, &oidc.Identity{
+			Provider:          "stub",
+			Subject:           stubUser.Subject,
+			Email:             stubUser.Email,
+			EmailVerified:     true,
+			PreferredUsername: stubUser.PreferredUsername,
+			Name:              stubUser.Name,
+		}, identity)
+
+		// code is single-use
+		_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+		assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+	})
+
+	t.Run("Wrong code verifier", func(t *testing.T) {
+		_, challenge, err := oidc.NewPKCE()
+		require.NoError(t, err)
+		otherVerifier, _, err := oidc.NewPKCE()
+		require.NoError(t, err)
+
+		code, _, err := server.Authorize(provider.AuthCodeURL("state", challenge, "nonce"), stubUser)
+		require.NoError(t, err)
+
+		_, err = provider.Exchange(context.Background(), code, otherVerifier, "nonce")
+		assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+	})
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		verifier, challenge, err := oidc.NewPKCE()
+		require.NoError(t, err)
+
+		code, _, err := server.Authorize(provider.AuthCodeURL("state", challenge, "nonce"), stubUser)
+		require.NoError(t, err)
+
+		_, err = provider.Exchange(context.Background(), code, verifier, "other nonce")
+		assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+	})
+}
+
+func TestOIDCProviderIDTokenValidation(t *testing.T) {
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+
+	now := time.Now()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   server.URL,
+			"aud":   []string{"other", "client"},
+			"sub":   "subject",
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		expErr error
+	}{
+		{
+			name:   "Audience array",
+			modify: func(map[string]interface{}) {},
+			expErr: nil,
+		},
+		{
+			name:   "Wrong audience",
+			modify: func(c map[string]interface{}) { c["aud"] = "other" },
+			expErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "Wrong issuer",
+			modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+			expErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "Expired",
+			modify: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() },
+			expErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:   "No subject",
+			modify: func(c map[string]interface{}) { delete(c, "sub") },
+			expErr: oidc.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := valid()
+			tc.modify(claims)
+			idToken := server.IDToken(claims)
+
+			// token endpoint returning prepared id token
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/.well-known/openid-configuration":
+					_ = json.NewEncoder(w).Encode(map[string]string{
+						"issuer":         server.URL,
+						"token_endpoint": "http://" + r.Host + "/token",
+						"jwks_uri":       server.URL + "/jwks",
+					})
+				case "/token":
+					_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+				}
+			}))
+			defer tokenServer.Close()
+
+			provider, err := oidc.NewOIDCProvider(context.Background(), oidc.Config{
+				Name:      "stub",
+				IssuerURL: server.URL,
+				ClientID:  "client",
+			}, &http.Client{Transport: rewriteHost(tokenServer.URL, server.URL)})
+			require.NoError(t, err)
+
+			_, err = provider.Exchange(context.Background(), "code", "verifier", "nonce")
+			if tc.expErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expErr)
+			}
+		})
+	}
+}
+
+// rewriteHost sends discovery and token requests to fake server,
+// keys are still fetched from the real stub.
+func rewriteHost(fake, real string) http.RoundTripper {
+	return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/jwks" {
+			fakeURL, _ := url.Parse(fake)
+			r.URL.Host = fakeURL.Host
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestGitHubProvider(t *testing.T) {
+	var gotVerifier string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			_ = r.ParseForm()
+			gotVerifier = r.PostForm.Get("code_verifier")
+			if r.PostForm.Get("code") != "code" {
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_token"})
+		case "/user":
+			assert.Equal(t, "Bearer gho_token", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "octocat", "name": "Octo Cat"})
+		}
+	}))
+	defer server.Close()
+
+	provider := oidc.NewGitHubProvider(oidc.GitHubConfig{
+		ClientID: "client",
+		TokenURL: server.URL + "/login/oauth/access_token",
+		APIURL:   server.URL,
+	}, server.Client())
+
+	identity, err := provider.Exchange(context.Background(), "code", "verifier", "")
+	require.NoError(t, err)
+	assert.Equal(t, "verifier", gotVerifier)
+	assert.Equal(t, &oidc.Identity{
+		Provider:          "github",
+		Subject:           "42",
+		PreferredUsername: "octocat",
+		Name:              "Octo Cat",
+	}, identity)
+
+	_, err = provider.Exchange(context.Background(), "wrong", "verifier", "")
+	assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+}
@@ -0,0 +1,184 @@
+// Package oidctest provides stub OpenID Connect provider for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/myacey/redditclone/internal/oidc"
+)
+
+const keyID = "test-key"
+
+// User is account at stub provider.
+type User struct {
+	Subject           string
+	Email             string
+	PreferredUsername string
+	Name              string
+}
+
+type authorization struct {
+	user          User
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+}
+
+// Server is minimal OIDC provider: discovery, token endpoint with PKCE and JWKS.
+// Instead of login page tests call Authorize to get authorization code.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+func NewServer(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        map[string]*authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Authorize plays the user approving login at provider. It takes parameters
+// from authorization URL and returns code, like provider's redirect would.
+func (s *Server) Authorize(authURL string, user User) (code, state string, err error) {
+	req, err := http.NewRequest(http.MethodGet, authURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	q := req.URL.Query()
+
+	code, err = oidc.NewState()
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	s.codes[code] = &authorization{
+		user:          user,
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		codeChallenge: q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+	}
+	s.mu.Unlock()
+
+	return code, q.Get("state"), nil
+}
+
+// IDToken signs ID token with server's key, tests use it for custom claims.
+func (s *Server) IDToken(claims jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = keyID
+	signed, err := tok.SignedString(s.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	s.mu.Lock()
+	auth, ok := s.codes[r.PostForm.Get("code")]
+	// codes are single-use
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok,
+		r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("client_id") != s.ClientID || auth.clientID != s.ClientID,
+		r.PostForm.Get("client_secret") != s.ClientSecret,
+		r.PostForm.Get("redirect_uri") != auth.redirectURI,
+		oidc.PKCEChallenge(r.PostForm.Get("code_verifier")) != auth.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := s.IDToken(jwt.MapClaims{
+		"iss":                s.URL,
+		"aud":                s.ClientID,
+		"sub":                auth.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              auth.nonce,
+		"email":              auth.user.Email,
+		"email_verified":     auth.user.Email != "",
+		"preferred_username": auth.user.PreferredUsername,
+		"name":               auth.user.Name,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
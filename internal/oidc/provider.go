@@ -0,0 +1,260 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Config of OpenID Connect provider, e.g. Keycloak or Google.
+type Config struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// openid is always requested
+	Scopes []string
+}
+
+// discovery is part of /.well-known/openid-configuration we use.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// OIDCProvider verifies ID tokens signed with provider's RSA keys.
+type OIDCProvider struct {
+	config     Config
+	discovery  discovery
+	httpClient *http.Client
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+}
+
+// NewOIDCProvider fetches provider configuration from issuer.
+func NewOIDCProvider(ctx context.Context, config Config, httpClient *http.Client) (Provider, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &OIDCProvider{
+		config:     config,
+		httpClient: httpClient,
+		keys:       map[string]*rsa.PublicKey{},
+	}
+
+	wellKnown := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.discovery); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	// issuer in tokens must be exactly the one we trust
+	if p.discovery.Issuer != strings.TrimSuffix(config.IssuerURL, "/") && p.discovery.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", ErrDiscoveryFailed, p.discovery.Issuer)
+	}
+
+	return p, nil
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *OIDCProvider) AuthCodeURL(state, codeChallenge, nonce string) string {
+	scopes := append([]string{"openid"}, p.config.Scopes...)
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientID)
+	v.Set("redirect_uri", p.config.RedirectURL)
+	v.Set("scope", strings.Join(scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+
+	return p.discovery.AuthorizationEndpoint + "?" + v.Encode()
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	err := postForm(ctx, p.httpClient, p.discovery.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}, &tokenResp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id token in response", ErrExchangeFailed)
+	}
+
+	return p.verifyIDToken(ctx, tokenResp.IDToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(tok *jwt.Token) (interface{}, error) {
+		if tok.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, ErrInvalidIDToken
+		}
+		kid, _ := tok.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return v
+	}
+	if str("iss") != p.discovery.Issuer || !hasAudience(claims["aud"], p.config.ClientID) {
+		return nil, fmt.Errorf("%w: wrong issuer or audience", ErrInvalidIDToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: no expiration", ErrInvalidIDToken)
+	}
+	if str("nonce") != nonce || str("sub") == "" {
+		return nil, fmt.Errorf("%w: wrong nonce or empty subject", ErrInvalidIDToken)
+	}
+	emailVerified, _ := claims["email_verified"].(bool)
+
+	return &Identity{
+		Provider:          p.config.Name,
+		Subject:           str("sub"),
+		Email:             str("email"),
+		EmailVerified:     emailVerified,
+		PreferredUsername: str("preferred_username"),
+		Name:              str("name"),
+	}, nil
+}
+
+// hasAudience checks aud claim, it can be string or array of strings.
+func hasAudience(aud interface{}, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []interface{}:
+		for _, v := range a {
+			if s, ok := v.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publicKey returns key by id, keys are refetched if provider rotated them.
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, dst interface{}) error {
+	return getJSON(ctx, p.httpClient, url, "", dst)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url, accessToken string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	return doJSON(client, req, dst)
+}
+
+func postForm(ctx context.Context, client *http.Client, url string, form url.Values, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	return doJSON(client, req, dst)
+}
+
+func doJSON(client *http.Client, req *http.Request, dst interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
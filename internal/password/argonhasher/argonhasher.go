@@ -0,0 +1,122 @@
+package argonhasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/myacey/redditclone/internal/password"
+)
+
+const hashPrefix = "$argon2id$"
+
+// Params are argon2id cost parameters.
+// Changing them makes old hashes get rehashed on next login.
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follows the OWASP recommendation for argon2id.
+func DefaultParams() Params {
+	return Params{
+		Memory:      64 * 1024,
+		Iterations:  1,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type Argon2idHasher struct {
+	params Params
+}
+
+func NewArgon2idHasher(params Params) password.Hasher {
+	return &Argon2idHasher{params: params}
+}
+
+// Hash returns PHC string: $argon2id$v=19$m=...,t=...,p=...$salt$hash
+func (h *Argon2idHasher) Hash(plain string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", password.ErrCantHashPassword
+	}
+
+	key := argon2.IDKey([]byte(plain), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		hashPrefix,
+		argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(plain, encoded string) (bool, error) {
+	if !strings.HasPrefix(encoded, hashPrefix) {
+		// legacy row with plaintext password, migrate it on success
+		if subtle.ConstantTimeCompare([]byte(plain), []byte(encoded)) != 1 {
+			return false, password.ErrMismatch
+		}
+		return true, nil
+	}
+
+	params, salt, key, err := decodeHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(plain), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return false, password.ErrMismatch
+	}
+
+	needsRehash := params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		params.SaltLength != h.params.SaltLength ||
+		params.KeyLength != h.params.KeyLength
+
+	return needsRehash, nil
+}
+
+func decodeHash(encoded string) (*Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, password.ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, password.ErrInvalidHash
+	}
+
+	params := &Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, password.ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, password.ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, password.ErrInvalidHash
+	}
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,98 @@
+package argonhasher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/myacey/redditclone/internal/password"
+	"github.com/myacey/redditclone/internal/password/argonhasher"
+)
+
+// cheap params to keep tests fast
+var testParams = argonhasher.Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHashAndVerify(t *testing.T) {
+	hasher := argonhasher.NewArgon2idHasher(testParams)
+
+	encoded, err := hasher.Hash("qwerty123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	other, err := hasher.Hash("qwerty123")
+	assert.NoError(t, err)
+	assert.NotEqual(t, encoded, other, "salt should be random")
+
+	testCases := []struct {
+		name           string
+		plain          string
+		encoded        string
+		expNeedsRehash bool
+		expErr         error
+	}{
+		{
+			name:           "Correct password",
+			plain:          "qwerty123",
+			encoded:        encoded,
+			expNeedsRehash: false,
+			expErr:         nil,
+		},
+		{
+			name:           "Wrong password",
+			plain:          "qwerty1234",
+			encoded:        encoded,
+			expNeedsRehash: false,
+			expErr:         password.ErrMismatch,
+		},
+		{
+			name:           "Legacy plaintext",
+			plain:          "qwerty123",
+			encoded:        "qwerty123",
+			expNeedsRehash: true,
+			expErr:         nil,
+		},
+		{
+			name:           "Legacy plaintext mismatch",
+			plain:          "qwerty",
+			encoded:        "qwerty123",
+			expNeedsRehash: false,
+			expErr:         password.ErrMismatch,
+		},
+		{
+			name:           "Broken hash",
+			plain:          "qwerty123",
+			encoded:        "$argon2id$v=19$broken",
+			expNeedsRehash: false,
+			expErr:         password.ErrInvalidHash,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			needsRehash, err := hasher.Verify(tc.plain, tc.encoded)
+			assert.Equal(t, tc.expNeedsRehash, needsRehash)
+			assert.Equal(t, tc.expErr, err)
+		})
+	}
+}
+
+func TestVerifyOutdatedParams(t *testing.T) {
+	oldHasher := argonhasher.NewArgon2idHasher(testParams)
+	encoded, err := oldHasher.Hash("qwerty123")
+	assert.NoError(t, err)
+
+	newParams := testParams
+	newParams.Iterations = 2
+	newHasher := argonhasher.NewArgon2idHasher(newParams)
+
+	needsRehash, err := newHasher.Verify("qwerty123", encoded)
+	assert.NoError(t, err)
+	assert.True(t, needsRehash)
+}
@@ -0,0 +1,19 @@
+package password
+
+import "errors"
+
+var (
+	ErrCantHashPassword = errors.New("cant hash password")
+	ErrInvalidHash      = errors.New("invalid password hash")
+	ErrMismatch         = errors.New("password mismatch")
+)
+
+// Hasher hashes passwords before they get to the DB
+// and verifies login attempts against stored hashes.
+type Hasher interface {
+	Hash(plain string) (string, error)
+	// Verify returns ErrMismatch if plain doesnt match encoded.
+	// needsRehash is true when encoded was made with outdated
+	// parameters (or is a legacy plaintext value) and should be replaced.
+	Verify(plain, encoded string) (needsRehash bool, err error)
+}
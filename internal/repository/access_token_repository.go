@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var ErrAccessTokenDontExists = errors.New("access token dont exist in db")
+
+type AccessTokenRepository interface {
+	CreateAccessToken(ctx context.Context, accessToken *models.AccessToken) error
+	GetAccessTokensByUserID(ctx context.Context, userID string) ([]*models.AccessToken, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error)
+	UpdateAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsed time.Time) error
+	DeleteAccessToken(ctx context.Context, userID, tokenID string) error
+}
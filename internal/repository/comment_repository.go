@@ -2,13 +2,51 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/myacey/redditclone/internal/models"
 )
 
+// Comments are read with deleted ones, so threads keep their shape.
+// Comment count of the post is changed in the same transaction as comments,
+// deleted comments arent counted.
 type CommentRepository interface {
 	GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error)
+	// GetCommentsByPostID returns all comments of the post, parents go before their replies
 	GetCommentsByPostID(ctx context.Context, postID string) ([]*models.Comment, error)
+	// GetCommentThread returns comments of the post below rootPath (whole post
+	// if it is empty) which are not deeper than maxDepth, ordered like GetCommentsByPostID
+	GetCommentThread(ctx context.Context, postID, rootPath string, maxDepth int) ([]*models.Comment, error)
+	GetCommentsByAuthorID(ctx context.Context, authorID string) ([]*models.Comment, error)
+	// CreateComment saves comment, ErrPostDontExists if its post is deleted
 	CreateComment(ctx context.Context, newComment *models.Comment) error
+	// EditComment saves comment's body and edited time if body is still prevBody,
+	// revision is added to history unless nil. ErrCommentChanged if body was
+	// changed meanwhile, ErrCommentDontExists if there is no comment
+	EditComment(ctx context.Context, comment *models.Comment, prevBody string, revision *models.CommentRevision) error
+	// SoftDeleteComment marks comment deleted until it is restored or purged,
+	// ErrCommentDontExists if there is no comment which isnt deleted
+	SoftDeleteComment(ctx context.Context, commentID, deletedBy string, deletedAt time.Time) error
+	// RestoreComment brings back comment deleted after deletedAfter,
+	// ErrCommentDontExists if there is no such comment
+	RestoreComment(ctx context.Context, commentID string, deletedAfter time.Time) error
+	// GetCommentsDeletedBefore returns at most limit comments soft deleted before time
+	GetCommentsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]*models.Comment, error)
+	// DeleteComment removes comment for good
 	DeleteComment(ctx context.Context, commentID string) error
+	// ReplaceCommentsAuthor replaces author of at most limit comments of authorID,
+	// number of changed comments is returned
+	ReplaceCommentsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error)
+	// DeleteCommentsByAuthor deletes at most limit comments of authorID and returns them
+	DeleteCommentsByAuthor(ctx context.Context, authorID string, limit int64) ([]*models.Comment, error)
+	// DeleteCommentsByPostID doesnt change comment count, post is deleted with them
+	DeleteCommentsByPostID(ctx context.Context, postID string) (int64, error)
+	// CountCommentsByPost returns number of comments which arent deleted
+	// for every post having them
+	CountCommentsByPost(ctx context.Context) (map[string]int, error)
+	// CountCommentsByAuthor returns number of comments of authorID which arent deleted
+	CountCommentsByAuthor(ctx context.Context, authorID string) (int, error)
+	// SumScoreByAuthor returns total score of comments of authorID, deleted ones too,
+	// as karma is kept when content is deleted
+	SumScoreByAuthor(ctx context.Context, authorID string) (int, error)
 }
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrDeletionJobDontExists = errors.New("deletion job dont exists")
+	ErrDeletionJobClaimed    = errors.New("deletion job is run by another instance")
+)
+
+type DeletionJobRepository interface {
+	CreateDeletionJob(ctx context.Context, job *models.DeletionJob) error
+	GetDeletionJob(ctx context.Context, jobID string) (*models.DeletionJob, error)
+	// GetUnfinishedDeletionJobs returns running and failed jobs to resume them
+	GetUnfinishedDeletionJobs(ctx context.Context) ([]*models.DeletionJob, error)
+	// ClaimDeletionJob marks job as running if it is failed or its last update
+	// is older than staleBefore, otherwise returns ErrDeletionJobClaimed
+	ClaimDeletionJob(ctx context.Context, job *models.DeletionJob, staleBefore time.Time) error
+	UpdateDeletionJob(ctx context.Context, job *models.DeletionJob) error
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrExportJobDontExists = errors.New("export job dont exists")
+	ErrExportJobClaimed    = errors.New("export job is run by another instance")
+)
+
+type ExportJobRepository interface {
+	CreateExportJob(ctx context.Context, job *models.ExportJob) error
+	GetExportJob(ctx context.Context, jobID string) (*models.ExportJob, error)
+	GetExportJobsByStatus(ctx context.Context, status string) ([]*models.ExportJob, error)
+	HasRunningExportJob(ctx context.Context, userID string) (bool, error)
+	// ClaimExportJob touches running job if its last update is older
+	// than staleBefore, otherwise returns ErrExportJobClaimed
+	ClaimExportJob(ctx context.Context, job *models.ExportJob, staleBefore time.Time) error
+	UpdateExportJob(ctx context.Context, job *models.ExportJob) error
+	// MarkExportDownloaded returns false if archive isnt ready anymore,
+	// so only one of concurrent downloads gets it
+	MarkExportDownloaded(ctx context.Context, jobID string) (bool, error)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrIdentityDontExists    = errors.New("external identity dont exists")
+	ErrIdentityAlreadyLinked = errors.New("external identity already linked")
+)
+
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, identity *models.ExternalIdentity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID string) ([]*models.ExternalIdentity, error)
+}
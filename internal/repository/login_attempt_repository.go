@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttemptRepository counts failed attempts per key (username, ip...)
+// and keeps temporary lockouts. It is shared by all instances of the app.
+type LoginAttemptRepository interface {
+	// IncrAttempts returns number of attempts including this one,
+	// counter is forgotten after window without attempts
+	IncrAttempts(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, duration time.Duration) error
+	// GetLockTTL returns time left until key is unlocked, zero if it isnt locked
+	GetLockTTL(ctx context.Context, key string) (time.Duration, error)
+	// Reset removes attempts counter and lockout of key
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrTOTPDontExists         = errors.New("totp config dont exists")
+	ErrTOTPCodeReused         = errors.New("totp code was already used")
+	ErrRecoveryCodeDontExists = errors.New("recovery code dont exists or was used")
+)
+
+// MFARepository keeps second factors of users.
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userID string) (*models.TOTPConfig, error)
+	// SaveTOTP creates or replaces not yet enabled config
+	SaveTOTP(ctx context.Context, config *models.TOTPConfig) error
+	EnableTOTP(ctx context.Context, userID string, step int64) error
+	// UseTOTPStep returns ErrTOTPCodeReused if code of this or later step was already accepted
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+
+	// ReplaceRecoveryCodes removes old codes of the user
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []*models.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+}
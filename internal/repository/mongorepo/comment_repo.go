@@ -2,29 +2,68 @@ package mongorepo
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/myacey/redditclone/internal/models"
 	"github.com/myacey/redditclone/internal/repository"
 )
 
 type MongoCommentRepo struct {
+	client            *mongo.Client
 	commentCollection *mongo.Collection
+	// comment count of posts is changed together with comments
+	postsCollection *mongo.Collection
 }
 
 func NewMongoCommentRepo(client *mongo.Client, dbName string) repository.CommentRepository {
 	return &MongoCommentRepo{
+		client:            client,
 		commentCollection: client.Database(dbName).Collection("comments"),
+		postsCollection:   client.Database(dbName).Collection("posts"),
 	}
 }
 
+// comments are sorted by path, it is served by (post_id, path) index
 func (r *MongoCommentRepo) GetCommentsByPostID(ctx context.Context, postID string) ([]*models.Comment, error) {
-	filter := bson.M{"post_id": postID}
+	return r.findThread(ctx, bson.M{"post_id": postID})
+}
+
+func (r *MongoCommentRepo) GetCommentThread(ctx context.Context, postID, rootPath string, maxDepth int) ([]*models.Comment, error) {
+	filter := bson.M{"post_id": postID, "depth": bson.M{"$lte": maxDepth}}
+	if rootPath != "" {
+		// anchored prefix uses the index too
+		filter["path"] = bson.M{"$regex": "^" + regexp.QuoteMeta(rootPath+models.PathSeparator)}
+	}
+	return r.findThread(ctx, filter)
+}
+
+func (r *MongoCommentRepo) findThread(ctx context.Context, filter interface{}) ([]*models.Comment, error) {
+	// history is read only by GetCommentByID
+	opts := options.Find().
+		SetSort(bson.D{{Key: "path", Value: 1}}).
+		SetProjection(bson.M{"revisions": 0})
 
 	comments := []*models.Comment{}
-	cursor, err := r.commentCollection.Find(ctx, filter)
+	cursor, err := r.commentCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &comments)
+
+	return comments, err
+}
+
+func (r *MongoCommentRepo) GetCommentsByAuthorID(ctx context.Context, authorID string) ([]*models.Comment, error) {
+	comments := []*models.Comment{}
+	cursor, err := r.commentCollection.Find(ctx, authorFilter(authorID))
 	if err != nil {
 		return nil, err
 	}
@@ -50,14 +89,192 @@ func (r *MongoCommentRepo) GetCommentByID(ctx context.Context, commentID string)
 	return post, nil
 }
 
+// post is updated first, so it cant be deleted before transaction ends
 func (r *MongoCommentRepo) CreateComment(ctx context.Context, newComment *models.Comment) error {
-	_, err := r.commentCollection.InsertOne(ctx, newComment)
-	return err
+	return inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		res, err := r.postsCollection.UpdateOne(ctx, notDeleted(bson.M{"_id": newComment.RelatedPostID}), commentCountUpdate(1))
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return repository.ErrPostDontExists
+		}
+
+		_, err = r.commentCollection.InsertOne(ctx, newComment)
+		return err
+	})
+}
+
+// body works as version: edit made after comment was read doesnt match
+func (r *MongoCommentRepo) EditComment(ctx context.Context, comment *models.Comment, prevBody string, revision *models.CommentRevision) error {
+	filter := bson.M{"_id": comment.ID, "body": prevBody}
+
+	update := bson.M{"$set": bson.M{"body": comment.Body, "edited": comment.Edited}}
+	if revision != nil {
+		update["$push"] = bson.M{"revisions": revision}
+	}
+
+	res, err := r.commentCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		err = r.commentCollection.FindOne(ctx, bson.M{"_id": comment.ID}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return repository.ErrCommentDontExists
+		} else if err != nil {
+			return err
+		}
+		return repository.ErrCommentChanged
+	}
+
+	return nil
 }
 
+func (r *MongoCommentRepo) SoftDeleteComment(ctx context.Context, commentID, deletedBy string, deletedAt time.Time) error {
+	filter, update := softDeleteQuery(commentID, deletedBy, deletedAt)
+	return r.updateCounted(ctx, filter, update, -1)
+}
+
+func (r *MongoCommentRepo) RestoreComment(ctx context.Context, commentID string, deletedAfter time.Time) error {
+	filter, update := restoreQuery(commentID, deletedAfter)
+	return r.updateCounted(ctx, filter, update, 1)
+}
+
+// updateCounted updates comment and changes comment count of its post
+// by delta in one transaction.
+func (r *MongoCommentRepo) updateCounted(ctx context.Context, filter, update bson.M, delta int) error {
+	return inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var comment models.Comment
+		opts := options.FindOneAndUpdate().SetProjection(bson.M{"post_id": 1})
+		err := r.commentCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&comment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return repository.ErrCommentDontExists
+		} else if err != nil {
+			return err
+		}
+
+		_, err = r.postsCollection.UpdateOne(ctx, bson.M{"_id": comment.RelatedPostID}, commentCountUpdate(delta))
+		return err
+	})
+}
+
+func (r *MongoCommentRepo) GetCommentsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]*models.Comment, error) {
+	opts := options.Find().SetLimit(limit)
+	cursor, err := r.commentCollection.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := []*models.Comment{}
+	err = cursor.All(ctx, &comments)
+	return comments, err
+}
+
+// soft deleted comment isnt counted already
 func (r *MongoCommentRepo) DeleteComment(ctx context.Context, commentID string) error {
-	filter := bson.M{"_id": commentID}
+	return inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var comment models.Comment
+		opts := options.FindOneAndDelete().SetProjection(bson.M{"post_id": 1, "deleted_at": 1})
+		err := r.commentCollection.FindOneAndDelete(ctx, bson.M{"_id": commentID}, opts).Decode(&comment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		} else if err != nil || comment.DeletedAt != nil {
+			return err
+		}
+
+		_, err = r.postsCollection.UpdateOne(ctx, bson.M{"_id": comment.RelatedPostID}, commentCountUpdate(-1))
+		return err
+	})
+}
+
+func (r *MongoCommentRepo) ReplaceCommentsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error) {
+	return replaceAuthor(ctx, r.commentCollection, authorID, bson.M{"$set": bson.M{"author": author}}, limit)
+}
+
+func (r *MongoCommentRepo) DeleteCommentsByAuthor(ctx context.Context, authorID string, limit int64) ([]*models.Comment, error) {
+	var comments []*models.Comment
+	err := inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		opts := options.Find().SetLimit(limit)
+		cursor, err := r.commentCollection.Find(ctx, authorFilter(authorID), opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		comments = []*models.Comment{}
+		if err = cursor.All(ctx, &comments); err != nil || len(comments) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(comments))
+		counted := map[string]int{}
+		for _, comment := range comments {
+			ids = append(ids, comment.ID)
+			if comment.DeletedAt == nil {
+				counted[comment.RelatedPostID]++
+			}
+		}
+		_, err = r.commentCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+
+		for postID, n := range counted {
+			_, err = r.postsCollection.UpdateOne(ctx, bson.M{"_id": postID}, commentCountUpdate(-n))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(comments) == 0 {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *MongoCommentRepo) DeleteCommentsByPostID(ctx context.Context, postID string) (int64, error) {
+	res, err := r.commentCollection.DeleteMany(ctx, bson.M{"post_id": postID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (r *MongoCommentRepo) CountCommentsByPost(ctx context.Context) (map[string]int, error) {
+	cursor, err := r.commentCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: notDeleted(bson.M{})}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$post_id"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		PostID string `bson:"_id"`
+		Count  int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(docs))
+	for _, doc := range docs {
+		counts[doc.PostID] = doc.Count
+	}
+	return counts, nil
+}
+
+func (r *MongoCommentRepo) CountCommentsByAuthor(ctx context.Context, authorID string) (int, error) {
+	return countByAuthor(ctx, r.commentCollection, authorID)
+}
 
-	_, err := r.commentCollection.DeleteOne(ctx, filter)
-	return err
+func (r *MongoCommentRepo) SumScoreByAuthor(ctx context.Context, authorID string) (int, error) {
+	return sumScoreByAuthor(ctx, r.commentCollection, authorID)
 }
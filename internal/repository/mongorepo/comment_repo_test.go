@@ -0,0 +1,209 @@
+package mongorepo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/repository/mongorepo"
+)
+
+func TestCreateComment(t *testing.T) {
+	mt := setupMockDB(t)
+	comment := models.NewComment("comment", mockUser, mockPost.ID)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, repo.CreateComment(context.Background(), comment))
+
+		// count of live post is changed in the same transaction
+		update := mt.GetStartedEvent()
+		assert.Equal(t, "update", update.CommandName)
+		assert.True(t, update.Command.Lookup("startTransaction").Boolean())
+		filter := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(t, mockPost.ID, filter.Lookup("_id").StringValue())
+		assert.False(t, filter.Lookup("deleted_at", "$exists").Boolean())
+		assert.Equal(t, "insert", mt.GetStartedEvent().CommandName)
+		assert.Equal(t, "commitTransaction", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("Post deleted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := repo.CreateComment(context.Background(), comment)
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+
+		mt.GetStartedEvent()
+		assert.Equal(t, "abortTransaction", mt.GetStartedEvent().CommandName)
+	})
+}
+
+func TestSoftDeleteComment(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+				{Key: "_id", Value: "comment1"},
+				{Key: "post_id", Value: mockPost.ID},
+			}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, repo.SoftDeleteComment(context.Background(), "comment1", mockUser.ID, time.Now()))
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, mockPost.ID, update.Lookup("q", "_id").StringValue())
+		assert.Contains(t, update.Lookup("u").String(), "-1")
+		assert.Equal(t, "commitTransaction", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("Already deleted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := repo.SoftDeleteComment(context.Background(), "comment1", mockUser.ID, time.Now())
+		assert.ErrorIs(t, err, repository.ErrCommentDontExists)
+	})
+}
+
+func TestDeleteCommentsByAuthor(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Only live comments are counted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "testDB.comments", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: "live"}, {Key: "post_id", Value: mockPost.ID}},
+				bson.D{{Key: "_id", Value: "deleted"}, {Key: "post_id", Value: mockPost.ID}, {Key: "deleted_at", Value: time.Now()}},
+			),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		comments, err := repo.DeleteCommentsByAuthor(context.Background(), mockUser.ID, 100)
+		require.NoError(t, err)
+		assert.Len(t, comments, 2)
+
+		mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, mockPost.ID, update.Lookup("q", "_id").StringValue())
+		assert.Contains(t, update.Lookup("u").String(), "-1")
+		assert.Equal(t, "commitTransaction", mt.GetStartedEvent().CommandName)
+	})
+}
+
+func TestGetCommentThread(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Whole post", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.comments", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "c1"}, {Key: "post_id", Value: "post1"}, {Key: "path", Value: "c1"}},
+			bson.D{{Key: "_id", Value: "c2"}, {Key: "post_id", Value: "post1"}, {Key: "path", Value: "c1/c2"}, {Key: "parent_id", Value: "c1"}, {Key: "depth", Value: 1}},
+		))
+
+		comments, err := repo.GetCommentThread(context.Background(), "post1", "", 3)
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		assert.Equal(t, "c1", comments[1].ParentID)
+		assert.Equal(t, 1, comments[1].Depth)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, int32(3), cmd.Lookup("filter", "depth", "$lte").Int32())
+		_, err = cmd.LookupErr("filter", "path")
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), cmd.Lookup("sort", "path").Int32())
+		assert.Equal(t, int32(0), cmd.Lookup("projection", "revisions").Int32())
+	})
+
+	mt.Run("Replies of comment", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.comments", mtest.FirstBatch))
+
+		_, err := repo.GetCommentThread(context.Background(), "post1", "c1/c2", 5)
+		require.NoError(t, err)
+
+		// only replies, not the comment itself
+		pattern := mt.GetStartedEvent().Command.Lookup("filter", "path", "$regex").StringValue()
+		assert.Equal(t, "^c1/c2/", pattern)
+	})
+}
+
+func TestEditComment(t *testing.T) {
+	mt := setupMockDB(t)
+
+	edited := time.Now()
+	comment := &models.Comment{ID: "comment1", Body: "new", Edited: &edited}
+
+	mt.Run("With revision", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := repo.EditComment(context.Background(), comment, "old", &models.CommentRevision{Body: "old", CreatedAt: edited.Add(-time.Hour)})
+		require.NoError(t, err)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates", "0")
+		assert.Equal(t, "old", update.Document().Lookup("q", "body").StringValue())
+		assert.Equal(t, "new", update.Document().Lookup("u", "$set", "body").StringValue())
+		assert.Equal(t, "old", update.Document().Lookup("u", "$push", "revisions", "body").StringValue())
+	})
+
+	mt.Run("In grace window", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := repo.EditComment(context.Background(), comment, "old", nil)
+		require.NoError(t, err)
+
+		_, err = mt.GetStartedEvent().Command.LookupErr("updates", "0", "u", "$push")
+		assert.Error(t, err)
+	})
+
+	mt.Run("Changed meanwhile", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			mtest.CreateCursorResponse(0, "testDB.comments", mtest.FirstBatch, bson.D{{Key: "_id", Value: "comment1"}}),
+		)
+
+		err := repo.EditComment(context.Background(), comment, "old", nil)
+		assert.ErrorIs(t, err, repository.ErrCommentChanged)
+	})
+
+	mt.Run("Comment not found", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoCommentRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			mtest.CreateCursorResponse(0, "testDB.comments", mtest.FirstBatch),
+		)
+
+		err := repo.EditComment(context.Background(), comment, "old", nil)
+		assert.ErrorIs(t, err, repository.ErrCommentDontExists)
+	})
+}
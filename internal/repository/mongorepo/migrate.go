@@ -0,0 +1,203 @@
+package mongorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+// Migrate creates indexes and moves data stored by older versions,
+// it is safe to run on every start.
+func Migrate(ctx context.Context, client *mongo.Client, dbName string) error {
+	db := client.Database(dbName)
+
+	_, err := db.Collection(votesCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cant create vote indexes: %v", err)
+	}
+
+	_, err = db.Collection(commentVotesCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "comment_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// viewer's votes on comments of a post, and export
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "post_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cant create comment vote indexes: %v", err)
+	}
+
+	_, err = db.Collection("comments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "path", Value: 1}},
+		},
+		{
+			// purge of deleted comments
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cant create comment indexes: %v", err)
+	}
+
+	_, err = db.Collection("posts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return fmt.Errorf("cant create post indexes: %v", err)
+	}
+
+	_, err = db.Collection(postRevisionsCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("cant create post revision indexes: %v", err)
+	}
+
+	if err = migrateEmbeddedVotes(ctx, db.Collection("posts"), db.Collection(votesCollectionName)); err != nil {
+		return fmt.Errorf("cant migrate votes: %v", err)
+	}
+
+	// comments before threads are top-level ones
+	_, err = db.Collection("comments").UpdateMany(ctx,
+		bson.M{"path": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "path", Value: "$_id"}, {Key: "depth", Value: 0}}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("cant set comment paths: %v", err)
+	}
+
+	// conditional updates dont match posts without version
+	_, err = db.Collection("posts").UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 0}},
+	)
+	if err != nil {
+		return fmt.Errorf("cant set post versions: %v", err)
+	}
+
+	// authors copied before Password was hidden from bson kept it,
+	// old posts kept their comments with authors too
+	passwordFields := []struct{ collection, filter, field string }{
+		{"posts", "author.password", "author.password"},
+		{"posts", "comments.author.password", "comments.$[].author.password"},
+		{"comments", "author.password", "author.password"},
+	}
+	for _, f := range passwordFields {
+		_, err = db.Collection(f.collection).UpdateMany(ctx,
+			bson.M{f.filter: bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{f.field: ""}},
+		)
+		if err != nil {
+			return fmt.Errorf("cant remove author passwords from %s: %v", f.collection, err)
+		}
+	}
+
+	return nil
+}
+
+// embeddedVote is vote kept in post before votes collection,
+// Vote had no bson tags then
+type embeddedVote struct {
+	UserID string `bson:"userid"`
+	Vote   int8   `bson:"vote"`
+}
+
+// migrateEmbeddedVotes moves votes array of every post to votes collection.
+// Array is removed only after its votes are inserted, so interrupted
+// migration starts the post over and already inserted votes are skipped.
+func migrateEmbeddedVotes(ctx context.Context, posts, votes *mongo.Collection) error {
+	opts := options.Find().SetProjection(bson.M{"votes": 1})
+	cursor, err := posts.Find(ctx, bson.M{"votes": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    string          `bson:"_id"`
+			Votes []*embeddedVote `bson:"votes"`
+		}
+		if err = cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		// first vote of the user wins, like with unique index
+		var newVotes []interface{}
+		voted := map[string]bool{}
+		post := models.Post{}
+		for _, v := range doc.Votes {
+			if v == nil || voted[v.UserID] || (v.Vote != 1 && v.Vote != -1) {
+				continue
+			}
+			voted[v.UserID] = true
+			newVotes = append(newVotes, &models.Vote{PostID: doc.ID, UserID: v.UserID, Vote: v.Vote})
+
+			upvotes, downvotes := models.VoteCounters(v.Vote)
+			post.SetVoteCounters(post.Upvotes+upvotes, post.Downvotes+downvotes)
+		}
+
+		if len(newVotes) > 0 {
+			_, err = votes.InsertMany(ctx, newVotes, options.InsertMany().SetOrdered(false))
+			if err != nil && !onlyDuplicateKeys(err) {
+				return err
+			}
+		}
+
+		_, err = posts.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{
+			"$set": bson.M{
+				"upvotes":           post.Upvotes,
+				"downvotes":         post.Downvotes,
+				"score":             post.Score,
+				"upvote_percantage": post.UpvotePercentage,
+			},
+			"$unset": bson.M{"votes": ""},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// duplicateKeyCode is returned for insert violating unique index
+const duplicateKeyCode = 11000
+
+// onlyDuplicateKeys is true if every failed insert was a duplicate
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != duplicateKeyCode {
+			return false
+		}
+	}
+	return true
+}
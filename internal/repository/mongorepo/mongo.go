@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -19,6 +21,178 @@ func ConfigureMongoClient() (*mongo.Client, error) {
 	if err = mongoClient.Ping(context.Background(), nil); err != nil {
 		return nil, fmt.Errorf("cant connect to mongo: %v", err)
 	}
+	if err = requireTransactions(context.Background(), mongoClient); err != nil {
+		return nil, err
+	}
 
 	return mongoClient, nil
 }
+
+// requireTransactions checks that mongo is replica set or sharded cluster,
+// standalone server cant run transactions comment counters rely on.
+func requireTransactions(ctx context.Context, client *mongo.Client) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return fmt.Errorf("cant check mongo topology: %v", err)
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return fmt.Errorf("mongo has to be replica set to run transactions, single member one is enough")
+	}
+	return nil
+}
+
+// inTransaction runs fn in transaction, fn is called again on transient errors.
+func inTransaction(ctx context.Context, client *mongo.Client, fn func(ctx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// commentCountUpdate changes comment count of post, it never gets below zero.
+func commentCountUpdate(delta int) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "comment_count", Value: bson.D{{Key: "$max", Value: bson.A{
+				0,
+				bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$comment_count", 0}}}, delta}}},
+			}}}},
+		}}},
+	}
+}
+
+// authorFilter matches documents with embedded author
+func authorFilter(authorID string) bson.M {
+	return bson.M{"author.id": authorID}
+}
+
+// countByAuthor counts documents of authorID which arent soft deleted
+func countByAuthor(ctx context.Context, collection *mongo.Collection, authorID string) (int, error) {
+	count, err := collection.CountDocuments(ctx, notDeleted(authorFilter(authorID)))
+	return int(count), err
+}
+
+// sumScoreByAuthor sums score of all documents of authorID
+func sumScoreByAuthor(ctx context.Context, collection *mongo.Collection, authorID string) (int, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: authorFilter(authorID)}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "score", Value: bson.D{{Key: "$sum", Value: "$score"}}},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		Score int `bson:"score"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+	// no documents, no group
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	return docs[0].Score, nil
+}
+
+// notDeleted matches documents which arent soft deleted
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// softDeleteQuery marks document deleted, unless it is deleted already.
+func softDeleteQuery(id, deletedBy string, deletedAt time.Time) (filter, update bson.M) {
+	filter = notDeleted(bson.M{"_id": id})
+	update = bson.M{"$set": bson.M{"deleted_at": deletedAt, "deleted_by": deletedBy}}
+	return filter, update
+}
+
+// restoreQuery is opposite of softDeleteQuery, only documents deleted after
+// deletedAfter are restored, older ones wait for purge.
+func restoreQuery(id string, deletedAfter time.Time) (filter, update bson.M) {
+	filter = bson.M{"_id": id, "deleted_at": bson.M{"$gt": deletedAfter}}
+	update = bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}}
+	return filter, update
+}
+
+// findIDs returns _id of at most limit documents matching filter.
+func findIDs(ctx context.Context, collection *mongo.Collection, filter interface{}, limit int64) ([]string, error) {
+	opts := options.Find().SetLimit(limit).SetProjection(bson.M{"_id": 1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+// replaceAuthor is done in batches, so progress can be saved between them.
+// Replaced documents dont match authorFilter anymore, so it is safe to repeat.
+// update has to set new author.
+func replaceAuthor(ctx context.Context, collection *mongo.Collection, authorID string, update bson.M, limit int64) (int64, error) {
+	ids, err := findIDs(ctx, collection, authorFilter(authorID), limit)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	res, err := collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "author.id": authorID},
+		update,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// voteCountersUpdate increments vote counters and recomputes score and upvote
+// percentage from them in the same pipeline update.
+func voteCountersUpdate(upvotes, downvotes int) mongo.Pipeline {
+	counters := bson.D{
+		{Key: "upvotes", Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$upvotes", 0}}}, upvotes}}}},
+		{Key: "downvotes", Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$downvotes", 0}}}, downvotes}}}},
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$set", Value: counters}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "score", Value: bson.D{{Key: "$subtract", Value: bson.A{"$upvotes", "$downvotes"}}}},
+			{Key: "upvote_percantage", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$add", Value: bson.A{"$upvotes", "$downvotes"}}}, 0}}},
+				0,
+				// integer math, like models.UpvotePercentage
+				bson.D{{Key: "$toInt", Value: bson.D{{Key: "$trunc", Value: bson.D{{Key: "$divide", Value: bson.A{
+					bson.D{{Key: "$multiply", Value: bson.A{"$upvotes", 100}}},
+					bson.D{{Key: "$add", Value: bson.A{"$upvotes", "$downvotes"}}},
+				}}}}}}},
+			}}}},
+		}}},
+	}
+}
@@ -3,6 +3,7 @@ package mongorepo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -30,7 +31,14 @@ func (r *MongoPostRepository) CreatePost(ctx context.Context, newPost *models.Po
 }
 
 func (r *MongoPostRepository) GetPostByID(ctx context.Context, postID string) (*models.Post, error) {
-	filter := bson.M{"_id": postID}
+	return r.findPost(ctx, notDeleted(bson.M{"_id": postID}))
+}
+
+func (r *MongoPostRepository) GetDeletedPostByID(ctx context.Context, postID string) (*models.Post, error) {
+	return r.findPost(ctx, bson.M{"_id": postID, "deleted_at": bson.M{"$exists": true}})
+}
+
+func (r *MongoPostRepository) findPost(ctx context.Context, filter bson.M) (*models.Post, error) {
 	post := models.Post{}
 	err := r.postsCollection.FindOne(ctx, filter).Decode(&post)
 	if err != nil {
@@ -44,7 +52,7 @@ func (r *MongoPostRepository) GetPostByID(ctx context.Context, postID string) (*
 }
 
 func (r *MongoPostRepository) GetAllPosts(ctx context.Context) ([]*models.Post, error) {
-	res, err := r.postsCollection.Find(ctx, bson.D{})
+	res, err := r.postsCollection.Find(ctx, notDeleted(bson.M{}))
 	if err != nil {
 		return nil, err
 	}
@@ -55,86 +63,154 @@ func (r *MongoPostRepository) GetAllPosts(ctx context.Context) ([]*models.Post,
 	return posts, err
 }
 
+// only content is saved, counters are changed in place by their own
+// updates and dont change version
 func (r *MongoPostRepository) UpdatePostInfo(ctx context.Context, newPost *models.Post) error {
-	filter := bson.M{"_id": newPost.ID}
+	filter := bson.M{"_id": newPost.ID, "version": newPost.Version}
+	update := bson.M{
+		"$set": bson.M{
+			"title":    newPost.Title,
+			"category": newPost.Category,
+			"type":     newPost.Type,
+			"text":     newPost.Text,
+			"url":      newPost.URL,
+			"edited":   newPost.Edited,
+		},
+		"$inc": bson.M{"version": 1},
+	}
 
-	opts := options.Replace().SetUpsert(false)
+	res, err := r.postsCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if err = r.requirePost(ctx, newPost.ID); err != nil {
+			return err
+		}
+		return &repository.VersionConflictError{PostID: newPost.ID, Version: newPost.Version}
+	}
 
-	_, err := r.postsCollection.ReplaceOne(ctx, filter, newPost, opts)
-	return err
+	newPost.Version++
+	return nil
 }
 
-func (r *MongoPostRepository) DeletePost(ctx context.Context, postID string) error {
-	filter := bson.M{"_id": postID}
-
-	_, err := r.postsCollection.DeleteOne(ctx, filter)
-	return err
+func (r *MongoPostRepository) IncrementViews(ctx context.Context, postID string) error {
+	update := bson.M{"$inc": bson.M{"views": 1}}
+	return r.updateOne(ctx, postID, update)
 }
 
-// vote logic (maybe to different file?....)
-func (r *MongoPostRepository) VotePost(ctx context.Context, postID string, newVote *models.Vote) (*models.Post, error) {
-	filter := bson.M{"_id": postID, "votes.user": newVote.UserID}
-	update := bson.M{
-		"$set": bson.M{
-			"votes.$.vote": newVote.Vote,
-		},
+func (r *MongoPostRepository) GetCommentCounts(ctx context.Context) (map[string]int, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "comment_count": 1})
+	cursor, err := r.postsCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	after := options.After
-	opts := options.FindOneAndUpdate().SetReturnDocument(after)
+	var docs []struct {
+		ID           string `bson:"_id"`
+		CommentCount int    `bson:"comment_count"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
 
-	res := r.postsCollection.FindOneAndUpdate(ctx, filter, update, opts)
-	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
-		// create new vote
+	counts := make(map[string]int, len(docs))
+	for _, doc := range docs {
+		counts[doc.ID] = doc.CommentCount
+	}
+	return counts, nil
+}
 
-		update = bson.M{
-			"$push": bson.M{
-				"votes": newVote,
-			},
-		}
-		_, err := r.postsCollection.UpdateOne(ctx, bson.M{"_id": postID}, update)
-		if err != nil {
-			return nil, err
-		}
+// stored count works as version: comment added after it was read doesnt match
+func (r *MongoPostRepository) SetCommentCount(ctx context.Context, postID string, stored, count int) (bool, error) {
+	filter := bson.M{"_id": postID, "comment_count": stored}
+	if stored == 0 {
+		// posts created before counter have no field
+		filter["comment_count"] = bson.M{"$in": bson.A{0, nil}}
+	}
 
-		return r.GetPostByID(ctx, postID)
-	} else if res.Err() != nil {
-		return nil, res.Err()
+	res, err := r.postsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"comment_count": count}})
+	if err != nil {
+		return false, err
 	}
+	return res.MatchedCount > 0, nil
+}
 
-	// was no error
-	var updatedPost models.Post
-	if err := res.Decode(&updatedPost); err != nil {
-		return nil, err
+func (r *MongoPostRepository) updateOne(ctx context.Context, postID string, update interface{}) error {
+	return r.updateMatching(ctx, bson.M{"_id": postID}, update)
+}
+
+func (r *MongoPostRepository) updateMatching(ctx context.Context, filter, update interface{}) error {
+	res, err := r.postsCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrPostDontExists
 	}
+	return nil
+}
 
-	return &updatedPost, nil
+func (r *MongoPostRepository) requirePost(ctx context.Context, postID string) error {
+	err := r.postsCollection.FindOne(ctx, bson.M{"_id": postID}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return repository.ErrPostDontExists
+	}
+	return err
 }
 
-func (r *MongoPostRepository) UnvotePost(ctx context.Context, postID, userID string) (*models.Post, error) {
+// deletion changes version, so post read before cant be saved over it
+func (r *MongoPostRepository) SoftDeletePost(ctx context.Context, postID, deletedBy string, deletedAt time.Time) error {
+	filter, update := softDeleteQuery(postID, deletedBy, deletedAt)
+	update["$inc"] = bson.M{"version": 1}
+	return r.updateMatching(ctx, filter, update)
+}
+
+func (r *MongoPostRepository) RestorePost(ctx context.Context, postID string, deletedAfter time.Time) error {
+	filter, update := restoreQuery(postID, deletedAfter)
+	update["$inc"] = bson.M{"version": 1}
+	return r.updateMatching(ctx, filter, update)
+}
+
+func (r *MongoPostRepository) GetPostIDsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]string, error) {
+	return findIDs(ctx, r.postsCollection, bson.M{"deleted_at": bson.M{"$lt": before}}, limit)
+}
+
+func (r *MongoPostRepository) DeletePost(ctx context.Context, postID string) error {
 	filter := bson.M{"_id": postID}
-	update := bson.M{
-		"$pull": bson.M{
-			"votes": bson.M{"user": userID},
-		},
-	}
 
-	after := options.After
-	opts := options.FindOneAndUpdate().SetReturnDocument(after)
+	_, err := r.postsCollection.DeleteOne(ctx, filter)
+	return err
+}
+
+func (r *MongoPostRepository) ReplacePostsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error) {
+	// new version, so post read before cant bring old author back
+	update := bson.M{"$set": bson.M{"author": author}, "$inc": bson.M{"version": 1}}
+	return replaceAuthor(ctx, r.postsCollection, authorID, update, limit)
+}
 
-	res := r.postsCollection.FindOneAndUpdate(ctx, filter, update, opts)
-	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
-		// error request, wtf he did?
-		return r.GetPostByID(ctx, postID)
-	} else if res.Err() != nil {
-		return nil, res.Err()
-	}
+func (r *MongoPostRepository) GetPostIDsByAuthor(ctx context.Context, authorID string, limit int64) ([]string, error) {
+	return findIDs(ctx, r.postsCollection, authorFilter(authorID), limit)
+}
 
-	// was no error
-	var updatedPost models.Post
-	if err := res.Decode(&updatedPost); err != nil {
+func (r *MongoPostRepository) GetPostsByAuthorID(ctx context.Context, authorID string) ([]*models.Post, error) {
+	cursor, err := r.postsCollection.Find(ctx, authorFilter(authorID))
+	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
+
+	posts := []*models.Post{}
+	err = cursor.All(ctx, &posts)
+
+	return posts, err
+}
+
+func (r *MongoPostRepository) CountPostsByAuthor(ctx context.Context, authorID string) (int, error) {
+	return countByAuthor(ctx, r.postsCollection, authorID)
+}
 
-	return &updatedPost, nil
+func (r *MongoPostRepository) SumScoreByAuthor(ctx context.Context, authorID string) (int, error) {
+	return sumScoreByAuthor(ctx, r.postsCollection, authorID)
 }
@@ -3,14 +3,23 @@ package mongorepo_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
 	"github.com/myacey/redditclone/internal/repository/mongorepo"
 )
 
@@ -225,21 +234,40 @@ func TestUpdatePostInfo(t *testing.T) {
 
 		testCases := []struct {
 			name         string
-			newPost      *models.Post
 			mockBehavior func()
 			expErr       error
+			expVersion   int64
 		}{
 			{
-				name:    "Success",
-				newPost: mockPost,
+				name: "Success",
 				mockBehavior: func() {
-					mt.AddMockResponses(mtest.CreateSuccessResponse())
+					mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
 				},
-				expErr: nil,
+				expErr:     nil,
+				expVersion: 1,
+			},
+			{
+				name: "Version conflict",
+				mockBehavior: func() {
+					mt.AddMockResponses(
+						bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+						mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch, bson.D{{Key: "_id", Value: mockPost.ID}}),
+					)
+				},
+				expErr: &repository.VersionConflictError{PostID: mockPost.ID, Version: 0},
 			},
 			{
-				name:    "Error",
-				newPost: mockPost,
+				name: "Post not found",
+				mockBehavior: func() {
+					mt.AddMockResponses(
+						bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+						mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch),
+					)
+				},
+				expErr: repository.ErrPostDontExists,
+			},
+			{
+				name: "Error",
 				mockBehavior: func() {
 					mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: ErrBasic.Error()}))
 				},
@@ -251,9 +279,19 @@ func TestUpdatePostInfo(t *testing.T) {
 			t.Run(tc.name, func(t *testing.T) {
 				tc.mockBehavior()
 
-				err := repo.UpdatePostInfo(context.Background(), tc.newPost)
+				post := *mockPost
+				err := repo.UpdatePostInfo(context.Background(), &post)
 				if tc.expErr == nil {
 					assert.NoError(t, err)
+					assert.Equal(t, tc.expVersion, post.Version)
+
+					// counters changed meanwhile arent overwritten
+					set := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+					assert.Equal(t, mockPost.Title, set.Lookup("title").StringValue())
+					for _, counter := range []string{"views", "score", "upvotes", "downvotes", "comment_count"} {
+						_, lookupErr := set.LookupErr(counter)
+						assert.Error(t, lookupErr, counter)
+					}
 				} else {
 					var cmdErr mongo.CommandError
 					if errors.As(err, &cmdErr) {
@@ -261,12 +299,64 @@ func TestUpdatePostInfo(t *testing.T) {
 					} else {
 						assert.EqualError(t, err, tc.expErr.Error())
 					}
+					assert.Equal(t, mockPost.Version, post.Version)
 				}
 			})
 		}
 	})
 }
 
+func TestIncrementViews(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := repo.IncrementViews(context.Background(), mockPost.ID)
+		require.NoError(t, err)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		assert.Equal(t, int32(1), update.Lookup("$inc", "views").Int32())
+		_, err = update.LookupErr("$inc", "version")
+		assert.Error(t, err)
+	})
+
+	mt.Run("Post not found", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err := repo.IncrementViews(context.Background(), mockPost.ID)
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+	})
+}
+
+func TestSetCommentCount(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		ok, err := repo.SetCommentCount(context.Background(), mockPost.ID, 3, 2)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, int32(3), update.Lookup("q", "comment_count").Int32())
+		assert.Equal(t, int32(2), update.Lookup("u", "$set", "comment_count").Int32())
+	})
+
+	mt.Run("Count changed", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		ok, err := repo.SetCommentCount(context.Background(), mockPost.ID, 3, 2)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
 func TestDeletePost(t *testing.T) {
 	mt := setupMockDB(t)
 
@@ -316,3 +406,261 @@ func TestDeletePost(t *testing.T) {
 		}
 	})
 }
+
+func TestSoftDeletePost(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := repo.SoftDeletePost(context.Background(), mockPost.ID, mockUser.ID, time.Now())
+		require.NoError(t, err)
+
+		cmd := mt.GetStartedEvent().Command
+		filter := cmd.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		exists, ok := filter.Lookup("deleted_at", "$exists").BooleanOK()
+		assert.True(t, ok)
+		assert.False(t, exists)
+	})
+
+	mt.Run("Already deleted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err := repo.SoftDeletePost(context.Background(), mockPost.ID, mockUser.ID, time.Now())
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+	})
+}
+
+func TestRestorePost(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		err := repo.RestorePost(context.Background(), mockPost.ID, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		_, err = update.LookupErr("$unset", "deleted_at")
+		assert.NoError(t, err)
+		_, err = update.LookupErr("$inc", "version")
+		assert.NoError(t, err)
+	})
+
+	mt.Run("Restore window passed", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err := repo.RestorePost(context.Background(), mockPost.ID, time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+	})
+}
+
+func TestReplacePostsAuthor(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Test Cases", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+
+		testCases := []struct {
+			name         string
+			mockBehavior func()
+			expN         int64
+			expErr       error
+		}{
+			{
+				name: "Success",
+				mockBehavior: func() {
+					mt.AddMockResponses(
+						mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch,
+							bson.D{{Key: "_id", Value: "post1"}},
+							bson.D{{Key: "_id", Value: "post2"}},
+						),
+						bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 2}},
+					)
+				},
+				expN:   2,
+				expErr: nil,
+			},
+			{
+				name: "Nothing left",
+				mockBehavior: func() {
+					mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch))
+				},
+				expN:   0,
+				expErr: nil,
+			},
+			{
+				name: "Error",
+				mockBehavior: func() {
+					mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: ErrBasic.Error()}))
+				},
+				expN:   0,
+				expErr: ErrBasic,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.mockBehavior()
+
+				n, err := repo.ReplacePostsAuthor(context.Background(), mockUser.ID, models.DeletedAuthor(), 100)
+				assert.Equal(t, tc.expN, n)
+				if tc.expErr == nil {
+					assert.NoError(t, err)
+				} else {
+					var cmdErr mongo.CommandError
+					if errors.As(err, &cmdErr) {
+						assert.Equal(t, tc.expErr.Error(), cmdErr.Message)
+					} else {
+						assert.EqualError(t, err, tc.expErr.Error())
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestGetPostsByAuthorID(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Deleted posts too", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch, toDoc(mockPosts[0], t)))
+
+		posts, err := repo.GetPostsByAuthorID(context.Background(), mockUser.ID)
+		require.NoError(t, err)
+		assert.Len(t, posts, 1)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, mockUser.ID, filter.Lookup("author.id").StringValue())
+		_, err = filter.LookupErr("deleted_at")
+		assert.Error(t, err)
+	})
+}
+
+func TestCountPostsByAuthor(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Deleted posts arent counted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: 2}},
+		))
+
+		n, err := repo.CountPostsByAuthor(context.Background(), mockUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		assert.Equal(t, mockUser.ID, match.Lookup("author.id").StringValue())
+		assert.False(t, match.Lookup("deleted_at", "$exists").Boolean())
+	})
+}
+
+func TestSumScoreByAuthor(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: nil}, {Key: "score", Value: 7}},
+		))
+
+		score, err := repo.SumScoreByAuthor(context.Background(), mockUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7, score)
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		assert.Equal(t, mockUser.ID, match.Lookup("author.id").StringValue())
+	})
+
+	mt.Run("No posts", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRepository(mt.Client, "testDB", nil)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch))
+
+		score, err := repo.SumScoreByAuthor(context.Background(), mockUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, score)
+	})
+}
+
+// TestVotePostConcurrent needs real mongo replica set (4.2+ for pipeline updates):
+// MONGO_TEST_URI=mongodb://localhost:27017/?replicaSet=rs0 go test ./internal/repository/mongorepo/
+func TestVotePostConcurrent(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI isnt set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+
+	dbName := "redditclone_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	defer client.Database(dbName).Drop(ctx)
+	require.NoError(t, mongorepo.Migrate(ctx, client, dbName))
+
+	repo := mongorepo.NewMongoPostRepository(client, dbName, nil)
+	voteRepo := mongorepo.NewMongoVoteRepo(client, dbName)
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	_, err = voteRepo.SetVote(ctx, post.Votes[0])
+	require.NoError(t, err)
+	require.NoError(t, repo.CreatePost(ctx, post))
+
+	vote := func(voter string, v int8) int8 {
+		_, prev, err := voteRepo.VotePost(ctx, &models.Vote{PostID: post.ID, UserID: voter, Vote: v})
+		assert.NoError(t, err)
+		return prev
+	}
+
+	const voters = 50
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			voter := fmt.Sprintf("voter%d", i)
+
+			// every voter downvotes, half of them change mind and upvote,
+			// every fifth one removes the vote in the end
+			vote(voter, -1)
+			if i%2 == 0 {
+				assert.Equal(t, int8(-1), vote(voter, 1))
+			}
+			if i%5 == 0 {
+				_, _, err := voteRepo.UnvotePost(ctx, post.ID, voter)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the same vote again is rejected by unique index
+	_, _, err = voteRepo.VotePost(ctx, &models.Vote{PostID: post.ID, UserID: mockUser.ID, Vote: 1})
+	assert.ErrorIs(t, err, repository.ErrVoteAlreadyExists)
+
+	got, err := repo.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+
+	// author's upvote + 20 upvotes left - 20 downvotes left
+	upvotes, downvotes := 1, 0
+	for i := 0; i < voters; i++ {
+		if i%5 == 0 {
+			continue
+		}
+		if i%2 == 0 {
+			upvotes++
+		} else {
+			downvotes++
+		}
+	}
+	assert.Equal(t, upvotes, got.Upvotes)
+	assert.Equal(t, downvotes, got.Downvotes)
+	assert.Equal(t, upvotes-downvotes, got.Score)
+	assert.Equal(t, upvotes*100/(upvotes+downvotes), got.UpvotePercentage)
+}
@@ -0,0 +1,51 @@
+package mongorepo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+const postRevisionsCollectionName = "post_revisions"
+
+type MongoPostRevisionRepo struct {
+	revisionsCollection *mongo.Collection
+}
+
+func NewMongoPostRevisionRepo(client *mongo.Client, dbName string) repository.PostRevisionRepository {
+	return &MongoPostRevisionRepo{
+		revisionsCollection: client.Database(dbName).Collection(postRevisionsCollectionName),
+	}
+}
+
+func (r *MongoPostRevisionRepo) CreateRevision(ctx context.Context, revision *models.PostRevision) error {
+	_, err := r.revisionsCollection.InsertOne(ctx, revision)
+	return err
+}
+
+// versions only grow, so they order revisions
+func (r *MongoPostRevisionRepo) GetRevisionsByPostID(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+	cursor, err := r.revisionsCollection.Find(ctx, bson.M{"post_id": postID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []*models.PostRevision{}
+	err = cursor.All(ctx, &revisions)
+	return revisions, err
+}
+
+func (r *MongoPostRevisionRepo) DeleteRevisionsByPostID(ctx context.Context, postID string) (int64, error) {
+	res, err := r.revisionsCollection.DeleteMany(ctx, bson.M{"post_id": postID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,43 @@
+package mongorepo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/myacey/redditclone/internal/repository/mongorepo"
+)
+
+func TestGetRevisionsByPostID(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRevisionRepo(mt.Client, "testDB")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.post_revisions", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "rev1"}, {Key: "post_id", Value: "post1"}, {Key: "version", Value: int64(0)}, {Key: "title", Value: "first"}},
+			bson.D{{Key: "_id", Value: "rev2"}, {Key: "post_id", Value: "post1"}, {Key: "version", Value: int64(4)}, {Key: "title", Value: "second"}},
+		))
+
+		revisions, err := repo.GetRevisionsByPostID(context.Background(), "post1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		assert.Equal(t, "second", revisions[1].Title)
+		assert.Equal(t, int64(4), revisions[1].Version)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, "post1", cmd.Lookup("filter", "post_id").StringValue())
+		assert.Equal(t, int32(1), cmd.Lookup("sort", "version").Int32())
+	})
+
+	mt.Run("Error", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoPostRevisionRepo(mt.Client, "testDB")
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: ErrBasic.Error()}))
+
+		_, err := repo.GetRevisionsByPostID(context.Background(), "post1")
+		assert.Error(t, err)
+	})
+}
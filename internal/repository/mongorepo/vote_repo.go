@@ -0,0 +1,297 @@
+package mongorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+const (
+	votesCollectionName        = "votes"
+	commentVotesCollectionName = "comment_votes"
+)
+
+// setVoteAttempts bounds retries when the same user votes concurrently
+const setVoteAttempts = 3
+
+type MongoVoteRepo struct {
+	client                 *mongo.Client
+	votesCollection        *mongo.Collection
+	commentVotesCollection *mongo.Collection
+	// vote counters of posts and comments are changed together with votes
+	postsCollection    *mongo.Collection
+	commentsCollection *mongo.Collection
+}
+
+func NewMongoVoteRepo(client *mongo.Client, dbName string) repository.VoteRepository {
+	return &MongoVoteRepo{
+		client:                 client,
+		votesCollection:        client.Database(dbName).Collection(votesCollectionName),
+		commentVotesCollection: client.Database(dbName).Collection(commentVotesCollectionName),
+		postsCollection:        client.Database(dbName).Collection("posts"),
+		commentsCollection:     client.Database(dbName).Collection("comments"),
+	}
+}
+
+func (r *MongoVoteRepo) SetVote(ctx context.Context, vote *models.Vote) (int8, error) {
+	key := bson.M{"post_id": vote.PostID, "user_id": vote.UserID}
+	return setVote(ctx, r.votesCollection, key, nil, vote)
+}
+
+func (r *MongoVoteRepo) VotePost(ctx context.Context, vote *models.Vote) (*models.Post, int8, error) {
+	key := bson.M{"post_id": vote.PostID, "user_id": vote.UserID}
+
+	var post models.Post
+	prev, err := r.voteInTransaction(ctx, r.votesCollection, key, nil, vote, func(ctx mongo.SessionContext, prev int8) error {
+		return addVoteCounters(ctx, r.postsCollection, vote.PostID, vote.Vote, prev, &post, repository.ErrPostDontExists)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &post, prev, nil
+}
+
+func (r *MongoVoteRepo) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, int8, error) {
+	key := bson.M{"comment_id": vote.CommentID, "user_id": vote.UserID}
+
+	var comment models.Comment
+	prev, err := r.voteInTransaction(ctx, r.commentVotesCollection, key, bson.M{"post_id": vote.PostID}, vote, func(ctx mongo.SessionContext, prev int8) error {
+		return addVoteCounters(ctx, r.commentsCollection, vote.CommentID, vote.Vote, prev, &comment, repository.ErrCommentDontExists)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &comment, prev, nil
+}
+
+// voteInTransaction is setVote which changes counters in the same transaction.
+// Duplicate key aborts transaction, so it is checked after it like in setVote.
+func (r *MongoVoteRepo) voteInTransaction(ctx context.Context, collection *mongo.Collection, key, onInsert bson.M, vote *models.Vote,
+	addCounters func(ctx mongo.SessionContext, prev int8) error,
+) (int8, error) {
+	filter, update, opts := setVoteQuery(key, onInsert, vote)
+
+	for i := 0; i < setVoteAttempts; i++ {
+		var prev int8
+		err := inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+			var err error
+			if prev, err = upsertVote(ctx, collection, filter, update, opts); err != nil {
+				return err
+			}
+			return addCounters(ctx, prev)
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			return prev, err
+		}
+
+		if err = sameVoteExists(ctx, collection, key, vote); err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, fmt.Errorf("vote of %s on %v changed concurrently %d times", vote.UserID, key, setVoteAttempts)
+}
+
+// addVoteCounters changes counters of voted post or comment by difference
+// of vote and previous one and decodes it into doc, notFound if there is none
+func addVoteCounters(ctx context.Context, collection *mongo.Collection, id string, vote, prev int8, doc interface{}, notFound error) error {
+	upvotes, downvotes := models.VoteCounters(vote)
+	prevUpvotes, prevDownvotes := models.VoteCounters(prev)
+	update := voteCountersUpdate(upvotes-prevUpvotes, downvotes-prevDownvotes)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, opts).Decode(doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound
+	}
+	return err
+}
+
+// setVote relies on unique index of key fields: the same vote doesnt
+// match the filter, so upsert tries to insert second vote and fails.
+// onInsert fields are saved only with new vote.
+func setVote(ctx context.Context, collection *mongo.Collection, key, onInsert bson.M, vote *models.Vote) (int8, error) {
+	filter, update, opts := setVoteQuery(key, onInsert, vote)
+
+	for i := 0; i < setVoteAttempts; i++ {
+		prev, err := upsertVote(ctx, collection, filter, update, opts)
+		if !mongo.IsDuplicateKeyError(err) {
+			return prev, err
+		}
+
+		if err = sameVoteExists(ctx, collection, key, vote); err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, fmt.Errorf("vote of %s on %v changed concurrently %d times", vote.UserID, key, setVoteAttempts)
+}
+
+func setVoteQuery(key, onInsert bson.M, vote *models.Vote) (filter, update bson.M, opts *options.FindOneAndUpdateOptions) {
+	filter = bson.M{"vote": bson.M{"$ne": vote.Vote}}
+	for field, value := range key {
+		filter[field] = value
+	}
+	update = bson.M{"$set": bson.M{"vote": vote.Vote}}
+	if len(onInsert) > 0 {
+		update["$setOnInsert"] = onInsert
+	}
+	opts = options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	return filter, update, opts
+}
+
+// upsertVote returns previous vote, 0 if new one is inserted
+func upsertVote(ctx context.Context, collection *mongo.Collection, filter, update bson.M, opts *options.FindOneAndUpdateOptions) (int8, error) {
+	var prev models.Vote
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&prev)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return prev.Vote, nil
+}
+
+// sameVoteExists is called after duplicate key error: it is the same vote
+// or concurrent request inserted vote first, then upsert is tried again
+func sameVoteExists(ctx context.Context, collection *mongo.Collection, key bson.M, vote *models.Vote) error {
+	same := bson.M{"vote": vote.Vote}
+	for field, value := range key {
+		same[field] = value
+	}
+	err := collection.FindOne(ctx, same).Err()
+	if err == nil {
+		return repository.ErrVoteAlreadyExists
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	return nil
+}
+
+func (r *MongoVoteRepo) UnvotePost(ctx context.Context, postID, userID string) (*models.Post, int8, error) {
+	var (
+		post models.Post
+		prev int8
+	)
+	err := inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var err error
+		if prev, err = deleteVote(ctx, r.votesCollection, bson.M{"post_id": postID, "user_id": userID}); err != nil {
+			return err
+		}
+		return addVoteCounters(ctx, r.postsCollection, postID, 0, prev, &post, repository.ErrPostDontExists)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &post, prev, nil
+}
+
+func (r *MongoVoteRepo) UnvoteComment(ctx context.Context, commentID, userID string) (*models.Comment, int8, error) {
+	var (
+		comment models.Comment
+		prev    int8
+	)
+	err := inTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var err error
+		if prev, err = deleteVote(ctx, r.commentVotesCollection, bson.M{"comment_id": commentID, "user_id": userID}); err != nil {
+			return err
+		}
+		return addVoteCounters(ctx, r.commentsCollection, commentID, 0, prev, &comment, repository.ErrCommentDontExists)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &comment, prev, nil
+}
+
+func deleteVote(ctx context.Context, collection *mongo.Collection, key bson.M) (int8, error) {
+	var vote models.Vote
+	err := collection.FindOneAndDelete(ctx, key).Decode(&vote)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, repository.ErrVoteDontExists
+	} else if err != nil {
+		return 0, err
+	}
+
+	return vote.Vote, nil
+}
+
+func (r *MongoVoteRepo) GetUserVotes(ctx context.Context, userID string, postIDs []string) (map[string]int8, error) {
+	res := map[string]int8{}
+	if len(postIDs) == 0 {
+		return res, nil
+	}
+
+	votes, err := findVotes(ctx, r.votesCollection, bson.M{"user_id": userID, "post_id": bson.M{"$in": postIDs}})
+	if err != nil {
+		return nil, err
+	}
+	for _, vote := range votes {
+		res[vote.PostID] = vote.Vote
+	}
+
+	return res, nil
+}
+
+func (r *MongoVoteRepo) GetVotesByUserID(ctx context.Context, userID string) ([]*models.Vote, error) {
+	return findVotes(ctx, r.votesCollection, bson.M{"user_id": userID})
+}
+
+func (r *MongoVoteRepo) DeleteVotesByPostID(ctx context.Context, postID string) (int64, error) {
+	return deleteVotes(ctx, r.votesCollection, bson.M{"post_id": postID})
+}
+
+func (r *MongoVoteRepo) GetUserCommentVotes(ctx context.Context, userID, postID string) (map[string]int8, error) {
+	votes, err := findVotes(ctx, r.commentVotesCollection, bson.M{"user_id": userID, "post_id": postID})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]int8, len(votes))
+	for _, vote := range votes {
+		res[vote.CommentID] = vote.Vote
+	}
+	return res, nil
+}
+
+func (r *MongoVoteRepo) GetCommentVotesByUserID(ctx context.Context, userID string) ([]*models.Vote, error) {
+	return findVotes(ctx, r.commentVotesCollection, bson.M{"user_id": userID})
+}
+
+func (r *MongoVoteRepo) DeleteCommentVotesByPostID(ctx context.Context, postID string) (int64, error) {
+	return deleteVotes(ctx, r.commentVotesCollection, bson.M{"post_id": postID})
+}
+
+func (r *MongoVoteRepo) DeleteCommentVotesByCommentIDs(ctx context.Context, commentIDs []string) (int64, error) {
+	if len(commentIDs) == 0 {
+		return 0, nil
+	}
+	return deleteVotes(ctx, r.commentVotesCollection, bson.M{"comment_id": bson.M{"$in": commentIDs}})
+}
+
+func deleteVotes(ctx context.Context, collection *mongo.Collection, filter interface{}) (int64, error) {
+	res, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func findVotes(ctx context.Context, collection *mongo.Collection, filter interface{}) ([]*models.Vote, error) {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	votes := []*models.Vote{}
+	err = cursor.All(ctx, &votes)
+	return votes, err
+}
@@ -0,0 +1,370 @@
+package mongorepo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/repository/mongorepo"
+)
+
+func TestSetVote(t *testing.T) {
+	mt := setupMockDB(t)
+
+	noMatch := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+	duplicate := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Message: "E11000 duplicate key error"})
+	found := func(vote int8) bson.D {
+		return mtest.CreateCursorResponse(0, "testDB.votes", mtest.FirstBatch, bson.D{
+			{Key: "post_id", Value: mockPost.ID},
+			{Key: "user_id", Value: "voter"},
+			{Key: "vote", Value: vote},
+		})
+	}
+
+	mt.Run("Test Cases", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+
+		testCases := []struct {
+			name         string
+			mockBehavior func()
+			expPrev      int8
+			expErr       error
+		}{
+			{
+				name: "New vote",
+				mockBehavior: func() {
+					mt.AddMockResponses(noMatch)
+				},
+				expPrev: 0,
+			},
+			{
+				name: "Changed downvote",
+				mockBehavior: func() {
+					mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{
+						{Key: "post_id", Value: mockPost.ID},
+						{Key: "user_id", Value: "voter"},
+						{Key: "vote", Value: -1},
+					}}})
+				},
+				expPrev: -1,
+			},
+			{
+				name: "Already voted",
+				mockBehavior: func() {
+					mt.AddMockResponses(duplicate, found(1))
+				},
+				expErr: repository.ErrVoteAlreadyExists,
+			},
+			{
+				name: "Concurrent vote inserted first",
+				mockBehavior: func() {
+					mt.AddMockResponses(
+						duplicate,
+						mtest.CreateCursorResponse(0, "testDB.votes", mtest.FirstBatch),
+						bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "vote", Value: -1}}}},
+					)
+				},
+				expPrev: -1,
+			},
+			{
+				name: "Error",
+				mockBehavior: func() {
+					mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: ErrBasic.Error()}))
+				},
+				expErr: ErrBasic,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.mockBehavior()
+
+				prev, err := repo.SetVote(context.Background(), &models.Vote{PostID: mockPost.ID, UserID: "voter", Vote: 1})
+				if tc.expErr != nil {
+					var cmdErr mongo.CommandError
+					if errors.As(err, &cmdErr) {
+						assert.Equal(t, tc.expErr.Error(), cmdErr.Message)
+					} else {
+						assert.ErrorIs(t, err, tc.expErr)
+					}
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expPrev, prev)
+			})
+		}
+	})
+}
+
+func TestVotePost(t *testing.T) {
+	mt := setupMockDB(t)
+
+	votedPost := bson.D{
+		{Key: "_id", Value: mockPost.ID},
+		{Key: "score", Value: 2},
+		{Key: "upvotes", Value: 2},
+		{Key: "upvote_percantage", Value: 100},
+	}
+	noMatch := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+
+	mt.Run("Downvote changed to upvote", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "vote", Value: -1}}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: votedPost}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		post, prev, err := repo.VotePost(context.Background(), &models.Vote{PostID: mockPost.ID, UserID: "voter", Vote: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int8(-1), prev)
+		assert.Equal(t, 2, post.Score)
+
+		assert.Equal(t, "votes", mt.GetStartedEvent().Command.Lookup("findAndModify").StringValue())
+		counters := mt.GetStartedEvent().Command
+		assert.Equal(t, "posts", counters.Lookup("findAndModify").StringValue())
+		set := counters.Lookup("update").Array().Index(0).Value().Document().Lookup("$set").Document()
+		assert.Contains(t, set.Lookup("upvotes").String(), `"$numberInt":"1"`)
+		assert.Contains(t, set.Lookup("downvotes").String(), `"$numberInt":"-1"`)
+		// votes dont change version of post
+		_, err = set.LookupErr("version")
+		assert.Error(t, err)
+		assert.Equal(t, "commitTransaction", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("Post not found", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(noMatch, noMatch, mtest.CreateSuccessResponse())
+
+		_, _, err := repo.VotePost(context.Background(), &models.Vote{PostID: mockPost.ID, UserID: "voter", Vote: 1})
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+
+		// vote is rolled back with counters
+		mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		assert.Equal(t, "abortTransaction", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("Already voted", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Message: "E11000 duplicate key error"}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "testDB.votes", mtest.FirstBatch, bson.D{{Key: "vote", Value: 1}}),
+		)
+
+		_, _, err := repo.VotePost(context.Background(), &models.Vote{PostID: mockPost.ID, UserID: "voter", Vote: 1})
+		assert.ErrorIs(t, err, repository.ErrVoteAlreadyExists)
+	})
+}
+
+func TestUnvotePost(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "vote", Value: -1}}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: mockPost.ID}, {Key: "score", Value: 1}}}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		post, prev, err := repo.UnvotePost(context.Background(), mockPost.ID, "voter")
+		require.NoError(t, err)
+		assert.Equal(t, int8(-1), prev)
+		assert.Equal(t, 1, post.Score)
+	})
+
+	mt.Run("No vote", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}, mtest.CreateSuccessResponse())
+
+		_, _, err := repo.UnvotePost(context.Background(), mockPost.ID, "voter")
+		assert.ErrorIs(t, err, repository.ErrVoteDontExists)
+	})
+}
+
+func TestGetUserVotes(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Test Cases", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.votes", mtest.FirstBatch,
+			bson.D{{Key: "post_id", Value: "post1"}, {Key: "user_id", Value: "voter"}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "post_id", Value: "post2"}, {Key: "user_id", Value: "voter"}, {Key: "vote", Value: -1}},
+		))
+		votes, err := repo.GetUserVotes(context.Background(), "voter", []string{"post1", "post2", "post3"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int8{"post1": 1, "post2": -1}, votes)
+
+		// no posts, no query
+		votes, err = repo.GetUserVotes(context.Background(), "voter", nil)
+		assert.NoError(t, err)
+		assert.Empty(t, votes)
+	})
+}
+
+func TestVoteComment(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("New vote", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: "comment1"}, {Key: "score", Value: 1}}}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		vote := &models.Vote{PostID: mockPost.ID, CommentID: "comment1", UserID: "voter", Vote: 1}
+		comment, prev, err := repo.VoteComment(context.Background(), vote)
+		require.NoError(t, err)
+		assert.Equal(t, int8(0), prev)
+		assert.Equal(t, 1, comment.Score)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, "comment_votes", cmd.Lookup("findAndModify").StringValue())
+		assert.Equal(t, "comment1", cmd.Lookup("query", "comment_id").StringValue())
+		// post of the comment is saved only with new vote
+		assert.Equal(t, mockPost.ID, cmd.Lookup("update", "$setOnInsert", "post_id").StringValue())
+		assert.Equal(t, "comments", mt.GetStartedEvent().Command.Lookup("findAndModify").StringValue())
+	})
+
+	mt.Run("Comment not found", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		vote := &models.Vote{PostID: mockPost.ID, CommentID: "comment1", UserID: "voter", Vote: 1}
+		_, _, err := repo.VoteComment(context.Background(), vote)
+		assert.ErrorIs(t, err, repository.ErrCommentDontExists)
+	})
+}
+
+func TestUnvoteComment(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Success", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "vote", Value: 1}}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: "comment1"}}}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		_, prev, err := repo.UnvoteComment(context.Background(), "comment1", "voter")
+		require.NoError(t, err)
+		assert.Equal(t, int8(1), prev)
+
+		assert.Equal(t, "comment_votes", mt.GetStartedEvent().Command.Lookup("findAndModify").StringValue())
+		assert.Equal(t, "comments", mt.GetStartedEvent().Command.Lookup("findAndModify").StringValue())
+	})
+}
+
+func TestGetUserCommentVotes(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Test Cases", func(mt *mtest.T) {
+		repo := mongorepo.NewMongoVoteRepo(mt.Client, "testDB")
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "testDB.comment_votes", mtest.FirstBatch,
+			bson.D{{Key: "post_id", Value: "post1"}, {Key: "comment_id", Value: "comment1"}, {Key: "user_id", Value: "voter"}, {Key: "vote", Value: 1}},
+			bson.D{{Key: "post_id", Value: "post1"}, {Key: "comment_id", Value: "comment2"}, {Key: "user_id", Value: "voter"}, {Key: "vote", Value: -1}},
+		))
+		votes, err := repo.GetUserCommentVotes(context.Background(), "voter", "post1")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int8{"comment1": 1, "comment2": -1}, votes)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	mt := setupMockDB(t)
+
+	mt.Run("Embedded votes", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(), // indexes
+			mtest.CreateSuccessResponse(), // comment vote indexes
+			mtest.CreateSuccessResponse(), // comment indexes
+			mtest.CreateSuccessResponse(), // post indexes
+			mtest.CreateSuccessResponse(), // post revision indexes
+			mtest.CreateCursorResponse(0, "testDB.posts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "post1"},
+				{Key: "votes", Value: bson.A{
+					bson.D{{Key: "userid", Value: "author"}, {Key: "vote", Value: 1}},
+					bson.D{{Key: "userid", Value: "voter1"}, {Key: "vote", Value: -1}},
+					bson.D{{Key: "userid", Value: "voter2"}, {Key: "vote", Value: 1}},
+					bson.D{{Key: "userid", Value: "voter1"}, {Key: "vote", Value: 1}},
+				}},
+			}),
+			// interrupted migration already inserted one of them
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // comment paths
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // versions
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // post author passwords
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}}, // post comment author passwords
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}, // comment author passwords
+		)
+
+		err := mongorepo.Migrate(context.Background(), mt.Client, "testDB")
+		require.NoError(t, err)
+
+		var insert bson.Raw
+		var updates []bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			switch event.CommandName {
+			case "insert":
+				insert = event.Command
+			case "update":
+				updates = append(updates, event.Command)
+			}
+		}
+		require.NotNil(t, insert)
+		require.Len(t, updates, 6)
+		update, paths, versions := updates[0], updates[1], updates[2]
+
+		// second vote of voter1 is dropped
+		docs, err := insert.Lookup("documents").Array().Values()
+		require.NoError(t, err)
+		assert.Len(t, docs, 3)
+
+		set := update.Lookup("updates", "0", "u", "$set").Document()
+		assert.Equal(t, int32(2), set.Lookup("upvotes").Int32())
+		assert.Equal(t, int32(1), set.Lookup("downvotes").Int32())
+		assert.Equal(t, int32(1), set.Lookup("score").Int32())
+		assert.Equal(t, int32(66), set.Lookup("upvote_percantage").Int32())
+
+		// comments before threads become top-level ones
+		assert.Equal(t, "$_id", paths.Lookup("updates", "0", "u", "0", "$set", "path").StringValue())
+
+		assert.True(t, versions.Lookup("updates", "0", "multi").Boolean())
+		assert.Equal(t, int32(0), versions.Lookup("updates", "0", "u", "$set", "version").Int32())
+
+		// password hashes are removed from author copies
+		for i, field := range []string{"author.password", "comments.$[].author.password", "author.password"} {
+			passwords := updates[3+i]
+			assert.True(t, passwords.Lookup("updates", "0", "multi").Boolean())
+			_, err = passwords.Lookup("updates", "0", "u", "$unset").Document().LookupErr(field)
+			assert.NoError(t, err, field)
+		}
+		assert.Equal(t, "comments", updates[5].Lookup("update").StringValue())
+	})
+
+	mt.Run("Error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: ErrBasic.Error()}))
+
+		err := mongorepo.Migrate(context.Background(), mt.Client, "testDB")
+		assert.ErrorContains(t, err, "cant create vote indexes")
+	})
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/myacey/redditclone/internal/models"
 )
@@ -13,12 +15,58 @@ var (
 
 	ErrCommentAlreadyExists = errors.New("comment already exists")
 	ErrCommentDontExists    = errors.New("comment dont exist")
+	ErrCommentChanged       = errors.New("comment changed")
 )
 
+// VersionConflictError is returned when post was changed after it was read.
+type VersionConflictError struct {
+	PostID  string
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("post %s was changed after version %d", e.PostID, e.Version)
+}
+
 type PostRepository interface {
 	CreatePost(ctx context.Context, newPost *models.Post) error
+	// GetAllPosts and GetPostByID dont return deleted posts
 	GetAllPosts(ctx context.Context) ([]*models.Post, error)
 	GetPostByID(ctx context.Context, postID string) (*models.Post, error)
+	// GetDeletedPostByID returns soft deleted post, nil if there is none
+	GetDeletedPostByID(ctx context.Context, postID string) (*models.Post, error)
+	// UpdatePostInfo saves content of post only if its version is still the same,
+	// otherwise VersionConflictError is returned. Version is increased on success.
+	// Views, vote and comment counters arent saved and dont change version.
 	UpdatePostInfo(ctx context.Context, updatedPost *models.Post) error
+	IncrementViews(ctx context.Context, postID string) error
+	// GetCommentCounts returns stored comment count of every post, deleted ones too.
+	// Count is changed by CommentRepository together with comments.
+	GetCommentCounts(ctx context.Context) (map[string]int, error)
+	// SetCommentCount replaces comment count if it is still stored,
+	// false if it was changed meanwhile or there is no post
+	SetCommentCount(ctx context.Context, postID string, stored, count int) (bool, error)
+	// SoftDeletePost hides post until it is restored or purged,
+	// ErrPostDontExists if there is no post which isnt deleted
+	SoftDeletePost(ctx context.Context, postID, deletedBy string, deletedAt time.Time) error
+	// RestorePost brings back post deleted after deletedAfter,
+	// ErrPostDontExists if there is no such post
+	RestorePost(ctx context.Context, postID string, deletedAfter time.Time) error
+	// GetPostIDsDeletedBefore returns ids of at most limit posts soft deleted before time
+	GetPostIDsDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]string, error)
+	// DeletePost removes post for good
 	DeletePost(ctx context.Context, postID string) error
+	// ReplacePostsAuthor replaces author of at most limit posts of authorID,
+	// number of changed posts is returned
+	ReplacePostsAuthor(ctx context.Context, authorID string, author *models.User, limit int64) (int64, error)
+	// GetPostIDsByAuthor returns ids of all posts of authorID if limit is 0,
+	// deleted ones too
+	GetPostIDsByAuthor(ctx context.Context, authorID string, limit int64) ([]string, error)
+	// GetPostsByAuthorID returns all posts of authorID, deleted ones too
+	GetPostsByAuthorID(ctx context.Context, authorID string) ([]*models.Post, error)
+	// CountPostsByAuthor returns number of posts of authorID which arent deleted
+	CountPostsByAuthor(ctx context.Context, authorID string) (int, error)
+	// SumScoreByAuthor returns total score of posts of authorID, deleted ones too,
+	// as karma is kept when content is deleted
+	SumScoreByAuthor(ctx context.Context, authorID string) (int, error)
 }
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+type PostRevisionRepository interface {
+	CreateRevision(ctx context.Context, revision *models.PostRevision) error
+	// GetRevisionsByPostID returns revisions of the post, oldest first
+	GetRevisionsByPostID(ctx context.Context, postID string) ([]*models.PostRevision, error)
+	DeleteRevisionsByPostID(ctx context.Context, postID string) (int64, error)
+}
@@ -0,0 +1,64 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresAccessTokenRepository stores personal access tokens next to users.
+type PostgresAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresAccessTokenRepository(db *gorm.DB) repository.AccessTokenRepository {
+	return &PostgresAccessTokenRepository{db: db}
+}
+
+func (r *PostgresAccessTokenRepository) CreateAccessToken(ctx context.Context, accessToken *models.AccessToken) error {
+	return r.db.WithContext(ctx).Create(accessToken).Error
+}
+
+func (r *PostgresAccessTokenRepository) GetAccessTokensByUserID(ctx context.Context, userID string) ([]*models.AccessToken, error) {
+	var tokens []*models.AccessToken
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *PostgresAccessTokenRepository) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	var accessToken models.AccessToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&accessToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrAccessTokenDontExists
+		}
+		return nil, err
+	}
+
+	return &accessToken, nil
+}
+
+func (r *PostgresAccessTokenRepository) UpdateAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsed time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.AccessToken{}).Where("id = ?", tokenID).Update("last_used_at", lastUsed).Error
+}
+
+func (r *PostgresAccessTokenRepository) DeleteAccessToken(ctx context.Context, userID, tokenID string) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.AccessToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrAccessTokenDontExists
+	}
+
+	return nil
+}
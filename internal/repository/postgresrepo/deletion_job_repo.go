@@ -0,0 +1,71 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresDeletionJobRepository keeps progress of account deletions.
+type PostgresDeletionJobRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresDeletionJobRepository(db *gorm.DB) repository.DeletionJobRepository {
+	return &PostgresDeletionJobRepository{db: db}
+}
+
+func (r *PostgresDeletionJobRepository) CreateDeletionJob(ctx context.Context, job *models.DeletionJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *PostgresDeletionJobRepository) GetDeletionJob(ctx context.Context, jobID string) (*models.DeletionJob, error) {
+	var job models.DeletionJob
+	err := r.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrDeletionJobDontExists
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *PostgresDeletionJobRepository) GetUnfinishedDeletionJobs(ctx context.Context) ([]*models.DeletionJob, error) {
+	var jobs []*models.DeletionJob
+	err := r.db.WithContext(ctx).Where("status <> ?", models.DeletionStatusDone).Order("created_at").Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *PostgresDeletionJobRepository) ClaimDeletionJob(ctx context.Context, job *models.DeletionJob, staleBefore time.Time) error {
+	// conditional update, so every instance can resume jobs but only one runs each
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&models.DeletionJob{}).
+		Where("id = ? AND status <> ? AND (status = ? OR updated_at < ?)",
+			job.ID, models.DeletionStatusDone, models.DeletionStatusFailed, staleBefore).
+		Updates(map[string]interface{}{"status": models.DeletionStatusRunning, "updated_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrDeletionJobClaimed
+	}
+
+	job.Status = models.DeletionStatusRunning
+	job.UpdatedAt = now
+	return nil
+}
+
+func (r *PostgresDeletionJobRepository) UpdateDeletionJob(ctx context.Context, job *models.DeletionJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
@@ -0,0 +1,88 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresExportJobRepository keeps personal data exports.
+type PostgresExportJobRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresExportJobRepository(db *gorm.DB) repository.ExportJobRepository {
+	return &PostgresExportJobRepository{db: db}
+}
+
+func (r *PostgresExportJobRepository) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *PostgresExportJobRepository) GetExportJob(ctx context.Context, jobID string) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := r.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrExportJobDontExists
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *PostgresExportJobRepository) GetExportJobsByStatus(ctx context.Context, status string) ([]*models.ExportJob, error) {
+	var jobs []*models.ExportJob
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at").Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *PostgresExportJobRepository) HasRunningExportJob(ctx context.Context, userID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("user_id = ? AND status = ?", userID, models.ExportStatusRunning).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *PostgresExportJobRepository) ClaimExportJob(ctx context.Context, job *models.ExportJob, staleBefore time.Time) error {
+	// conditional update, so every instance can resume jobs but only one runs each
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("id = ? AND status = ? AND updated_at < ?", job.ID, models.ExportStatusRunning, staleBefore).
+		Update("updated_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrExportJobClaimed
+	}
+
+	job.UpdatedAt = now
+	return nil
+}
+
+func (r *PostgresExportJobRepository) UpdateExportJob(ctx context.Context, job *models.ExportJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+func (r *PostgresExportJobRepository) MarkExportDownloaded(ctx context.Context, jobID string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", jobID, models.ExportStatusReady).
+		Update("status", models.ExportStatusDownloaded)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,51 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresIdentityRepository links external accounts to users.
+type PostgresIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresIdentityRepository(db *gorm.DB) repository.IdentityRepository {
+	return &PostgresIdentityRepository{db: db}
+}
+
+func (r *PostgresIdentityRepository) CreateIdentity(ctx context.Context, identity *models.ExternalIdentity) error {
+	err := r.db.WithContext(ctx).Create(identity).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return repository.ErrIdentityAlreadyLinked
+	}
+	return err
+}
+
+func (r *PostgresIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrIdentityDontExists
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *PostgresIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID string) ([]*models.ExternalIdentity, error) {
+	var identities []*models.ExternalIdentity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
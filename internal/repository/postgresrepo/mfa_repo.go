@@ -0,0 +1,90 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresMFARepository stores TOTP secrets and hashed recovery codes next to users.
+type PostgresMFARepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresMFARepository(db *gorm.DB) repository.MFARepository {
+	return &PostgresMFARepository{db: db}
+}
+
+func (r *PostgresMFARepository) GetTOTP(ctx context.Context, userID string) (*models.TOTPConfig, error) {
+	var config models.TOTPConfig
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&config).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrTOTPDontExists
+		}
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (r *PostgresMFARepository) SaveTOTP(ctx context.Context, config *models.TOTPConfig) error {
+	return r.db.WithContext(ctx).Save(config).Error
+}
+
+func (r *PostgresMFARepository) EnableTOTP(ctx context.Context, userID string, step int64) error {
+	res := r.db.WithContext(ctx).Model(&models.TOTPConfig{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"enabled": true, "last_used_step": step})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrTOTPDontExists
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	// conditional update, so two concurrent logins cant use the same code
+	res := r.db.WithContext(ctx).Model(&models.TOTPConfig{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrTOTPCodeReused
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []*models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(codes).Error
+	})
+}
+
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	res := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrRecoveryCodeDontExists
+	}
+
+	return nil
+}
@@ -1,23 +1,48 @@
 package postgresrepo
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/validation"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func ConfigurePostgres() (*gorm.DB, error) {
 	dsn := os.Getenv("POSTGRES_DSN")
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("cant connect to postgres: %v", err)
 	}
-	if err = db.AutoMigrate(&models.User{}); err != nil {
+	if err = db.AutoMigrate(&models.User{}, &models.AccessToken{}, &models.TOTPConfig{}, &models.RecoveryCode{}, &models.ExternalIdentity{}, &models.RoleAssignment{}, &models.DeletionJob{}, &models.ExportJob{}, &models.Profile{}); err != nil {
 		return nil, err
 	}
+	if err = backfillUsernameKeys(db); err != nil {
+		return nil, fmt.Errorf("cant backfill username keys: %v", err)
+	}
 
 	return db, nil
 }
+
+// backfillUsernameKeys sets keys of users registered before they existed.
+// If old users differ only in case, the first one gets the key and others
+// keep empty one: they still can log in, but their names cant be taken again.
+func backfillUsernameKeys(db *gorm.DB) error {
+	var users []*models.User
+	if err := db.Where("username_key = '' OR username_key IS NULL").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, usr := range users {
+		err := db.Model(&models.User{}).Where("id = ?", usr.ID).
+			Update("username_key", validation.UsernameKey(usr.Username)).Error
+		if err != nil && !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresProfileRepository keeps user profiles.
+type PostgresProfileRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresProfileRepository(db *gorm.DB) repository.ProfileRepository {
+	return &PostgresProfileRepository{db: db}
+}
+
+func (r *PostgresProfileRepository) CreateProfile(ctx context.Context, profile *models.Profile) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(profile).Error
+}
+
+func (r *PostgresProfileRepository) GetProfile(ctx context.Context, userID string) (*models.Profile, error) {
+	var profile models.Profile
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&profile).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrProfileDontExists
+		}
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func (r *PostgresProfileRepository) UpdateProfile(ctx context.Context, profile *models.Profile) error {
+	res := r.db.WithContext(ctx).Model(&models.Profile{}).Where("user_id = ?", profile.UserID).
+		Updates(map[string]interface{}{
+			"display_name": profile.DisplayName,
+			"bio":          profile.Bio,
+			"avatar_url":   profile.AvatarURL,
+			"updated_at":   profile.UpdatedAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrProfileDontExists
+	}
+
+	return nil
+}
+
+func (r *PostgresProfileRepository) AddKarma(ctx context.Context, userID string, postDelta, commentDelta int) error {
+	return r.db.WithContext(ctx).Model(&models.Profile{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"post_karma":    gorm.Expr("post_karma + ?", postDelta),
+			"comment_karma": gorm.Expr("comment_karma + ?", commentDelta),
+			"updated_at":    time.Now(),
+		}).Error
+}
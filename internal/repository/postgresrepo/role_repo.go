@@ -0,0 +1,50 @@
+package postgresrepo
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// PostgresRoleRepository stores admin and moderator roles of users.
+type PostgresRoleRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresRoleRepository(db *gorm.DB) repository.RoleRepository {
+	return &PostgresRoleRepository{db: db}
+}
+
+func (r *PostgresRoleRepository) CreateRole(ctx context.Context, role *models.RoleAssignment) error {
+	err := r.db.WithContext(ctx).Create(role).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return repository.ErrRoleAlreadyAssigned
+	}
+	return err
+}
+
+func (r *PostgresRoleRepository) GetRolesByUserID(ctx context.Context, userID string) ([]*models.RoleAssignment, error) {
+	var roles []*models.RoleAssignment
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *PostgresRoleRepository) DeleteRole(ctx context.Context, roleID string) error {
+	res := r.db.WithContext(ctx).Where("id = ?", roleID).Delete(&models.RoleAssignment{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrRoleDontExists
+	}
+
+	return nil
+}
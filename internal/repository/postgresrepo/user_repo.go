@@ -2,11 +2,14 @@ package postgresrepo
 
 import (
 	"context"
+	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/myacey/redditclone/internal/models"
 	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/validation"
 )
 
 // PostgresUserRepository should implement UserRepository interface.
@@ -19,8 +22,18 @@ func NewPostgresUserRepository(db *gorm.DB) repository.UserRepository {
 	return &PostgresUserRepository{db: db}
 }
 
+// CreateUser creates user with empty profile, so join date is known.
 func (r *PostgresUserRepository) CreateUser(ctx context.Context, user *models.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.NewProfile(user.ID)).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return repository.ErrUserAlreadyExists
+	}
+	return err
 }
 
 func (r *PostgresUserRepository) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
@@ -33,12 +46,73 @@ func (r *PostgresUserRepository) GetUserByID(ctx context.Context, userID string)
 	return &usr, nil
 }
 
+// GetUserByUsername ignores case and look-alike letters,
+// exact match wins for old users registered before it.
 func (r *PostgresUserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var usr models.User
-	err := r.db.WithContext(ctx).Where("username = ?", username).First(&usr).Error
+	err := r.db.WithContext(ctx).
+		Where("username = ? OR username_key = ?", username, validation.UsernameKey(username)).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "username = ? DESC", Vars: []interface{}{username}}}).
+		Take(&usr).Error
 	if err != nil {
 		return nil, err
 	}
 
 	return &usr, nil
 }
+
+func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var usr models.User
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&usr).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &usr, nil
+}
+
+func (r *PostgresUserRepository) UpdateUserPassword(ctx context.Context, userID, passwordHash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password", passwordHash).Error
+}
+
+func (r *PostgresUserRepository) UpdateUserEmail(ctx context.Context, userID, email string) error {
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"email": email, "email_verified": false}).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return repository.ErrEmailAlreadyTaken
+	}
+	return err
+}
+
+func (r *PostgresUserRepository) VerifyUserEmail(ctx context.Context, userID, email string) error {
+	res := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND email = ?", userID, email).Update("email_verified", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrUserDontExists
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// tables arent linked with foreign keys, so rows are deleted one by one
+		related := []interface{}{
+			&models.AccessToken{},
+			&models.TOTPConfig{},
+			&models.RecoveryCode{},
+			&models.ExternalIdentity{},
+			&models.RoleAssignment{},
+			&models.Profile{},
+		}
+		for _, model := range related {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", userID).Delete(&models.User{}).Error
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
 	"github.com/myacey/redditclone/internal/repository/postgresrepo"
 )
 
@@ -41,7 +43,10 @@ func TestCreateUser(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(mockUser.ID, mockUser.Username, mockUser.Password).
+		WithArgs(mockUser.ID, mockUser.Username, mockUser.UsernameKey, mockUser.Password, mockUser.Email, mockUser.EmailVerified).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "profiles"`).
+		WithArgs(mockUser.ID, "", "", "", 0, 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -68,8 +73,8 @@ func TestGetUserByID(t *testing.T) {
 			name:   "Success",
 			userID: mockUser.ID,
 			mockBehavior: func(userID string) {
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(mockUser.ID, mockUser.Username, mockUser.Password)
+				rows := sqlmock.NewRows([]string{"id", "username", "username_key", "password"}).
+					AddRow(mockUser.ID, mockUser.Username, mockUser.UsernameKey, mockUser.Password)
 				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 ORDER BY "users"\."id" LIMIT \$2`).
 					WithArgs(userID, 1).
 					WillReturnRows(rows)
@@ -120,10 +125,23 @@ func TestGetUserByUsername(t *testing.T) {
 			name:     "Success",
 			username: mockUser.Username,
 			mockBehavior: func(username string) {
-				rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-					AddRow(mockUser.ID, mockUser.Username, mockUser.Password)
-				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 ORDER BY "users"\."id" LIMIT \$2`).
-					WithArgs(username, 1).
+				rows := sqlmock.NewRows([]string{"id", "username", "username_key", "password"}).
+					AddRow(mockUser.ID, mockUser.Username, mockUser.UsernameKey, mockUser.Password)
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 OR username_key = \$2 ORDER BY username = \$3 DESC LIMIT \$4`).
+					WithArgs(username, mockUser.UsernameKey, username, 1).
+					WillReturnRows(rows)
+			},
+			expUser: mockUser,
+			expErr:  nil,
+		},
+		{
+			name:     "Different case",
+			username: "TestUser",
+			mockBehavior: func(username string) {
+				rows := sqlmock.NewRows([]string{"id", "username", "username_key", "password"}).
+					AddRow(mockUser.ID, mockUser.Username, mockUser.UsernameKey, mockUser.Password)
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 OR username_key = \$2 ORDER BY username = \$3 DESC LIMIT \$4`).
+					WithArgs(username, mockUser.UsernameKey, username, 1).
 					WillReturnRows(rows)
 			},
 			expUser: mockUser,
@@ -133,8 +151,8 @@ func TestGetUserByUsername(t *testing.T) {
 			name:     "Err sql",
 			username: mockUser.Username,
 			mockBehavior: func(username string) {
-				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 ORDER BY "users"\."id" LIMIT \$2`).
-					WithArgs(username, 1).
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 OR username_key = \$2 ORDER BY username = \$3 DESC LIMIT \$4`).
+					WithArgs(username, mockUser.UsernameKey, username, 1).
 					WillReturnError(ErrBasic)
 			},
 			expUser: nil,
@@ -154,3 +172,426 @@ func TestGetUserByUsername(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateUserPassword(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresUserRepository(db)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name         string
+		mockBehavior func()
+		expErr       error
+	}{
+		{
+			name: "Success",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "password"=\$1 WHERE id = \$2`).
+					WithArgs("newhash", mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			expErr: nil,
+		},
+		{
+			name: "Err sql",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "password"=\$1 WHERE id = \$2`).
+					WithArgs("newhash", mockUser.ID).
+					WillReturnError(ErrBasic)
+				mock.ExpectRollback()
+			},
+			expErr: ErrBasic,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			err := repo.UpdateUserPassword(ctx, mockUser.ID, "newhash")
+			assert.Equal(t, tc.expErr, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestVerifyUserEmail(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresUserRepository(db)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name         string
+		mockBehavior func()
+		expErr       error
+	}{
+		{
+			name: "Success",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "email_verified"=\$1 WHERE id = \$2 AND email = \$3`).
+					WithArgs(true, mockUser.ID, "test@example.com").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			expErr: nil,
+		},
+		{
+			name: "Email changed",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET "email_verified"=\$1 WHERE id = \$2 AND email = \$3`).
+					WithArgs(true, mockUser.ID, "test@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expErr: repository.ErrUserDontExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			err := repo.VerifyUserEmail(ctx, mockUser.ID, "test@example.com")
+			assert.Equal(t, tc.expErr, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresUserRepository(db)
+	ctx := context.TODO()
+
+	tables := []string{"access_tokens", "totp_configs", "recovery_codes", "external_identities", "role_assignments", "profiles"}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		for _, table := range tables {
+			mock.ExpectExec(`DELETE FROM "` + table + `" WHERE user_id = \$1`).
+				WithArgs(mockUser.ID).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(`DELETE FROM "users" WHERE id = \$1`).
+			WithArgs(mockUser.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.DeleteUser(ctx, mockUser.ID)
+		assert.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Err sql", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "access_tokens" WHERE user_id = \$1`).
+			WithArgs(mockUser.ID).
+			WillReturnError(ErrBasic)
+		mock.ExpectRollback()
+
+		err := repo.DeleteUser(ctx, mockUser.ID)
+		assert.Equal(t, ErrBasic, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAddKarma(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresProfileRepository(db)
+	ctx := context.TODO()
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "profiles" SET "comment_karma"=comment_karma \+ \$1,"post_karma"=post_karma \+ \$2,"updated_at"=\$3 WHERE user_id = \$4`).
+			WithArgs(0, -2, sqlmock.AnyArg(), mockUser.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.AddKarma(ctx, mockUser.ID, -2, 0)
+		assert.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Err sql", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "profiles"`).
+			WillReturnError(ErrBasic)
+		mock.ExpectRollback()
+
+		err := repo.AddKarma(ctx, mockUser.ID, 1, 0)
+		assert.Equal(t, ErrBasic, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteAccessToken(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresAccessTokenRepository(db)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name         string
+		mockBehavior func()
+		expErr       error
+	}{
+		{
+			name: "Success",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "access_tokens" WHERE id = \$1 AND user_id = \$2`).
+					WithArgs("tokenid", mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expErr: nil,
+		},
+		{
+			name: "Not found",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "access_tokens" WHERE id = \$1 AND user_id = \$2`).
+					WithArgs("tokenid", mockUser.ID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expErr: repository.ErrAccessTokenDontExists,
+		},
+		{
+			name: "Err sql",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "access_tokens" WHERE id = \$1 AND user_id = \$2`).
+					WithArgs("tokenid", mockUser.ID).
+					WillReturnError(ErrBasic)
+				mock.ExpectRollback()
+			},
+			expErr: ErrBasic,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			err := repo.DeleteAccessToken(ctx, mockUser.ID, "tokenid")
+			assert.Equal(t, tc.expErr, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresRoleRepository(db)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name         string
+		mockBehavior func()
+		expErr       error
+	}{
+		{
+			name: "Success",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "role_assignments" WHERE id = \$1`).
+					WithArgs("roleid").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expErr: nil,
+		},
+		{
+			name: "Not found",
+			mockBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`DELETE FROM "role_assignments" WHERE id = \$1`).
+					WithArgs("roleid").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expErr: repository.ErrRoleDontExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			err := repo.DeleteRole(ctx, "roleid")
+			assert.Equal(t, tc.expErr, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAccessTokenByHash(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresAccessTokenRepository(db)
+	ctx := context.TODO()
+
+	mock.ExpectQuery(`SELECT \* FROM "access_tokens" WHERE token_hash = \$1 ORDER BY "access_tokens"\."id" LIMIT \$2`).
+		WithArgs("hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "token_hash", "scopes"}).
+			AddRow("tokenid", mockUser.ID, "bot", "hash", "{read,posts:write}"))
+
+	accessToken, err := repo.GetAccessTokenByHash(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, mockUser.ID, accessToken.UserID)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopePostsWrite}, []string(accessToken.Scopes))
+
+	mock.ExpectQuery(`SELECT \* FROM "access_tokens" WHERE token_hash = \$1 ORDER BY "access_tokens"\."id" LIMIT \$2`).
+		WithArgs("unknown", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.GetAccessTokenByHash(ctx, "unknown")
+	assert.Equal(t, repository.ErrAccessTokenDontExists, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresMFARepository(db)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		name         string
+		rowsAffected int64
+		expErr       error
+	}{
+		{
+			name:         "Success",
+			rowsAffected: 1,
+			expErr:       nil,
+		},
+		{
+			name:         "Used or unknown code",
+			rowsAffected: 0,
+			expErr:       repository.ErrRecoveryCodeDontExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "recovery_codes" SET "used_at"=\$1 WHERE user_id = \$2 AND code_hash = \$3 AND used_at IS NULL`).
+				WithArgs(sqlmock.AnyArg(), mockUser.ID, "hash").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+			mock.ExpectCommit()
+
+			err := repo.UseRecoveryCode(ctx, mockUser.ID, "hash")
+			assert.Equal(t, tc.expErr, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresMFARepository(db)
+	ctx := context.TODO()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "totp_configs" SET "last_used_step"=\$1 WHERE user_id = \$2 AND last_used_step < \$3`).
+		WithArgs(int64(100), mockUser.ID, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := repo.UseTOTPStep(ctx, mockUser.ID, 100)
+	assert.Equal(t, repository.ErrTOTPCodeReused, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimDeletionJob(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresDeletionJobRepository(db)
+	ctx := context.TODO()
+	job := models.NewDeletionJob(mockUser.ID, models.DeletionModeAnonymize)
+	staleBefore := time.Now().Add(-time.Minute)
+
+	t.Run("OK", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "deletion_jobs" SET "status"=\$1,"updated_at"=\$2 WHERE id = \$3 AND status <> \$4 AND \(status = \$5 OR updated_at < \$6\)`).
+			WithArgs(models.DeletionStatusRunning, sqlmock.AnyArg(), job.ID, models.DeletionStatusDone, models.DeletionStatusFailed, staleBefore).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.ClaimDeletionJob(ctx, job, staleBefore)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeletionStatusRunning, job.Status)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Claimed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "deletion_jobs" SET`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.ClaimDeletionJob(ctx, job, staleBefore)
+		assert.Equal(t, repository.ErrDeletionJobClaimed, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestClaimExportJob(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := postgresrepo.NewPostgresExportJobRepository(db)
+	ctx := context.TODO()
+	job := models.NewExportJob(mockUser.ID)
+	staleBefore := time.Now().Add(-time.Minute)
+
+	t.Run("OK", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "export_jobs" SET "updated_at"=\$1 WHERE id = \$2 AND status = \$3 AND updated_at < \$4`).
+			WithArgs(sqlmock.AnyArg(), job.ID, models.ExportStatusRunning, staleBefore).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.ClaimExportJob(ctx, job, staleBefore)
+		assert.NoError(t, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Claimed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "export_jobs" SET`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := repo.ClaimExportJob(ctx, job, staleBefore)
+		assert.Equal(t, repository.ErrExportJobClaimed, err)
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var ErrProfileDontExists = errors.New("profile dont exists")
+
+type ProfileRepository interface {
+	// CreateProfile does nothing if user already has profile
+	CreateProfile(ctx context.Context, profile *models.Profile) error
+	GetProfile(ctx context.Context, userID string) (*models.Profile, error)
+	// UpdateProfile saves editable fields only, karma isnt touched
+	UpdateProfile(ctx context.Context, profile *models.Profile) error
+	// AddKarma changes karma atomically, users without profile are skipped:
+	// their karma is counted from votes when profile is created
+	AddKarma(ctx context.Context, userID string, postDelta, commentDelta int) error
+}
@@ -0,0 +1,61 @@
+package redisrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+func loginAttemptsKey(key string) string {
+	return "login_attempts:" + key
+}
+
+func loginLockKey(key string) string {
+	return "login_lock:" + key
+}
+
+type RedisLoginAttemptRepo struct {
+	rdb *redis.Client
+}
+
+func NewRedisLoginAttemptRepo(rdb *redis.Client) repository.LoginAttemptRepository {
+	return &RedisLoginAttemptRepo{rdb: rdb}
+}
+
+func (r *RedisLoginAttemptRepo) IncrAttempts(ctx context.Context, key string, window time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, loginAttemptsKey(key))
+		pipe.Expire(ctx, loginAttemptsKey(key), window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (r *RedisLoginAttemptRepo) Lock(ctx context.Context, key string, duration time.Duration) error {
+	return r.rdb.Set(ctx, loginLockKey(key), 1, duration).Err()
+}
+
+func (r *RedisLoginAttemptRepo) GetLockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.rdb.PTTL(ctx, loginLockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 if key doesnt exist, -1 if it has no TTL (never set by us)
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (r *RedisLoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	return r.rdb.Del(ctx, loginAttemptsKey(key), loginLockKey(key)).Err()
+}
@@ -3,6 +3,7 @@ package redisrepo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -11,6 +12,94 @@ import (
 	"github.com/myacey/redditclone/internal/repository"
 )
 
+// every session is stored in its own key with TTL,
+// set sessions:{userID} keeps IDs of user's sessions.
+// IDs of expired sessions are removed from the set lazily.
+func sessionKey(userID, sessionID string) string {
+	return "session:" + userID + ":" + sessionID
+}
+
+func userSessionsKey(userID string) string {
+	return "sessions:" + userID
+}
+
+// access token hash points to its session, so authenticated request
+// reads one session. Index of rotated or deleted session isnt removed,
+// it expires with session and lookup checks the hash anyway.
+func sessionTokenKey(tokenHash string) string {
+	return "session_token:" + tokenHash
+}
+
+// refresh tokens are looked up only by their hash
+func refreshTokenKey(tokenHash string) string {
+	return "refresh:" + tokenHash
+}
+
+func refreshTokenUsedKey(tokenHash string) string {
+	return "refresh_used:" + tokenHash
+}
+
+// logins waiting for the second factor
+func mfaChallengeKey(tokenHash string) string {
+	return "mfa:" + tokenHash
+}
+
+func mfaAttemptsKey(tokenHash string) string {
+	return "mfa_attempts:" + tokenHash
+}
+
+// pending external provider logins
+func oauthStateKey(stateHash string) string {
+	return "oauth_state:" + stateHash
+}
+
+// single-use tokens sent by email
+func emailTokenKey(purpose, tokenHash string) string {
+	return "email_token:" + purpose + ":" + tokenHash
+}
+
+// sessionRecord is how session is stored in redis.
+// models.SessionInfo hides TokenHash from json, so we cant marshal it directly.
+type sessionRecord struct {
+	ID               string    `json:"id"`
+	UserID           string    `json:"user_id"`
+	TokenHash        string    `json:"token_hash"`
+	RefreshTokenHash string    `json:"refresh_token_hash"`
+	Device           string    `json:"device"`
+	IP               string    `json:"ip"`
+	UserAgent        string    `json:"user_agent"`
+	CreatedAt        time.Time `json:"created"`
+	LastSeen         time.Time `json:"last_seen"`
+}
+
+func newSessionRecord(s *models.SessionInfo) *sessionRecord {
+	return &sessionRecord{
+		ID:               s.ID,
+		UserID:           s.UserID,
+		TokenHash:        s.TokenHash,
+		RefreshTokenHash: s.RefreshTokenHash,
+		Device:           s.Device,
+		IP:               s.IP,
+		UserAgent:        s.UserAgent,
+		CreatedAt:        s.CreatedAt,
+		LastSeen:         s.LastSeen,
+	}
+}
+
+func (r *sessionRecord) toModel() *models.SessionInfo {
+	return &models.SessionInfo{
+		ID:               r.ID,
+		UserID:           r.UserID,
+		TokenHash:        r.TokenHash,
+		RefreshTokenHash: r.RefreshTokenHash,
+		Device:           r.Device,
+		IP:               r.IP,
+		UserAgent:        r.UserAgent,
+		CreatedAt:        r.CreatedAt,
+		LastSeen:         r.LastSeen,
+	}
+}
+
 type RedisSessionRepo struct {
 	rdb *redis.Client
 }
@@ -21,41 +110,346 @@ func NewRedisSessionRepo(rdb *redis.Client) repository.SessionRepository {
 
 func (r *RedisSessionRepo) CreateSession(
 	ctx context.Context,
-	session *models.Session,
-	userID string,
+	session *models.SessionInfo,
 	expirationTime time.Duration,
 ) error {
-	marshalled, err := session.GetMarshal()
+	marshalled, err := json.Marshal(newSessionRecord(session))
 	if err != nil {
 		return err
 	}
-	return r.rdb.Set(ctx, userID, marshalled, expirationTime).Err()
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(session.UserID, session.ID), marshalled, expirationTime)
+		pipe.Set(ctx, sessionTokenKey(session.TokenHash), session.ID, expirationTime)
+		pipe.SAdd(ctx, userSessionsKey(session.UserID), session.ID)
+		// set lives as long as the newest session
+		pipe.Expire(ctx, userSessionsKey(session.UserID), expirationTime)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisSessionRepo) GetSessionsByUserID(ctx context.Context, userID string) ([]*models.SessionInfo, error) {
+	ids, err := r.rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*models.SessionInfo{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(userID, id)
+	}
+
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.SessionInfo, 0, len(values))
+	expired := []interface{}{}
+	for i, v := range values {
+		marshalled, ok := v.(string)
+		if !ok { // key expired
+			expired = append(expired, ids[i])
+			continue
+		}
+
+		var record sessionRecord
+		if err = json.Unmarshal([]byte(marshalled), &record); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, record.toModel())
+	}
+
+	if len(expired) > 0 {
+		if err = r.rdb.SRem(ctx, userSessionsKey(userID), expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *RedisSessionRepo) GetSession(ctx context.Context, userID, sessionID string) (*models.SessionInfo, error) {
+	marshalled, err := r.rdb.Get(ctx, sessionKey(userID, sessionID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrSessionDontExists
+		}
+		return nil, err
+	}
+
+	var record sessionRecord
+	if err = json.Unmarshal([]byte(marshalled), &record); err != nil {
+		return nil, err
+	}
+
+	return record.toModel(), nil
 }
 
-func (r *RedisSessionRepo) GetSessionTokenByUsername(ctx context.Context, userID string) (string, error) {
-	var session *models.Session
-	marshalledSession, err := r.rdb.Get(ctx, userID).Result()
+func (r *RedisSessionRepo) GetSessionByTokenHash(ctx context.Context, userID, tokenHash string) (*models.SessionInfo, error) {
+	sessionID, err := r.rdb.Get(ctx, sessionTokenKey(tokenHash)).Result()
 	if err != nil {
-		return "", err
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrSessionDontExists
+		}
+		return nil, err
 	}
 
-	if err = json.Unmarshal([]byte(marshalledSession), &session); err != nil {
-		return "", err
+	session, err := r.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// token was rotated by refresh
+	if session.TokenHash != tokenHash {
+		return nil, repository.ErrSessionDontExists
 	}
 
-	return session.Token, nil
+	return session, nil
 }
 
-func (r *RedisSessionRepo) UpdateSessionToken(
+func (r *RedisSessionRepo) UpdateSession(
 	ctx context.Context,
-	newSession *models.Session,
-	userID string,
+	session *models.SessionInfo,
 	expirationTime time.Duration,
 ) error {
-	marshalled, err := json.Marshal(newSession)
+	marshalled, err := json.Marshal(newSessionRecord(session))
 	if err != nil {
 		return err
 	}
 
-	return r.rdb.Set(ctx, userID, marshalled, expirationTime).Err()
+	var set *redis.StatusCmd
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		set = pipe.SetArgs(ctx, sessionKey(session.UserID, session.ID), marshalled, redis.SetArgs{
+			Mode: "XX",
+			TTL:  expirationTime,
+		})
+		// index of missing session leads nowhere, lookup doesnt find the session
+		pipe.Set(ctx, sessionTokenKey(session.TokenHash), session.ID, expirationTime)
+		pipe.Expire(ctx, userSessionsKey(session.UserID), expirationTime)
+		return nil
+	})
+	if errors.Is(set.Err(), redis.Nil) {
+		return repository.ErrSessionDontExists
+	}
+
+	return err
+}
+
+func (r *RedisSessionRepo) UpdateSessionLastSeen(ctx context.Context, session *models.SessionInfo) error {
+	marshalled, err := json.Marshal(newSessionRecord(session))
+	if err != nil {
+		return err
+	}
+
+	// XX: dont resurrect session that expired or was revoked meanwhile
+	err = r.rdb.SetArgs(ctx, sessionKey(session.UserID, session.ID), marshalled, redis.SetArgs{
+		Mode:    "XX",
+		KeepTTL: true,
+	}).Err()
+	if errors.Is(err, redis.Nil) {
+		return repository.ErrSessionDontExists
+	}
+
+	return err
+}
+
+func (r *RedisSessionRepo) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	var del *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, sessionKey(userID, sessionID))
+		pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if del.Val() == 0 {
+		return repository.ErrSessionDontExists
+	}
+
+	return nil
+}
+
+func (r *RedisSessionRepo) DeleteSessionsByUserID(ctx context.Context, userID string) error {
+	ids, err := r.rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, sessionKey(userID, id))
+	}
+	keys = append(keys, userSessionsKey(userID))
+
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+func (r *RedisSessionRepo) CreateRefreshToken(
+	ctx context.Context,
+	refreshToken *models.RefreshToken,
+	expirationTime time.Duration,
+) error {
+	marshalled, err := json.Marshal(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Set(ctx, refreshTokenKey(refreshToken.TokenHash), marshalled, expirationTime).Err()
+}
+
+func (r *RedisSessionRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	marshalled, err := r.rdb.Get(ctx, refreshTokenKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrRefreshTokenDontExists
+		}
+		return nil, err
+	}
+
+	var refreshToken models.RefreshToken
+	if err = json.Unmarshal([]byte(marshalled), &refreshToken); err != nil {
+		return nil, err
+	}
+
+	return &refreshToken, nil
+}
+
+func (r *RedisSessionRepo) MarkRefreshTokenUsed(ctx context.Context, tokenHash string, expirationTime time.Duration) (bool, error) {
+	// SETNX makes concurrent refreshes with the same token
+	// look like reuse, so only one of them wins
+	return r.rdb.SetNX(ctx, refreshTokenUsedKey(tokenHash), 1, expirationTime).Result()
+}
+
+func (r *RedisSessionRepo) CreateMFAChallenge(
+	ctx context.Context,
+	challenge *models.MFAChallenge,
+	expirationTime time.Duration,
+) error {
+	marshalled, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Set(ctx, mfaChallengeKey(challenge.TokenHash), marshalled, expirationTime).Err()
+}
+
+func (r *RedisSessionRepo) GetMFAChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	marshalled, err := r.rdb.Get(ctx, mfaChallengeKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrMFAChallengeDontExists
+		}
+		return nil, err
+	}
+
+	var challenge models.MFAChallenge
+	if err = json.Unmarshal([]byte(marshalled), &challenge); err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (r *RedisSessionRepo) IncrMFAChallengeAttempts(ctx context.Context, tokenHash string, expirationTime time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, mfaAttemptsKey(tokenHash))
+		pipe.Expire(ctx, mfaAttemptsKey(tokenHash), expirationTime)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (r *RedisSessionRepo) DeleteMFAChallenge(ctx context.Context, tokenHash string) error {
+	return r.rdb.Del(ctx, mfaChallengeKey(tokenHash), mfaAttemptsKey(tokenHash)).Err()
+}
+
+func (r *RedisSessionRepo) CreateOAuthState(
+	ctx context.Context,
+	state *models.OAuthState,
+	expirationTime time.Duration,
+) error {
+	marshalled, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Set(ctx, oauthStateKey(state.StateHash), marshalled, expirationTime).Err()
+}
+
+func (r *RedisSessionRepo) TakeOAuthState(ctx context.Context, stateHash string) (*models.OAuthState, error) {
+	var get *redis.StringCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, oauthStateKey(stateHash))
+		pipe.Del(ctx, oauthStateKey(stateHash))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	marshalled, err := get.Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrOAuthStateDontExists
+		}
+		return nil, err
+	}
+
+	var state models.OAuthState
+	if err = json.Unmarshal([]byte(marshalled), &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (r *RedisSessionRepo) CreateEmailToken(
+	ctx context.Context,
+	emailToken *models.EmailToken,
+	expirationTime time.Duration,
+) error {
+	marshalled, err := json.Marshal(emailToken)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Set(ctx, emailTokenKey(emailToken.Purpose, emailToken.TokenHash), marshalled, expirationTime).Err()
+}
+
+func (r *RedisSessionRepo) TakeEmailToken(ctx context.Context, purpose, tokenHash string) (*models.EmailToken, error) {
+	var get *redis.StringCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, emailTokenKey(purpose, tokenHash))
+		pipe.Del(ctx, emailTokenKey(purpose, tokenHash))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	marshalled, err := get.Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, repository.ErrEmailTokenDontExists
+		}
+		return nil, err
+	}
+
+	var emailToken models.EmailToken
+	if err = json.Unmarshal([]byte(marshalled), &emailToken); err != nil {
+		return nil, err
+	}
+
+	return &emailToken, nil
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrRoleDontExists      = errors.New("role dont exists")
+	ErrRoleAlreadyAssigned = errors.New("role already assigned")
+)
+
+type RoleRepository interface {
+	CreateRole(ctx context.Context, role *models.RoleAssignment) error
+	GetRolesByUserID(ctx context.Context, userID string) ([]*models.RoleAssignment, error)
+	DeleteRole(ctx context.Context, roleID string) error
+}
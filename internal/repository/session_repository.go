@@ -2,23 +2,74 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/myacey/redditclone/internal/models"
 )
 
+var (
+	ErrSessionDontExists      = errors.New("session dont exists")
+	ErrRefreshTokenDontExists = errors.New("refresh token dont exists")
+	ErrMFAChallengeDontExists = errors.New("mfa challenge dont exists")
+	ErrOAuthStateDontExists   = errors.New("oauth state dont exists")
+
+	ErrEmailTokenDontExists = errors.New("email token dont exists")
+)
+
+// SessionRepository keeps every logged in device of the user.
 type SessionRepository interface {
 	CreateSession(
 		ctx context.Context,
-		session *models.Session,
-		username string,
+		session *models.SessionInfo,
+		expirationTime time.Duration,
+	) error
+	GetSessionsByUserID(ctx context.Context, userID string) ([]*models.SessionInfo, error)
+	GetSession(ctx context.Context, userID, sessionID string) (*models.SessionInfo, error)
+	GetSessionByTokenHash(ctx context.Context, userID, tokenHash string) (*models.SessionInfo, error)
+	// UpdateSession replaces existing session and resets its TTL
+	UpdateSession(
+		ctx context.Context,
+		session *models.SessionInfo,
+		expirationTime time.Duration,
+	) error
+	UpdateSessionLastSeen(ctx context.Context, session *models.SessionInfo) error
+	DeleteSession(ctx context.Context, userID, sessionID string) error
+	// DeleteSessionsByUserID logs user out of every device
+	DeleteSessionsByUserID(ctx context.Context, userID string) error
+
+	CreateRefreshToken(
+		ctx context.Context,
+		refreshToken *models.RefreshToken,
 		expirationTime time.Duration,
 	) error
-	GetSessionTokenByUsername(ctx context.Context, username string) (string, error)
-	UpdateSessionToken(
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed returns false if token was already used before
+	MarkRefreshTokenUsed(ctx context.Context, tokenHash string, expirationTime time.Duration) (bool, error)
+
+	CreateMFAChallenge(
+		ctx context.Context,
+		challenge *models.MFAChallenge,
+		expirationTime time.Duration,
+	) error
+	GetMFAChallenge(ctx context.Context, tokenHash string) (*models.MFAChallenge, error)
+	// IncrMFAChallengeAttempts returns number of failed attempts including this one
+	IncrMFAChallengeAttempts(ctx context.Context, tokenHash string, expirationTime time.Duration) (int64, error)
+	DeleteMFAChallenge(ctx context.Context, tokenHash string) error
+
+	CreateOAuthState(
+		ctx context.Context,
+		state *models.OAuthState,
+		expirationTime time.Duration,
+	) error
+	// TakeOAuthState returns state and deletes it, so it can be used once
+	TakeOAuthState(ctx context.Context, stateHash string) (*models.OAuthState, error)
+
+	CreateEmailToken(
 		ctx context.Context,
-		newSession *models.Session,
-		username string,
+		emailToken *models.EmailToken,
 		expirationTime time.Duration,
 	) error
+	// TakeEmailToken returns token and deletes it, so it can be used once
+	TakeEmailToken(ctx context.Context, purpose, tokenHash string) (*models.EmailToken, error)
 }
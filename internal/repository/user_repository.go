@@ -11,10 +11,21 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists in db")
 	ErrUserDontExists    = errors.New("user dont exist in db")
 	ErrInvalidToken      = errors.New("invalid token")
+	ErrEmailAlreadyTaken = errors.New("email already taken")
 )
 
 type UserRepository interface {
+	// CreateUser creates user together with empty profile
 	CreateUser(ctx context.Context, user *models.User) error
 	GetUserByID(ctx context.Context, userID string) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdateUserPassword(ctx context.Context, userID, passwordHash string) error
+	// UpdateUserEmail sets new unverified email
+	UpdateUserEmail(ctx context.Context, userID, email string) error
+	// VerifyUserEmail marks email verified if user still has it,
+	// otherwise ErrUserDontExists is returned
+	VerifyUserEmail(ctx context.Context, userID, email string) error
+	// DeleteUser removes user with tokens, 2FA, external identities, roles and profile
+	DeleteUser(ctx context.Context, userID string) error
 }
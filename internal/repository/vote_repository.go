@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/myacey/redditclone/internal/models"
+)
+
+var (
+	ErrVoteAlreadyExists = errors.New("vote already exists")
+	ErrVoteDontExists    = errors.New("vote dont exists")
+)
+
+type VoteRepository interface {
+	// SetVote creates or changes user's vote, previous vote is returned,
+	// 0 if there was none. ErrVoteAlreadyExists if vote is the same.
+	// Counters of the post arent changed, it is used for votes of new post.
+	SetVote(ctx context.Context, vote *models.Vote) (int8, error)
+	// VotePost is SetVote which changes counters of the post by difference
+	// in the same transaction, post with new counters is returned.
+	// ErrPostDontExists if there is no post which isnt deleted.
+	VotePost(ctx context.Context, vote *models.Vote) (*models.Post, int8, error)
+	// UnvotePost removes user's vote and its counters in one transaction,
+	// ErrVoteDontExists if there is no vote
+	UnvotePost(ctx context.Context, postID, userID string) (*models.Post, int8, error)
+	// GetUserVotes returns user's votes on given posts by post id
+	GetUserVotes(ctx context.Context, userID string, postIDs []string) (map[string]int8, error)
+	GetVotesByUserID(ctx context.Context, userID string) ([]*models.Vote, error)
+	DeleteVotesByPostID(ctx context.Context, postID string) (int64, error)
+
+	// VoteComment is VotePost for comments, vote has CommentID and PostID set.
+	// ErrCommentDontExists if there is no comment which isnt deleted.
+	VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, int8, error)
+	UnvoteComment(ctx context.Context, commentID, userID string) (*models.Comment, int8, error)
+	// GetUserCommentVotes returns user's votes on comments of the post by comment id
+	GetUserCommentVotes(ctx context.Context, userID, postID string) (map[string]int8, error)
+	GetCommentVotesByUserID(ctx context.Context, userID string) ([]*models.Vote, error)
+	DeleteCommentVotesByPostID(ctx context.Context, postID string) (int64, error)
+	DeleteCommentVotesByCommentIDs(ctx context.Context, commentIDs []string) (int64, error)
+}
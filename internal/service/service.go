@@ -2,13 +2,20 @@ package service
 
 import (
 	"context"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/myacey/redditclone/internal/export"
+	"github.com/myacey/redditclone/internal/mailer"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/oidc"
+	"github.com/myacey/redditclone/internal/password"
 	"github.com/myacey/redditclone/internal/repository"
 	"github.com/myacey/redditclone/internal/repository/mongorepo"
 	"github.com/myacey/redditclone/internal/repository/postgresrepo"
@@ -20,36 +27,127 @@ type ServiceInterface interface {
 	// user
 	GetUserFromDBByID(ctx context.Context, userID string) (*models.User, error)
 	GetUserFromDBByUsername(ctx context.Context, username string) (*models.User, error)
-	CreateNewUser(ctx context.Context, user *models.User) (*models.Session, error)
-	LoginUser(ctx context.Context, username string) (*models.Session, error)
+	CreateNewUser(ctx context.Context, user *models.User, client *models.ClientInfo) (*models.Session, error)
+	LoginUser(ctx context.Context, username, password string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error)
+	ForgotPassword(ctx context.Context, email string, client *models.ClientInfo) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
 
-	// post
-	GetAllPosts(ctx context.Context) ([]*models.Post, error)
+	// email
+	ChangeEmail(ctx context.Context, userID, email string) error
+	VerifyEmail(ctx context.Context, plainToken string) error
+	StartEmailLogin(ctx context.Context, email string, client *models.ClientInfo) error
+	FinishEmailLogin(ctx context.Context, plainToken string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error)
+
+	// account
+	DeleteAccount(ctx context.Context, userID, mode string) (*models.DeletionJob, error)
+	GetDeletionJob(ctx context.Context, jobID string) (*models.DeletionProgress, error)
+	ResumeDeletionJobs(ctx context.Context) error
+	RequestExport(ctx context.Context, userID string) (*models.ExportJob, error)
+	GetExport(ctx context.Context, userID, jobID string) (*models.ExportJob, error)
+	DownloadExport(ctx context.Context, jobID, expires, signature string) (io.ReadCloser, error)
+	CompleteExportDownload(ctx context.Context, jobID string) error
+	ResumeExportJobs(ctx context.Context) error
+	CleanupExports(ctx context.Context) error
+
+	// profile
+	GetProfile(ctx context.Context, username string) (*models.UserProfile, error)
+	UpdateProfile(ctx context.Context, userID string, update *models.ProfileUpdate) (*models.UserProfile, error)
+
+	// 2fa
+	EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) error
+	VerifyMFA(ctx context.Context, mfaToken, code string) (*models.Session, error)
+
+	// external login
+	StartOAuthLogin(ctx context.Context, providerName, userID string, client *models.ClientInfo) (string, error)
+	FinishOAuthLogin(ctx context.Context, providerName, state, code string) (*models.Session, *models.MFAPending, error)
+	GetExternalIdentities(ctx context.Context, userID string) ([]*models.ExternalIdentity, error)
+
+	// roles
+	GrantRole(ctx context.Context, adminID, username, role, category string) (*models.RoleAssignment, error)
+	RevokeRole(ctx context.Context, adminID, roleID string) error
+	GetUserRoles(ctx context.Context, adminID, username string) ([]*models.RoleAssignment, error)
+	UnlockLogin(ctx context.Context, adminID, username, ip string) error
+
+	// post, userID is the viewer whose vote is returned, empty for anonymous
+	GetAllPosts(ctx context.Context, userID string) ([]*models.Post, error)
 	AddPost(ctx context.Context, newPost *models.Post) error
-	GetPostByID(ctx context.Context, postID string, increateVote bool) (*models.Post, error)
-	GetPostsByAuthor(ctx context.Context, username string) ([]*models.Post, error)
-	GetPostsByCategory(ctx context.Context, category string) ([]*models.Post, error)
-	DeletePostWithID(ctx context.Context, postID string) error
+	GetPostByID(ctx context.Context, userID, postID string, increateVote bool) (*models.Post, error)
+	GetPostsByAuthor(ctx context.Context, userID, username string) ([]*models.Post, error)
+	GetPostsByCategory(ctx context.Context, userID, category string) ([]*models.Post, error)
+	DeletePostWithID(ctx context.Context, userID, postID string) error
+	EditPost(ctx context.Context, userID, postID string, edit *models.PostEdit) (*models.Post, error)
+	GetPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error)
+	GetPostDiff(ctx context.Context, postID string, from, to int) (*models.PostDiff, error)
+	RestorePost(ctx context.Context, userID, postID string) (*models.Post, error)
+	PurgeDeletedContent(ctx context.Context) error
 
 	// comment
-	RemoveComment(ctx context.Context, postID, commentID string) (*models.Post, error)
+	RemoveComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error)
+	ReplyToComment(ctx context.Context, postID, parentID string, newComment models.Comment) (*models.Post, error)
+	GetCommentReplies(ctx context.Context, userID, postID, commentID string) (*models.Comment, error)
+	EditComment(ctx context.Context, userID, postID, commentID, body string) (*models.Post, error)
+	GetCommentRevisions(ctx context.Context, userID, postID, commentID string) ([]*models.CommentRevision, error)
+	RestoreComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error)
 	AddCommentToPost(ctx context.Context, postID string, newComment models.Comment) (*models.Post, error)
+	ReconcileCommentCounts(ctx context.Context, dryRun bool) ([]*models.CommentCountDrift, error)
 
 	// session
-	CheckUserSession(ctx context.Context, userID, token string) error
+	CheckUserSession(ctx context.Context, userID, token string) (*models.SessionInfo, error)
+	RefreshSession(ctx context.Context, refreshToken string) (*models.Session, error)
+	GetUserSessions(ctx context.Context, userID, currentSessionID string) ([]*models.SessionInfo, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+
+	// personal access token
+	CreateAccessToken(ctx context.Context, userID, name string, scopes []string, expiresIn time.Duration) (*models.CreatedAccessToken, error)
+	GetAccessTokens(ctx context.Context, userID string) ([]*models.AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID string) error
+	CheckAccessToken(ctx context.Context, plainToken string) (*models.AccessToken, error)
 
 	// vote
 	VotePostWithID(ctx context.Context, postID string, newVote *models.Vote) (*models.Post, error)
 	UnvotePostWithID(ctx context.Context, postID, userID string) (*models.Post, error)
+	VoteCommentWithID(ctx context.Context, postID, commentID string, newVote *models.Vote) (*models.Post, error)
+	UnvoteCommentWithID(ctx context.Context, postID, commentID, userID string) (*models.Post, error)
 }
 
 type Service struct {
-	userRepo    repository.UserRepository
-	postRepo    repository.PostRepository
-	commentRepo repository.CommentRepository
-	sessionRepo repository.SessionRepository
+	userRepo     repository.UserRepository
+	postRepo     repository.PostRepository
+	revisionRepo repository.PostRevisionRepository
+	commentRepo  repository.CommentRepository
+	voteRepo     repository.VoteRepository
+	sessionRepo  repository.SessionRepository
 
-	tokenMaker token.TokenMaker
+	accessTokenRepo  repository.AccessTokenRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	mfaRepo          repository.MFARepository
+	identityRepo     repository.IdentityRepository
+	roleRepo         repository.RoleRepository
+	deletionJobRepo  repository.DeletionJobRepository
+	exportJobRepo    repository.ExportJobRepository
+	profileRepo      repository.ProfileRepository
+
+	tokenMaker     token.TokenMaker
+	passwordHasher password.Hasher
+	oauthProviders map[string]oidc.Provider
+	mailer         mailer.Mailer
+	// appURL is base of links sent in emails
+	appURL string
+
+	exportStore  export.Store
+	exportSigner *export.Signer
+	exportTTL    time.Duration
+
+	// commentMaxDepth is how many levels of replies are loaded at once
+	commentMaxDepth int
+	// commentEditGrace is time after writing when edits arent recorded
+	commentEditGrace time.Duration
+	// contentRetention is how long deleted posts and comments can be restored
+	contentRetention time.Duration
+
+	// jobs tracks background jobs
+	jobs sync.WaitGroup
 
 	logger *zap.SugaredLogger
 }
@@ -59,20 +157,60 @@ func NewService(db *gorm.DB,
 	mongoDatabaseName string,
 	redisPool *redis.Client,
 	tokenMaker token.TokenMaker,
+	passwordHasher password.Hasher,
+	oauthProviders map[string]oidc.Provider,
+	mail mailer.Mailer,
+	appURL string,
+	exports ExportConfig,
+	comments CommentConfig,
+	contentRetention time.Duration,
 	lg *zap.SugaredLogger,
 ) ServiceInterface {
 	userRepo := postgresrepo.NewPostgresUserRepository(db)
 	commentRepo := mongorepo.NewMongoCommentRepo(mongoClient, mongoDatabaseName)
 	postRepo := mongorepo.NewMongoPostRepository(mongoClient, mongoDatabaseName, commentRepo)
+	voteRepo := mongorepo.NewMongoVoteRepo(mongoClient, mongoDatabaseName)
+	revisionRepo := mongorepo.NewMongoPostRevisionRepo(mongoClient, mongoDatabaseName)
 	sessionRepo := redisrepo.NewRedisSessionRepo(redisPool)
+	loginAttemptRepo := redisrepo.NewRedisLoginAttemptRepo(redisPool)
+	accessTokenRepo := postgresrepo.NewPostgresAccessTokenRepository(db)
+	mfaRepo := postgresrepo.NewPostgresMFARepository(db)
+	identityRepo := postgresrepo.NewPostgresIdentityRepository(db)
+	roleRepo := postgresrepo.NewPostgresRoleRepository(db)
+	deletionJobRepo := postgresrepo.NewPostgresDeletionJobRepository(db)
+	exportJobRepo := postgresrepo.NewPostgresExportJobRepository(db)
+	profileRepo := postgresrepo.NewPostgresProfileRepository(db)
 
 	return &Service{
-		userRepo:    userRepo,
-		postRepo:    postRepo,
-		commentRepo: commentRepo,
-		sessionRepo: sessionRepo,
+		userRepo:     userRepo,
+		postRepo:     postRepo,
+		revisionRepo: revisionRepo,
+		commentRepo:  commentRepo,
+		voteRepo:     voteRepo,
+		sessionRepo:  sessionRepo,
+
+		accessTokenRepo:  accessTokenRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		mfaRepo:          mfaRepo,
+		identityRepo:     identityRepo,
+		roleRepo:         roleRepo,
+		deletionJobRepo:  deletionJobRepo,
+		exportJobRepo:    exportJobRepo,
+		profileRepo:      profileRepo,
+
+		tokenMaker:     tokenMaker,
+		passwordHasher: passwordHasher,
+		oauthProviders: oauthProviders,
+		mailer:         mail,
+		appURL:         appURL,
+
+		exportStore:  exports.Store,
+		exportSigner: export.NewSigner(exports.SigningKey),
+		exportTTL:    exports.TTL,
 
-		tokenMaker: tokenMaker,
+		commentMaxDepth:  comments.MaxDepth,
+		commentEditGrace: comments.EditGrace,
+		contentRetention: contentRetention,
 
 		logger: lg,
 	}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// CreateAccessToken creates personal access token with given scopes.
+// Zero expiresIn means token never expires.
+// Plain token is returned only here, db keeps its hash.
+func (s *Service) CreateAccessToken(ctx context.Context, userID, name string, scopes []string, expiresIn time.Duration) (*models.CreatedAccessToken, error) {
+	if name == "" {
+		return nil, errhandler.New(http.StatusBadRequest, "token name is required", "access token name is empty", nil)
+	}
+	if len(scopes) == 0 {
+		return nil, errhandler.New(http.StatusBadRequest, "at least one scope is required", "access token scopes are empty", nil)
+	}
+	if expiresIn < 0 {
+		return nil, errhandler.New(http.StatusBadRequest, "invalid expiration", "negative access token expiration", nil)
+	}
+
+	uniqueScopes := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return nil, errhandler.New(http.StatusBadRequest, "invalid scope: "+scope, "unknown access token scope: "+scope, nil)
+		}
+		if !slices.Contains(uniqueScopes, scope) {
+			uniqueScopes = append(uniqueScopes, scope)
+		}
+	}
+
+	random, err := newOpaqueToken()
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create access token", err)
+	}
+	plainToken := models.AccessTokenPrefix + random
+
+	var expiresAt *time.Time
+	if expiresIn > 0 {
+		t := time.Now().Add(expiresIn)
+		expiresAt = &t
+	}
+
+	accessToken := models.NewAccessToken(userID, name, hashToken(plainToken), uniqueScopes, expiresAt)
+	if err = s.accessTokenRepo.CreateAccessToken(ctx, accessToken); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create access token in db", err)
+	}
+
+	s.logger.Infow("created access token",
+		"user_id", userID,
+		"token_id", accessToken.ID,
+		"scopes", uniqueScopes,
+	)
+
+	return &models.CreatedAccessToken{AccessToken: accessToken, Token: plainToken}, nil
+}
+
+func (s *Service) GetAccessTokens(ctx context.Context, userID string) ([]*models.AccessToken, error) {
+	tokens, err := s.accessTokenRepo.GetAccessTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get access tokens", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *Service) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	err := s.accessTokenRepo.DeleteAccessToken(ctx, userID, tokenID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAccessTokenDontExists) {
+			return errhandler.New(http.StatusNotFound, "token not found", "access token not found: "+tokenID, nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant delete access token", err)
+	}
+
+	return nil
+}
+
+// CheckAccessToken finds personal access token the request was made with.
+func (s *Service) CheckAccessToken(ctx context.Context, plainToken string) (*models.AccessToken, error) {
+	accessToken, err := s.accessTokenRepo.GetAccessTokenByHash(ctx, hashToken(plainToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrAccessTokenDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid token", "access token not found", nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get access token", err)
+	}
+	if accessToken.Expired() {
+		return nil, errhandler.New(http.StatusUnauthorized, "token expired", "access token expired: "+accessToken.ID, nil)
+	}
+
+	now := time.Now()
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) > lastSeenUpdateInterval {
+		// last used time is informational, request shouldnt fail because of it
+		if err = s.accessTokenRepo.UpdateAccessTokenLastUsed(ctx, accessToken.ID, now); err != nil {
+			s.logger.Warnw("cant update access token last used time",
+				"token_id", accessToken.ID,
+				"err", err,
+			)
+		} else {
+			accessToken.LastUsedAt = &now
+		}
+	}
+
+	return accessToken, nil
+}
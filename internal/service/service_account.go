@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// deletionBatchSize is how many posts or comments are changed
+// between saves of deletion job progress
+const deletionBatchSize = 100
+
+// deletionJobStaleAfter is how long running job can go without saving progress
+// before it is considered dead and can be claimed by another instance
+const deletionJobStaleAfter = 10 * time.Minute
+
+// DeleteAccount deletes user and logs him out right away. His posts and comments
+// are anonymized (or purged) by background job, its progress can be seen at GetDeletionJob.
+func (s *Service) DeleteAccount(ctx context.Context, userID, mode string) (*models.DeletionJob, error) {
+	if mode == "" {
+		mode = models.DeletionModeAnonymize
+	}
+	if !models.ValidateDeletionMode(mode) {
+		return nil, errhandler.New(http.StatusBadRequest, "invalid deletion mode", "invalid deletion mode: "+mode, nil)
+	}
+
+	// job is saved first, so deletion is finished even if it fails halfway
+	job := models.NewDeletionJob(userID, mode)
+	if err := s.deletionJobRepo.CreateDeletionJob(ctx, job); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create deletion job", err)
+	}
+
+	if err := s.deleteUserData(ctx, userID); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant delete user", err)
+	}
+
+	s.logger.Infow("deleted account",
+		"user_id", userID,
+		"job_id", job.ID,
+		"mode", mode,
+	)
+
+	// runner gets its own copy, returned job is marshalled by handler
+	running := *job
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		s.runDeletionJob(context.Background(), &running)
+	}()
+
+	return job, nil
+}
+
+// GetDeletionJob returns progress of account deletion. Link isnt protected
+// by session, so only status is returned.
+func (s *Service) GetDeletionJob(ctx context.Context, jobID string) (*models.DeletionProgress, error) {
+	job, err := s.deletionJobRepo.GetDeletionJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeletionJobDontExists) {
+			return nil, errhandler.New(http.StatusNotFound, "deletion job not found", "deletion job not found: "+jobID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get deletion job", err)
+	}
+
+	return job.Progress(), nil
+}
+
+// ResumeDeletionJobs continues jobs interrupted by restart and retries failed ones.
+// Jobs are run one by one in background. It runs on every instance, so each job
+// is claimed first and skipped if another instance is running it.
+func (s *Service) ResumeDeletionJobs(ctx context.Context) error {
+	jobs, err := s.deletionJobRepo.GetUnfinishedDeletionJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("cant get unfinished deletion jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	s.logger.Infow("resuming deletion jobs",
+		"count", len(jobs),
+	)
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		for _, job := range jobs {
+			ctx := context.Background()
+			err := s.deletionJobRepo.ClaimDeletionJob(ctx, job, time.Now().Add(-deletionJobStaleAfter))
+			if err != nil {
+				if !errors.Is(err, repository.ErrDeletionJobClaimed) {
+					s.logger.Errorw("cant claim deletion job",
+						"job_id", job.ID,
+						"err", err,
+					)
+				}
+				continue
+			}
+			s.runDeletionJob(ctx, job)
+		}
+	}()
+
+	return nil
+}
+
+// deleteUserData removes everything except posts and comments.
+// It is safe to repeat, so resumed job starts with it too.
+func (s *Service) deleteUserData(ctx context.Context, userID string) error {
+	if err := s.userRepo.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("cant delete user: %w", err)
+	}
+	if err := s.sessionRepo.DeleteSessionsByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("cant revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// runDeletionJob processes job and saves its result.
+func (s *Service) runDeletionJob(ctx context.Context, job *models.DeletionJob) {
+	err := s.processDeletionJob(ctx, job)
+
+	now := time.Now()
+	job.UpdatedAt = now
+	if err != nil {
+		s.logger.Errorw("deletion job failed",
+			"job_id", job.ID,
+			"err", err,
+		)
+		job.Status = models.DeletionStatusFailed
+		job.LastError = err.Error()
+	} else {
+		s.logger.Infow("deletion job finished",
+			"job_id", job.ID,
+			"posts", job.PostsProcessed,
+			"comments", job.CommentsProcessed,
+		)
+		job.Status = models.DeletionStatusDone
+		job.LastError = ""
+		job.FinishedAt = &now
+	}
+
+	if err = s.deletionJobRepo.UpdateDeletionJob(ctx, job); err != nil {
+		s.logger.Errorw("cant save deletion job",
+			"job_id", job.ID,
+			"err", err,
+		)
+	}
+}
+
+// processDeletionJob goes through comments, then posts in batches.
+// Processed documents dont belong to user anymore, so after restart
+// job just continues with the rest.
+func (s *Service) processDeletionJob(ctx context.Context, job *models.DeletionJob) error {
+	if err := s.deleteUserData(ctx, job.UserID); err != nil {
+		return err
+	}
+
+	job.Status = models.DeletionStatusRunning
+	if job.Mode == models.DeletionModePurge {
+		if err := s.removeUserVotes(ctx, job.UserID); err != nil {
+			return fmt.Errorf("cant remove votes: %w", err)
+		}
+	}
+
+	for {
+		n, err := s.processCommentsBatch(ctx, job)
+		if err != nil {
+			return fmt.Errorf("cant process comments: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+		job.CommentsProcessed += n
+		if err = s.saveDeletionProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	for {
+		n, err := s.processPostsBatch(ctx, job)
+		if err != nil {
+			return fmt.Errorf("cant process posts: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+		job.PostsProcessed += n
+		if err = s.saveDeletionProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeUserVotes takes back votes of purged user on posts and comments
+// together with their counters and authors' karma. Votes on deleted content
+// are left, they are removed when that content is purged.
+func (s *Service) removeUserVotes(ctx context.Context, userID string) error {
+	votes, err := s.voteRepo.GetVotesByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, vote := range votes {
+		post, prev, err := s.voteRepo.UnvotePost(ctx, vote.PostID, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrPostDontExists) || errors.Is(err, repository.ErrVoteDontExists) {
+				continue
+			}
+			return err
+		}
+		if post.Author != nil && post.Author.ID != userID {
+			s.addKarma(ctx, post.Author, -int(prev), 0)
+		}
+	}
+
+	commentVotes, err := s.voteRepo.GetCommentVotesByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, vote := range commentVotes {
+		comment, prev, err := s.voteRepo.UnvoteComment(ctx, vote.CommentID, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrCommentDontExists) || errors.Is(err, repository.ErrVoteDontExists) {
+				continue
+			}
+			return err
+		}
+		if comment.Author != nil && comment.Author.ID != userID {
+			s.addKarma(ctx, comment.Author, 0, -int(prev))
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) saveDeletionProgress(ctx context.Context, job *models.DeletionJob) error {
+	job.UpdatedAt = time.Now()
+	if err := s.deletionJobRepo.UpdateDeletionJob(ctx, job); err != nil {
+		return fmt.Errorf("cant save progress: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) processCommentsBatch(ctx context.Context, job *models.DeletionJob) (int64, error) {
+	if job.Mode != models.DeletionModePurge {
+		return s.commentRepo.ReplaceCommentsAuthor(ctx, job.UserID, models.DeletedAuthor(), deletionBatchSize)
+	}
+
+	comments, err := s.commentRepo.DeleteCommentsByAuthor(ctx, job.UserID, deletionBatchSize)
+	if err != nil || len(comments) == 0 {
+		return 0, err
+	}
+	commentIDs := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		commentIDs = append(commentIDs, comment.ID)
+	}
+	if _, err = s.voteRepo.DeleteCommentVotesByCommentIDs(ctx, commentIDs); err != nil {
+		return 0, err
+	}
+
+	return int64(len(comments)), nil
+}
+
+func (s *Service) processPostsBatch(ctx context.Context, job *models.DeletionJob) (int64, error) {
+	if job.Mode != models.DeletionModePurge {
+		return s.postRepo.ReplacePostsAuthor(ctx, job.UserID, models.DeletedAuthor(), deletionBatchSize)
+	}
+
+	postIDs, err := s.postRepo.GetPostIDsByAuthor(ctx, job.UserID, deletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, postID := range postIDs {
+		// comments of other users go with the post, so does karma of their votes
+		comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID)
+		if err != nil {
+			return 0, err
+		}
+		deleted, err := s.purgePost(ctx, postID)
+		if err != nil {
+			return 0, err
+		}
+		job.CommentsProcessed += deleted
+
+		for _, comment := range comments {
+			if comment.Author != nil && comment.Author.ID != job.UserID {
+				s.addKarma(ctx, comment.Author, 0, -comment.Score)
+			}
+		}
+	}
+
+	return int64(len(postIDs)), nil
+}
@@ -2,18 +2,222 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+const (
+	// session lives as long as its refresh token,
+	// access token lifetime is configured in token maker
+	refreshTokenTTL = 30 * 24 * time.Hour
+	// dont write to redis on every request
+	lastSeenUpdateInterval = time.Minute
 )
 
-func (s *Service) CheckUserSession(ctx context.Context, userID, token string) error {
-	dbToken, err := s.sessionRepo.GetSessionTokenByUsername(ctx, userID)
+// hashToken is used to store tokens in session repo,
+// so leaked redis dump cant be used to log in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newOpaqueToken generates random token for refresh and personal access tokens.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// issueTokens creates access and refresh tokens for user.
+func (s *Service) issueTokens(usr *models.User) (*models.Session, error) {
+	token, err := s.tokenMaker.CreateToken(usr)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create token", err)
+	}
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create refresh token", err)
+	}
+
+	s.logger.Infow("created token",
+		"username", usr.Username,
+		"user_id", usr.ID,
+	)
+
+	session := models.NewSession(token)
+	session.RefreshToken = refreshToken
+	return session, nil
+}
+
+// createSession creates tokens for user and adds new device session.
+func (s *Service) createSession(ctx context.Context, usr *models.User, client *models.ClientInfo) (*models.Session, error) {
+	session, err := s.issueTokens(usr)
+	if err != nil {
+		return nil, err
+	}
+
+	info := models.NewSessionInfo(usr.ID, hashToken(session.Token), client)
+	info.RefreshTokenHash = hashToken(session.RefreshToken)
+	err = s.sessionRepo.CreateSession(ctx, info, refreshTokenTTL)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create session in db", err)
+	}
+
+	err = s.sessionRepo.CreateRefreshToken(ctx, models.NewRefreshToken(info.RefreshTokenHash, info), refreshTokenTTL)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create refresh token in db", err)
+	}
+
+	return session, nil
+}
+
+// RefreshSession exchanges refresh token for new access and refresh tokens.
+// Every refresh token can be used only once: presenting already used
+// token means it was stolen, so the whole session gets revoked.
+func (s *Service) RefreshSession(ctx context.Context, refreshToken string) (*models.Session, error) {
+	refreshHash := hashToken(refreshToken)
+
+	stored, err := s.sessionRepo.GetRefreshToken(ctx, refreshHash)
 	if err != nil {
-		return err
+		if errors.Is(err, repository.ErrRefreshTokenDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "refresh token not found", nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get refresh token", err)
 	}
 
-	if dbToken == token {
-		return nil
+	firstUse, err := s.sessionRepo.MarkRefreshTokenUsed(ctx, refreshHash, refreshTokenTTL)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant mark refresh token used", err)
+	}
+	if !firstUse {
+		s.revokeRefreshTokenFamily(ctx, stored)
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "refresh token reuse detected", nil)
+	}
+
+	info, err := s.sessionRepo.GetSession(ctx, stored.UserID, stored.SessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "session was revoked: "+stored.SessionID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get session", err)
+	}
+	if info.RefreshTokenHash != refreshHash {
+		// token from the family, but not the latest one
+		s.revokeRefreshTokenFamily(ctx, stored)
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "outdated refresh token", nil)
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, info.UserID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "cant find user: "+info.UserID, nil)
+	}
+
+	session, err := s.issueTokens(usr)
+	if err != nil {
+		return nil, err
+	}
+
+	info.TokenHash = hashToken(session.Token)
+	info.RefreshTokenHash = hashToken(session.RefreshToken)
+	info.LastSeen = time.Now()
+
+	// register new refresh token first, so session never points to unknown token
+	err = s.sessionRepo.CreateRefreshToken(ctx, models.NewRefreshToken(info.RefreshTokenHash, info), refreshTokenTTL)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create refresh token in db", err)
+	}
+
+	err = s.sessionRepo.UpdateSession(ctx, info, refreshTokenTTL)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid refresh token", "session was revoked: "+info.ID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant update session", err)
+	}
+
+	return session, nil
+}
+
+func (s *Service) revokeRefreshTokenFamily(ctx context.Context, stored *models.RefreshToken) {
+	s.logger.Warnw("refresh token reuse, revoking session",
+		"user_id", stored.UserID,
+		"session_id", stored.SessionID,
+	)
+
+	err := s.sessionRepo.DeleteSession(ctx, stored.UserID, stored.SessionID)
+	if err != nil && !errors.Is(err, repository.ErrSessionDontExists) {
+		s.logger.Errorw("cant revoke session",
+			"user_id", stored.UserID,
+			"session_id", stored.SessionID,
+			"err", err,
+		)
+	}
+}
+
+// CheckUserSession checks that token belongs to one of user's active sessions.
+func (s *Service) CheckUserSession(ctx context.Context, userID, token string) (*models.SessionInfo, error) {
+	info, err := s.sessionRepo.GetSessionByTokenHash(ctx, userID, hashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid token", "session not found for user "+userID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get session", err)
+	}
+
+	if time.Since(info.LastSeen) > lastSeenUpdateInterval {
+		info.LastSeen = time.Now()
+		if err = s.sessionRepo.UpdateSessionLastSeen(ctx, info); err != nil {
+			s.logger.Warnw("cant update session last seen",
+				"user_id", userID,
+				"session_id", info.ID,
+				"err", err,
+			)
+		}
+	}
+
+	return info, nil
+}
+
+// GetUserSessions returns user's sessions, most recently used first.
+// Session with currentSessionID is marked as current.
+func (s *Service) GetUserSessions(ctx context.Context, userID, currentSessionID string) ([]*models.SessionInfo, error) {
+	sessions, err := s.sessionRepo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get sessions", err)
+	}
+
+	for _, v := range sessions {
+		v.Current = v.ID == currentSessionID
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession logs user out of the device.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	err := s.sessionRepo.DeleteSession(ctx, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionDontExists) {
+			return errhandler.New(http.StatusNotFound, "session not found", "session not found: "+sessionID, nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant delete session", err)
 	}
 
-	return fmt.Errorf("invalid token")
+	return nil
 }
@@ -3,23 +3,38 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
 )
 
+const (
+	DefaultCommentMaxDepth  = 5
+	DefaultCommentEditGrace = 3 * time.Minute
+)
+
+// CommentConfig configures comment threads and editing.
+type CommentConfig struct {
+	// MaxDepth is how many levels of replies are loaded at once
+	MaxDepth int
+	// EditGrace is time after writing when edits arent recorded,
+	// so quick typo fixes dont show comment as edited
+	EditGrace time.Duration
+}
+
 var (
 	ErrCommentAlreadyExists = errors.New("comment already exists")
 	ErrCommentDontExists    = errors.New("comment dont exists")
 )
 
-func (s *Service) increatePostCommentCount(ctx context.Context, post *models.Post) error {
-	post.CommentCount++
-
-	return s.postRepo.UpdatePostInfo(ctx, post)
-}
-
 // createComment creates new comment
 // intaraction with comment's functions excluesevly from posts
 func (s *Service) createComment(ctx context.Context, newComment *models.Comment) error {
@@ -42,51 +57,303 @@ func (s *Service) AddCommentToPost(ctx context.Context, postID string, newCommen
 	if len(newComment.Body) == 0 {
 		return nil, ErrCommentCantBeNull
 	}
+	if err := requireVerifiedEmail(newComment.Author); err != nil {
+		return nil, err
+	}
 
 	// check if post exist
 	gotPost, err := s.postRepo.GetPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
+	if gotPost == nil {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
 
-	// create comment with internal func
+	// create comment with internal func, post's comment count is changed with it
 	err = s.createComment(ctx, &newComment)
+	if errors.Is(err, repository.ErrPostDontExists) {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post was deleted meanwhile: "+postID, nil)
+	} else if err != nil {
+		return nil, err
+	}
+	gotPost.CommentCount++
+
+	if err = s.withUserVotes(ctx, newComment.Author.ID, gotPost); err != nil {
+		return nil, err
+	}
+
+	return s.withComments(ctx, newComment.Author.ID, gotPost)
+}
+
+// ReplyToComment adds newComment as reply to parentID comment of the post.
+func (s *Service) ReplyToComment(ctx context.Context, postID, parentID string, newComment models.Comment) (*models.Post, error) {
+	parent, err := s.commentRepo.GetCommentByID(ctx, parentID)
 	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get comment", err)
+	}
+	if parent == nil || parent.DeletedAt != nil || parent.RelatedPostID != postID {
+		return nil, errhandler.New(http.StatusNotFound, "comment not found", "comment "+parentID+" not found in post "+postID, nil)
+	}
+
+	newComment.SetParent(parent)
+	return s.AddCommentToPost(ctx, postID, newComment)
+}
+
+// GetCommentReplies returns comment with its replies, used to continue
+// threads cut by max depth. Comments of deleted post are hidden with it.
+func (s *Service) GetCommentReplies(ctx context.Context, userID, postID, commentID string) (*models.Comment, error) {
+	if err := s.requirePost(ctx, postID); err != nil {
 		return nil, err
 	}
 
-	comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID)
+	comment, err := s.commentRepo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get comment", err)
+	}
+	if comment == nil || comment.RelatedPostID != postID {
+		return nil, errhandler.New(http.StatusNotFound, "comment not found", "comment "+commentID+" not found in post "+postID, nil)
+	}
+
+	comment.Replies, err = s.commentThread(ctx, userID, postID, comment)
 	if err != nil {
 		return nil, err
 	}
+	if comment.DeletedAt != nil {
+		comment.Tombstone()
+	}
+	return comment, nil
+}
+
+// commentThread returns tree of replies to root, whole post if root is nil.
+// Only max depth levels are loaded at once.
+func (s *Service) commentThread(ctx context.Context, userID, postID string, root *models.Comment) ([]*models.Comment, error) {
+	rootID, rootPath, rootDepth := "", "", -1
+	if root != nil {
+		rootID, rootPath, rootDepth = root.ID, root.Path, root.Depth
+	}
+	lastDepth := rootDepth + s.maxCommentDepth()
 
-	err = s.increatePostCommentCount(ctx, gotPost)
+	// one more level tells which comments have replies left out
+	comments, err := s.commentRepo.GetCommentThread(ctx, postID, rootPath, lastDepth+1)
 	if err != nil {
+		return nil, errhandler.New(http.StatusBadRequest, "cant find comments", "invalid params to find comments", err)
+	}
+
+	voted := comments
+	if root != nil {
+		voted = append(voted, root)
+	}
+	if err = s.withCommentVotes(ctx, userID, postID, voted); err != nil {
 		return nil, err
 	}
 
-	gotPost.Comments = comments
+	return commentTree(comments, rootID, lastDepth), nil
+}
 
-	return gotPost, nil
+func (s *Service) maxCommentDepth() int {
+	if s.commentMaxDepth <= 0 {
+		return DefaultCommentMaxDepth
+	}
+	return s.commentMaxDepth
+}
+
+// commentTree puts comments into replies of their parents, oldest first.
+// Comments deeper than lastDepth are left out, their parent gets cursor
+// to load them. Deleted comment with replies is shown as tombstone, reply
+// of purged comment goes to nearest ancestor left.
+func commentTree(comments []*models.Comment, rootID string, lastDepth int) []*models.Comment {
+	slices.SortStableFunc(comments, func(a, b *models.Comment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	byID := make(map[string]*models.Comment, len(comments))
+	for _, comment := range comments {
+		comment.Replies = []*models.Comment{}
+		byID[comment.ID] = comment
+	}
+
+	tree := []*models.Comment{}
+	for _, comment := range comments {
+		var parent *models.Comment
+		for _, id := range comment.Ancestors() {
+			if id == rootID {
+				break
+			}
+			if parent = byID[id]; parent != nil {
+				break
+			}
+		}
+
+		switch {
+		case parent == nil:
+			tree = append(tree, comment)
+		case comment.Depth > lastDepth:
+			parent.MoreReplies = parent.ID
+		default:
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+
+	return pruneDeleted(tree)
+}
+
+// pruneDeleted drops deleted comments left without replies,
+// the others become tombstones.
+func pruneDeleted(comments []*models.Comment) []*models.Comment {
+	kept := comments[:0]
+	for _, comment := range comments {
+		comment.Replies = pruneDeleted(comment.Replies)
+		if comment.DeletedAt != nil {
+			if len(comment.Replies) == 0 && comment.MoreReplies == "" {
+				continue
+			}
+			comment.Tombstone()
+		}
+		kept = append(kept, comment)
+	}
+	return kept
 }
 
-func (s *Service) RemoveComment(ctx context.Context, postID, commentID string) (*models.Post, error) {
+func (s *Service) RemoveComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error) {
 	// check if post really exists
 	gotPost, err := s.postRepo.GetPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
+	if gotPost == nil {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
+
+	gotComment, err := s.commentRepo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if gotComment == nil || gotComment.DeletedAt != nil || gotComment.RelatedPostID != postID {
+		return nil, errhandler.New(http.StatusNotFound, "comment not found", "comment "+commentID+" not found in post "+postID, nil)
+	}
 
-	err = s.deleteComment(ctx, commentID)
+	// moderators are bound to category of the post
+	err = s.authorizeContentDelete(ctx, userID, gotComment.Author, gotPost.Category)
 	if err != nil {
 		return nil, err
 	}
 
-	comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID)
+	// votes are kept until comment is purged
+	err = s.commentRepo.SoftDeleteComment(ctx, commentID, userID, time.Now())
+	if errors.Is(err, repository.ErrCommentDontExists) {
+		return nil, errhandler.New(http.StatusNotFound, "comment not found", "comment was deleted meanwhile: "+commentID, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = s.withUserVotes(ctx, userID, gotPost); err != nil {
+		return nil, err
+	}
+
+	return s.withComments(ctx, userID, gotPost)
+}
+
+// EditComment changes body of user's own comment. Edits in grace window
+// after comment was written replace body, later ones keep previous body
+// in history and mark comment as edited.
+func (s *Service) EditComment(ctx context.Context, userID, postID, commentID, body string) (*models.Post, error) {
+	if len(body) == 0 {
+		return nil, ErrCommentCantBeNull
+	}
+
+	gotPost, gotComment, err := s.requireComment(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if gotComment.Author == nil || gotComment.Author.ID != userID {
+		return nil, errhandler.New(http.StatusForbidden, "only author can edit comment", "user "+userID+" isnt author of comment "+commentID, nil)
+	}
+
+	if body != gotComment.Body {
+		prevBody := gotComment.Body
+		written := gotComment.CreatedAt
+		if gotComment.Edited != nil {
+			written = *gotComment.Edited
+		}
+
+		var revision *models.CommentRevision
+		now := time.Now()
+		if now.Sub(written) >= s.commentEditGrace {
+			revision = &models.CommentRevision{Body: prevBody, CreatedAt: written}
+			gotComment.Edited = &now
+		}
+		gotComment.Body = body
+
+		err = s.commentRepo.EditComment(ctx, gotComment, prevBody, revision)
+		if errors.Is(err, repository.ErrCommentDontExists) {
+			return nil, errhandler.New(http.StatusNotFound, "comment not found", "comment was deleted: "+commentID, nil)
+		} else if errors.Is(err, repository.ErrCommentChanged) {
+			return nil, errhandler.New(http.StatusConflict, "comment was changed, try again", "comment changed during edit: "+commentID, nil)
+		} else if err != nil {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant edit comment", err)
+		}
+	}
+
+	if err = s.withUserVotes(ctx, userID, gotPost); err != nil {
+		return nil, err
+	}
+
+	return s.withComments(ctx, userID, gotPost)
+}
+
+// GetCommentRevisions returns bodies comment had, oldest first and current
+// one last. Author and moderators of post's category can see them.
+func (s *Service) GetCommentRevisions(ctx context.Context, userID, postID, commentID string) ([]*models.CommentRevision, error) {
+	gotPost, gotComment, err := s.requireComment(ctx, postID, commentID)
 	if err != nil {
 		return nil, err
 	}
-	gotPost.Comments = comments
 
-	return gotPost, nil
+	if err = s.authorizeContentHistory(ctx, userID, gotComment.Author, gotPost.Category); err != nil {
+		return nil, err
+	}
+
+	current := &models.CommentRevision{Body: gotComment.Body, CreatedAt: gotComment.CreatedAt}
+	if gotComment.Edited != nil {
+		current.CreatedAt = *gotComment.Edited
+	}
+
+	return append(gotComment.Revisions, current), nil
+}
+
+// ReconcileCommentCounts recounts comments of every post and fixes stored
+// counts which drifted, unless it is dry run. Drifted posts are returned.
+func (s *Service) ReconcileCommentCounts(ctx context.Context, dryRun bool) ([]*models.CommentCountDrift, error) {
+	// counts are read before comments, so post commented in between
+	// doesnt match stored count anymore and is skipped
+	stored, err := s.postRepo.GetCommentCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cant get comment counts: %w", err)
+	}
+	actual, err := s.commentRepo.CountCommentsByPost(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cant count comments: %w", err)
+	}
+
+	drifts := []*models.CommentCountDrift{}
+	for postID, count := range stored {
+		if actual[postID] == count {
+			continue
+		}
+
+		drift := &models.CommentCountDrift{PostID: postID, Stored: count, Actual: actual[postID]}
+		if !dryRun {
+			drift.Fixed, err = s.postRepo.SetCommentCount(ctx, postID, count, drift.Actual)
+			if err != nil {
+				return nil, fmt.Errorf("cant fix comment count of post %s: %w", postID, err)
+			}
+		}
+		drifts = append(drifts, drift)
+	}
+
+	slices.SortFunc(drifts, func(a, b *models.CommentCountDrift) int {
+		return strings.Compare(a.PostID, b.PostID)
+	})
+	return drifts, nil
 }
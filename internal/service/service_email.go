@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/mailer"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+const (
+	emailVerifyTTL = 24 * time.Hour
+	emailLoginTTL  = 15 * time.Minute
+)
+
+// emails are compared case-insensitively
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// createEmailToken saves single-use token for user's current email.
+func (s *Service) createEmailToken(ctx context.Context, usr *models.User, purpose string, ttl time.Duration) (string, error) {
+	plainToken, err := newOpaqueToken()
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant create email token", err)
+	}
+
+	err = s.sessionRepo.CreateEmailToken(ctx, models.NewEmailToken(hashToken(plainToken), purpose, usr.ID, usr.Email), ttl)
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant save email token", err)
+	}
+
+	return plainToken, nil
+}
+
+// sendEmailLink mails link to appURL+path with token in query.
+func (s *Service) sendEmailLink(ctx context.Context, usr *models.User, path, plainToken string, ttl time.Duration, subject, text string) error {
+	link := s.appURL + path + "?token=" + url.QueryEscape(plainToken)
+
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      usr.Email,
+		Subject: subject,
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n%s\n\nThe link works once and expires in %v. If it wasnt you, just ignore this email.\n",
+			usr.Username, text, link, ttl),
+	})
+}
+
+func (s *Service) sendVerificationEmail(ctx context.Context, usr *models.User) error {
+	plainToken, err := s.createEmailToken(ctx, usr, models.EmailTokenVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.sendEmailLink(ctx, usr, "/verify-email", plainToken, emailVerifyTTL,
+		"Confirm your email", "please confirm your email to start posting:")
+	if err != nil {
+		return errhandler.New(http.StatusInternalServerError, "cant send email", "cant send verification email", err)
+	}
+
+	return nil
+}
+
+// requireVerifiedEmail is the posting policy: unverified users can only read.
+func requireVerifiedEmail(usr *models.User) error {
+	if usr == nil || !usr.EmailVerified {
+		return errhandler.New(http.StatusForbidden, "email not verified", "user without verified email tried to post", nil)
+	}
+	return nil
+}
+
+// ChangeEmail sets new unverified email and mails verification link to it.
+func (s *Service) ChangeEmail(ctx context.Context, userID, email string) error {
+	email = normalizeEmail(email)
+	if err := mailer.ValidateAddress(email); err != nil {
+		return errhandler.New(http.StatusBadRequest, "invalid email", "invalid email: "+email, nil)
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return errhandler.New(http.StatusUnauthorized, "invalid token", "cant find user: "+userID, nil)
+	}
+	if usr.Email == email && usr.EmailVerified {
+		return errhandler.New(http.StatusConflict, "email already verified", "email is the same: "+email, nil)
+	}
+
+	err = s.userRepo.UpdateUserEmail(ctx, userID, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailAlreadyTaken) {
+			return errhandler.New(http.StatusConflict, "email already taken", "email already taken: "+email, nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant update email", err)
+	}
+	usr.Email = email
+	usr.EmailVerified = false
+
+	return s.sendVerificationEmail(ctx, usr)
+}
+
+// VerifyEmail confirms email the token was sent to.
+// Token for old email doesnt verify the changed one.
+func (s *Service) VerifyEmail(ctx context.Context, plainToken string) error {
+	stored, err := s.sessionRepo.TakeEmailToken(ctx, models.EmailTokenVerify, hashToken(plainToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailTokenDontExists) {
+			return errhandler.New(http.StatusBadRequest, "invalid or expired token", "verification token not found", nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get verification token", err)
+	}
+
+	err = s.userRepo.VerifyUserEmail(ctx, stored.UserID, stored.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserDontExists) {
+			return errhandler.New(http.StatusBadRequest, "invalid or expired token", "email was changed after token was sent", nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant verify email", err)
+	}
+
+	s.logger.Infow("verified email",
+		"user_id", stored.UserID,
+	)
+
+	return nil
+}
+
+// StartEmailLogin mails login link if email is verified.
+// Result is the same either way, so it cant be used to find out registered emails.
+func (s *Service) StartEmailLogin(ctx context.Context, email string, client *models.ClientInfo) error {
+	if client != nil && client.IP != "" {
+		if err := s.checkLocked(ctx, emailLoginThrottle.key(client.IP)); err != nil {
+			return err
+		}
+		s.recordAttempt(ctx, emailLoginThrottle, client.IP)
+	}
+
+	email = normalizeEmail(email)
+	if err := mailer.ValidateAddress(email); err != nil {
+		return errhandler.New(http.StatusBadRequest, "invalid email", "invalid email: "+email, nil)
+	}
+
+	usr, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get user by email", err)
+	}
+	// link to unverified email could log in someone who doesnt own the account
+	if !usr.EmailVerified {
+		return nil
+	}
+
+	plainToken, err := s.createEmailToken(ctx, usr, models.EmailTokenLogin, emailLoginTTL)
+	if err != nil {
+		return err
+	}
+	err = s.sendEmailLink(ctx, usr, "/login/email", plainToken, emailLoginTTL,
+		"Your login link", "open the link to log in:")
+	if err != nil {
+		s.logger.Errorw("cant send login email",
+			"user_id", usr.ID,
+			"err", err,
+		)
+	}
+
+	return nil
+}
+
+// FinishEmailLogin exchanges login link token for session,
+// second factor is still required if user has 2FA enabled.
+func (s *Service) FinishEmailLogin(ctx context.Context, plainToken string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error) {
+	stored, err := s.sessionRepo.TakeEmailToken(ctx, models.EmailTokenLogin, hashToken(plainToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailTokenDontExists) {
+			return nil, nil, errhandler.New(http.StatusUnauthorized, "invalid or expired token", "login token not found", nil)
+		}
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get login token", err)
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusUnauthorized, "invalid or expired token", "cant find user: "+stored.UserID, nil)
+	}
+	if usr.Email != stored.Email || !usr.EmailVerified {
+		return nil, nil, errhandler.New(http.StatusUnauthorized, "invalid or expired token", "email was changed after token was sent", nil)
+	}
+
+	mfaEnabled, err := s.mfaEnabled(ctx, usr.ID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant check 2fa", err)
+	}
+	if mfaEnabled {
+		pending, err := s.createMFAChallenge(ctx, usr, client)
+		return nil, pending, err
+	}
+
+	session, err := s.createSession(ctx, usr, client)
+	return session, nil, err
+}
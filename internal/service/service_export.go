@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/export"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// exportJobStaleAfter is how long export can be built before it is
+// considered dead and can be claimed by another instance
+const exportJobStaleAfter = 30 * time.Minute
+
+// ExportConfig configures personal data exports.
+type ExportConfig struct {
+	Store export.Store
+	// SigningKey signs download links
+	SigningKey []byte
+	// TTL is how long ready archive can be downloaded
+	TTL time.Duration
+}
+
+// RequestExport starts building archive of user's data,
+// user can have only one export in progress.
+func (s *Service) RequestExport(ctx context.Context, userID string) (*models.ExportJob, error) {
+	running, err := s.exportJobRepo.HasRunningExportJob(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant check running exports", err)
+	}
+	if running {
+		return nil, errhandler.New(http.StatusConflict, "export already running", "user already has running export: "+userID, nil)
+	}
+
+	job := models.NewExportJob(userID)
+	if err = s.exportJobRepo.CreateExportJob(ctx, job); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create export job", err)
+	}
+
+	// runner gets its own copy, returned job is marshalled by handler
+	runnerJob := *job
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		s.runExportJob(context.Background(), &runnerJob)
+	}()
+
+	return job, nil
+}
+
+// GetExport returns user's export, ready one has signed download link.
+func (s *Service) GetExport(ctx context.Context, userID, jobID string) (*models.ExportJob, error) {
+	job, err := s.exportJobRepo.GetExportJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrExportJobDontExists) {
+			return nil, errhandler.New(http.StatusNotFound, "export not found", "export not found: "+jobID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get export job", err)
+	}
+	// other user's export looks like missing one
+	if job.UserID != userID {
+		return nil, errhandler.New(http.StatusNotFound, "export not found", "export "+jobID+" belongs to other user", nil)
+	}
+
+	if job.Status == models.ExportStatusReady && job.ExpiresAt != nil && time.Now().Before(*job.ExpiresAt) {
+		job.DownloadURL = s.exportDownloadURL(job.ID, *job.ExpiresAt)
+	}
+
+	return job, nil
+}
+
+func (s *Service) exportDownloadURL(jobID string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", s.exportSigner.Sign(jobID, expires))
+
+	return s.appURL + "/api/exports/" + url.PathEscape(jobID) + "/download?" + query.Encode()
+}
+
+// DownloadExport checks signed link and opens archive. It can be downloaded
+// again until one download is completed, see CompleteExportDownload.
+func (s *Service) DownloadExport(ctx context.Context, jobID, expires, signature string) (io.ReadCloser, error) {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, errhandler.New(http.StatusForbidden, "invalid link", "invalid expires: "+expires, nil)
+	}
+	expiresAt := time.Unix(expiresUnix, 0)
+	if !s.exportSigner.Verify(jobID, expiresAt, signature) {
+		return nil, errhandler.New(http.StatusForbidden, "invalid link", "invalid export link signature", nil)
+	}
+	if time.Now().After(expiresAt) {
+		return nil, errhandler.New(http.StatusGone, "link expired", "export link expired: "+jobID, nil)
+	}
+
+	job, err := s.exportJobRepo.GetExportJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrExportJobDontExists) {
+			return nil, errhandler.New(http.StatusNotFound, "export not found", "export not found: "+jobID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get export job", err)
+	}
+	if job.ExpiresAt == nil || job.ExpiresAt.Unix() != expiresUnix {
+		return nil, errhandler.New(http.StatusForbidden, "invalid link", "link doesnt match export: "+jobID, nil)
+	}
+	if job.Status != models.ExportStatusReady {
+		return nil, errhandler.New(http.StatusGone, "export already downloaded", "export isnt ready: "+jobID, nil)
+	}
+
+	archive, err := s.exportStore.Open(jobID)
+	if errors.Is(err, export.ErrArchiveDontExists) {
+		// concurrent download completed meanwhile
+		return nil, errhandler.New(http.StatusGone, "export already downloaded", "export archive is removed: "+jobID, nil)
+	} else if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant open export archive", err)
+	}
+
+	return archive, nil
+}
+
+// CompleteExportDownload is called when archive is fully sent. Export is
+// marked downloaded and archive is removed, so interrupted download can be
+// started again.
+func (s *Service) CompleteExportDownload(ctx context.Context, jobID string) error {
+	marked, err := s.exportJobRepo.MarkExportDownloaded(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("cant mark export downloaded: %w", err)
+	}
+	if !marked {
+		// concurrent download completed first and removes archive
+		return nil
+	}
+
+	s.logger.Infow("export downloaded",
+		"job_id", jobID,
+	)
+
+	if err = s.exportStore.Remove(jobID); err != nil {
+		return fmt.Errorf("cant remove downloaded export: %w", err)
+	}
+	return nil
+}
+
+// ResumeExportJobs restarts exports interrupted by restart. It runs on every
+// instance, so each job is claimed first and skipped if another instance
+// is building it.
+func (s *Service) ResumeExportJobs(ctx context.Context) error {
+	jobs, err := s.exportJobRepo.GetExportJobsByStatus(ctx, models.ExportStatusRunning)
+	if err != nil {
+		return fmt.Errorf("cant get running export jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		for _, job := range jobs {
+			ctx := context.Background()
+			err := s.exportJobRepo.ClaimExportJob(ctx, job, time.Now().Add(-exportJobStaleAfter))
+			if err != nil {
+				if !errors.Is(err, repository.ErrExportJobClaimed) {
+					s.logger.Errorw("cant claim export job",
+						"job_id", job.ID,
+						"err", err,
+					)
+				}
+				continue
+			}
+			s.runExportJob(ctx, job)
+		}
+	}()
+
+	return nil
+}
+
+// CleanupExports removes archives which werent downloaded in time.
+func (s *Service) CleanupExports(ctx context.Context) error {
+	jobs, err := s.exportJobRepo.GetExportJobsByStatus(ctx, models.ExportStatusReady)
+	if err != nil {
+		return fmt.Errorf("cant get ready export jobs: %w", err)
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		if job.ExpiresAt != nil && now.Before(*job.ExpiresAt) {
+			continue
+		}
+
+		if err = s.exportStore.Remove(job.ID); err != nil {
+			return fmt.Errorf("cant remove export archive %s: %w", job.ID, err)
+		}
+		job.Status = models.ExportStatusExpired
+		job.UpdatedAt = now
+		if err = s.exportJobRepo.UpdateExportJob(ctx, job); err != nil {
+			return fmt.Errorf("cant update export job %s: %w", job.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// runExportJob builds archive and saves result of the job.
+func (s *Service) runExportJob(ctx context.Context, job *models.ExportJob) {
+	err := s.exportStore.Save(job.ID, func(w io.Writer) error {
+		archive, err := s.collectExport(ctx, job.UserID)
+		if err != nil {
+			return err
+		}
+		return export.Write(w, archive)
+	})
+
+	now := time.Now()
+	job.UpdatedAt = now
+	if err != nil {
+		s.logger.Errorw("export job failed",
+			"job_id", job.ID,
+			"err", err,
+		)
+		job.Status = models.ExportStatusFailed
+		job.LastError = err.Error()
+	} else {
+		expiresAt := now.Add(s.exportTTL)
+		job.Status = models.ExportStatusReady
+		job.ExpiresAt = &expiresAt
+	}
+
+	if err = s.exportJobRepo.UpdateExportJob(ctx, job); err != nil {
+		s.logger.Errorw("cant save export job",
+			"job_id", job.ID,
+			"err", err,
+		)
+	}
+}
+
+// collectExport gathers everything tied to user.
+func (s *Service) collectExport(ctx context.Context, userID string) (*export.Archive, error) {
+	usr, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get user: %w", err)
+	}
+
+	sessions, err := s.sessionRepo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get sessions: %w", err)
+	}
+
+	// deleted posts are still user's data until they are purged
+	posts, err := s.postRepo.GetPostsByAuthorID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get posts: %w", err)
+	}
+
+	userVotes, err := s.voteRepo.GetVotesByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get votes: %w", err)
+	}
+	commentVotes, err := s.voteRepo.GetCommentVotesByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get comment votes: %w", err)
+	}
+	votes := make([]*export.VoteRecord, 0, len(userVotes)+len(commentVotes))
+	for _, vote := range append(userVotes, commentVotes...) {
+		votes = append(votes, &export.VoteRecord{PostID: vote.PostID, CommentID: vote.CommentID, Vote: vote.Vote})
+	}
+
+	comments, err := s.commentRepo.GetCommentsByAuthorID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("cant get comments: %w", err)
+	}
+
+	return &export.Archive{
+		User: &export.UserRecord{
+			ID:            usr.ID,
+			Username:      usr.Username,
+			Email:         usr.Email,
+			EmailVerified: usr.EmailVerified,
+			ExportedAt:    time.Now(),
+		},
+		Sessions: sessions,
+		Posts:    posts,
+		Comments: comments,
+		Votes:    votes,
+	}, nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/totp"
+)
+
+const (
+	totpIssuer = "redditclone"
+
+	recoveryCodesCount = 10
+	// login has to be finished with second factor in this time
+	mfaChallengeTTL = 5 * time.Minute
+	// after that many wrong codes user has to enter password again
+	mfaMaxAttempts = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode generates code like "abcde-fghij".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode lets user type code without dash or in upper case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	if len(code) == 10 {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
+
+// EnrollTOTP starts 2FA setup. It is enabled only after ConfirmTOTP,
+// so user isnt locked out if he didnt scan the code.
+func (s *Service) EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	usr, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid token", "cant find user: "+userID, nil)
+	}
+
+	config, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPDontExists) {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get totp config", err)
+	}
+	if config != nil && config.Enabled {
+		return nil, errhandler.New(http.StatusConflict, "2fa already enabled", "totp already enabled for user: "+userID, nil)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant generate totp secret", err)
+	}
+	if err = s.mfaRepo.SaveTOTP(ctx, models.NewTOTPConfig(userID, secret)); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant save totp config", err)
+	}
+
+	recoveryCodes := make([]string, 0, recoveryCodesCount)
+	hashedCodes := make([]*models.RecoveryCode, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant generate recovery code", err)
+		}
+		recoveryCodes = append(recoveryCodes, code)
+		hashedCodes = append(hashedCodes, models.NewRecoveryCode(userID, hashToken(code)))
+	}
+	if err = s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashedCodes); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant save recovery codes", err)
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:        secret,
+		URI:           totp.URI(totpIssuer, usr.Username, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA if code from authenticator app is valid.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID, code string) error {
+	config, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPDontExists) {
+			return errhandler.New(http.StatusBadRequest, "2fa enrollment not started", "totp config not found: "+userID, nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get totp config", err)
+	}
+	if config.Enabled {
+		return errhandler.New(http.StatusConflict, "2fa already enabled", "totp already enabled for user: "+userID, nil)
+	}
+
+	step, ok := totp.Validate(config.Secret, code, time.Now())
+	if !ok {
+		return errhandler.New(http.StatusBadRequest, "invalid code", "totp code verification failed", nil)
+	}
+
+	if err = s.mfaRepo.EnableTOTP(ctx, userID, step); err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant enable totp", err)
+	}
+
+	s.logger.Infow("enabled totp",
+		"user_id", userID,
+	)
+	return nil
+}
+
+// mfaEnabled reports whether login needs the second step.
+func (s *Service) mfaEnabled(ctx context.Context, userID string) (bool, error) {
+	config, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPDontExists) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return config.Enabled, nil
+}
+
+// createMFAChallenge remembers login that passed password check.
+func (s *Service) createMFAChallenge(ctx context.Context, usr *models.User, client *models.ClientInfo) (*models.MFAPending, error) {
+	mfaToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create mfa token", err)
+	}
+
+	challenge := models.NewMFAChallenge(hashToken(mfaToken), usr.ID, client)
+	if err = s.sessionRepo.CreateMFAChallenge(ctx, challenge, mfaChallengeTTL); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create mfa challenge", err)
+	}
+
+	return models.NewMFAPending(mfaToken), nil
+}
+
+// VerifyMFA finishes login with code from authenticator app or recovery code.
+func (s *Service) VerifyMFA(ctx context.Context, mfaToken, code string) (*models.Session, error) {
+	mfaHash := hashToken(mfaToken)
+
+	challenge, err := s.sessionRepo.GetMFAChallenge(ctx, mfaHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFAChallengeDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid mfa token", "mfa challenge not found", nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get mfa challenge", err)
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid mfa token", "cant find user: "+challenge.UserID, nil)
+	}
+	// wrong codes share lockout with wrong passwords, so new challenges
+	// dont give more attempts to someone who knows the password
+	if err = s.checkLocked(ctx, loginThrottleKeys(usr.Username, challenge.Client)...); err != nil {
+		return nil, err
+	}
+
+	config, err := s.mfaRepo.GetTOTP(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPDontExists) {
+			return nil, errhandler.New(http.StatusUnauthorized, "invalid mfa token", "totp config not found: "+challenge.UserID, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get totp config", err)
+	}
+
+	if err = s.checkSecondFactor(ctx, config, code); err != nil {
+		if !errors.Is(err, errInvalidSecondFactor) {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant check second factor", err)
+		}
+		s.recordLoginFailure(ctx, usr.Username, challenge.Client)
+
+		attempts, err := s.sessionRepo.IncrMFAChallengeAttempts(ctx, mfaHash, mfaChallengeTTL)
+		if err != nil {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant count mfa attempts", err)
+		}
+		if attempts >= mfaMaxAttempts {
+			if err = s.sessionRepo.DeleteMFAChallenge(ctx, mfaHash); err != nil {
+				s.logger.Warnw("cant delete mfa challenge",
+					"user_id", challenge.UserID,
+					"err", err,
+				)
+			}
+		}
+		return nil, errhandler.New(http.StatusUnauthorized, "invalid code", "second factor verification failed", nil)
+	}
+
+	// mfa token is single-use too
+	if err = s.sessionRepo.DeleteMFAChallenge(ctx, mfaHash); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant delete mfa challenge", err)
+	}
+	s.resetAttempts(ctx, loginUserThrottle.key(loginUserID(usr.Username)))
+
+	return s.createSession(ctx, usr, challenge.Client)
+}
+
+var errInvalidSecondFactor = errors.New("invalid second factor")
+
+// checkSecondFactor accepts either TOTP code or unused recovery code.
+func (s *Service) checkSecondFactor(ctx context.Context, config *models.TOTPConfig, code string) error {
+	if step, ok := totp.Validate(config.Secret, code, time.Now()); ok {
+		err := s.mfaRepo.UseTOTPStep(ctx, config.UserID, step)
+		if errors.Is(err, repository.ErrTOTPCodeReused) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+
+	err := s.mfaRepo.UseRecoveryCode(ctx, config.UserID, hashToken(normalizeRecoveryCode(code)))
+	if errors.Is(err, repository.ErrRecoveryCodeDontExists) {
+		return errInvalidSecondFactor
+	}
+	if err == nil {
+		s.logger.Infow("used recovery code",
+			"user_id", config.UserID,
+		)
+	}
+	return err
+}
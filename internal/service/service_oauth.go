@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/oidc"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/validation"
+)
+
+const (
+	// user has this much time to log in at provider
+	oauthStateTTL = 10 * time.Minute
+
+	// attempts to find free username with random suffix
+	usernameAttempts = 5
+)
+
+var usernameForbiddenChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func (s *Service) oauthProvider(name string) (oidc.Provider, error) {
+	provider, ok := s.oauthProviders[name]
+	if !ok {
+		return nil, errhandler.New(http.StatusNotFound, "unknown provider", "oauth provider not configured: "+name, nil)
+	}
+	return provider, nil
+}
+
+// StartOAuthLogin returns provider's URL user should be redirected to.
+// If userID isnt empty, external account will be linked to this user.
+func (s *Service) StartOAuthLogin(ctx context.Context, providerName, userID string, client *models.ClientInfo) (string, error) {
+	provider, err := s.oauthProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant create oauth state", err)
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant create oauth nonce", err)
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant create pkce verifier", err)
+	}
+
+	err = s.sessionRepo.CreateOAuthState(ctx, &models.OAuthState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		Client:       client,
+	}, oauthStateTTL)
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant save oauth state", err)
+	}
+
+	return provider.AuthCodeURL(state, challenge, nonce), nil
+}
+
+// FinishOAuthLogin handles provider's callback. Known identity logs its user in,
+// unknown one gets new account. For linking flow nothing is returned on success.
+func (s *Service) FinishOAuthLogin(ctx context.Context, providerName, state, code string) (*models.Session, *models.MFAPending, error) {
+	provider, err := s.oauthProvider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oauthState, err := s.sessionRepo.TakeOAuthState(ctx, hashToken(state))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthStateDontExists) {
+			return nil, nil, errhandler.New(http.StatusBadRequest, "invalid state", "oauth state not found", nil)
+		}
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get oauth state", err)
+	}
+	// state of one provider cant be used with another
+	if oauthState.Provider != providerName {
+		return nil, nil, errhandler.New(http.StatusBadRequest, "invalid state", "oauth state is for provider "+oauthState.Provider, nil)
+	}
+
+	identity, err := provider.Exchange(ctx, code, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusUnauthorized, "external login failed", "cant exchange code: "+err.Error(), nil)
+	}
+
+	if oauthState.UserID != "" {
+		return nil, nil, s.linkIdentity(ctx, oauthState.UserID, identity)
+	}
+
+	usr, err := s.userByIdentity(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mfaEnabled, err := s.mfaEnabled(ctx, usr.ID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant check 2fa", err)
+	}
+	if mfaEnabled {
+		pending, err := s.createMFAChallenge(ctx, usr, oauthState.Client)
+		return nil, pending, err
+	}
+
+	session, err := s.createSession(ctx, usr, oauthState.Client)
+	return session, nil, err
+}
+
+func (s *Service) GetExternalIdentities(ctx context.Context, userID string) ([]*models.ExternalIdentity, error) {
+	identities, err := s.identityRepo.GetIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get external identities", err)
+	}
+
+	return identities, nil
+}
+
+func (s *Service) linkIdentity(ctx context.Context, userID string, identity *oidc.Identity) error {
+	linked, err := s.identityRepo.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if linked.UserID == userID {
+			return nil
+		}
+		return errhandler.New(http.StatusConflict, "account already linked to another user", "identity belongs to user "+linked.UserID, nil)
+	}
+	if !errors.Is(err, repository.ErrIdentityDontExists) {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get external identity", err)
+	}
+
+	err = s.identityRepo.CreateIdentity(ctx, models.NewExternalIdentity(userID, identity.Provider, identity.Subject, identity.Email))
+	if err != nil {
+		if errors.Is(err, repository.ErrIdentityAlreadyLinked) {
+			return errhandler.New(http.StatusConflict, "account already linked to another user", "identity was linked concurrently", nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant create external identity", err)
+	}
+
+	s.logger.Infow("linked external identity",
+		"user_id", userID,
+		"provider", identity.Provider,
+	)
+	return nil
+}
+
+// userByIdentity finds user linked to identity or creates new one.
+func (s *Service) userByIdentity(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	linked, err := s.identityRepo.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		usr, err := s.userRepo.GetUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant find linked user: "+linked.UserID, err)
+		}
+		return usr, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityDontExists) {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get external identity", err)
+	}
+
+	username, err := s.freeUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// user can log in only through provider until he resets password
+	// by email, so password is random and nobody knows it
+	randomPassword, err := newOpaqueToken()
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create password", err)
+	}
+	passwordHash, err := s.passwordHasher.Hash(randomPassword)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant hash password", err)
+	}
+
+	usr := models.NewUser(username, passwordHash)
+	// provider already verified the email, but it can belong to other local user
+	if email := normalizeEmail(identity.Email); identity.EmailVerified && email != "" {
+		_, err = s.userRepo.GetUserByEmail(ctx, email)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			usr.Email = email
+			usr.EmailVerified = true
+		} else if err != nil {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user by email", err)
+		}
+	}
+	for i := 1; ; i++ {
+		err = s.userRepo.CreateUser(ctx, usr)
+		if !errors.Is(err, repository.ErrUserAlreadyExists) || i == usernameAttempts {
+			break
+		}
+		// username was taken meanwhile or differs from taken one
+		// only in case or look-alike letters, next one has suffix
+		if usr.Username, err = suffixedUsername(usernameBase(identity)); err != nil {
+			return nil, err
+		}
+		usr.UsernameKey = validation.UsernameKey(usr.Username)
+	}
+	if errors.Is(err, repository.ErrUserAlreadyExists) {
+		return nil, errhandler.New(http.StatusConflict, "user already exists", "cant create user from external identity", err)
+	} else if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create user in db", err)
+	}
+
+	err = s.identityRepo.CreateIdentity(ctx, models.NewExternalIdentity(usr.ID, identity.Provider, identity.Subject, identity.Email))
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create external identity", err)
+	}
+
+	s.logger.Infow("created user from external identity",
+		"user_id", usr.ID,
+		"username", usr.Username,
+		"provider", identity.Provider,
+	)
+	return usr, nil
+}
+
+// freeUsername picks username from provider's profile. If it is taken
+// or doesnt pass validation (too short or reserved), random numeric suffix is added.
+func (s *Service) freeUsername(ctx context.Context, identity *oidc.Identity) (string, error) {
+	base := usernameBase(identity)
+
+	candidate := base
+	for i := 0; i < usernameAttempts; i++ {
+		if len(validation.ValidateUsername(candidate)) == 0 {
+			_, err := s.userRepo.GetUserByUsername(ctx, candidate)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return candidate, nil
+			}
+			if err != nil {
+				return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant check username", err)
+			}
+		}
+
+		var err error
+		if candidate, err = suffixedUsername(base); err != nil {
+			return "", err
+		}
+	}
+
+	return "", errhandler.New(http.StatusConflict, "cant pick username", "no free username for "+base, nil)
+}
+
+// suffixedUsername adds random numeric suffix to base
+func suffixedUsername(base string) (string, error) {
+	suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "", errhandler.New(http.StatusInternalServerError, "internal error", "cant generate username", err)
+	}
+	return fmt.Sprintf("%s_%04d", truncate(base, validation.MaxUsernameLength-5), suffix.Int64()), nil
+}
+
+func usernameBase(identity *oidc.Identity) string {
+	emailLocal, _, _ := strings.Cut(identity.Email, "@")
+	for _, candidate := range []string{identity.PreferredUsername, emailLocal, identity.Name} {
+		// username has to start with letter or digit
+		cleaned := strings.TrimLeft(usernameForbiddenChars.ReplaceAllString(candidate, ""), "_-")
+		if cleaned != "" {
+			return truncate(cleaned, validation.MaxUsernameLength)
+		}
+	}
+	return "user"
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
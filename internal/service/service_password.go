@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/mailer"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/validation"
+)
+
+const passwordResetTTL = time.Hour
+
+// ForgotPassword mails password reset link if email belongs to a user.
+// Result is the same either way, so it cant be used to find out registered emails.
+func (s *Service) ForgotPassword(ctx context.Context, email string, client *models.ClientInfo) error {
+	if client != nil && client.IP != "" {
+		if err := s.checkLocked(ctx, forgotPasswordThrottle.key(client.IP)); err != nil {
+			return err
+		}
+		s.recordAttempt(ctx, forgotPasswordThrottle, client.IP)
+	}
+
+	email = normalizeEmail(email)
+	if err := mailer.ValidateAddress(email); err != nil {
+		return errhandler.New(http.StatusBadRequest, "invalid email", "invalid email: "+email, nil)
+	}
+
+	usr, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Info("password reset for unknown email")
+			return nil
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get user by email", err)
+	}
+	// link to unverified email could reset password of someone else's account
+	if !usr.EmailVerified {
+		s.logger.Infow("password reset for unverified email",
+			"user_id", usr.ID,
+		)
+		return nil
+	}
+
+	resetToken, err := s.createEmailToken(ctx, usr, models.EmailTokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	// failed send isnt reported to client, otherwise it would reveal the email is registered
+	err = s.sendEmailLink(ctx, usr, "/reset-password", resetToken, passwordResetTTL,
+		"Reset your password", "someone asked to reset your password. Open the link to choose a new one:")
+	if err != nil {
+		s.logger.Errorw("cant send password reset email",
+			"user_id", usr.ID,
+			"err", err,
+		)
+		return nil
+	}
+
+	s.logger.Infow("sent password reset email",
+		"user_id", usr.ID,
+	)
+
+	return nil
+}
+
+// ResetPassword sets new password and logs user out of every device.
+func (s *Service) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	// username isnt known before token is checked, it is better
+	// to tell about weak password before token is used up
+	if err := validation.NewError(validation.ValidatePassword(newPassword, "")...); err != nil {
+		return err
+	}
+
+	stored, err := s.sessionRepo.TakeEmailToken(ctx, models.EmailTokenPasswordReset, hashToken(resetToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailTokenDontExists) {
+			return errhandler.New(http.StatusBadRequest, "invalid or expired token", "password reset token not found", nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get reset token", err)
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return errhandler.New(http.StatusBadRequest, "invalid or expired token", "cant find user: "+stored.UserID, nil)
+	}
+	if usr.Email != stored.Email || !usr.EmailVerified {
+		return errhandler.New(http.StatusBadRequest, "invalid or expired token", "email was changed after token was sent", nil)
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant hash password", err)
+	}
+	if err = s.userRepo.UpdateUserPassword(ctx, stored.UserID, passwordHash); err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant update password", err)
+	}
+
+	if err = s.sessionRepo.DeleteSessionsByUserID(ctx, stored.UserID); err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant revoke sessions", err)
+	}
+
+	s.logger.Infow("password reset",
+		"user_id", stored.UserID,
+	)
+
+	return nil
+}
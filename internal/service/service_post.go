@@ -3,24 +3,35 @@ package service
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/myacey/redditclone/internal/customerror/errhandler"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
 )
 
 var (
 	ErrInvalidPostData   = errors.New("invalid post data")
 	ErrUnknown           = errors.New("unknown error")
 	ErrCommentCantBeNull = errors.New("comment cant be null")
+
+	// errPostUnchanged stops update when edit doesnt change anything
+	errPostUnchanged = errors.New("post unchanged")
 )
 
-func (s *Service) GetAllPosts(ctx context.Context) ([]*models.Post, error) {
+func (s *Service) GetAllPosts(ctx context.Context, userID string) ([]*models.Post, error) {
 	posts, err := s.postRepo.GetAllPosts(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.withUserVotes(ctx, userID, posts...); err != nil {
+		return nil, err
+	}
 	models.AddNilComments(posts...) // to show comment count
 	return posts, nil
 }
@@ -29,15 +40,33 @@ func (s *Service) AddPost(ctx context.Context, newPost *models.Post) error {
 	if !models.ValidatePost(*newPost) {
 		return ErrInvalidPostData
 	}
+	if err := requireVerifiedEmail(newPost.Author); err != nil {
+		return err
+	}
+
+	// votes go first, so counters of saved post always have them
+	for _, vote := range newPost.Votes {
+		if _, err := s.voteRepo.SetVote(ctx, vote); err != nil {
+			return err
+		}
+	}
+	if err := s.postRepo.CreatePost(ctx, newPost); err != nil {
+		return err
+	}
+	// new post is upvoted by its author
+	s.addKarma(ctx, newPost.Author, newPost.Score, 0)
 
-	return s.postRepo.CreatePost(ctx, newPost)
+	return nil
 }
 
-func (s *Service) GetPostByID(ctx context.Context, postID string, increateVote bool) (*models.Post, error) {
+func (s *Service) GetPostByID(ctx context.Context, userID, postID string, increateVote bool) (*models.Post, error) {
 	gotPost, err := s.postRepo.GetPostByID(ctx, postID)
 	if err != nil {
 		return nil, errhandler.New(http.StatusBadRequest, "cant find post", "invalid params to find post", err)
 	}
+	if gotPost == nil {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
 
 	if increateVote {
 		err = s.increatePostViews(ctx, gotPost)
@@ -46,25 +75,217 @@ func (s *Service) GetPostByID(ctx context.Context, postID string, increateVote b
 		}
 	}
 
-	comments, err := s.commentRepo.GetCommentsByPostID(ctx, postID)
-	if err != nil {
-		return nil, errhandler.New(http.StatusBadRequest, "cant find comments", "invalid params to find comments", err)
+	if err = s.withUserVotes(ctx, userID, gotPost); err != nil {
+		return nil, err
 	}
 
-	gotPost.Comments = comments
-
-	return gotPost, nil
+	return s.withComments(ctx, userID, gotPost)
 }
 
 func (s *Service) increatePostViews(ctx context.Context, post *models.Post) error {
+	if err := s.postRepo.IncrementViews(ctx, post.ID); err != nil {
+		return err
+	}
 	post.Views++
 
-	return s.postRepo.UpdatePostInfo(ctx, post)
+	return nil
+}
+
+const (
+	postUpdateAttempts = 5
+	postUpdateBackoff  = 10 * time.Millisecond
+)
+
+// updatePost reads post, changes it and saves it if nobody changed it
+// in between. On conflict it starts over after growing pause.
+func (s *Service) updatePost(ctx context.Context, postID string, change func(post *models.Post) error) (*models.Post, error) {
+	backoff := postUpdateBackoff
+	for attempt := 1; ; attempt++ {
+		post, err := s.postRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+		if post == nil {
+			return nil, repository.ErrPostDontExists
+		}
+		if err = change(post); err != nil {
+			return nil, err
+		}
+
+		err = s.postRepo.UpdatePostInfo(ctx, post)
+		var conflict *repository.VersionConflictError
+		if err == nil {
+			return post, nil
+		} else if !errors.As(err, &conflict) || attempt == postUpdateAttempts {
+			return nil, err
+		}
+
+		s.logger.Infow("post changed concurrently, retrying",
+			"post_id", postID,
+			"attempt", attempt,
+		)
+		// jitter, so conflicting writers dont retry at the same moment
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff + rand.N(backoff)):
+		}
+		backoff *= 2
+	}
+}
+
+// EditPost changes content of user's own post, previous content is kept
+// in revisions. Type and URL cant be changed once other users voted.
+func (s *Service) EditPost(ctx context.Context, userID, postID string, edit *models.PostEdit) (*models.Post, error) {
+	if edit.Title != nil && strings.TrimSpace(*edit.Title) == "" {
+		return nil, errhandler.New(http.StatusBadRequest, "title cant be empty", "empty title in edit of post "+postID, nil)
+	}
+
+	var revision *models.PostRevision
+	post, err := s.updatePost(ctx, postID, func(post *models.Post) error {
+		if post.Author == nil || post.Author.ID != userID {
+			return errhandler.New(http.StatusForbidden, "only author can edit post", "user "+userID+" isnt author of post "+postID, nil)
+		}
+
+		edited := *post
+		if edit.Title != nil {
+			edited.Title = *edit.Title
+		}
+		if edit.Type != nil {
+			edited.Type = *edit.Type
+		}
+		if edit.Text != nil {
+			edited.Text = *edit.Text
+		}
+		if edit.URL != nil {
+			edited.URL = *edit.URL
+		}
+
+		if edited.Title == post.Title && edited.Type == post.Type && edited.Text == post.Text && edited.URL == post.URL {
+			return errPostUnchanged
+		}
+		if !models.ValidatePost(edited) {
+			return errhandler.New(http.StatusBadRequest, "invalid post data", "invalid type or too long text in edit of post "+postID, nil)
+		}
+		if edited.Type != post.Type || edited.URL != post.URL {
+			voted, err := s.votedByOthers(ctx, post)
+			if err != nil {
+				return errhandler.New(http.StatusInternalServerError, "internal error", "cant get author's vote", err)
+			}
+			if voted {
+				return errhandler.New(http.StatusConflict, "cant change type or url of voted post", "post already voted: "+postID, nil)
+			}
+		}
+
+		revision = models.NewPostRevision(post)
+		now := time.Now()
+		post.Title, post.Type, post.Text, post.URL = edited.Title, edited.Type, edited.Text, edited.URL
+		post.Edited = &now
+		return nil
+	})
+
+	var (
+		scErr    *errhandler.StatusCodedError
+		conflict *repository.VersionConflictError
+	)
+	switch {
+	case errors.Is(err, errPostUnchanged):
+		return s.GetPostByID(ctx, userID, postID, false)
+	case errors.As(err, &scErr):
+		return nil, err
+	case errors.Is(err, repository.ErrPostDontExists):
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	case errors.As(err, &conflict):
+		return nil, errhandler.New(http.StatusConflict, "post was changed, try again", "post keeps changing during edit: "+postID, err)
+	case err != nil:
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant edit post", err)
+	}
+
+	// post is already saved, so only its history is lost
+	if err = s.revisionRepo.CreateRevision(ctx, revision); err != nil {
+		s.logger.Errorw("cant save post revision",
+			"post_id", postID,
+			"err", err,
+		)
+	}
+
+	if err = s.withUserVotes(ctx, userID, post); err != nil {
+		return nil, err
+	}
+
+	return s.withComments(ctx, userID, post)
+}
+
+// votedByOthers reports if anyone besides author voted on the post.
+func (s *Service) votedByOthers(ctx context.Context, post *models.Post) (bool, error) {
+	votes := post.Upvotes + post.Downvotes
+
+	authorVotes, err := s.voteRepo.GetUserVotes(ctx, post.Author.ID, []string{post.ID})
+	if err != nil {
+		return false, err
+	}
+	if authorVotes[post.ID] != 0 {
+		votes--
+	}
+
+	return votes > 0, nil
+}
+
+// GetPostRevisions returns content post had, oldest first and current one last.
+func (s *Service) GetPostRevisions(ctx context.Context, postID string) ([]*models.PostRevision, error) {
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get post", err)
+	}
+	if post == nil {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
+
+	saved, err := s.revisionRepo.GetRevisionsByPostID(ctx, postID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get post revisions", err)
+	}
+
+	// edits saved after post was read are newer than its content
+	revisions := make([]*models.PostRevision, 0, len(saved)+1)
+	for _, revision := range saved {
+		if revision.Version < post.Version {
+			revisions = append(revisions, revision)
+		}
+	}
+	revisions = append(revisions, models.NewPostRevision(post))
+	for i, revision := range revisions {
+		revision.Number = i + 1
+	}
+
+	return revisions, nil
+}
+
+// GetPostDiff compares revisions with numbers from and to. Zero to means
+// current content, zero from means revision before to.
+func (s *Service) GetPostDiff(ctx context.Context, postID string, from, to int) (*models.PostDiff, error) {
+	revisions, err := s.GetPostRevisions(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == 0 {
+		to = len(revisions)
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+	if from < 1 || from > len(revisions) || to < 1 || to > len(revisions) {
+		return nil, errhandler.New(http.StatusNotFound, "revision not found", "post "+postID+" has no revisions "+strconv.Itoa(from)+", "+strconv.Itoa(to), nil)
+	}
+
+	return models.NewPostDiff(revisions[from-1], revisions[to-1]), nil
 }
 
-func (s *Service) GetPostsByAuthor(ctx context.Context, username string) ([]*models.Post, error) {
-	// check if user really exists
-	_, err := s.userRepo.GetUserByUsername(ctx, username)
+func (s *Service) GetPostsByAuthor(ctx context.Context, userID, username string) ([]*models.Post, error) {
+	// check if user really exists, username can differ from stored one
+	// in case or confusable characters, so posts are found by id
+	usr, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -75,16 +296,19 @@ func (s *Service) GetPostsByAuthor(ctx context.Context, username string) ([]*mod
 	}
 	sortedPosts := []*models.Post{}
 	for _, v := range allPosts {
-		if v.Author.Username == username {
+		if v.Author != nil && v.Author.ID == usr.ID {
 			sortedPosts = append(sortedPosts, v)
 		}
 	}
+	if err = s.withUserVotes(ctx, userID, sortedPosts...); err != nil {
+		return nil, err
+	}
 	models.AddNilComments(sortedPosts...) // to show comment count
 
 	return sortedPosts, nil
 }
 
-func (s *Service) GetPostsByCategory(ctx context.Context, category string) ([]*models.Post, error) {
+func (s *Service) GetPostsByCategory(ctx context.Context, userID, category string) ([]*models.Post, error) {
 	if !slices.Contains(models.GetCategories(), category) {
 		return nil, errhandler.New(http.StatusBadRequest, "invalid category", "invalid category", nil)
 	}
@@ -99,11 +323,32 @@ func (s *Service) GetPostsByCategory(ctx context.Context, category string) ([]*m
 			sortedPosts = append(sortedPosts, v)
 		}
 	}
+	if err = s.withUserVotes(ctx, userID, sortedPosts...); err != nil {
+		return nil, err
+	}
 	models.AddNilComments(sortedPosts...) // to show comment count
 
 	return sortedPosts, nil
 }
 
-func (s *Service) DeletePostWithID(ctx context.Context, postID string) error {
-	return s.postRepo.DeletePost(ctx, postID)
+func (s *Service) DeletePostWithID(ctx context.Context, userID, postID string) error {
+	gotPost, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if gotPost == nil {
+		return errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
+
+	err = s.authorizeContentDelete(ctx, userID, gotPost.Author, gotPost.Category)
+	if err != nil {
+		return err
+	}
+
+	// votes, comments and revisions are kept until post is purged
+	err = s.postRepo.SoftDeletePost(ctx, postID, userID, time.Now())
+	if errors.Is(err, repository.ErrPostDontExists) {
+		return errhandler.New(http.StatusNotFound, "post not found", "post was deleted meanwhile: "+postID, nil)
+	}
+	return err
 }
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// GetProfile returns public profile of the user with karma and counters.
+func (s *Service) GetProfile(ctx context.Context, username string) (*models.UserProfile, error) {
+	usr, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errhandler.New(http.StatusNotFound, "user not found", "user not found: "+username, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user", err)
+	}
+
+	return s.userProfile(ctx, usr)
+}
+
+// UpdateProfile changes editable fields of user's own profile.
+func (s *Service) UpdateProfile(ctx context.Context, userID string, update *models.ProfileUpdate) (*models.UserProfile, error) {
+	if err := validateProfileUpdate(update); err != nil {
+		return nil, err
+	}
+
+	usr, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user", err)
+	}
+
+	profile, err := s.ensureProfile(ctx, userID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get profile", err)
+	}
+
+	if update.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		profile.Bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.AvatarURL != nil {
+		profile.AvatarURL = strings.TrimSpace(*update.AvatarURL)
+	}
+	profile.UpdatedAt = time.Now()
+
+	if err = s.profileRepo.UpdateProfile(ctx, profile); err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant update profile", err)
+	}
+
+	return s.userProfile(ctx, usr)
+}
+
+func validateProfileUpdate(update *models.ProfileUpdate) error {
+	if update.DisplayName != nil && utf8.RuneCountInString(strings.TrimSpace(*update.DisplayName)) > models.MaxDisplayNameLength {
+		return errhandler.New(http.StatusBadRequest, "display name is too long", "display name is too long", nil)
+	}
+	if update.Bio != nil && utf8.RuneCountInString(strings.TrimSpace(*update.Bio)) > models.MaxBioLength {
+		return errhandler.New(http.StatusBadRequest, "bio is too long", "bio is too long", nil)
+	}
+	// empty url removes avatar
+	if update.AvatarURL != nil && strings.TrimSpace(*update.AvatarURL) != "" {
+		avatarURL, err := url.Parse(strings.TrimSpace(*update.AvatarURL))
+		if err != nil || (avatarURL.Scheme != "http" && avatarURL.Scheme != "https") || avatarURL.Host == "" {
+			return errhandler.New(http.StatusBadRequest, "invalid avatar url", "invalid avatar url: "+*update.AvatarURL, nil)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) userProfile(ctx context.Context, usr *models.User) (*models.UserProfile, error) {
+	profile, err := s.ensureProfile(ctx, usr.ID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get profile", err)
+	}
+
+	postCount, err := s.postRepo.CountPostsByAuthor(ctx, usr.ID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant count user's posts", err)
+	}
+	commentCount, err := s.commentRepo.CountCommentsByAuthor(ctx, usr.ID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant count user's comments", err)
+	}
+
+	return &models.UserProfile{
+		ID:           usr.ID,
+		Username:     usr.Username,
+		Profile:      profile,
+		Karma:        profile.PostKarma + profile.CommentKarma,
+		PostCount:    postCount,
+		CommentCount: commentCount,
+	}, nil
+}
+
+// ensureProfile creates missing profile of users registered before profiles
+// existed. Their karma is counted from scores of existing posts and comments
+// once, after that it is changed on every vote.
+func (s *Service) ensureProfile(ctx context.Context, userID string) (*models.Profile, error) {
+	profile, err := s.profileRepo.GetProfile(ctx, userID)
+	if err == nil {
+		return profile, nil
+	} else if !errors.Is(err, repository.ErrProfileDontExists) {
+		return nil, err
+	}
+
+	profile = models.NewProfile(userID)
+	if profile.PostKarma, err = s.postRepo.SumScoreByAuthor(ctx, userID); err != nil {
+		return nil, err
+	}
+	if profile.CommentKarma, err = s.commentRepo.SumScoreByAuthor(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// concurrent request could create it first, then its profile wins
+	if err = s.profileRepo.CreateProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+	return s.profileRepo.GetProfile(ctx, userID)
+}
+
+// addKarma changes karma of content author. Vote is already saved by then,
+// so error is only logged.
+func (s *Service) addKarma(ctx context.Context, author *models.User, postDelta, commentDelta int) {
+	if author == nil || author.ID == "" || (postDelta == 0 && commentDelta == 0) {
+		return
+	}
+
+	if err := s.profileRepo.AddKarma(ctx, author.ID, postDelta, commentDelta); err != nil {
+		s.logger.Errorw("cant update karma",
+			"user_id", author.ID,
+			"err", err,
+		)
+	}
+}
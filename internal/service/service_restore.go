@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+const (
+	// DefaultContentRetention is how long deleted content can be restored
+	DefaultContentRetention = 30 * 24 * time.Hour
+
+	purgeBatchSize = 100
+)
+
+// restoreDeadline is the oldest deletion which can be restored,
+// content deleted before it waits for purge.
+func (s *Service) restoreDeadline() time.Time {
+	retention := s.contentRetention
+	if retention <= 0 {
+		retention = DefaultContentRetention
+	}
+	return time.Now().Add(-retention)
+}
+
+// RestorePost brings back deleted post with its comments.
+func (s *Service) RestorePost(ctx context.Context, userID, postID string) (*models.Post, error) {
+	post, err := s.postRepo.GetDeletedPostByID(ctx, postID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get deleted post", err)
+	}
+	if post == nil {
+		return nil, errhandler.New(http.StatusNotFound, "deleted post not found", "deleted post not found: "+postID, nil)
+	}
+
+	if err = s.authorizeRestore(ctx, userID, post.Author, post.DeletedBy, post.Category); err != nil {
+		return nil, err
+	}
+
+	err = s.postRepo.RestorePost(ctx, postID, s.restoreDeadline())
+	if errors.Is(err, repository.ErrPostDontExists) {
+		return nil, errhandler.New(http.StatusGone, "restore window expired", "post cant be restored anymore: "+postID, nil)
+	} else if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant restore post", err)
+	}
+
+	return s.GetPostByID(ctx, userID, postID, false)
+}
+
+// RestoreComment brings back deleted comment of existing post.
+func (s *Service) RestoreComment(ctx context.Context, userID, postID, commentID string) (*models.Post, error) {
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get post", err)
+	}
+	if post == nil {
+		return nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
+
+	comment, err := s.commentRepo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get comment", err)
+	}
+	if comment == nil || comment.DeletedAt == nil || comment.RelatedPostID != postID {
+		return nil, errhandler.New(http.StatusNotFound, "deleted comment not found", "deleted comment "+commentID+" not found in post "+postID, nil)
+	}
+
+	if err = s.authorizeRestore(ctx, userID, comment.Author, comment.DeletedBy, post.Category); err != nil {
+		return nil, err
+	}
+
+	err = s.commentRepo.RestoreComment(ctx, commentID, s.restoreDeadline())
+	if errors.Is(err, repository.ErrCommentDontExists) {
+		return nil, errhandler.New(http.StatusGone, "restore window expired", "comment cant be restored anymore: "+commentID, nil)
+	} else if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant restore comment", err)
+	}
+
+	if err = s.withUserVotes(ctx, userID, post); err != nil {
+		return nil, err
+	}
+
+	return s.withComments(ctx, userID, post)
+}
+
+// PurgeDeletedContent removes posts and comments deleted longer than
+// retention ago for good, posts go with their comments, votes and revisions.
+func (s *Service) PurgeDeletedContent(ctx context.Context) error {
+	deadline := s.restoreDeadline()
+
+	for {
+		postIDs, err := s.postRepo.GetPostIDsDeletedBefore(ctx, deadline, purgeBatchSize)
+		if err != nil {
+			return fmt.Errorf("cant get deleted posts: %w", err)
+		}
+		if len(postIDs) == 0 {
+			break
+		}
+		for _, postID := range postIDs {
+			if _, err = s.purgePost(ctx, postID); err != nil {
+				return fmt.Errorf("cant purge post %s: %w", postID, err)
+			}
+		}
+	}
+
+	for {
+		comments, err := s.commentRepo.GetCommentsDeletedBefore(ctx, deadline, purgeBatchSize)
+		if err != nil {
+			return fmt.Errorf("cant get deleted comments: %w", err)
+		}
+		if len(comments) == 0 {
+			break
+		}
+		commentIDs := make([]string, 0, len(comments))
+		for _, comment := range comments {
+			commentIDs = append(commentIDs, comment.ID)
+		}
+		// votes go first, otherwise they would be lost if purge stops in between
+		if _, err = s.voteRepo.DeleteCommentVotesByCommentIDs(ctx, commentIDs); err != nil {
+			return fmt.Errorf("cant delete votes of comments: %w", err)
+		}
+		for _, commentID := range commentIDs {
+			if err = s.deleteComment(ctx, commentID); err != nil {
+				return fmt.Errorf("cant purge comment %s: %w", commentID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// purgePost removes post with everything tied to it and returns number of
+// its comments. Post goes last, so stopped purge is continued by next one.
+func (s *Service) purgePost(ctx context.Context, postID string) (int64, error) {
+	comments, err := s.commentRepo.DeleteCommentsByPostID(ctx, postID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = s.voteRepo.DeleteCommentVotesByPostID(ctx, postID); err != nil {
+		return 0, err
+	}
+	if _, err = s.voteRepo.DeleteVotesByPostID(ctx, postID); err != nil {
+		return 0, err
+	}
+	if _, err = s.revisionRepo.DeleteRevisionsByPostID(ctx, postID); err != nil {
+		return 0, err
+	}
+	if err = s.postRepo.DeletePost(ctx, postID); err != nil {
+		return 0, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+)
+
+// authorize checks that user has role giving permission in category.
+func (s *Service) authorize(ctx context.Context, userID string, permission models.Permission, category string) error {
+	roles, err := s.roleRepo.GetRolesByUserID(ctx, userID)
+	if err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get user roles", err)
+	}
+
+	for _, role := range roles {
+		if role.Allows(permission, category) {
+			return nil
+		}
+	}
+
+	return errhandler.New(http.StatusForbidden, "forbidden", "user "+userID+" has no permission "+string(permission)+" in category "+category, nil)
+}
+
+// authorizeContentDelete lets author delete own content,
+// moderators content in their category and admins anything.
+func (s *Service) authorizeContentDelete(ctx context.Context, userID string, author *models.User, category string) error {
+	if author != nil && author.ID == userID {
+		return nil
+	}
+
+	return s.authorize(ctx, userID, models.PermissionDeleteContent, category)
+}
+
+// authorizeContentHistory lets author see edit history of own content,
+// moderators of content in their category and admins of anything.
+func (s *Service) authorizeContentHistory(ctx context.Context, userID string, author *models.User, category string) error {
+	if author != nil && author.ID == userID {
+		return nil
+	}
+
+	return s.authorize(ctx, userID, models.PermissionViewHistory, category)
+}
+
+// authorizeRestore lets author restore content they deleted themselves,
+// content deleted by moderator can be restored only by moderators.
+func (s *Service) authorizeRestore(ctx context.Context, userID string, author *models.User, deletedBy, category string) error {
+	if author != nil && author.ID == userID && deletedBy == userID {
+		return nil
+	}
+
+	return s.authorize(ctx, userID, models.PermissionDeleteContent, category)
+}
+
+// GrantRole gives role to user with username, only admins can do it.
+func (s *Service) GrantRole(ctx context.Context, adminID, username, role, category string) (*models.RoleAssignment, error) {
+	if err := s.authorize(ctx, adminID, models.PermissionManageRoles, ""); err != nil {
+		return nil, err
+	}
+	if !models.ValidateRole(role, category) {
+		return nil, errhandler.New(http.StatusBadRequest, "invalid role", "invalid role "+role+" with category "+category, nil)
+	}
+
+	usr, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errhandler.New(http.StatusNotFound, "user not found", "user not found: "+username, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user", err)
+	}
+
+	assignment := models.NewRoleAssignment(usr.ID, role, category, adminID)
+	err = s.roleRepo.CreateRole(ctx, assignment)
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleAlreadyAssigned) {
+			return nil, errhandler.New(http.StatusConflict, "role already assigned", "user "+usr.ID+" already has role "+role, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create role", err)
+	}
+
+	s.logger.Infow("granted role",
+		"admin_id", adminID,
+		"user_id", usr.ID,
+		"role", role,
+		"category", category,
+	)
+
+	return assignment, nil
+}
+
+func (s *Service) RevokeRole(ctx context.Context, adminID, roleID string) error {
+	if err := s.authorize(ctx, adminID, models.PermissionManageRoles, ""); err != nil {
+		return err
+	}
+
+	err := s.roleRepo.DeleteRole(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleDontExists) {
+			return errhandler.New(http.StatusNotFound, "role not found", "role not found: "+roleID, nil)
+		}
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant delete role", err)
+	}
+
+	s.logger.Infow("revoked role",
+		"admin_id", adminID,
+		"role_id", roleID,
+	)
+
+	return nil
+}
+
+func (s *Service) GetUserRoles(ctx context.Context, adminID, username string) ([]*models.RoleAssignment, error) {
+	if err := s.authorize(ctx, adminID, models.PermissionManageRoles, ""); err != nil {
+		return nil, err
+	}
+
+	usr, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errhandler.New(http.StatusNotFound, "user not found", "user not found: "+username, nil)
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user", err)
+	}
+
+	roles, err := s.roleRepo.GetRolesByUserID(ctx, usr.ID)
+	if err != nil {
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user roles", err)
+	}
+
+	return roles, nil
+}
@@ -1,16 +1,36 @@
 package service
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
+	"github.com/myacey/redditclone/internal/export"
+	"github.com/myacey/redditclone/internal/mailer"
 	"github.com/myacey/redditclone/internal/mocks"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/oidc"
+	"github.com/myacey/redditclone/internal/oidc/oidctest"
+	"github.com/myacey/redditclone/internal/password"
+	"github.com/myacey/redditclone/internal/password/argonhasher"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/textdiff"
+	"github.com/myacey/redditclone/internal/totp"
+	"github.com/myacey/redditclone/internal/validation"
 )
 
 var ErrBasic = errors.New("some error")
@@ -163,64 +183,152 @@ func TestCreateNewUser(t *testing.T) {
 	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
 	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockHasher := mocks.NewMockHasher(ctrl)
 	mockLogger := zap.NewNop().Sugar()
 
 	service := &Service{
-		userRepo:    mockUserRepo,
-		postRepo:    mockPostRepo,
-		commentRepo: mockCommentRepo,
-		sessionRepo: mockSessionRepo,
-		tokenMaker:  mockTokenMaker,
-		logger:      mockLogger,
+		userRepo:       mockUserRepo,
+		postRepo:       mockPostRepo,
+		commentRepo:    mockCommentRepo,
+		sessionRepo:    mockSessionRepo,
+		tokenMaker:     mockTokenMaker,
+		passwordHasher: mockHasher,
+		mailer:         mailer.NewMemoryOutbox(),
+		appURL:         "http://localhost:8080",
+		logger:         mockLogger,
+	}
+
+	newUserWithEmail := func(email string) *models.User {
+		usr := models.NewUser("testuser", "correct-horse-42")
+		usr.Email = email
+		return usr
 	}
 
 	testCases := []struct {
 		name       string
 		userToAdd  *models.User
 		mockSetup  func()
-		expRes     interface{}
+		expRes     *models.Session
 		wantErrMsg string
 	}{
+		{
+			name:      "Success with email",
+			userToAdd: newUserWithEmail(" Test@Example.com"),
+			mockSetup: func() {
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "test@example.com").Return(nil, gorm.ErrRecordNotFound)
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, usr *models.User) error {
+					assert.Equal(t, "test@example.com", usr.Email)
+					assert.False(t, usr.EmailVerified)
+					return nil
+				})
+				mockSessionRepo.EXPECT().CreateEmailToken(gomock.Any(), gomock.Any(), emailVerifyTTL).
+					DoAndReturn(func(_ context.Context, verifyToken *models.EmailToken, _ time.Duration) error {
+						assert.Equal(t, models.EmailTokenVerify, verifyToken.Purpose)
+						assert.Equal(t, "test@example.com", verifyToken.Email)
+						return nil
+					})
+				mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return(mockSession.Token, nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expRes:     mockSession,
+			wantErrMsg: "",
+		},
+		{
+			name:      "Email taken",
+			userToAdd: newUserWithEmail("test@example.com"),
+			mockSetup: func() {
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "test@example.com").Return(mockUser, nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "email already taken",
+		},
+		{
+			name:       "Invalid username and password",
+			userToAdd:  models.NewUser("ab", "abc"),
+			mockSetup:  func() {},
+			expRes:     nil,
+			wantErrMsg: "validation failed",
+		},
+		{
+			name:       "Reserved username",
+			userToAdd:  models.NewUser("Admin", "correct-horse-42"),
+			mockSetup:  func() {},
+			expRes:     nil,
+			wantErrMsg: "validation failed",
+		},
+		{
+			name:       "Common password",
+			userToAdd:  models.NewUser("testuser", "qwerty123"),
+			mockSetup:  func() {},
+			expRes:     nil,
+			wantErrMsg: "validation failed",
+		},
+		{
+			name:      "Username taken",
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
+			mockSetup: func() {
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(repository.ErrUserAlreadyExists)
+			},
+			expRes:     nil,
+			wantErrMsg: "user already exists",
+		},
 		{
 			name:      "Success",
-			userToAdd: mockUser,
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
 			mockSetup: func() {
-				mockUserRepo.EXPECT().CreateUser(gomock.Any(), mockUser).Return(nil)
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, usr *models.User) error {
+					assert.Equal(t, "hashed", usr.Password)
+					return nil
+				})
 				mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return(mockSession.Token, nil)
-				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 			},
 			expRes:     mockSession,
 			wantErrMsg: "",
 		},
+		{
+			name:      "Hasher Error",
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
+			mockSetup: func() {
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("", ErrBasic)
+			},
+			expRes:     nil,
+			wantErrMsg: "internal error",
+		},
 		{
 			name:      "Repo Error",
-			userToAdd: mockUser,
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
 			mockSetup: func() {
-				mockUserRepo.EXPECT().CreateUser(gomock.Any(), mockUser).Return(ErrBasic)
-				// mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return(mockSession.Token, nil)
-				// mockSessionRepo.EXPECT().CreateSession(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(ErrBasic)
 			},
 			expRes:     nil,
 			wantErrMsg: "internal error",
 		},
 		{
 			name:      "Token Maker Error",
-			userToAdd: mockUser,
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
 			mockSetup: func() {
-				mockUserRepo.EXPECT().CreateUser(gomock.Any(), mockUser).Return(nil)
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
 				mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return("", ErrBasic)
-				// mockSessionRepo.EXPECT().CreateSession(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
 			},
 			expRes:     nil,
 			wantErrMsg: "internal error",
 		},
 		{
 			name:      "Session Error",
-			userToAdd: mockUser,
+			userToAdd: models.NewUser("testuser", "correct-horse-42"),
 			mockSetup: func() {
-				mockUserRepo.EXPECT().CreateUser(gomock.Any(), mockUser).Return(nil)
+				mockHasher.EXPECT().Hash("correct-horse-42").Return("hashed", nil)
+				mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
 				mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return(mockSession.Token, nil)
-				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(ErrBasic)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(ErrBasic)
 			},
 			expRes:     nil,
 			wantErrMsg: "internal error",
@@ -231,11 +339,12 @@ func TestCreateNewUser(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.CreateNewUser(context.Background(), tc.userToAdd)
+			res, err := service.CreateNewUser(context.Background(), tc.userToAdd, nil)
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
-				assert.Equal(t, tc.expRes, res)
+				assert.Equal(t, tc.expRes.Token, res.Token)
+				assert.NotEmpty(t, res.RefreshToken)
 			}
 			if tc.wantErrMsg == "" {
 				assert.NoError(t, err)
@@ -246,6 +355,13 @@ func TestCreateNewUser(t *testing.T) {
 	}
 }
 
+func TestDummyPasswordHash(t *testing.T) {
+	// hash has to be valid, otherwise it is rejected without hashing
+	hasher := argonhasher.NewArgon2idHasher(argonhasher.DefaultParams())
+	_, err := hasher.Verify("qwerty123", dummyPasswordHash)
+	assert.ErrorIs(t, err, password.ErrMismatch)
+}
+
 func TestLoginUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -255,42 +371,204 @@ func TestLoginUser(t *testing.T) {
 	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
 	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockHasher := mocks.NewMockHasher(ctrl)
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+	mockLoginAttemptRepo := mocks.NewMockLoginAttemptRepository(ctrl)
 	mockLogger := zap.NewNop().Sugar()
 
 	service := &Service{
-		userRepo:    mockUserRepo,
-		postRepo:    mockPostRepo,
-		commentRepo: mockCommentRepo,
-		sessionRepo: mockSessionRepo,
-		tokenMaker:  mockTokenMaker,
-		logger:      mockLogger,
+		userRepo:         mockUserRepo,
+		postRepo:         mockPostRepo,
+		commentRepo:      mockCommentRepo,
+		sessionRepo:      mockSessionRepo,
+		mfaRepo:          mockMFARepo,
+		loginAttemptRepo: mockLoginAttemptRepo,
+		tokenMaker:       mockTokenMaker,
+		passwordHasher:   mockHasher,
+		logger:           mockLogger,
 	}
 
 	testCases := []struct {
 		name       string
 		username   string
+		password   string
 		mockSetup  func()
-		expRes     interface{}
+		expRes     *models.Session
+		expPending bool
 		wantErrMsg string
 	}{
 		{
 			name:     "Success",
 			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
+				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expRes:     mockSession,
+			wantErrMsg: "",
+		},
+		{
+			name:     "Success with rehash",
+			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(true, nil)
+				mockHasher.EXPECT().Hash("qwerty123").Return(mockUser.Password, nil)
+				mockUserRepo.EXPECT().UpdateUserPassword(gomock.Any(), mockUser.ID, mockUser.Password).Return(nil)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
+				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expRes:     mockSession,
+			wantErrMsg: "",
+		},
+		{
+			name:     "Rehash error doesnt fail login",
+			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(true, nil)
+				mockHasher.EXPECT().Hash("qwerty123").Return(mockUser.Password, nil)
+				mockUserRepo.EXPECT().UpdateUserPassword(gomock.Any(), mockUser.ID, mockUser.Password).Return(ErrBasic)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
+				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expRes:     mockSession,
+			wantErrMsg: "",
+		},
+		{
+			name:     "2FA enabled",
+			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(&models.TOTPConfig{UserID: mockUser.ID, Enabled: true}, nil)
+				mockSessionRepo.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Any(), mfaChallengeTTL).Return(nil)
+			},
+			expRes:     nil,
+			expPending: true,
+			wantErrMsg: "",
+		},
+		{
+			name:     "2FA not confirmed",
+			username: mockUser.Username,
+			password: "qwerty123",
 			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
 				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(&models.TOTPConfig{UserID: mockUser.ID, Enabled: false}, nil)
 				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
-				mockSessionRepo.EXPECT().UpdateSessionToken(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 			},
 			expRes:     mockSession,
 			wantErrMsg: "",
 		},
+		{
+			name:     "Err mfa repo",
+			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, ErrBasic)
+			},
+			expRes:     nil,
+			wantErrMsg: "internal error",
+		},
+		{
+			name:     "Unknown user",
+			username: "unknown",
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+"unknown").Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "unknown").Return(nil, gorm.ErrRecordNotFound)
+				// password is still verified, so unknown user isnt answered faster
+				mockHasher.EXPECT().Verify("qwerty123", dummyPasswordHash).Return(false, password.ErrMismatch)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "user:"+"unknown", loginUserThrottle.window).Return(int64(1), nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid credentials",
+		},
+		{
+			name:     "Wrong password",
+			username: mockUser.Username,
+			password: "wrong",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("wrong", mockUser.Password).Return(false, password.ErrMismatch)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "user:"+mockUser.Username, loginUserThrottle.window).Return(int64(1), nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid credentials",
+		},
+		{
+			name:     "Locked out",
+			username: mockUser.Username,
+			password: "qwerty123",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Minute, nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "too many attempts",
+		},
+		{
+			// "TеstUser" has cyrillic "е", it logs into the same account
+			name:     "Confusable username shares counter",
+			username: "TеstUser",
+			password: "wrong",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "TеstUser").Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("wrong", mockUser.Password).Return(false, password.ErrMismatch)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "user:"+mockUser.Username, loginUserThrottle.window).Return(int64(1), nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid credentials",
+		},
+		{
+			name:     "Wrong password locks after free attempts",
+			username: mockUser.Username,
+			password: "wrong",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("wrong", mockUser.Password).Return(false, password.ErrMismatch)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "user:"+mockUser.Username, loginUserThrottle.window).Return(loginUserThrottle.free+1, nil)
+				mockLoginAttemptRepo.EXPECT().Lock(gomock.Any(), "user:"+mockUser.Username, 2*lockBaseDuration).Return(nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid credentials",
+		},
 		{
 			name:     "Err user repo",
 			username: mockUser.Username,
+			password: "qwerty123",
 			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
 				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(nil, ErrBasic)
-				// mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
-				// mockSessionRepo.EXPECT().UpdateSessionToken(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
 			},
 			expRes:     nil,
 			wantErrMsg: ErrBasic.Error(),
@@ -298,24 +576,33 @@ func TestLoginUser(t *testing.T) {
 		{
 			name:     "Err token maker",
 			username: mockUser.Username,
+			password: "qwerty123",
 			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
 				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
 				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("", ErrBasic)
-				// mockSessionRepo.EXPECT().UpdateSessionToken(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(nil)
 			},
 			expRes:     nil,
-			wantErrMsg: ErrBasic.Error(),
+			wantErrMsg: "internal error",
 		},
 		{
 			name:     "Err session repo",
 			username: mockUser.Username,
+			password: "qwerty123",
 			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
 				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockHasher.EXPECT().Verify("qwerty123", mockUser.Password).Return(false, nil)
+				mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
 				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
-				mockSessionRepo.EXPECT().UpdateSessionToken(gomock.Any(), mockSession, mockUser.ID, gomock.Any()).Return(ErrBasic)
+				mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(ErrBasic)
 			},
 			expRes:     nil,
-			wantErrMsg: ErrBasic.Error(),
+			wantErrMsg: "internal error",
 		},
 	}
 
@@ -323,11 +610,18 @@ func TestLoginUser(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.LoginUser(context.Background(), tc.username)
+			res, pending, err := service.LoginUser(context.Background(), tc.username, tc.password, nil)
+			if tc.expPending {
+				assert.True(t, pending.MFARequired)
+				assert.NotEmpty(t, pending.MFAToken)
+			} else {
+				assert.Nil(t, pending)
+			}
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
-				assert.Equal(t, tc.expRes, res)
+				assert.Equal(t, tc.expRes.Token, res.Token)
+				assert.NotEmpty(t, res.RefreshToken)
 			}
 			if tc.wantErrMsg == "" {
 				assert.NoError(t, err)
@@ -345,6 +639,7 @@ func TestGetAllPosts(t *testing.T) {
 	mockUserRepo := mocks.NewMockUserRepository(ctrl)
 	mockPostRepo := mocks.NewMockPostRepository(ctrl)
 	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
 	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 	mockLogger := zap.NewNop().Sugar()
@@ -353,13 +648,18 @@ func TestGetAllPosts(t *testing.T) {
 		userRepo:    mockUserRepo,
 		postRepo:    mockPostRepo,
 		commentRepo: mockCommentRepo,
+		voteRepo:    mockVoteRepo,
 		sessionRepo: mockSessionRepo,
 		tokenMaker:  mockTokenMaker,
 		logger:      mockLogger,
 	}
 
+	votedPost := models.NewPost(mockUser, "music", "voted", "text", "mock text", "")
+	otherPost := models.NewPost(mockUser, "music", "other", "text", "mock text", "")
+
 	testCases := []struct {
 		name       string
+		userID     string
 		mockSetup  func()
 		expRes     interface{}
 		wantErrMsg string
@@ -372,6 +672,16 @@ func TestGetAllPosts(t *testing.T) {
 			expRes:     mockPosts,
 			wantErrMsg: "",
 		},
+		{
+			name:   "Only viewer's votes",
+			userID: "viewer",
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetAllPosts(gomock.Any()).Return([]*models.Post{votedPost, otherPost}, nil)
+				mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), "viewer", []string{votedPost.ID, otherPost.ID}).Return(map[string]int8{votedPost.ID: -1}, nil)
+			},
+			expRes:     []*models.Post{votedPost, otherPost},
+			wantErrMsg: "",
+		},
 		{
 			name: "Err post repo",
 			mockSetup: func() {
@@ -386,7 +696,7 @@ func TestGetAllPosts(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.GetAllPosts(context.Background())
+			res, err := service.GetAllPosts(context.Background(), tc.userID)
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
@@ -399,6 +709,9 @@ func TestGetAllPosts(t *testing.T) {
 			}
 		})
 	}
+
+	assert.Equal(t, []*models.Vote{{PostID: votedPost.ID, UserID: "viewer", Vote: -1}}, votedPost.Votes)
+	assert.Empty(t, otherPost.Votes)
 }
 
 func TestAddPost(t *testing.T) {
@@ -410,6 +723,8 @@ func TestAddPost(t *testing.T) {
 	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
 	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
 	mockLogger := zap.NewNop().Sugar()
 
 	service := &Service{
@@ -418,9 +733,16 @@ func TestAddPost(t *testing.T) {
 		commentRepo: mockCommentRepo,
 		sessionRepo: mockSessionRepo,
 		tokenMaker:  mockTokenMaker,
+		profileRepo: mockProfileRepo,
+		voteRepo:    mockVoteRepo,
 		logger:      mockLogger,
 	}
 
+	verifiedUser := models.NewUser("verified", "qwerty123")
+	verifiedUser.Email = "verified@example.com"
+	verifiedUser.EmailVerified = true
+	verifiedPost := models.NewPost(verifiedUser, "music", "mock title", "text", "mock text", "")
+
 	testCases := []struct {
 		name       string
 		newPost    *models.Post
@@ -429,12 +751,30 @@ func TestAddPost(t *testing.T) {
 	}{
 		{
 			name:    "Success",
-			newPost: mockSinglePost,
+			newPost: verifiedPost,
 			mockSetup: func() {
-				mockPostRepo.EXPECT().CreatePost(gomock.Any(), mockSinglePost).Return(nil)
+				gomock.InOrder(
+					mockVoteRepo.EXPECT().SetVote(gomock.Any(), &models.Vote{PostID: verifiedPost.ID, UserID: verifiedUser.ID, Vote: 1}).Return(int8(0), nil),
+					mockPostRepo.EXPECT().CreatePost(gomock.Any(), verifiedPost).Return(nil),
+				)
+				mockProfileRepo.EXPECT().AddKarma(gomock.Any(), verifiedUser.ID, 1, 0).Return(nil)
 			},
 			wantErrMsg: "",
 		},
+		{
+			name:    "Err vote repo",
+			newPost: verifiedPost,
+			mockSetup: func() {
+				mockVoteRepo.EXPECT().SetVote(gomock.Any(), gomock.Any()).Return(int8(0), ErrBasic)
+			},
+			wantErrMsg: ErrBasic.Error(),
+		},
+		{
+			name:       "Err email not verified",
+			newPost:    mockSinglePost,
+			mockSetup:  func() {},
+			wantErrMsg: "email not verified",
+		},
 		{
 			name:    "Err invalid post data",
 			newPost: &models.Post{Category: "invalid"},
@@ -443,6 +783,12 @@ func TestAddPost(t *testing.T) {
 			},
 			wantErrMsg: ErrInvalidPostData.Error(),
 		},
+		{
+			name:       "Err too long text",
+			newPost:    models.NewPost(verifiedUser, "music", "mock title", "text", strings.Repeat("a", models.MaxPostTextLength+1), ""),
+			mockSetup:  func() {},
+			wantErrMsg: ErrInvalidPostData.Error(),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -493,8 +839,8 @@ func TestGetPostByID(t *testing.T) {
 			increaseVote: true,
 			mockSetup: func() {
 				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
-				mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), mockSinglePost).Return(nil) // increate vote
-				mockCommentRepo.EXPECT().GetCommentsByPostID(gomock.Any(), mockSinglePost.ID).Return(nil, nil)
+				mockPostRepo.EXPECT().IncrementViews(gomock.Any(), mockSinglePost.ID).Return(nil) // increate vote
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return(nil, nil)
 			},
 			expRes:     mockSinglePost,
 			wantErrMsg: "",
@@ -505,8 +851,8 @@ func TestGetPostByID(t *testing.T) {
 			increaseVote: true,
 			mockSetup: func() {
 				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(nil, ErrBasic)
-				// mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), mockSinglePost).Return(nil) // increate vote
-				// mockCommentRepo.EXPECT().GetCommentsByPostID(gomock.Any(), mockSinglePost.ID).Return(nil, nil)
+				// mockPostRepo.EXPECT().IncrementViews(gomock.Any(), mockSinglePost.ID).Return(nil) // increate vote
+				// mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return(nil, nil)
 			},
 			expRes:     nil,
 			wantErrMsg: "cant find post",
@@ -517,8 +863,8 @@ func TestGetPostByID(t *testing.T) {
 			increaseVote: true,
 			mockSetup: func() {
 				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
-				mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), mockSinglePost).Return(ErrBasic) // increate vote
-				// mockCommentRepo.EXPECT().GetCommentsByPostID(gomock.Any(), mockSinglePost.ID).Return(nil, nil)
+				mockPostRepo.EXPECT().IncrementViews(gomock.Any(), mockSinglePost.ID).Return(ErrBasic) // increate vote
+				// mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return(nil, nil)
 			},
 			expRes:     nil,
 			wantErrMsg: ErrBasic.Error(),
@@ -529,8 +875,8 @@ func TestGetPostByID(t *testing.T) {
 			increaseVote: true,
 			mockSetup: func() {
 				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
-				mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), mockSinglePost).Return(nil) // increate vote
-				mockCommentRepo.EXPECT().GetCommentsByPostID(gomock.Any(), mockSinglePost.ID).Return(nil, ErrBasic)
+				mockPostRepo.EXPECT().IncrementViews(gomock.Any(), mockSinglePost.ID).Return(nil) // increate vote
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return(nil, ErrBasic)
 			},
 			expRes:     nil,
 			wantErrMsg: "cant find comments",
@@ -541,7 +887,7 @@ func TestGetPostByID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.GetPostByID(context.Background(), tc.postID, tc.increaseVote)
+			res, err := service.GetPostByID(context.Background(), "", tc.postID, tc.increaseVote)
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
@@ -556,6 +902,235 @@ func TestGetPostByID(t *testing.T) {
 	}
 }
 
+func TestUpdatePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	service := &Service{
+		postRepo: mockPostRepo,
+		logger:   zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	conflict := &repository.VersionConflictError{PostID: post.ID, Version: post.Version}
+	setTitle := func(p *models.Post) error {
+		p.Title = "new title"
+		return nil
+	}
+
+	t.Run("Retry on conflict", func(t *testing.T) {
+		gomock.InOrder(
+			mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil),
+			mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).Return(conflict),
+			mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil),
+			mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).Return(nil),
+		)
+
+		res, err := service.updatePost(context.Background(), post.ID, setTitle)
+		require.NoError(t, err)
+		assert.Equal(t, "new title", res.Title)
+	})
+
+	t.Run("Attempts exhausted", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil).Times(postUpdateAttempts)
+		mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).Return(conflict).Times(postUpdateAttempts)
+
+		res, err := service.updatePost(context.Background(), post.ID, setTitle)
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, conflict)
+	})
+
+	t.Run("Post not found", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(nil, nil)
+
+		_, err := service.updatePost(context.Background(), post.ID, setTitle)
+		assert.ErrorIs(t, err, repository.ErrPostDontExists)
+	})
+
+	t.Run("Other error isnt retried", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).Return(ErrBasic)
+
+		_, err := service.updatePost(context.Background(), post.ID, setTitle)
+		assert.ErrorIs(t, err, ErrBasic)
+	})
+}
+
+func TestEditPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockRevisionRepo := mocks.NewMockPostRevisionRepository(ctrl)
+
+	service := &Service{
+		postRepo:     mockPostRepo,
+		commentRepo:  mockCommentRepo,
+		voteRepo:     mockVoteRepo,
+		revisionRepo: mockRevisionRepo,
+		logger:       zap.NewNop().Sugar(),
+	}
+
+	newPost := func() *models.Post {
+		post := models.NewPost(mockUser, "music", "title", "text", "old text", "")
+		post.Version = 3
+		return post
+	}
+	expectResult := func(post *models.Post) {
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{post.ID: 1}, nil)
+		mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+	}
+	str := func(s string) *string { return &s }
+
+	t.Run("Success", func(t *testing.T) {
+		post := newPost()
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).DoAndReturn(func(_ context.Context, p *models.Post) error {
+			assert.Equal(t, "new text", p.Text)
+			assert.NotNil(t, p.Edited)
+			p.Version++
+			return nil
+		})
+		mockRevisionRepo.EXPECT().CreateRevision(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, revision *models.PostRevision) error {
+			assert.Equal(t, "old text", revision.Text)
+			assert.Equal(t, int64(3), revision.Version)
+			assert.Equal(t, post.CreatedAt, revision.CreatedAt)
+			return nil
+		})
+		expectResult(post)
+
+		res, err := service.EditPost(context.Background(), mockUser.ID, post.ID, &models.PostEdit{Text: str("new text")})
+		require.NoError(t, err)
+		assert.Equal(t, "new text", res.Text)
+	})
+
+	t.Run("Type of post voted only by author", func(t *testing.T) {
+		post := newPost()
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{post.ID: 1}, nil)
+		mockPostRepo.EXPECT().UpdatePostInfo(gomock.Any(), post).Return(nil)
+		mockRevisionRepo.EXPECT().CreateRevision(gomock.Any(), gomock.Any()).Return(nil)
+		expectResult(post)
+
+		res, err := service.EditPost(context.Background(), mockUser.ID, post.ID, &models.PostEdit{Type: str("link"), URL: str("https://example.com")})
+		require.NoError(t, err)
+		assert.Equal(t, "link", res.Type)
+	})
+
+	t.Run("URL of voted post", func(t *testing.T) {
+		post := newPost()
+		post.SetVoteCounters(1, 1)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{post.ID: 1}, nil)
+
+		_, err := service.EditPost(context.Background(), mockUser.ID, post.ID, &models.PostEdit{URL: str("https://example.com")})
+		assert.EqualError(t, err, "cant change type or url of voted post")
+	})
+
+	t.Run("Not an author", func(t *testing.T) {
+		post := newPost()
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+
+		_, err := service.EditPost(context.Background(), "other", post.ID, &models.PostEdit{Text: str("new text")})
+		assert.EqualError(t, err, "only author can edit post")
+	})
+
+	t.Run("Nothing changed", func(t *testing.T) {
+		post := newPost()
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil).Times(2)
+		expectResult(post)
+
+		res, err := service.EditPost(context.Background(), mockUser.ID, post.ID, &models.PostEdit{Text: str("old text")})
+		require.NoError(t, err)
+		assert.Nil(t, res.Edited)
+	})
+
+	t.Run("Post not found", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), "post").Return(nil, nil)
+
+		_, err := service.EditPost(context.Background(), mockUser.ID, "post", &models.PostEdit{Text: str("new text")})
+		assert.EqualError(t, err, "post not found")
+	})
+
+	t.Run("Empty title", func(t *testing.T) {
+		_, err := service.EditPost(context.Background(), mockUser.ID, "post", &models.PostEdit{Title: str(" ")})
+		assert.EqualError(t, err, "title cant be empty")
+	})
+}
+
+func TestGetPostRevisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockRevisionRepo := mocks.NewMockPostRevisionRepository(ctrl)
+
+	service := &Service{
+		postRepo:     mockPostRepo,
+		revisionRepo: mockRevisionRepo,
+		logger:       zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "one\nthree", "")
+	post.Version = 5
+	first := &models.PostRevision{PostID: post.ID, Version: 0, Title: "title", Type: "text", Text: "one\ntwo"}
+	// saved by edit after post was read
+	newer := &models.PostRevision{PostID: post.ID, Version: 5, Title: "title", Type: "text", Text: "one\nthree"}
+
+	expectRevisions := func() {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockRevisionRepo.EXPECT().GetRevisionsByPostID(gomock.Any(), post.ID).Return([]*models.PostRevision{first, newer}, nil)
+	}
+
+	t.Run("Revisions", func(t *testing.T) {
+		expectRevisions()
+
+		revisions, err := service.GetPostRevisions(context.Background(), post.ID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		assert.Equal(t, 1, revisions[0].Number)
+		assert.Equal(t, 2, revisions[1].Number)
+		assert.Equal(t, "one\nthree", revisions[1].Text)
+	})
+
+	t.Run("Diff of last edit", func(t *testing.T) {
+		expectRevisions()
+
+		diff, err := service.GetPostDiff(context.Background(), post.ID, 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, diff.From)
+		assert.Equal(t, 2, diff.To)
+		assert.Equal(t, []textdiff.Line{
+			{Op: textdiff.OpEqual, Text: "one"},
+			{Op: textdiff.OpDelete, Text: "two"},
+			{Op: textdiff.OpInsert, Text: "three"},
+		}, diff.Text)
+		assert.Empty(t, diff.URL)
+	})
+
+	t.Run("Revision not found", func(t *testing.T) {
+		expectRevisions()
+
+		_, err := service.GetPostDiff(context.Background(), post.ID, 1, 3)
+		assert.EqualError(t, err, "revision not found")
+	})
+
+	t.Run("Post not found", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), "post").Return(nil, nil)
+
+		_, err := service.GetPostRevisions(context.Background(), "post")
+		assert.EqualError(t, err, "post not found")
+	})
+}
+
 func TestGetPostsByAuthor(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -593,6 +1168,16 @@ func TestGetPostsByAuthor(t *testing.T) {
 			expRes:     mockPosts,
 			wantErrMsg: "",
 		},
+		{
+			name:     "Differently cased username",
+			username: "TestUser",
+			mockSetup: func() {
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "TestUser").Return(mockUser, nil)
+				mockPostRepo.EXPECT().GetAllPosts(gomock.Any()).Return(mockPosts, nil)
+			},
+			expRes:     mockPosts,
+			wantErrMsg: "",
+		},
 		{
 			name:     "Err user repo",
 			username: mockUser.Username,
@@ -619,7 +1204,7 @@ func TestGetPostsByAuthor(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.GetPostsByAuthor(context.Background(), tc.username)
+			res, err := service.GetPostsByAuthor(context.Background(), "", tc.username)
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
@@ -702,7 +1287,7 @@ func TestGetPostsByCategory(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			res, err := service.GetPostsByCategory(context.Background(), tc.category)
+			res, err := service.GetPostsByCategory(context.Background(), "", tc.category)
 			if tc.expRes == nil {
 				assert.Nil(t, res)
 			} else {
@@ -725,6 +1310,8 @@ func TestDeletePostWithID(t *testing.T) {
 	mockPostRepo := mocks.NewMockPostRepository(ctrl)
 	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
 	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
 	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
 	mockLogger := zap.NewNop().Sugar()
 
@@ -733,47 +1320,3179 @@ func TestDeletePostWithID(t *testing.T) {
 		postRepo:    mockPostRepo,
 		commentRepo: mockCommentRepo,
 		sessionRepo: mockSessionRepo,
+		roleRepo:    mockRoleRepo,
+		voteRepo:    mockVoteRepo,
 		tokenMaker:  mockTokenMaker,
 		logger:      mockLogger,
 	}
 
+	otherUserID := "otheruser"
+
 	testCases := []struct {
-		name         string
-		postID       string
-		increaseVote bool
-		mockSetup    func()
-		wantErrMsg   string
+		name       string
+		userID     string
+		postID     string
+		mockSetup  func()
+		wantErrMsg string
 	}{
 		{
-			name:         "Success",
-			postID:       mockSinglePost.ID,
-			increaseVote: true,
+			name:   "Success author",
+			userID: mockUser.ID,
+			postID: mockSinglePost.ID,
 			mockSetup: func() {
-				mockPostRepo.EXPECT().DeletePost(gomock.Any(), mockSinglePost.ID).Return(nil)
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockPostRepo.EXPECT().SoftDeletePost(gomock.Any(), mockSinglePost.ID, gomock.Any(), gomock.Any()).Return(nil)
 			},
 			wantErrMsg: "",
 		},
 		{
-			name:         "Error",
-			postID:       mockSinglePost.ID,
-			increaseVote: true,
+			name:   "Success moderator of category",
+			userID: otherUserID,
+			postID: mockSinglePost.ID,
 			mockSetup: func() {
-				mockPostRepo.EXPECT().DeletePost(gomock.Any(), mockSinglePost.ID).Return(ErrBasic)
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), otherUserID).Return([]*models.RoleAssignment{
+					models.NewRoleAssignment(otherUserID, models.RoleModerator, "music", ""),
+				}, nil)
+				mockPostRepo.EXPECT().SoftDeletePost(gomock.Any(), mockSinglePost.ID, gomock.Any(), gomock.Any()).Return(nil)
 			},
-			wantErrMsg: ErrBasic.Error(),
+			wantErrMsg: "",
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			tc.mockSetup()
-
-			err := service.DeletePostWithID(context.Background(), tc.postID)
-			if tc.wantErrMsg == "" {
-				assert.NoError(t, err)
+		{
+			name:   "Success admin",
+			userID: otherUserID,
+			postID: mockSinglePost.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), otherUserID).Return([]*models.RoleAssignment{
+					models.NewRoleAssignment(otherUserID, models.RoleAdmin, "", ""),
+				}, nil)
+				mockPostRepo.EXPECT().SoftDeletePost(gomock.Any(), mockSinglePost.ID, gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name:   "Moderator of other category",
+			userID: otherUserID,
+			postID: mockSinglePost.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), otherUserID).Return([]*models.RoleAssignment{
+					models.NewRoleAssignment(otherUserID, models.RoleModerator, "news", ""),
+				}, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+		{
+			name:   "Not an author",
+			userID: otherUserID,
+			postID: mockSinglePost.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), otherUserID).Return(nil, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+		{
+			name:   "Post not found",
+			userID: mockUser.ID,
+			postID: mockSinglePost.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(nil, nil)
+			},
+			wantErrMsg: "post not found",
+		},
+		{
+			name:   "Error",
+			userID: mockUser.ID,
+			postID: mockSinglePost.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockPostRepo.EXPECT().SoftDeletePost(gomock.Any(), mockSinglePost.ID, mockUser.ID, gomock.Any()).Return(ErrBasic)
+			},
+			wantErrMsg: ErrBasic.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.DeletePostWithID(context.Background(), tc.userID, tc.postID)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestReplyToComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	author := models.NewUser("author", "qwerty123")
+	author.EmailVerified = true
+	post := models.NewPost(author, "music", "title", "text", "text", "")
+	parent := models.NewComment("parent", author, post.ID)
+
+	t.Run("Success", func(t *testing.T) {
+		reply := models.NewComment("reply", author, post.ID)
+
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), parent.ID).Return(parent, nil)
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), reply.ID).Return(nil, nil)
+		mockCommentRepo.EXPECT().CreateComment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, c *models.Comment) error {
+			assert.Equal(t, parent.ID, c.ParentID)
+			assert.Equal(t, parent.ID+"/"+reply.ID, c.Path)
+			assert.Equal(t, 1, c.Depth)
+			reply = c
+			return nil
+		})
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), author.ID, []string{post.ID}).Return(map[string]int8{}, nil)
+		mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).DoAndReturn(func(context.Context, string, string, int) ([]*models.Comment, error) {
+			return []*models.Comment{parent, reply}, nil
+		})
+		mockVoteRepo.EXPECT().GetUserCommentVotes(gomock.Any(), author.ID, post.ID).Return(map[string]int8{}, nil)
+
+		res, err := service.ReplyToComment(context.Background(), post.ID, parent.ID, *reply)
+		require.NoError(t, err)
+		require.Len(t, res.Comments, 1)
+		assert.Equal(t, []*models.Comment{reply}, res.Comments[0].Replies)
+	})
+
+	t.Run("Parent in other post", func(t *testing.T) {
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), parent.ID).Return(parent, nil)
+
+		_, err := service.ReplyToComment(context.Background(), "other", parent.ID, *models.NewComment("reply", author, "other"))
+		assert.EqualError(t, err, "comment not found")
+	})
+
+	t.Run("Post deleted meanwhile", func(t *testing.T) {
+		reply := models.NewComment("reply", author, post.ID)
+
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), parent.ID).Return(parent, nil)
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), reply.ID).Return(nil, nil)
+		mockCommentRepo.EXPECT().CreateComment(gomock.Any(), gomock.Any()).Return(repository.ErrPostDontExists)
+
+		_, err := service.ReplyToComment(context.Background(), post.ID, parent.ID, *reply)
+		assert.EqualError(t, err, "post not found")
+	})
+}
+
+func TestReconcileCommentCounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	setup := func() {
+		mockPostRepo.EXPECT().GetCommentCounts(gomock.Any()).Return(map[string]int{"ok": 2, "more": 1, "less": 3, "empty": 1}, nil)
+		mockCommentRepo.EXPECT().CountCommentsByPost(gomock.Any()).Return(map[string]int{"ok": 2, "more": 4, "less": 1, "orphan": 1}, nil)
+	}
+
+	t.Run("Fix", func(t *testing.T) {
+		setup()
+		mockPostRepo.EXPECT().SetCommentCount(gomock.Any(), "more", 1, 4).Return(true, nil)
+		mockPostRepo.EXPECT().SetCommentCount(gomock.Any(), "less", 3, 1).Return(true, nil)
+		// commented during reconciliation
+		mockPostRepo.EXPECT().SetCommentCount(gomock.Any(), "empty", 1, 0).Return(false, nil)
+
+		drifts, err := service.ReconcileCommentCounts(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, []*models.CommentCountDrift{
+			{PostID: "empty", Stored: 1, Actual: 0, Fixed: false},
+			{PostID: "less", Stored: 3, Actual: 1, Fixed: true},
+			{PostID: "more", Stored: 1, Actual: 4, Fixed: true},
+		}, drifts)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		setup()
+
+		drifts, err := service.ReconcileCommentCounts(context.Background(), true)
+		require.NoError(t, err)
+		assert.Len(t, drifts, 3)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetCommentCounts(gomock.Any()).Return(nil, ErrBasic)
+
+		_, err := service.ReconcileCommentCounts(context.Background(), false)
+		assert.ErrorIs(t, err, ErrBasic)
+	})
+}
+
+func TestGetCommentReplies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+
+	service := &Service{
+		postRepo:        mockPostRepo,
+		commentRepo:     mockCommentRepo,
+		voteRepo:        mockVoteRepo,
+		commentMaxDepth: 2,
+		logger:          zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	chain := []*models.Comment{models.NewComment("0", mockUser, post.ID)}
+	for i := 1; i < 6; i++ {
+		reply := models.NewComment(strconv.Itoa(i), mockUser, post.ID)
+		reply.SetParent(chain[i-1])
+		chain = append(chain, reply)
+	}
+
+	// depth 2 comment continues with depths 3 and 4, depth 5 is cut again
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil).Times(2)
+	mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), chain[2].ID).Return(chain[2], nil)
+	mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, chain[2].Path, 5).Return([]*models.Comment{chain[3], chain[4], chain[5]}, nil)
+	mockVoteRepo.EXPECT().GetUserCommentVotes(gomock.Any(), "viewer", post.ID).Return(map[string]int8{chain[2].ID: 1}, nil)
+
+	res, err := service.GetCommentReplies(context.Background(), "viewer", post.ID, chain[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int8(1), res.Votes[0].Vote)
+	require.Len(t, res.Replies, 1)
+	assert.Equal(t, chain[3].ID, res.Replies[0].ID)
+	require.Len(t, res.Replies[0].Replies, 1)
+	assert.Empty(t, res.Replies[0].Replies[0].Replies)
+	assert.Equal(t, chain[4].ID, res.Replies[0].Replies[0].MoreReplies)
+
+	mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), "missing").Return(nil, nil)
+	_, err = service.GetCommentReplies(context.Background(), "viewer", post.ID, "missing")
+	assert.EqualError(t, err, "comment not found")
+
+	// deleted post isnt returned by repo, its comments are hidden too
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), "deleted").Return(nil, nil)
+	_, err = service.GetCommentReplies(context.Background(), "viewer", "deleted", chain[2].ID)
+	assert.EqualError(t, err, "post not found")
+}
+
+func TestEditComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+
+	service := &Service{
+		postRepo:         mockPostRepo,
+		commentRepo:      mockCommentRepo,
+		voteRepo:         mockVoteRepo,
+		commentEditGrace: time.Minute,
+		logger:           zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	expectResult := func() {
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{}, nil)
+		mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+	}
+
+	t.Run("In grace window", func(t *testing.T) {
+		comment := models.NewComment("old", mockUser, post.ID)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+		mockCommentRepo.EXPECT().EditComment(gomock.Any(), gomock.Any(), "old", nil).DoAndReturn(func(_ context.Context, c *models.Comment, _ string, _ *models.CommentRevision) error {
+			assert.Equal(t, "new", c.Body)
+			assert.Nil(t, c.Edited)
+			return nil
+		})
+		expectResult()
+
+		_, err := service.EditComment(context.Background(), mockUser.ID, post.ID, comment.ID, "new")
+		require.NoError(t, err)
+	})
+
+	t.Run("Recorded", func(t *testing.T) {
+		comment := models.NewComment("old", mockUser, post.ID)
+		comment.CreatedAt = time.Now().Add(-time.Hour)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+		mockCommentRepo.EXPECT().EditComment(gomock.Any(), gomock.Any(), "old", gomock.Any()).DoAndReturn(func(_ context.Context, c *models.Comment, _ string, revision *models.CommentRevision) error {
+			require.NotNil(t, revision)
+			assert.Equal(t, "old", revision.Body)
+			assert.Equal(t, comment.CreatedAt, revision.CreatedAt)
+			assert.NotNil(t, c.Edited)
+			return nil
+		})
+		expectResult()
+
+		_, err := service.EditComment(context.Background(), mockUser.ID, post.ID, comment.ID, "new")
+		require.NoError(t, err)
+	})
+
+	t.Run("Same body", func(t *testing.T) {
+		comment := models.NewComment("old", mockUser, post.ID)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+		expectResult()
+
+		_, err := service.EditComment(context.Background(), mockUser.ID, post.ID, comment.ID, "old")
+		require.NoError(t, err)
+	})
+
+	t.Run("Not an author", func(t *testing.T) {
+		comment := models.NewComment("old", mockUser, post.ID)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+
+		_, err := service.EditComment(context.Background(), "other", post.ID, comment.ID, "new")
+		assert.EqualError(t, err, "only author can edit comment")
+	})
+
+	t.Run("Changed meanwhile", func(t *testing.T) {
+		comment := models.NewComment("old", mockUser, post.ID)
+
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+		mockCommentRepo.EXPECT().EditComment(gomock.Any(), gomock.Any(), "old", nil).Return(repository.ErrCommentChanged)
+
+		_, err := service.EditComment(context.Background(), mockUser.ID, post.ID, comment.ID, "new")
+		assert.EqualError(t, err, "comment was changed, try again")
+	})
+
+	t.Run("Empty body", func(t *testing.T) {
+		_, err := service.EditComment(context.Background(), mockUser.ID, post.ID, "comment", "")
+		assert.ErrorIs(t, err, ErrCommentCantBeNull)
+	})
+}
+
+func TestGetCommentRevisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepository(ctrl)
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		roleRepo:    mockRoleRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	edited := time.Now()
+	comment := models.NewComment("new", mockUser, post.ID)
+	comment.Edited = &edited
+	comment.Revisions = []*models.CommentRevision{{Body: "old", CreatedAt: comment.CreatedAt}}
+
+	testCases := []struct {
+		name       string
+		userID     string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name:      "Author",
+			userID:    mockUser.ID,
+			mockSetup: func() {},
+		},
+		{
+			name:   "Moderator of category",
+			userID: "moderator",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), "moderator").Return([]*models.RoleAssignment{
+					models.NewRoleAssignment("moderator", models.RoleModerator, "music", ""),
+				}, nil)
+			},
+		},
+		{
+			name:   "Other user",
+			userID: "other",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), "other").Return(nil, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+			mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+			tc.mockSetup()
+
+			revisions, err := service.GetCommentRevisions(context.Background(), tc.userID, post.ID, comment.ID)
+			if tc.wantErrMsg != "" {
+				assert.EqualError(t, err, tc.wantErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []*models.CommentRevision{
+				{Body: "old", CreatedAt: comment.CreatedAt},
+				{Body: "new", CreatedAt: edited},
+			}, revisions)
+		})
+	}
+}
+
+func TestCommentTree(t *testing.T) {
+	now := time.Now()
+	comment := func(text string, parent *models.Comment, age time.Duration) *models.Comment {
+		c := models.NewComment(text, mockUser, "post")
+		if parent != nil {
+			c.SetParent(parent)
+		}
+		c.CreatedAt = now.Add(-age)
+		return c
+	}
+
+	first := comment("first", nil, 3*time.Hour)
+	second := comment("second", nil, 2*time.Hour)
+	reply := comment("reply", first, time.Hour)
+	deep := comment("deep", reply, time.Minute)
+	deletedTop := comment("deleted", nil, 4*time.Hour)
+	orphan := comment("orphan", deletedTop, time.Minute)
+	deleted := comment("deleted", second, time.Hour)
+	deepOrphan := comment("deep orphan", deleted, time.Minute)
+
+	// sorted by path, purged comments are missing
+	tree := commentTree([]*models.Comment{second, deepOrphan, first, reply, deep, orphan}, "", 1)
+
+	// reply of purged top-level comment becomes top-level one
+	require.Equal(t, []*models.Comment{first, second, orphan}, tree)
+	assert.Equal(t, []*models.Comment{reply}, first.Replies)
+	assert.Empty(t, first.MoreReplies)
+	// too deep reply is left for cursor
+	assert.Empty(t, reply.Replies)
+	assert.Equal(t, reply.ID, reply.MoreReplies)
+	// the same for reply of purged comment, it goes to its grandparent
+	assert.Empty(t, second.Replies)
+	assert.Equal(t, second.ID, second.MoreReplies)
+}
+
+func TestCommentTreeTombstones(t *testing.T) {
+	deletedAt := time.Now()
+	comment := func(text string, parent *models.Comment, deleted bool) *models.Comment {
+		c := models.NewComment(text, mockUser, "post")
+		if parent != nil {
+			c.SetParent(parent)
+		}
+		if deleted {
+			c.DeletedAt = &deletedAt
+		}
+		return c
+	}
+
+	withReplies := comment("deleted with replies", nil, true)
+	reply := comment("reply", withReplies, false)
+	alone := comment("deleted alone", nil, true)
+	kept := comment("kept", nil, false)
+	deletedReply := comment("deleted reply", kept, true)
+	deletedLeaf := comment("deleted leaf", deletedReply, true)
+
+	tree := commentTree([]*models.Comment{withReplies, reply, alone, kept, deletedReply, deletedLeaf}, "", DefaultCommentMaxDepth)
+
+	require.Equal(t, []*models.Comment{withReplies, kept}, tree)
+	assert.True(t, withReplies.Deleted)
+	assert.Equal(t, models.DeletedBody, withReplies.Body)
+	assert.Equal(t, models.DeletedUsername, withReplies.Author.Username)
+	assert.Equal(t, []*models.Comment{reply}, withReplies.Replies)
+	// deleted branch without live comments is dropped as a whole
+	assert.Empty(t, kept.Replies)
+	assert.False(t, kept.Deleted)
+}
+
+func TestRemoveComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		roleRepo:    mockRoleRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	commenter := models.NewUser("commenter", "qwerty123")
+	comment := models.NewComment("mock comment", commenter, mockSinglePost.ID)
+
+	testCases := []struct {
+		name       string
+		userID     string
+		commentID  string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name:      "Success comment author",
+			userID:    commenter.ID,
+			commentID: comment.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockCommentRepo.EXPECT().SoftDeleteComment(gomock.Any(), comment.ID, gomock.Any(), gomock.Any()).Return(nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+				mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), gomock.Any(), []string{mockSinglePost.ID}).Return(map[string]int8{}, nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name:      "Post author isnt comment author",
+			userID:    mockUser.ID,
+			commentID: comment.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), mockUser.ID).Return(nil, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+		{
+			name:      "Success moderator",
+			userID:    mockUser.ID,
+			commentID: comment.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), mockUser.ID).Return([]*models.RoleAssignment{
+					models.NewRoleAssignment(mockUser.ID, models.RoleModerator, mockSinglePost.Category, ""),
+				}, nil)
+				mockCommentRepo.EXPECT().SoftDeleteComment(gomock.Any(), comment.ID, gomock.Any(), gomock.Any()).Return(nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), mockSinglePost.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+				mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), gomock.Any(), []string{mockSinglePost.ID}).Return(map[string]int8{}, nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name:      "Comment from other post",
+			userID:    commenter.ID,
+			commentID: comment.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(
+					models.NewComment("mock comment", commenter, "otherpost"), nil)
+			},
+			wantErrMsg: "comment not found",
+		},
+		{
+			name:      "Roles repo error",
+			userID:    mockUser.ID,
+			commentID: comment.ID,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), mockSinglePost.ID).Return(mockSinglePost, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), mockUser.ID).Return(nil, ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			_, err := service.RemoveComment(context.Background(), tc.userID, mockSinglePost.ID, tc.commentID)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+
+	service := &Service{
+		postRepo:         mockPostRepo,
+		commentRepo:      mockCommentRepo,
+		roleRepo:         mockRoleRepo,
+		voteRepo:         mockVoteRepo,
+		contentRetention: time.Hour,
+		logger:           zap.NewNop().Sugar(),
+	}
+
+	deletedPost := func(deletedBy string) *models.Post {
+		post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+		deletedAt := time.Now().Add(-time.Minute)
+		post.DeletedAt = &deletedAt
+		post.DeletedBy = deletedBy
+		return post
+	}
+
+	testCases := []struct {
+		name       string
+		userID     string
+		post       *models.Post
+		mockSetup  func(post *models.Post)
+		wantErrMsg string
+	}{
+		{
+			name:   "Author",
+			userID: mockUser.ID,
+			post:   deletedPost(mockUser.ID),
+			mockSetup: func(post *models.Post) {
+				mockPostRepo.EXPECT().RestorePost(gomock.Any(), post.ID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, deletedAfter time.Time) error {
+					assert.WithinDuration(t, time.Now().Add(-time.Hour), deletedAfter, time.Second)
+					return nil
+				})
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{}, nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+			},
+		},
+		{
+			name:   "Author after moderator",
+			userID: mockUser.ID,
+			post:   deletedPost("moderator"),
+			mockSetup: func(*models.Post) {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), mockUser.ID).Return(nil, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+		{
+			name:   "Moderator",
+			userID: "moderator",
+			post:   deletedPost(mockUser.ID),
+			mockSetup: func(post *models.Post) {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), "moderator").Return([]*models.RoleAssignment{
+					models.NewRoleAssignment("moderator", models.RoleModerator, "music", ""),
+				}, nil)
+				mockPostRepo.EXPECT().RestorePost(gomock.Any(), post.ID, gomock.Any()).Return(nil)
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), "moderator", []string{post.ID}).Return(map[string]int8{}, nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+			},
+		},
+		{
+			name:   "Retention passed",
+			userID: mockUser.ID,
+			post:   deletedPost(mockUser.ID),
+			mockSetup: func(post *models.Post) {
+				mockPostRepo.EXPECT().RestorePost(gomock.Any(), post.ID, gomock.Any()).Return(repository.ErrPostDontExists)
+			},
+			wantErrMsg: "restore window expired",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockPostRepo.EXPECT().GetDeletedPostByID(gomock.Any(), tc.post.ID).Return(tc.post, nil)
+			tc.mockSetup(tc.post)
+
+			_, err := service.RestorePost(context.Background(), tc.userID, tc.post.ID)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+
+	t.Run("Not deleted", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetDeletedPostByID(gomock.Any(), "post").Return(nil, nil)
+
+		_, err := service.RestorePost(context.Background(), mockUser.ID, "post")
+		assert.EqualError(t, err, "deleted post not found")
+	})
+}
+
+func TestRestoreComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	comment := models.NewComment("comment", mockUser, post.ID)
+	deletedAt := time.Now()
+	comment.DeletedAt = &deletedAt
+	comment.DeletedBy = mockUser.ID
+
+	t.Run("Success", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+		mockCommentRepo.EXPECT().RestoreComment(gomock.Any(), comment.ID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, deletedAfter time.Time) error {
+			// default retention
+			assert.WithinDuration(t, time.Now().Add(-DefaultContentRetention), deletedAfter, time.Second)
+			return nil
+		})
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), mockUser.ID, []string{post.ID}).Return(map[string]int8{}, nil)
+		mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+
+		_, err := service.RestoreComment(context.Background(), mockUser.ID, post.ID, comment.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Comment isnt deleted", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+		mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), "live").Return(models.NewComment("live", mockUser, post.ID), nil)
+
+		_, err := service.RestoreComment(context.Background(), mockUser.ID, post.ID, "live")
+		assert.EqualError(t, err, "deleted comment not found")
+	})
+}
+
+func TestPurgeDeletedContent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockRevisionRepo := mocks.NewMockPostRevisionRepository(ctrl)
+
+	service := &Service{
+		postRepo:         mockPostRepo,
+		commentRepo:      mockCommentRepo,
+		voteRepo:         mockVoteRepo,
+		revisionRepo:     mockRevisionRepo,
+		contentRetention: time.Hour,
+		logger:           zap.NewNop().Sugar(),
+	}
+
+	comment := models.NewComment("comment", mockUser, "post2")
+
+	t.Run("Success", func(t *testing.T) {
+		gomock.InOrder(
+			mockPostRepo.EXPECT().GetPostIDsDeletedBefore(gomock.Any(), gomock.Any(), int64(purgeBatchSize)).Return([]string{"post1"}, nil),
+			mockCommentRepo.EXPECT().DeleteCommentsByPostID(gomock.Any(), "post1").Return(int64(2), nil),
+			mockVoteRepo.EXPECT().DeleteCommentVotesByPostID(gomock.Any(), "post1").Return(int64(1), nil),
+			mockVoteRepo.EXPECT().DeleteVotesByPostID(gomock.Any(), "post1").Return(int64(3), nil),
+			mockRevisionRepo.EXPECT().DeleteRevisionsByPostID(gomock.Any(), "post1").Return(int64(1), nil),
+			mockPostRepo.EXPECT().DeletePost(gomock.Any(), "post1").Return(nil),
+			mockPostRepo.EXPECT().GetPostIDsDeletedBefore(gomock.Any(), gomock.Any(), int64(purgeBatchSize)).Return(nil, nil),
+			mockCommentRepo.EXPECT().GetCommentsDeletedBefore(gomock.Any(), gomock.Any(), int64(purgeBatchSize)).Return([]*models.Comment{comment}, nil),
+			mockVoteRepo.EXPECT().DeleteCommentVotesByCommentIDs(gomock.Any(), []string{comment.ID}).Return(int64(1), nil),
+			mockCommentRepo.EXPECT().DeleteComment(gomock.Any(), comment.ID).Return(nil),
+			mockCommentRepo.EXPECT().GetCommentsDeletedBefore(gomock.Any(), gomock.Any(), int64(purgeBatchSize)).Return(nil, nil),
+		)
+
+		assert.NoError(t, service.PurgeDeletedContent(context.Background()))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostIDsDeletedBefore(gomock.Any(), gomock.Any(), int64(purgeBatchSize)).Return([]string{"post1"}, nil)
+		mockCommentRepo.EXPECT().DeleteCommentsByPostID(gomock.Any(), "post1").Return(int64(0), ErrBasic)
+
+		err := service.PurgeDeletedContent(context.Background())
+		assert.ErrorIs(t, err, ErrBasic)
+	})
+}
+
+func TestGrantRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockRoleRepo := mocks.NewMockRoleRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo: mockUserRepo,
+		roleRepo: mockRoleRepo,
+		logger:   mockLogger,
+	}
+
+	adminID := "admin"
+	adminRoles := []*models.RoleAssignment{models.NewRoleAssignment(adminID, models.RoleAdmin, "", "")}
+
+	testCases := []struct {
+		name       string
+		role       string
+		category   string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name:     "Success",
+			role:     models.RoleModerator,
+			category: "music",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return(adminRoles, nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockRoleRepo.EXPECT().CreateRole(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name:     "Not an admin",
+			role:     models.RoleModerator,
+			category: "music",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return([]*models.RoleAssignment{
+					models.NewRoleAssignment(adminID, models.RoleModerator, "music", ""),
+				}, nil)
+			},
+			wantErrMsg: "forbidden",
+		},
+		{
+			name:     "Moderator without category",
+			role:     models.RoleModerator,
+			category: "",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return(adminRoles, nil)
+			},
+			wantErrMsg: "invalid role",
+		},
+		{
+			name:     "Already assigned",
+			role:     models.RoleAdmin,
+			category: "",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return(adminRoles, nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+				mockRoleRepo.EXPECT().CreateRole(gomock.Any(), gomock.Any()).Return(repository.ErrRoleAlreadyAssigned)
+			},
+			wantErrMsg: "role already assigned",
+		},
+		{
+			name:     "User not found",
+			role:     models.RoleAdmin,
+			category: "",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return(adminRoles, nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErrMsg: "user not found",
+		},
+		{
+			name:     "User lookup failed",
+			role:     models.RoleAdmin,
+			category: "",
+			mockSetup: func() {
+				mockRoleRepo.EXPECT().GetRolesByUserID(gomock.Any(), adminID).Return(adminRoles, nil)
+				mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(nil, ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.GrantRole(context.Background(), adminID, mockUser.Username, tc.role, tc.category)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, mockUser.ID, res.UserID)
+				assert.Equal(t, adminID, res.GrantedBy)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestThrottleLockDuration(t *testing.T) {
+	testCases := []struct {
+		name     string
+		attempts int64
+		expRes   time.Duration
+	}{
+		{name: "Free attempts", attempts: loginUserThrottle.free - 1, expRes: 0},
+		{name: "First lock", attempts: loginUserThrottle.free, expRes: lockBaseDuration},
+		{name: "Backoff", attempts: loginUserThrottle.free + 2, expRes: 4 * lockBaseDuration},
+		{name: "Max lock", attempts: loginUserThrottle.free + 100, expRes: lockMaxDuration},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expRes, loginUserThrottle.lockDuration(tc.attempts))
+		})
+	}
+}
+
+func TestCheckUserSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		sessionRepo: mockSessionRepo,
+		logger:      mockLogger,
+	}
+
+	freshSession := models.NewSessionInfo(mockUser.ID, hashToken("token"), nil)
+	staleSession := models.NewSessionInfo(mockUser.ID, hashToken("token"), nil)
+	staleSession.LastSeen = time.Now().Add(-time.Hour)
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		expRes     interface{}
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetSessionByTokenHash(gomock.Any(), mockUser.ID, hashToken("token")).Return(freshSession, nil)
+			},
+			expRes:     freshSession,
+			wantErrMsg: "",
+		},
+		{
+			name: "Success updates last seen",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetSessionByTokenHash(gomock.Any(), mockUser.ID, hashToken("token")).Return(staleSession, nil)
+				mockSessionRepo.EXPECT().UpdateSessionLastSeen(gomock.Any(), staleSession).Return(nil)
+			},
+			expRes:     staleSession,
+			wantErrMsg: "",
+		},
+		{
+			name: "Unknown session",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetSessionByTokenHash(gomock.Any(), mockUser.ID, hashToken("token")).Return(nil, repository.ErrSessionDontExists)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid token",
+		},
+		{
+			name: "Repo error",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetSessionByTokenHash(gomock.Any(), mockUser.ID, hashToken("token")).Return(nil, ErrBasic)
+			},
+			expRes:     nil,
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.CheckUserSession(context.Background(), mockUser.ID, "token")
+			if tc.expRes == nil {
+				assert.Nil(t, res)
+			} else {
+				assert.Equal(t, tc.expRes, res)
+			}
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestGetUserSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+
+	service := &Service{
+		sessionRepo: mockSessionRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	older := models.NewSessionInfo(mockUser.ID, "hash1", nil)
+	older.LastSeen = time.Now().Add(-time.Hour)
+	newer := models.NewSessionInfo(mockUser.ID, "hash2", nil)
+
+	mockSessionRepo.EXPECT().GetSessionsByUserID(gomock.Any(), mockUser.ID).Return([]*models.SessionInfo{older, newer}, nil)
+
+	res, err := service.GetUserSessions(context.Background(), mockUser.ID, older.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.SessionInfo{newer, older}, res)
+	assert.True(t, older.Current)
+	assert.False(t, newer.Current)
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+
+	service := &Service{
+		sessionRepo: mockSessionRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().DeleteSession(gomock.Any(), mockUser.ID, "sessionid").Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Not found",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().DeleteSession(gomock.Any(), mockUser.ID, "sessionid").Return(repository.ErrSessionDontExists)
+			},
+			wantErrMsg: "session not found",
+		},
+		{
+			name: "Repo error",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().DeleteSession(gomock.Any(), mockUser.ID, "sessionid").Return(ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.RevokeSession(context.Background(), mockUser.ID, "sessionid")
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+
+	service := &Service{
+		userRepo:    mockUserRepo,
+		sessionRepo: mockSessionRepo,
+		tokenMaker:  mockTokenMaker,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	refreshHash := hashToken("refresh")
+	newSessionInfo := func() *models.SessionInfo {
+		info := models.NewSessionInfo(mockUser.ID, hashToken("token"), nil)
+		info.RefreshTokenHash = refreshHash
+		return info
+	}
+	info := newSessionInfo()
+	stored := models.NewRefreshToken(refreshHash, info)
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		expToken   string
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(stored, nil)
+				mockSessionRepo.EXPECT().MarkRefreshTokenUsed(gomock.Any(), refreshHash, gomock.Any()).Return(true, nil)
+				mockSessionRepo.EXPECT().GetSession(gomock.Any(), mockUser.ID, info.ID).Return(newSessionInfo(), nil)
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+				mockTokenMaker.EXPECT().CreateToken(mockUser).Return("newtoken", nil)
+				mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockSessionRepo.EXPECT().UpdateSession(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, updated *models.SessionInfo, _ time.Duration) error {
+						assert.Equal(t, hashToken("newtoken"), updated.TokenHash)
+						assert.NotEqual(t, refreshHash, updated.RefreshTokenHash)
+						return nil
+					})
+			},
+			expToken:   "newtoken",
+			wantErrMsg: "",
+		},
+		{
+			name: "Unknown token",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(nil, repository.ErrRefreshTokenDontExists)
+			},
+			wantErrMsg: "invalid refresh token",
+		},
+		{
+			name: "Reuse revokes session",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(stored, nil)
+				mockSessionRepo.EXPECT().MarkRefreshTokenUsed(gomock.Any(), refreshHash, gomock.Any()).Return(false, nil)
+				mockSessionRepo.EXPECT().DeleteSession(gomock.Any(), mockUser.ID, info.ID).Return(nil)
+			},
+			wantErrMsg: "invalid refresh token",
+		},
+		{
+			name: "Outdated token revokes session",
+			mockSetup: func() {
+				rotated := newSessionInfo()
+				rotated.RefreshTokenHash = hashToken("newer")
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(stored, nil)
+				mockSessionRepo.EXPECT().MarkRefreshTokenUsed(gomock.Any(), refreshHash, gomock.Any()).Return(true, nil)
+				mockSessionRepo.EXPECT().GetSession(gomock.Any(), mockUser.ID, info.ID).Return(rotated, nil)
+				mockSessionRepo.EXPECT().DeleteSession(gomock.Any(), mockUser.ID, info.ID).Return(nil)
+			},
+			wantErrMsg: "invalid refresh token",
+		},
+		{
+			name: "Revoked session",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(stored, nil)
+				mockSessionRepo.EXPECT().MarkRefreshTokenUsed(gomock.Any(), refreshHash, gomock.Any()).Return(true, nil)
+				mockSessionRepo.EXPECT().GetSession(gomock.Any(), mockUser.ID, info.ID).Return(nil, repository.ErrSessionDontExists)
+			},
+			wantErrMsg: "invalid refresh token",
+		},
+		{
+			name: "Repo error",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetRefreshToken(gomock.Any(), refreshHash).Return(nil, ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.RefreshSession(context.Background(), "refresh")
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expToken, res.Token)
+				assert.NotEmpty(t, res.RefreshToken)
+			} else {
+				assert.Nil(t, res)
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestCreateAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAccessTokenRepo := mocks.NewMockAccessTokenRepository(ctrl)
+
+	service := &Service{
+		accessTokenRepo: mockAccessTokenRepo,
+		logger:          zap.NewNop().Sugar(),
+	}
+
+	testCases := []struct {
+		name       string
+		tokenName  string
+		scopes     []string
+		expiresIn  time.Duration
+		mockSetup  func()
+		expScopes  []string
+		wantErrMsg string
+	}{
+		{
+			name:      "Success",
+			tokenName: "ci bot",
+			scopes:    []string{models.ScopePostsWrite, models.ScopeRead, models.ScopePostsWrite},
+			expiresIn: 0,
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().CreateAccessToken(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expScopes:  []string{models.ScopePostsWrite, models.ScopeRead},
+			wantErrMsg: "",
+		},
+		{
+			name:       "Empty name",
+			tokenName:  "",
+			scopes:     []string{models.ScopeRead},
+			mockSetup:  func() {},
+			wantErrMsg: "token name is required",
+		},
+		{
+			name:       "No scopes",
+			tokenName:  "ci bot",
+			scopes:     nil,
+			mockSetup:  func() {},
+			wantErrMsg: "at least one scope is required",
+		},
+		{
+			name:       "Unknown scope",
+			tokenName:  "ci bot",
+			scopes:     []string{"admin"},
+			mockSetup:  func() {},
+			wantErrMsg: "invalid scope: admin",
+		},
+		{
+			name:      "Repo error",
+			tokenName: "ci bot",
+			scopes:    []string{models.ScopeRead},
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().CreateAccessToken(gomock.Any(), gomock.Any()).Return(ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.CreateAccessToken(context.Background(), mockUser.ID, tc.tokenName, tc.scopes, tc.expiresIn)
+			if tc.wantErrMsg != "" {
+				assert.EqualError(t, err, tc.wantErrMsg)
+				assert.Nil(t, res)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(res.Token, models.AccessTokenPrefix))
+			assert.Equal(t, hashToken(res.Token), res.TokenHash)
+			assert.Equal(t, mockUser.ID, res.UserID)
+			assert.Equal(t, tc.expScopes, []string(res.Scopes))
+			assert.Nil(t, res.ExpiresAt)
+		})
+	}
+}
+
+func TestCheckAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAccessTokenRepo := mocks.NewMockAccessTokenRepository(ctrl)
+
+	service := &Service{
+		accessTokenRepo: mockAccessTokenRepo,
+		logger:          zap.NewNop().Sugar(),
+	}
+
+	plainToken := models.AccessTokenPrefix + "token"
+	recentlyUsed := time.Now()
+	expiredAt := time.Now().Add(-time.Hour)
+
+	usedToken := models.NewAccessToken(mockUser.ID, "bot", hashToken(plainToken), []string{models.ScopeRead}, nil)
+	usedToken.LastUsedAt = &recentlyUsed
+	newToken := models.NewAccessToken(mockUser.ID, "bot", hashToken(plainToken), []string{models.ScopeRead}, nil)
+	expiredToken := models.NewAccessToken(mockUser.ID, "bot", hashToken(plainToken), []string{models.ScopeRead}, &expiredAt)
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		expRes     *models.AccessToken
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().GetAccessTokenByHash(gomock.Any(), hashToken(plainToken)).Return(usedToken, nil)
+			},
+			expRes:     usedToken,
+			wantErrMsg: "",
+		},
+		{
+			name: "Success updates last used",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().GetAccessTokenByHash(gomock.Any(), hashToken(plainToken)).Return(newToken, nil)
+				mockAccessTokenRepo.EXPECT().UpdateAccessTokenLastUsed(gomock.Any(), newToken.ID, gomock.Any()).Return(nil)
+			},
+			expRes:     newToken,
+			wantErrMsg: "",
+		},
+		{
+			name: "Expired",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().GetAccessTokenByHash(gomock.Any(), hashToken(plainToken)).Return(expiredToken, nil)
+			},
+			expRes:     nil,
+			wantErrMsg: "token expired",
+		},
+		{
+			name: "Unknown token",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().GetAccessTokenByHash(gomock.Any(), hashToken(plainToken)).Return(nil, repository.ErrAccessTokenDontExists)
+			},
+			expRes:     nil,
+			wantErrMsg: "invalid token",
+		},
+		{
+			name: "Repo error",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().GetAccessTokenByHash(gomock.Any(), hashToken(plainToken)).Return(nil, ErrBasic)
+			},
+			expRes:     nil,
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.CheckAccessToken(context.Background(), plainToken)
+			assert.Equal(t, tc.expRes, res)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+				assert.NotNil(t, res.LastUsedAt)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAccessTokenRepo := mocks.NewMockAccessTokenRepository(ctrl)
+
+	service := &Service{
+		accessTokenRepo: mockAccessTokenRepo,
+		logger:          zap.NewNop().Sugar(),
+	}
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().DeleteAccessToken(gomock.Any(), mockUser.ID, "tokenid").Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Not found",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().DeleteAccessToken(gomock.Any(), mockUser.ID, "tokenid").Return(repository.ErrAccessTokenDontExists)
+			},
+			wantErrMsg: "token not found",
+		},
+		{
+			name: "Repo error",
+			mockSetup: func() {
+				mockAccessTokenRepo.EXPECT().DeleteAccessToken(gomock.Any(), mockUser.ID, "tokenid").Return(ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.RevokeAccessToken(context.Background(), mockUser.ID, "tokenid")
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+
+	service := &Service{
+		userRepo: mockUserRepo,
+		mfaRepo:  mockMFARepo,
+		logger:   zap.NewNop().Sugar(),
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var savedCodes []*models.RecoveryCode
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
+		mockMFARepo.EXPECT().SaveTOTP(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, config *models.TOTPConfig) error {
+			assert.False(t, config.Enabled)
+			return nil
+		})
+		mockMFARepo.EXPECT().ReplaceRecoveryCodes(gomock.Any(), mockUser.ID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, codes []*models.RecoveryCode) error {
+			savedCodes = codes
+			return nil
+		})
+
+		res, err := service.EnrollTOTP(context.Background(), mockUser.ID)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Secret)
+		assert.True(t, strings.HasPrefix(res.URI, "otpauth://totp/redditclone:testuser?"))
+		assert.Len(t, res.RecoveryCodes, recoveryCodesCount)
+
+		// only hashes are stored
+		for i, code := range res.RecoveryCodes {
+			assert.Equal(t, hashToken(code), savedCodes[i].CodeHash)
+		}
+	})
+
+	t.Run("Already enabled", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(&models.TOTPConfig{UserID: mockUser.ID, Enabled: true}, nil)
+
+		res, err := service.EnrollTOTP(context.Background(), mockUser.ID)
+		assert.Nil(t, res)
+		assert.EqualError(t, err, "2fa already enabled")
+	})
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+
+	service := &Service{
+		mfaRepo: mockMFARepo,
+		logger:  zap.NewNop().Sugar(),
+	}
+
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		code       string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			code: code,
+			mockSetup: func() {
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(models.NewTOTPConfig(mockUser.ID, secret), nil)
+				mockMFARepo.EXPECT().EnableTOTP(gomock.Any(), mockUser.ID, gomock.Any()).Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Wrong code",
+			code: "000000",
+			mockSetup: func() {
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(models.NewTOTPConfig(mockUser.ID, secret), nil)
+			},
+			wantErrMsg: "invalid code",
+		},
+		{
+			name: "Not enrolled",
+			code: code,
+			mockSetup: func() {
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(nil, repository.ErrTOTPDontExists)
+			},
+			wantErrMsg: "2fa enrollment not started",
+		},
+		{
+			name: "Already enabled",
+			code: code,
+			mockSetup: func() {
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(&models.TOTPConfig{UserID: mockUser.ID, Secret: secret, Enabled: true}, nil)
+			},
+			wantErrMsg: "2fa already enabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.ConfirmTOTP(context.Background(), mockUser.ID, tc.code)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockLoginAttemptRepo := mocks.NewMockLoginAttemptRepository(ctrl)
+
+	service := &Service{
+		userRepo:         mockUserRepo,
+		sessionRepo:      mockSessionRepo,
+		mfaRepo:          mockMFARepo,
+		loginAttemptRepo: mockLoginAttemptRepo,
+		tokenMaker:       mockTokenMaker,
+		logger:           zap.NewNop().Sugar(),
+	}
+
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+
+	mfaHash := hashToken("mfatoken")
+	challenge := models.NewMFAChallenge(mfaHash, mockUser.ID, nil)
+	config := &models.TOTPConfig{UserID: mockUser.ID, Secret: secret, Enabled: true}
+
+	getChallenge := func() {
+		mockSessionRepo.EXPECT().GetMFAChallenge(gomock.Any(), mfaHash).Return(challenge, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Duration(0), nil)
+	}
+	// wrong code is counted as failed login of the user
+	recordFailure := func() {
+		mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "user:"+mockUser.Username, loginUserThrottle.window).Return(int64(1), nil)
+	}
+	createSession := func() {
+		mockSessionRepo.EXPECT().DeleteMFAChallenge(gomock.Any(), mfaHash).Return(nil)
+		mockLoginAttemptRepo.EXPECT().Reset(gomock.Any(), "user:"+mockUser.Username).Return(nil)
+		mockTokenMaker.EXPECT().CreateToken(mockUser).Return("token", nil)
+		mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	}
+
+	testCases := []struct {
+		name       string
+		code       string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "Success with totp",
+			code: code,
+			mockSetup: func() {
+				getChallenge()
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(config, nil)
+				mockMFARepo.EXPECT().UseTOTPStep(gomock.Any(), mockUser.ID, gomock.Any()).Return(nil)
+				createSession()
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Success with recovery code",
+			code: "ABCDEFGHIJ",
+			mockSetup: func() {
+				getChallenge()
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(config, nil)
+				mockMFARepo.EXPECT().UseRecoveryCode(gomock.Any(), mockUser.ID, hashToken("abcde-fghij")).Return(nil)
+				createSession()
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Reused totp code",
+			code: code,
+			mockSetup: func() {
+				getChallenge()
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(config, nil)
+				mockMFARepo.EXPECT().UseTOTPStep(gomock.Any(), mockUser.ID, gomock.Any()).Return(repository.ErrTOTPCodeReused)
+				recordFailure()
+				mockSessionRepo.EXPECT().IncrMFAChallengeAttempts(gomock.Any(), mfaHash, mfaChallengeTTL).Return(int64(1), nil)
+			},
+			wantErrMsg: "invalid code",
+		},
+		{
+			name: "Used recovery code",
+			code: "abcde-fghij",
+			mockSetup: func() {
+				getChallenge()
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(config, nil)
+				mockMFARepo.EXPECT().UseRecoveryCode(gomock.Any(), mockUser.ID, hashToken("abcde-fghij")).Return(repository.ErrRecoveryCodeDontExists)
+				recordFailure()
+				mockSessionRepo.EXPECT().IncrMFAChallengeAttempts(gomock.Any(), mfaHash, mfaChallengeTTL).Return(int64(1), nil)
+			},
+			wantErrMsg: "invalid code",
+		},
+		{
+			name: "Too many attempts",
+			code: "abcde-fghij",
+			mockSetup: func() {
+				getChallenge()
+				mockMFARepo.EXPECT().GetTOTP(gomock.Any(), mockUser.ID).Return(config, nil)
+				mockMFARepo.EXPECT().UseRecoveryCode(gomock.Any(), mockUser.ID, gomock.Any()).Return(repository.ErrRecoveryCodeDontExists)
+				recordFailure()
+				mockSessionRepo.EXPECT().IncrMFAChallengeAttempts(gomock.Any(), mfaHash, mfaChallengeTTL).Return(int64(mfaMaxAttempts), nil)
+				mockSessionRepo.EXPECT().DeleteMFAChallenge(gomock.Any(), mfaHash).Return(nil)
+			},
+			wantErrMsg: "invalid code",
+		},
+		{
+			name: "Locked out by failed logins",
+			code: code,
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetMFAChallenge(gomock.Any(), mfaHash).Return(challenge, nil)
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "user:"+mockUser.Username).Return(time.Minute, nil)
+			},
+			wantErrMsg: "too many attempts",
+		},
+		{
+			name: "Unknown mfa token",
+			code: code,
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().GetMFAChallenge(gomock.Any(), mfaHash).Return(nil, repository.ErrMFAChallengeDontExists)
+			},
+			wantErrMsg: "invalid mfa token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.VerifyMFA(context.Background(), "mfatoken", tc.code)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "token", res.Token)
+				assert.NotEmpty(t, res.RefreshToken)
+			} else {
+				assert.Nil(t, res)
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestOAuthLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+
+	provider, err := oidc.NewOIDCProvider(context.Background(), oidc.Config{
+		Name:         "stub",
+		IssuerURL:    server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  "http://localhost:8080/api/oauth/stub/callback",
+	}, server.Client())
+	assert.NoError(t, err)
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+	mockIdentityRepo := mocks.NewMockIdentityRepository(ctrl)
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockHasher := mocks.NewMockHasher(ctrl)
+
+	service := &Service{
+		userRepo:       mockUserRepo,
+		sessionRepo:    mockSessionRepo,
+		mfaRepo:        mockMFARepo,
+		identityRepo:   mockIdentityRepo,
+		tokenMaker:     mockTokenMaker,
+		passwordHasher: mockHasher,
+		oauthProviders: map[string]oidc.Provider{"stub": provider},
+		logger:         zap.NewNop().Sugar(),
+	}
+
+	stubUser := oidctest.User{Subject: "subject-1", Email: "stub@example.com", PreferredUsername: "stub.user"}
+
+	// startLogin goes through redirect to stub provider and back
+	startLogin := func(t *testing.T, userID string) (code, state string) {
+		var saved *models.OAuthState
+		mockSessionRepo.EXPECT().CreateOAuthState(gomock.Any(), gomock.Any(), oauthStateTTL).DoAndReturn(func(_ context.Context, s *models.OAuthState, _ time.Duration) error {
+			saved = s
+			return nil
+		})
+
+		authURL, err := service.StartOAuthLogin(context.Background(), "stub", userID, nil)
+		assert.NoError(t, err)
+
+		code, state, err = server.Authorize(authURL, stubUser)
+		assert.NoError(t, err)
+		assert.Equal(t, hashToken(state), saved.StateHash)
+
+		mockSessionRepo.EXPECT().TakeOAuthState(gomock.Any(), hashToken(state)).Return(saved, nil)
+		return code, state
+	}
+
+	expectSession := func() {
+		mockMFARepo.EXPECT().GetTOTP(gomock.Any(), gomock.Any()).Return(nil, repository.ErrTOTPDontExists)
+		mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return("token", nil)
+		mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	}
+
+	t.Run("New user with taken username", func(t *testing.T) {
+		code, state := startLogin(t, "")
+
+		var created *models.User
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).Return(nil, repository.ErrIdentityDontExists)
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "stubuser").Return(mockUser, nil)
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), gomock.Any()).Return(nil, gorm.ErrRecordNotFound)
+		mockHasher.EXPECT().Hash(gomock.Any()).Return("hash", nil)
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), stubUser.Email).Return(nil, gorm.ErrRecordNotFound)
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, usr *models.User) error {
+			created = usr
+			return nil
+		})
+		mockIdentityRepo.EXPECT().CreateIdentity(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, identity *models.ExternalIdentity) error {
+			assert.Equal(t, created.ID, identity.UserID)
+			assert.Equal(t, stubUser.Email, identity.Email)
+			return nil
+		})
+		expectSession()
+
+		session, pending, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.NoError(t, err)
+		assert.Nil(t, pending)
+		assert.Equal(t, "token", session.Token)
+		assert.Regexp(t, `^stubuser_\d{4}$`, created.Username)
+		assert.Equal(t, "hash", created.Password)
+		assert.Equal(t, stubUser.Email, created.Email)
+		assert.True(t, created.EmailVerified)
+	})
+
+	t.Run("New user racing for username", func(t *testing.T) {
+		code, state := startLogin(t, "")
+
+		var created *models.User
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).Return(nil, repository.ErrIdentityDontExists)
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "stubuser").Return(nil, gorm.ErrRecordNotFound)
+		mockHasher.EXPECT().Hash(gomock.Any()).Return("hash", nil)
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), stubUser.Email).Return(nil, gorm.ErrRecordNotFound)
+		gomock.InOrder(
+			// concurrent registration took it after the check
+			mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(repository.ErrUserAlreadyExists),
+			mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, usr *models.User) error {
+				created = usr
+				return nil
+			}),
+		)
+		mockIdentityRepo.EXPECT().CreateIdentity(gomock.Any(), gomock.Any()).Return(nil)
+		expectSession()
+
+		_, _, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^stubuser_\d{4}$`, created.Username)
+		assert.Equal(t, validation.UsernameKey(created.Username), created.UsernameKey)
+	})
+
+	t.Run("New user keeps conflicting", func(t *testing.T) {
+		code, state := startLogin(t, "")
+
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).Return(nil, repository.ErrIdentityDontExists)
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "stubuser").Return(nil, gorm.ErrRecordNotFound)
+		mockHasher.EXPECT().Hash(gomock.Any()).Return("hash", nil)
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), stubUser.Email).Return(nil, gorm.ErrRecordNotFound)
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(repository.ErrUserAlreadyExists).Times(usernameAttempts)
+
+		_, _, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.EqualError(t, err, "user already exists")
+	})
+
+	t.Run("Linked user", func(t *testing.T) {
+		code, state := startLogin(t, "")
+
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).
+			Return(models.NewExternalIdentity(mockUser.ID, "stub", stubUser.Subject, ""), nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		expectSession()
+
+		session, _, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.NoError(t, err)
+		assert.Equal(t, "token", session.Token)
+	})
+
+	t.Run("Link to another user", func(t *testing.T) {
+		code, state := startLogin(t, mockUser.ID)
+
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).
+			Return(models.NewExternalIdentity("otheruser", "stub", stubUser.Subject, ""), nil)
+
+		session, pending, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.EqualError(t, err, "account already linked to another user")
+		assert.Nil(t, session)
+		assert.Nil(t, pending)
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		code, state := startLogin(t, mockUser.ID)
+
+		mockIdentityRepo.EXPECT().GetIdentity(gomock.Any(), "stub", stubUser.Subject).Return(nil, repository.ErrIdentityDontExists)
+		mockIdentityRepo.EXPECT().CreateIdentity(gomock.Any(), gomock.Any()).Return(nil)
+
+		session, pending, err := service.FinishOAuthLogin(context.Background(), "stub", state, code)
+		assert.NoError(t, err)
+		assert.Nil(t, session)
+		assert.Nil(t, pending)
+	})
+
+	t.Run("Unknown state", func(t *testing.T) {
+		mockSessionRepo.EXPECT().TakeOAuthState(gomock.Any(), hashToken("state")).Return(nil, repository.ErrOAuthStateDontExists)
+
+		_, _, err := service.FinishOAuthLogin(context.Background(), "stub", "state", "code")
+		assert.EqualError(t, err, "invalid state")
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		_, err := service.StartOAuthLogin(context.Background(), "unknown", "", nil)
+		assert.EqualError(t, err, "unknown provider")
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockLoginAttemptRepo := mocks.NewMockLoginAttemptRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	userWithEmail := models.NewUser("testuser", "qwerty123")
+	userWithEmail.Email = "test@example.com"
+	userWithEmail.EmailVerified = true
+	unverifiedUser := models.NewUser("unverified", "qwerty123")
+	unverifiedUser.Email = "unverified@example.com"
+	client := &models.ClientInfo{IP: "203.0.113.7"}
+
+	testCases := []struct {
+		name       string
+		email      string
+		mockSetup  func()
+		expSent    bool
+		wantErrMsg string
+	}{
+		{
+			name:  "Success",
+			email: userWithEmail.Email,
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Duration(0), nil)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "forgot:"+client.IP, forgotPasswordThrottle.window).Return(int64(1), nil)
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), userWithEmail.Email).Return(userWithEmail, nil)
+				mockSessionRepo.EXPECT().CreateEmailToken(gomock.Any(), gomock.Any(), passwordResetTTL).
+					DoAndReturn(func(_ context.Context, resetToken *models.EmailToken, _ time.Duration) error {
+						assert.Equal(t, userWithEmail.ID, resetToken.UserID)
+						assert.Equal(t, models.EmailTokenPasswordReset, resetToken.Purpose)
+						return nil
+					})
+			},
+			expSent:    true,
+			wantErrMsg: "",
+		},
+		{
+			name:  "Unknown email",
+			email: "unknown@example.com",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Duration(0), nil)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "forgot:"+client.IP, forgotPasswordThrottle.window).Return(int64(1), nil)
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+			},
+			expSent:    false,
+			wantErrMsg: "",
+		},
+		{
+			name:  "Unverified email",
+			email: unverifiedUser.Email,
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Duration(0), nil)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "forgot:"+client.IP, forgotPasswordThrottle.window).Return(int64(1), nil)
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), unverifiedUser.Email).Return(unverifiedUser, nil)
+			},
+			expSent:    false,
+			wantErrMsg: "",
+		},
+		{
+			name:  "Invalid email",
+			email: "not an email",
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Duration(0), nil)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "forgot:"+client.IP, forgotPasswordThrottle.window).Return(int64(1), nil)
+			},
+			expSent:    false,
+			wantErrMsg: "invalid email",
+		},
+		{
+			name:  "Too many requests",
+			email: userWithEmail.Email,
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Minute, nil)
+			},
+			expSent:    false,
+			wantErrMsg: "too many attempts",
+		},
+		{
+			name:  "Err session repo",
+			email: userWithEmail.Email,
+			mockSetup: func() {
+				mockLoginAttemptRepo.EXPECT().GetLockTTL(gomock.Any(), "forgot:"+client.IP).Return(time.Duration(0), nil)
+				mockLoginAttemptRepo.EXPECT().IncrAttempts(gomock.Any(), "forgot:"+client.IP, forgotPasswordThrottle.window).Return(int64(1), nil)
+				mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), userWithEmail.Email).Return(userWithEmail, nil)
+				mockSessionRepo.EXPECT().CreateEmailToken(gomock.Any(), gomock.Any(), passwordResetTTL).Return(ErrBasic)
+			},
+			expSent:    false,
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			outbox := mailer.NewMemoryOutbox()
+			service := &Service{
+				userRepo:         mockUserRepo,
+				sessionRepo:      mockSessionRepo,
+				loginAttemptRepo: mockLoginAttemptRepo,
+				mailer:           outbox,
+				appURL:           "http://localhost:8080",
+				logger:           mockLogger,
+			}
+
+			err := service.ForgotPassword(context.Background(), tc.email, client)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+
+			messages := outbox.Messages()
+			if !tc.expSent {
+				assert.Empty(t, messages)
+				return
+			}
+			if assert.Len(t, messages, 1) {
+				assert.Equal(t, userWithEmail.Email, messages[0].To)
+				assert.Contains(t, messages[0].Body, "http://localhost:8080/reset-password?token=")
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockHasher := mocks.NewMockHasher(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo:       mockUserRepo,
+		sessionRepo:    mockSessionRepo,
+		passwordHasher: mockHasher,
+		logger:         mockLogger,
+	}
+
+	stored := models.NewEmailToken(hashToken("resettoken"), models.EmailTokenPasswordReset, mockUser.ID, "test@example.com")
+	owner := *mockUser
+	owner.Email = "test@example.com"
+	owner.EmailVerified = true
+	changedEmail := owner
+	changedEmail.Email = "new@example.com"
+
+	testCases := []struct {
+		name       string
+		password   string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name:     "Success",
+			password: "newpassword",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenPasswordReset, hashToken("resettoken")).Return(stored, nil)
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(&owner, nil)
+				mockHasher.EXPECT().Hash("newpassword").Return("hashed", nil)
+				mockUserRepo.EXPECT().UpdateUserPassword(gomock.Any(), mockUser.ID, "hashed").Return(nil)
+				mockSessionRepo.EXPECT().DeleteSessionsByUserID(gomock.Any(), mockUser.ID).Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name:     "Used or expired token",
+			password: "newpassword",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenPasswordReset, hashToken("resettoken")).Return(nil, repository.ErrEmailTokenDontExists)
+			},
+			wantErrMsg: "invalid or expired token",
+		},
+		{
+			name:     "Email changed after token was sent",
+			password: "newpassword",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenPasswordReset, hashToken("resettoken")).Return(stored, nil)
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(&changedEmail, nil)
+			},
+			wantErrMsg: "invalid or expired token",
+		},
+		{
+			name:       "Empty password",
+			password:   "",
+			mockSetup:  func() {},
+			wantErrMsg: "validation failed",
+		},
+		{
+			name:       "Common password",
+			password:   "password123",
+			mockSetup:  func() {},
+			wantErrMsg: "validation failed",
+		},
+		{
+			name:     "Err revoke sessions",
+			password: "newpassword",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenPasswordReset, hashToken("resettoken")).Return(stored, nil)
+				mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(&owner, nil)
+				mockHasher.EXPECT().Hash("newpassword").Return("hashed", nil)
+				mockUserRepo.EXPECT().UpdateUserPassword(gomock.Any(), mockUser.ID, "hashed").Return(nil)
+				mockSessionRepo.EXPECT().DeleteSessionsByUserID(gomock.Any(), mockUser.ID).Return(ErrBasic)
+			},
+			wantErrMsg: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.ResetPassword(context.Background(), "resettoken", tc.password)
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo:    mockUserRepo,
+		sessionRepo: mockSessionRepo,
+		logger:      mockLogger,
+	}
+
+	stored := models.NewEmailToken(hashToken("verifytoken"), models.EmailTokenVerify, mockUser.ID, "test@example.com")
+
+	testCases := []struct {
+		name       string
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "Success",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenVerify, hashToken("verifytoken")).Return(stored, nil)
+				mockUserRepo.EXPECT().VerifyUserEmail(gomock.Any(), mockUser.ID, "test@example.com").Return(nil)
+			},
+			wantErrMsg: "",
+		},
+		{
+			name: "Used or expired token",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenVerify, hashToken("verifytoken")).Return(nil, repository.ErrEmailTokenDontExists)
+			},
+			wantErrMsg: "invalid or expired token",
+		},
+		{
+			name: "Email changed",
+			mockSetup: func() {
+				mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenVerify, hashToken("verifytoken")).Return(stored, nil)
+				mockUserRepo.EXPECT().VerifyUserEmail(gomock.Any(), mockUser.ID, "test@example.com").Return(repository.ErrUserDontExists)
+			},
+			wantErrMsg: "invalid or expired token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			err := service.VerifyEmail(context.Background(), "verifytoken")
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestEmailLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockMFARepo := mocks.NewMockMFARepository(ctrl)
+	mockTokenMaker := mocks.NewMockTokenMaker(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	outbox := mailer.NewMemoryOutbox()
+	service := &Service{
+		userRepo:    mockUserRepo,
+		sessionRepo: mockSessionRepo,
+		mfaRepo:     mockMFARepo,
+		tokenMaker:  mockTokenMaker,
+		mailer:      outbox,
+		appURL:      "http://localhost:8080",
+		logger:      mockLogger,
+	}
+
+	verifiedUser := models.NewUser("verified", "qwerty123")
+	verifiedUser.Email = "verified@example.com"
+	verifiedUser.EmailVerified = true
+	unverifiedUser := models.NewUser("unverified", "qwerty123")
+	unverifiedUser.Email = "unverified@example.com"
+
+	t.Run("Start", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), verifiedUser.Email).Return(verifiedUser, nil)
+		mockSessionRepo.EXPECT().CreateEmailToken(gomock.Any(), gomock.Any(), emailLoginTTL).
+			DoAndReturn(func(_ context.Context, loginToken *models.EmailToken, _ time.Duration) error {
+				assert.Equal(t, models.EmailTokenLogin, loginToken.Purpose)
+				assert.Equal(t, verifiedUser.ID, loginToken.UserID)
+				return nil
+			})
+
+		err := service.StartEmailLogin(context.Background(), "Verified@Example.com", nil)
+		assert.NoError(t, err)
+		if messages := outbox.Messages(); assert.Len(t, messages, 1) {
+			assert.Equal(t, verifiedUser.Email, messages[0].To)
+			assert.Contains(t, messages[0].Body, "http://localhost:8080/login/email?token=")
+		}
+	})
+
+	t.Run("Start with unverified email", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), unverifiedUser.Email).Return(unverifiedUser, nil)
+
+		err := service.StartEmailLogin(context.Background(), unverifiedUser.Email, nil)
+		assert.NoError(t, err)
+		assert.Len(t, outbox.Messages(), 1)
+	})
+
+	t.Run("Start with unknown email", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByEmail(gomock.Any(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+		err := service.StartEmailLogin(context.Background(), "unknown@example.com", nil)
+		assert.NoError(t, err)
+		assert.Len(t, outbox.Messages(), 1)
+	})
+
+	t.Run("Finish", func(t *testing.T) {
+		stored := models.NewEmailToken(hashToken("logintoken"), models.EmailTokenLogin, verifiedUser.ID, verifiedUser.Email)
+		mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenLogin, hashToken("logintoken")).Return(stored, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), verifiedUser.ID).Return(verifiedUser, nil)
+		mockMFARepo.EXPECT().GetTOTP(gomock.Any(), verifiedUser.ID).Return(nil, repository.ErrTOTPDontExists)
+		mockTokenMaker.EXPECT().CreateToken(gomock.Any()).Return("token", nil)
+		mockSessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		mockSessionRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		session, pending, err := service.FinishEmailLogin(context.Background(), "logintoken", nil)
+		assert.NoError(t, err)
+		assert.Nil(t, pending)
+		assert.Equal(t, "token", session.Token)
+	})
+
+	t.Run("Finish after email change", func(t *testing.T) {
+		stored := models.NewEmailToken(hashToken("logintoken"), models.EmailTokenLogin, verifiedUser.ID, "old@example.com")
+		mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenLogin, hashToken("logintoken")).Return(stored, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), verifiedUser.ID).Return(verifiedUser, nil)
+
+		session, pending, err := service.FinishEmailLogin(context.Background(), "logintoken", nil)
+		assert.EqualError(t, err, "invalid or expired token")
+		assert.Nil(t, session)
+		assert.Nil(t, pending)
+	})
+
+	t.Run("Finish with used token", func(t *testing.T) {
+		mockSessionRepo.EXPECT().TakeEmailToken(gomock.Any(), models.EmailTokenLogin, hashToken("logintoken")).Return(nil, repository.ErrEmailTokenDontExists)
+
+		_, _, err := service.FinishEmailLogin(context.Background(), "logintoken", nil)
+		assert.EqualError(t, err, "invalid or expired token")
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockDeletionJobRepo := mocks.NewMockDeletionJobRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo:        mockUserRepo,
+		postRepo:        mockPostRepo,
+		commentRepo:     mockCommentRepo,
+		sessionRepo:     mockSessionRepo,
+		deletionJobRepo: mockDeletionJobRepo,
+		logger:          mockLogger,
+	}
+
+	t.Run("Anonymize", func(t *testing.T) {
+		var saved []models.DeletionJob
+		mockDeletionJobRepo.EXPECT().CreateDeletionJob(gomock.Any(), gomock.Any()).Return(nil)
+		// once right away and once more when job starts
+		mockUserRepo.EXPECT().DeleteUser(gomock.Any(), mockUser.ID).Return(nil).Times(2)
+		mockSessionRepo.EXPECT().DeleteSessionsByUserID(gomock.Any(), mockUser.ID).Return(nil).Times(2)
+		gomock.InOrder(
+			mockCommentRepo.EXPECT().ReplaceCommentsAuthor(gomock.Any(), mockUser.ID, models.DeletedAuthor(), int64(deletionBatchSize)).Return(int64(deletionBatchSize), nil),
+			mockCommentRepo.EXPECT().ReplaceCommentsAuthor(gomock.Any(), mockUser.ID, models.DeletedAuthor(), int64(deletionBatchSize)).Return(int64(3), nil),
+			mockCommentRepo.EXPECT().ReplaceCommentsAuthor(gomock.Any(), mockUser.ID, models.DeletedAuthor(), int64(deletionBatchSize)).Return(int64(0), nil),
+			mockPostRepo.EXPECT().ReplacePostsAuthor(gomock.Any(), mockUser.ID, models.DeletedAuthor(), int64(deletionBatchSize)).Return(int64(2), nil),
+			mockPostRepo.EXPECT().ReplacePostsAuthor(gomock.Any(), mockUser.ID, models.DeletedAuthor(), int64(deletionBatchSize)).Return(int64(0), nil),
+		)
+		mockDeletionJobRepo.EXPECT().UpdateDeletionJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.DeletionJob) error {
+			saved = append(saved, *job)
+			return nil
+		}).Times(4)
+
+		job, err := service.DeleteAccount(context.Background(), mockUser.ID, "")
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeletionModeAnonymize, job.Mode)
+		assert.Equal(t, models.DeletionStatusRunning, job.Status)
+
+		service.jobs.Wait()
+		if assert.Len(t, saved, 4) {
+			// progress after every batch
+			assert.Equal(t, int64(deletionBatchSize), saved[0].CommentsProcessed)
+			assert.Equal(t, int64(2), saved[2].PostsProcessed)
+
+			last := saved[3]
+			assert.Equal(t, job.ID, last.ID)
+			assert.Equal(t, models.DeletionStatusDone, last.Status)
+			assert.Equal(t, int64(deletionBatchSize+3), last.CommentsProcessed)
+			assert.Equal(t, int64(2), last.PostsProcessed)
+			assert.NotNil(t, last.FinishedAt)
+		}
+	})
+
+	t.Run("Invalid mode", func(t *testing.T) {
+		job, err := service.DeleteAccount(context.Background(), mockUser.ID, "everything")
+		assert.EqualError(t, err, "invalid deletion mode")
+		assert.Nil(t, job)
+	})
+
+	t.Run("Err deleting user", func(t *testing.T) {
+		mockDeletionJobRepo.EXPECT().CreateDeletionJob(gomock.Any(), gomock.Any()).Return(nil)
+		mockUserRepo.EXPECT().DeleteUser(gomock.Any(), mockUser.ID).Return(ErrBasic)
+
+		job, err := service.DeleteAccount(context.Background(), mockUser.ID, models.DeletionModePurge)
+		assert.EqualError(t, err, "internal error")
+		assert.Nil(t, job)
+	})
+}
+
+func TestGetDeletionJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDeletionJobRepo := mocks.NewMockDeletionJobRepository(ctrl)
+	service := &Service{
+		deletionJobRepo: mockDeletionJobRepo,
+		logger:          zap.NewNop().Sugar(),
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		job := models.NewDeletionJob(mockUser.ID, models.DeletionModePurge)
+		job.CommentsProcessed = 7
+		mockDeletionJobRepo.EXPECT().GetDeletionJob(gomock.Any(), job.ID).Return(job, nil)
+
+		progress, err := service.GetDeletionJob(context.Background(), job.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.DeletionProgress{ID: job.ID, Status: job.Status}, progress)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mockDeletionJobRepo.EXPECT().GetDeletionJob(gomock.Any(), "missing").Return(nil, repository.ErrDeletionJobDontExists)
+
+		progress, err := service.GetDeletionJob(context.Background(), "missing")
+		assert.EqualError(t, err, "deletion job not found")
+		assert.Nil(t, progress)
+	})
+}
+
+func TestRunDeletionJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockDeletionJobRepo := mocks.NewMockDeletionJobRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockRevisionRepo := mocks.NewMockPostRevisionRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo:        mockUserRepo,
+		postRepo:        mockPostRepo,
+		commentRepo:     mockCommentRepo,
+		sessionRepo:     mockSessionRepo,
+		deletionJobRepo: mockDeletionJobRepo,
+		voteRepo:        mockVoteRepo,
+		revisionRepo:    mockRevisionRepo,
+		profileRepo:     mockProfileRepo,
+		logger:          mockLogger,
+	}
+
+	expectUserDeleted := func(userID string) {
+		mockUserRepo.EXPECT().DeleteUser(gomock.Any(), userID).Return(nil)
+		mockSessionRepo.EXPECT().DeleteSessionsByUserID(gomock.Any(), userID).Return(nil)
+	}
+
+	t.Run("Purge", func(t *testing.T) {
+		job := models.NewDeletionJob(mockUser.ID, models.DeletionModePurge)
+		otherPost := models.NewPost(models.NewUser("other", "qwerty123"), "music", "title", "text", "text", "")
+		userComment := models.NewComment("comment", mockUser, otherPost.ID)
+		otherComment := models.NewComment("comment", otherPost.Author, otherPost.ID)
+
+		expectUserDeleted(mockUser.ID)
+		// votes on others' content are taken back with their karma,
+		// vote on deleted post is left for purge of that post
+		mockProfileRepo.EXPECT().AddKarma(gomock.Any(), otherPost.Author.ID, -1, 0).Return(nil)
+		mockProfileRepo.EXPECT().AddKarma(gomock.Any(), otherComment.Author.ID, 0, 1).Return(nil)
+		// karma of other user's comment on purged post is taken back,
+		// anonymized author has no profile
+		commentOnPost := models.NewComment("comment", otherPost.Author, "post1")
+		commentOnPost.Score = 3
+		anonymizedComment := models.NewComment("comment", models.DeletedAuthor(), "post1")
+		anonymizedComment.Score = 2
+		mockProfileRepo.EXPECT().AddKarma(gomock.Any(), otherPost.Author.ID, 0, -3).Return(nil)
+		gomock.InOrder(
+			mockVoteRepo.EXPECT().GetVotesByUserID(gomock.Any(), mockUser.ID).Return([]*models.Vote{
+				{UserID: mockUser.ID, PostID: otherPost.ID, Vote: 1},
+				{UserID: mockUser.ID, PostID: "deletedpost", Vote: 1},
+			}, nil),
+			mockVoteRepo.EXPECT().UnvotePost(gomock.Any(), otherPost.ID, mockUser.ID).Return(otherPost, int8(1), nil),
+			mockVoteRepo.EXPECT().UnvotePost(gomock.Any(), "deletedpost", mockUser.ID).Return(nil, int8(0), repository.ErrPostDontExists),
+			mockVoteRepo.EXPECT().GetCommentVotesByUserID(gomock.Any(), mockUser.ID).Return([]*models.Vote{
+				{UserID: mockUser.ID, PostID: otherPost.ID, CommentID: otherComment.ID, Vote: -1},
+			}, nil),
+			mockVoteRepo.EXPECT().UnvoteComment(gomock.Any(), otherComment.ID, mockUser.ID).Return(otherComment, int8(-1), nil),
+			mockCommentRepo.EXPECT().DeleteCommentsByAuthor(gomock.Any(), mockUser.ID, int64(deletionBatchSize)).Return([]*models.Comment{userComment}, nil),
+			mockVoteRepo.EXPECT().DeleteCommentVotesByCommentIDs(gomock.Any(), []string{userComment.ID}).Return(int64(2), nil),
+			mockCommentRepo.EXPECT().DeleteCommentsByAuthor(gomock.Any(), mockUser.ID, int64(deletionBatchSize)).Return(nil, nil),
+			mockPostRepo.EXPECT().GetPostIDsByAuthor(gomock.Any(), mockUser.ID, int64(deletionBatchSize)).Return([]string{"post1"}, nil),
+			mockCommentRepo.EXPECT().GetCommentsByPostID(gomock.Any(), "post1").Return([]*models.Comment{commentOnPost, anonymizedComment}, nil),
+			mockCommentRepo.EXPECT().DeleteCommentsByPostID(gomock.Any(), "post1").Return(int64(4), nil),
+			mockVoteRepo.EXPECT().DeleteCommentVotesByPostID(gomock.Any(), "post1").Return(int64(1), nil),
+			mockVoteRepo.EXPECT().DeleteVotesByPostID(gomock.Any(), "post1").Return(int64(3), nil),
+			mockRevisionRepo.EXPECT().DeleteRevisionsByPostID(gomock.Any(), "post1").Return(int64(2), nil),
+			mockPostRepo.EXPECT().DeletePost(gomock.Any(), "post1").Return(nil),
+			mockPostRepo.EXPECT().GetPostIDsByAuthor(gomock.Any(), mockUser.ID, int64(deletionBatchSize)).Return(nil, nil),
+		)
+		mockDeletionJobRepo.EXPECT().UpdateDeletionJob(gomock.Any(), job).Return(nil).Times(3)
+
+		service.runDeletionJob(context.Background(), job)
+		assert.Equal(t, models.DeletionStatusDone, job.Status)
+		assert.Equal(t, int64(5), job.CommentsProcessed)
+		assert.Equal(t, int64(1), job.PostsProcessed)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		job := models.NewDeletionJob(mockUser.ID, models.DeletionModeAnonymize)
+
+		expectUserDeleted(mockUser.ID)
+		mockCommentRepo.EXPECT().ReplaceCommentsAuthor(gomock.Any(), mockUser.ID, gomock.Any(), int64(deletionBatchSize)).Return(int64(0), ErrBasic)
+		mockDeletionJobRepo.EXPECT().UpdateDeletionJob(gomock.Any(), job).Return(nil)
+
+		service.runDeletionJob(context.Background(), job)
+		assert.Equal(t, models.DeletionStatusFailed, job.Status)
+		assert.Contains(t, job.LastError, ErrBasic.Error())
+		assert.Nil(t, job.FinishedAt)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		failed := models.NewDeletionJob("otheruser", models.DeletionModeAnonymize)
+		failed.Status = models.DeletionStatusFailed
+		failed.CommentsProcessed = 10
+
+		mockDeletionJobRepo.EXPECT().GetUnfinishedDeletionJobs(gomock.Any()).Return([]*models.DeletionJob{failed}, nil)
+		mockDeletionJobRepo.EXPECT().ClaimDeletionJob(gomock.Any(), failed, gomock.Any()).Return(nil)
+		expectUserDeleted("otheruser")
+		mockCommentRepo.EXPECT().ReplaceCommentsAuthor(gomock.Any(), "otheruser", gomock.Any(), int64(deletionBatchSize)).Return(int64(0), nil)
+		mockPostRepo.EXPECT().ReplacePostsAuthor(gomock.Any(), "otheruser", gomock.Any(), int64(deletionBatchSize)).Return(int64(0), nil)
+		mockDeletionJobRepo.EXPECT().UpdateDeletionJob(gomock.Any(), failed).Return(nil)
+
+		err := service.ResumeDeletionJobs(context.Background())
+		assert.NoError(t, err)
+
+		service.jobs.Wait()
+		assert.Equal(t, models.DeletionStatusDone, failed.Status)
+		assert.Equal(t, int64(10), failed.CommentsProcessed)
+		assert.Empty(t, failed.LastError)
+	})
+
+	t.Run("Resume claimed by other instance", func(t *testing.T) {
+		running := models.NewDeletionJob("otheruser", models.DeletionModeAnonymize)
+
+		mockDeletionJobRepo.EXPECT().GetUnfinishedDeletionJobs(gomock.Any()).Return([]*models.DeletionJob{running}, nil)
+		mockDeletionJobRepo.EXPECT().ClaimDeletionJob(gomock.Any(), running, gomock.Any()).Return(repository.ErrDeletionJobClaimed)
+
+		err := service.ResumeDeletionJobs(context.Background())
+		assert.NoError(t, err)
+
+		service.jobs.Wait()
+		assert.Equal(t, models.DeletionStatusRunning, running.Status)
+	})
+}
+
+func TestExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockSessionRepo := mocks.NewMockSessionRepository(ctrl)
+	mockExportJobRepo := mocks.NewMockExportJobRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	store := export.NewMemoryStore()
+	service := &Service{
+		userRepo:      mockUserRepo,
+		postRepo:      mockPostRepo,
+		commentRepo:   mockCommentRepo,
+		sessionRepo:   mockSessionRepo,
+		exportJobRepo: mockExportJobRepo,
+		voteRepo:      mockVoteRepo,
+		exportStore:   store,
+		exportSigner:  export.NewSigner([]byte("secret")),
+		exportTTL:     time.Hour,
+		appURL:        "http://localhost:8080",
+		logger:        mockLogger,
+	}
+
+	otherPost := models.NewPost(models.NewUser("other", "qwerty123"), "music", "title", "text", "text", "")
+	userVote := &models.Vote{PostID: otherPost.ID, UserID: mockUser.ID, Vote: -1}
+	userCommentVote := &models.Vote{PostID: otherPost.ID, CommentID: "comment1", UserID: mockUser.ID, Vote: 1}
+	deletedPost := models.NewPost(mockUser, "music", "deleted", "text", "text", "")
+	deletedAt := time.Now()
+	deletedPost.DeletedAt = &deletedAt
+
+	var ready *models.ExportJob
+	t.Run("Request", func(t *testing.T) {
+		mockExportJobRepo.EXPECT().HasRunningExportJob(gomock.Any(), mockUser.ID).Return(false, nil)
+		mockExportJobRepo.EXPECT().CreateExportJob(gomock.Any(), gomock.Any()).Return(nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockSessionRepo.EXPECT().GetSessionsByUserID(gomock.Any(), mockUser.ID).Return([]*models.SessionInfo{}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthorID(gomock.Any(), mockUser.ID).Return([]*models.Post{mockSinglePost, deletedPost}, nil)
+		mockVoteRepo.EXPECT().GetVotesByUserID(gomock.Any(), mockUser.ID).Return([]*models.Vote{userVote}, nil)
+		mockVoteRepo.EXPECT().GetCommentVotesByUserID(gomock.Any(), mockUser.ID).Return([]*models.Vote{userCommentVote}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthorID(gomock.Any(), mockUser.ID).Return([]*models.Comment{}, nil)
+		mockExportJobRepo.EXPECT().UpdateExportJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.ExportJob) error {
+			ready = job
+			return nil
+		})
+
+		job, err := service.RequestExport(context.Background(), mockUser.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ExportStatusRunning, job.Status)
+
+		service.jobs.Wait()
+		require.NotNil(t, ready)
+		assert.Equal(t, job.ID, ready.ID)
+		assert.Equal(t, models.ExportStatusReady, ready.Status)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *ready.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Already running", func(t *testing.T) {
+		mockExportJobRepo.EXPECT().HasRunningExportJob(gomock.Any(), mockUser.ID).Return(true, nil)
+
+		job, err := service.RequestExport(context.Background(), mockUser.ID)
+		assert.EqualError(t, err, "export already running")
+		assert.Nil(t, job)
+	})
+
+	var downloadURL *url.URL
+	t.Run("Get", func(t *testing.T) {
+		stored := *ready
+		mockExportJobRepo.EXPECT().GetExportJob(gomock.Any(), ready.ID).Return(&stored, nil)
+
+		job, err := service.GetExport(context.Background(), mockUser.ID, ready.ID)
+		require.NoError(t, err)
+		assert.Contains(t, job.DownloadURL, "http://localhost:8080/api/exports/"+ready.ID+"/download?")
+
+		downloadURL, err = url.Parse(job.DownloadURL)
+		require.NoError(t, err)
+	})
+
+	t.Run("Get other user's export", func(t *testing.T) {
+		stored := *ready
+		mockExportJobRepo.EXPECT().GetExportJob(gomock.Any(), ready.ID).Return(&stored, nil)
+
+		_, err := service.GetExport(context.Background(), "otheruser", ready.ID)
+		assert.EqualError(t, err, "export not found")
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		_, err := service.DownloadExport(context.Background(), ready.ID, downloadURL.Query().Get("expires"), "forged")
+		assert.EqualError(t, err, "invalid link")
+	})
+
+	t.Run("Download once", func(t *testing.T) {
+		query := downloadURL.Query()
+		mockExportJobRepo.EXPECT().GetExportJob(gomock.Any(), ready.ID).Return(ready, nil).Times(3)
+
+		// interrupted download keeps archive
+		archive, err := service.DownloadExport(context.Background(), ready.ID, query.Get("expires"), query.Get("sig"))
+		require.NoError(t, err)
+		require.NoError(t, archive.Close())
+
+		archive, err = service.DownloadExport(context.Background(), ready.ID, query.Get("expires"), query.Get("sig"))
+		require.NoError(t, err)
+		data, err := io.ReadAll(archive)
+		require.NoError(t, err)
+		require.NoError(t, archive.Close())
+
+		mockExportJobRepo.EXPECT().MarkExportDownloaded(gomock.Any(), ready.ID).Return(true, nil)
+		require.NoError(t, service.CompleteExportDownload(context.Background(), ready.ID))
+
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		assert.Contains(t, files["posts.ndjson"], mockSinglePost.ID)
+		assert.Contains(t, files["posts.ndjson"], deletedPost.ID)
+		assert.NotContains(t, files["posts.ndjson"], otherPost.ID)
+		assert.Contains(t, files["votes.ndjson"], otherPost.ID)
+		assert.Contains(t, files["votes.ndjson"], `"commentID":"comment1"`)
+
+		// archive is removed after download
+		_, err = store.Open(ready.ID)
+		assert.ErrorIs(t, err, export.ErrArchiveDontExists)
+
+		_, err = service.DownloadExport(context.Background(), ready.ID, query.Get("expires"), query.Get("sig"))
+		assert.EqualError(t, err, "export already downloaded")
+
+		// concurrent download completed first
+		mockExportJobRepo.EXPECT().MarkExportDownloaded(gomock.Any(), ready.ID).Return(false, nil)
+		assert.NoError(t, service.CompleteExportDownload(context.Background(), ready.ID))
+	})
+
+	t.Run("Expired link", func(t *testing.T) {
+		expires := time.Now().Add(-time.Minute)
+		sig := service.exportSigner.Sign(ready.ID, expires)
+
+		_, err := service.DownloadExport(context.Background(), ready.ID, strconv.FormatInt(expires.Unix(), 10), sig)
+		assert.EqualError(t, err, "link expired")
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		expired := models.NewExportJob(mockUser.ID)
+		expiredAt := time.Now().Add(-time.Minute)
+		expired.Status = models.ExportStatusReady
+		expired.ExpiresAt = &expiredAt
+		require.NoError(t, store.Save(expired.ID, func(w io.Writer) error { return nil }))
+
+		mockExportJobRepo.EXPECT().GetExportJobsByStatus(gomock.Any(), models.ExportStatusReady).Return([]*models.ExportJob{expired}, nil)
+		mockExportJobRepo.EXPECT().UpdateExportJob(gomock.Any(), expired).Return(nil)
+
+		err := service.CleanupExports(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, models.ExportStatusExpired, expired.Status)
+		_, err = store.Open(expired.ID)
+		assert.ErrorIs(t, err, export.ErrArchiveDontExists)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		stale := models.NewExportJob(mockUser.ID)
+		building := models.NewExportJob("otheruser")
+
+		mockExportJobRepo.EXPECT().GetExportJobsByStatus(gomock.Any(), models.ExportStatusRunning).Return([]*models.ExportJob{stale, building}, nil)
+		mockExportJobRepo.EXPECT().ClaimExportJob(gomock.Any(), stale, gomock.Any()).Return(nil)
+		// another instance is building it
+		mockExportJobRepo.EXPECT().ClaimExportJob(gomock.Any(), building, gomock.Any()).Return(repository.ErrExportJobClaimed)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockSessionRepo.EXPECT().GetSessionsByUserID(gomock.Any(), mockUser.ID).Return([]*models.SessionInfo{}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthorID(gomock.Any(), mockUser.ID).Return([]*models.Post{}, nil)
+		mockVoteRepo.EXPECT().GetVotesByUserID(gomock.Any(), mockUser.ID).Return(nil, nil)
+		mockVoteRepo.EXPECT().GetCommentVotesByUserID(gomock.Any(), mockUser.ID).Return(nil, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthorID(gomock.Any(), mockUser.ID).Return([]*models.Comment{}, nil)
+		mockExportJobRepo.EXPECT().UpdateExportJob(gomock.Any(), stale).Return(nil)
+
+		err := service.ResumeExportJobs(context.Background())
+		assert.NoError(t, err)
+
+		service.jobs.Wait()
+		assert.Equal(t, models.ExportStatusReady, stale.Status)
+		assert.Equal(t, models.ExportStatusRunning, building.Status)
+	})
+}
+
+func TestProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		userRepo:    mockUserRepo,
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: mockProfileRepo,
+		logger:      mockLogger,
+	}
+
+	profile := models.NewProfile(mockUser.ID)
+	profile.Bio = "hello"
+	profile.PostKarma = 5
+
+	t.Run("Get", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+		mockProfileRepo.EXPECT().GetProfile(gomock.Any(), mockUser.ID).Return(profile, nil)
+		mockPostRepo.EXPECT().CountPostsByAuthor(gomock.Any(), mockUser.ID).Return(2, nil)
+		mockCommentRepo.EXPECT().CountCommentsByAuthor(gomock.Any(), mockUser.ID).Return(1, nil)
+
+		res, err := service.GetProfile(context.Background(), mockUser.Username)
+		require.NoError(t, err)
+		assert.Equal(t, mockUser.Username, res.Username)
+		assert.Equal(t, "hello", res.Bio)
+		assert.Equal(t, 5, res.Karma)
+		assert.Equal(t, 2, res.PostCount)
+		assert.Equal(t, 1, res.CommentCount)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), "nobody").Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := service.GetProfile(context.Background(), "nobody")
+		assert.EqualError(t, err, "user not found")
+	})
+
+	t.Run("Missing profile is counted from votes", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByUsername(gomock.Any(), mockUser.Username).Return(mockUser, nil)
+		mockProfileRepo.EXPECT().GetProfile(gomock.Any(), mockUser.ID).Return(nil, repository.ErrProfileDontExists)
+		mockPostRepo.EXPECT().SumScoreByAuthor(gomock.Any(), mockUser.ID).Return(3, nil)
+		mockCommentRepo.EXPECT().SumScoreByAuthor(gomock.Any(), mockUser.ID).Return(-1, nil)
+		mockProfileRepo.EXPECT().CreateProfile(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, created *models.Profile) error {
+			assert.Equal(t, 3, created.PostKarma)
+			assert.Equal(t, -1, created.CommentKarma)
+			return nil
+		})
+		mockProfileRepo.EXPECT().GetProfile(gomock.Any(), mockUser.ID).Return(&models.Profile{UserID: mockUser.ID, PostKarma: 3, CommentKarma: -1}, nil)
+		mockPostRepo.EXPECT().CountPostsByAuthor(gomock.Any(), mockUser.ID).Return(1, nil)
+		mockCommentRepo.EXPECT().CountCommentsByAuthor(gomock.Any(), mockUser.ID).Return(1, nil)
+
+		res, err := service.GetProfile(context.Background(), mockUser.Username)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.Karma)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		displayName := "  Test User "
+		avatarURL := "https://example.com/me.png"
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), mockUser.ID).Return(mockUser, nil)
+		mockProfileRepo.EXPECT().GetProfile(gomock.Any(), mockUser.ID).Return(profile, nil).Times(2)
+		mockProfileRepo.EXPECT().UpdateProfile(gomock.Any(), profile).Return(nil)
+		mockPostRepo.EXPECT().CountPostsByAuthor(gomock.Any(), mockUser.ID).Return(0, nil)
+		mockCommentRepo.EXPECT().CountCommentsByAuthor(gomock.Any(), mockUser.ID).Return(0, nil)
+
+		res, err := service.UpdateProfile(context.Background(), mockUser.ID, &models.ProfileUpdate{DisplayName: &displayName, AvatarURL: &avatarURL})
+		require.NoError(t, err)
+		assert.Equal(t, "Test User", res.DisplayName)
+		assert.Equal(t, avatarURL, res.AvatarURL)
+		// bio isnt in request, so it is kept
+		assert.Equal(t, "hello", res.Bio)
+	})
+
+	t.Run("Invalid update", func(t *testing.T) {
+		longBio := strings.Repeat("a", models.MaxBioLength+1)
+		_, err := service.UpdateProfile(context.Background(), mockUser.ID, &models.ProfileUpdate{Bio: &longBio})
+		assert.EqualError(t, err, "bio is too long")
+
+		avatarURL := "javascript:alert(1)"
+		_, err = service.UpdateProfile(context.Background(), mockUser.ID, &models.ProfileUpdate{AvatarURL: &avatarURL})
+		assert.EqualError(t, err, "invalid avatar url")
+	})
+}
+
+func TestVotePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: mockProfileRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	voter := "voter"
+	vote := func(v int8) *models.Vote {
+		return &models.Vote{PostID: post.ID, UserID: voter, Vote: v}
+	}
+
+	testCases := []struct {
+		name       string
+		vote       int8
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name: "New downvote",
+			vote: -1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockVoteRepo.EXPECT().VotePost(gomock.Any(), vote(-1)).Return(post, int8(0), nil)
+				mockProfileRepo.EXPECT().AddKarma(gomock.Any(), mockUser.ID, -1, 0).Return(nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+			},
+		},
+		{
+			name: "Downvote changed to upvote",
+			vote: 1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockVoteRepo.EXPECT().VotePost(gomock.Any(), vote(1)).Return(post, int8(-1), nil)
+				mockProfileRepo.EXPECT().AddKarma(gomock.Any(), mockUser.ID, 2, 0).Return(nil)
+				mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+			},
+		},
+		{
+			name: "Already voted",
+			vote: 1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockVoteRepo.EXPECT().VotePost(gomock.Any(), vote(1)).Return(nil, int8(0), repository.ErrVoteAlreadyExists)
+			},
+			wantErrMsg: ErrVoteAlreadyExists.Error(),
+		},
+		{
+			name: "Post not found",
+			vote: 1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(nil, nil)
+			},
+			wantErrMsg: "post not found",
+		},
+		{
+			name:       "Invalid vote",
+			vote:       2,
+			mockSetup:  func() {},
+			wantErrMsg: "invalid vote",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.VotePostWithID(context.Background(), post.ID, models.NewVote(voter, tc.vote))
+			if tc.wantErrMsg == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, post, res)
+				// only voter's own vote is returned
+				assert.Equal(t, []*models.Vote{vote(tc.vote)}, res.Votes)
+			} else {
+				assert.EqualError(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestUnvotePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: mockProfileRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+	mockVoteRepo.EXPECT().UnvotePost(gomock.Any(), post.ID, "voter").Return(post, int8(-1), nil)
+	mockProfileRepo.EXPECT().AddKarma(gomock.Any(), mockUser.ID, 1, 0).Return(nil)
+	mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil)
+
+	res, err := service.UnvotePostWithID(context.Background(), post.ID, "voter")
+	assert.NoError(t, err)
+	assert.Equal(t, post, res)
+	assert.Empty(t, res.Votes)
+
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+	mockVoteRepo.EXPECT().UnvotePost(gomock.Any(), post.ID, "voter").Return(nil, int8(0), repository.ErrVoteDontExists)
+
+	_, err = service.UnvotePostWithID(context.Background(), post.ID, "voter")
+	assert.EqualError(t, err, ErrVoteDontExist.Error())
+}
+
+func TestVoteComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: mockProfileRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	commenter := models.NewUser("commenter", "qwerty123")
+	comment := models.NewComment("comment", commenter, post.ID)
+	otherComment := models.NewComment("other", commenter, post.ID)
+	voter := "voter"
+	vote := func(v int8) *models.Vote {
+		return &models.Vote{PostID: post.ID, CommentID: comment.ID, UserID: voter, Vote: v}
+	}
+	expectPostWithVotes := func() {
+		mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), voter, []string{post.ID}).Return(map[string]int8{}, nil)
+		mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{comment, otherComment}, nil)
+		mockVoteRepo.EXPECT().GetUserCommentVotes(gomock.Any(), voter, post.ID).Return(map[string]int8{comment.ID: -1}, nil)
+	}
+
+	testCases := []struct {
+		name       string
+		commentID  string
+		vote       int8
+		mockSetup  func()
+		wantErrMsg string
+	}{
+		{
+			name:      "New downvote",
+			commentID: comment.ID,
+			vote:      -1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockVoteRepo.EXPECT().VoteComment(gomock.Any(), vote(-1)).Return(comment, int8(0), nil)
+				mockProfileRepo.EXPECT().AddKarma(gomock.Any(), commenter.ID, 0, -1).Return(nil)
+				expectPostWithVotes()
+			},
+		},
+		{
+			name:      "Upvote changed to downvote",
+			commentID: comment.ID,
+			vote:      -1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockVoteRepo.EXPECT().VoteComment(gomock.Any(), vote(-1)).Return(comment, int8(1), nil)
+				mockProfileRepo.EXPECT().AddKarma(gomock.Any(), commenter.ID, 0, -2).Return(nil)
+				expectPostWithVotes()
+			},
+		},
+		{
+			name:      "Already voted",
+			commentID: comment.ID,
+			vote:      -1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockVoteRepo.EXPECT().VoteComment(gomock.Any(), vote(-1)).Return(nil, int8(0), repository.ErrVoteAlreadyExists)
+			},
+			wantErrMsg: ErrVoteAlreadyExists.Error(),
+		},
+		{
+			name:      "Comment of other post",
+			commentID: "foreign",
+			vote:      1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), "foreign").Return(models.NewComment("foreign", commenter, "other post"), nil)
+			},
+			wantErrMsg: "comment not found",
+		},
+		{
+			name:      "Comment deleted meanwhile",
+			commentID: comment.ID,
+			vote:      1,
+			mockSetup: func() {
+				mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+				mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+				mockVoteRepo.EXPECT().VoteComment(gomock.Any(), gomock.Any()).Return(nil, int8(0), repository.ErrCommentDontExists)
+			},
+			wantErrMsg: "comment not found",
+		},
+		{
+			name:       "Invalid vote",
+			commentID:  comment.ID,
+			vote:       2,
+			mockSetup:  func() {},
+			wantErrMsg: "invalid vote",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+
+			res, err := service.VoteCommentWithID(context.Background(), post.ID, tc.commentID, models.NewVote(voter, tc.vote))
+			if tc.wantErrMsg == "" {
+				require.NoError(t, err)
+				require.Len(t, res.Comments, 2)
+				// only voter's own vote is returned
+				assert.Equal(t, []*models.Vote{vote(-1)}, res.Comments[0].Votes)
+				assert.Empty(t, res.Comments[1].Votes)
 			} else {
 				assert.EqualError(t, err, tc.wantErrMsg)
 			}
 		})
 	}
 }
+
+func TestUnvoteComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPostRepo := mocks.NewMockPostRepository(ctrl)
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockProfileRepo := mocks.NewMockProfileRepository(ctrl)
+	mockVoteRepo := mocks.NewMockVoteRepository(ctrl)
+	mockLogger := zap.NewNop().Sugar()
+
+	service := &Service{
+		postRepo:    mockPostRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: mockProfileRepo,
+		voteRepo:    mockVoteRepo,
+		logger:      mockLogger,
+	}
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	commenter := models.NewUser("commenter", "qwerty123")
+	comment := models.NewComment("comment", commenter, post.ID)
+
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+	mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+	mockVoteRepo.EXPECT().UnvoteComment(gomock.Any(), comment.ID, "voter").Return(comment, int8(1), nil)
+	mockProfileRepo.EXPECT().AddKarma(gomock.Any(), commenter.ID, 0, -1).Return(nil)
+	mockVoteRepo.EXPECT().GetUserVotes(gomock.Any(), "voter", []string{post.ID}).Return(map[string]int8{post.ID: 1}, nil)
+	mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), post.ID, "", DefaultCommentMaxDepth).Return([]*models.Comment{comment}, nil)
+	mockVoteRepo.EXPECT().GetUserCommentVotes(gomock.Any(), "voter", post.ID).Return(map[string]int8{}, nil)
+
+	res, err := service.UnvoteCommentWithID(context.Background(), post.ID, comment.ID, "voter")
+	require.NoError(t, err)
+	assert.Len(t, res.Votes, 1)
+	assert.Empty(t, res.Comments[0].Votes)
+
+	mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID).Return(post, nil)
+	mockCommentRepo.EXPECT().GetCommentByID(gomock.Any(), comment.ID).Return(comment, nil)
+	mockVoteRepo.EXPECT().UnvoteComment(gomock.Any(), comment.ID, "voter").Return(nil, int8(0), repository.ErrVoteDontExists)
+
+	_, err = service.UnvoteCommentWithID(context.Background(), post.ID, comment.ID, "voter")
+	assert.EqualError(t, err, ErrVoteDontExist.Error())
+}
+
+// memoryVoteRepo keeps votes of one post and its counters under lock,
+// like transaction in mongo
+type memoryVoteRepo struct {
+	repository.VoteRepository
+
+	mu    sync.Mutex
+	votes map[string]int8
+	post  *models.Post
+}
+
+func (r *memoryVoteRepo) VotePost(_ context.Context, vote *models.Vote) (*models.Post, int8, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.votes[vote.UserID]
+	if prev == vote.Vote {
+		return nil, 0, repository.ErrVoteAlreadyExists
+	}
+	r.votes[vote.UserID] = vote.Vote
+	return r.addVoteCounters(vote.Vote, prev), prev, nil
+}
+
+func (r *memoryVoteRepo) UnvotePost(_ context.Context, _, userID string) (*models.Post, int8, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.votes[userID]
+	if !ok {
+		return nil, 0, repository.ErrVoteDontExists
+	}
+	delete(r.votes, userID)
+	return r.addVoteCounters(0, prev), prev, nil
+}
+
+func (r *memoryVoteRepo) addVoteCounters(vote, prev int8) *models.Post {
+	upvotes, downvotes := models.VoteCounters(vote)
+	prevUpvotes, prevDownvotes := models.VoteCounters(prev)
+	r.post.SetVoteCounters(r.post.Upvotes+upvotes-prevUpvotes, r.post.Downvotes+downvotes-prevDownvotes)
+	post := *r.post
+	return &post
+}
+
+// votedPostRepo returns copy of the post of memoryVoteRepo
+type votedPostRepo struct {
+	repository.PostRepository
+
+	votes *memoryVoteRepo
+}
+
+func (r *votedPostRepo) GetPostByID(_ context.Context, _ string) (*models.Post, error) {
+	r.votes.mu.Lock()
+	defer r.votes.mu.Unlock()
+
+	post := *r.votes.post
+	return &post, nil
+}
+
+// karmaCounter sums karma changes
+type karmaCounter struct {
+	repository.ProfileRepository
+
+	mu    sync.Mutex
+	karma int
+}
+
+func (c *karmaCounter) AddKarma(_ context.Context, _ string, postDelta, _ int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.karma += postDelta
+	return nil
+}
+
+// TestVotePostConcurrent checks that service doesnt read-modify-write
+// the post: every vote and karma change has to survive.
+func TestVotePostConcurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCommentRepo := mocks.NewMockCommentRepository(ctrl)
+	mockCommentRepo.EXPECT().GetCommentThread(gomock.Any(), gomock.Any(), "", DefaultCommentMaxDepth).Return([]*models.Comment{}, nil).AnyTimes()
+
+	post := models.NewPost(mockUser, "music", "title", "text", "text", "")
+	voteRepo := &memoryVoteRepo{votes: map[string]int8{mockUser.ID: 1}, post: post}
+	postRepo := &votedPostRepo{votes: voteRepo}
+	karma := &karmaCounter{karma: post.Score}
+
+	service := &Service{
+		postRepo:    postRepo,
+		commentRepo: mockCommentRepo,
+		profileRepo: karma,
+		voteRepo:    voteRepo,
+		logger:      zap.NewNop().Sugar(),
+	}
+
+	const voters = 100
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			voter := "voter" + strconv.Itoa(i)
+
+			_, err := service.VotePostWithID(context.Background(), post.ID, models.NewVote(voter, -1))
+			assert.NoError(t, err)
+			if i%2 == 0 {
+				_, err = service.VotePostWithID(context.Background(), post.ID, models.NewVote(voter, 1))
+				assert.NoError(t, err)
+			}
+			if i%5 == 0 {
+				_, err = service.UnvotePostWithID(context.Background(), post.ID, voter)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// author's upvote, 40 upvotes and 40 downvotes are left
+	assert.Len(t, voteRepo.votes, 81)
+	assert.Equal(t, 41, post.Upvotes)
+	assert.Equal(t, 40, post.Downvotes)
+	assert.Equal(t, 1, post.Score)
+	assert.Equal(t, 50, post.UpvotePercentage)
+	assert.Equal(t, post.Score, karma.karma)
+}
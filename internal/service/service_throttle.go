@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/validation"
+)
+
+const (
+	lockBaseDuration = 30 * time.Second
+	lockMaxDuration  = time.Hour
+)
+
+// throttle describes how many attempts are allowed for one key
+// before it gets locked. Every next attempt doubles lock duration.
+type throttle struct {
+	prefix string
+	free   int64
+	window time.Duration
+}
+
+var (
+	loginUserThrottle = throttle{prefix: "user:", free: 5, window: 24 * time.Hour}
+	// many users can be behind one NAT
+	loginIPThrottle    = throttle{prefix: "ip:", free: 20, window: 24 * time.Hour}
+	registerIPThrottle = throttle{prefix: "register:", free: 10, window: time.Hour}
+	// every reset request sends an email
+	forgotPasswordThrottle = throttle{prefix: "forgot:", free: 5, window: time.Hour}
+	emailLoginThrottle     = throttle{prefix: "email_login:", free: 5, window: time.Hour}
+)
+
+func (t throttle) key(id string) string {
+	return t.prefix + strings.ToLower(id)
+}
+
+// lockDuration is exponential backoff after free attempts run out.
+func (t throttle) lockDuration(attempts int64) time.Duration {
+	if attempts < t.free {
+		return 0
+	}
+
+	d := lockBaseDuration
+	for i := t.free; i < attempts && d < lockMaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, lockMaxDuration)
+}
+
+// TooManyAttemptsError is returned while key is locked out,
+// RetryAfter is sent to client in Retry-After header.
+type TooManyAttemptsError struct {
+	*errhandler.StatusCodedError
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return e.StatusCodedError
+}
+
+func newTooManyAttemptsError(retryAfter time.Duration, debugLog string) error {
+	return &TooManyAttemptsError{
+		StatusCodedError: &errhandler.StatusCodedError{
+			StatusCode: http.StatusTooManyRequests,
+			UserAnswer: "too many attempts",
+			DebugLog:   debugLog,
+		},
+		RetryAfter: retryAfter,
+	}
+}
+
+// checkLocked returns TooManyAttemptsError if any of keys is locked,
+// client has to wait for the longest lock.
+func (s *Service) checkLocked(ctx context.Context, keys ...string) error {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := s.loginAttemptRepo.GetLockTTL(ctx, key)
+		if err != nil {
+			return errhandler.New(http.StatusInternalServerError, "internal error", "cant check lockout", err)
+		}
+		retryAfter = max(retryAfter, ttl)
+	}
+
+	if retryAfter > 0 {
+		return newTooManyAttemptsError(retryAfter, "locked out: "+strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// recordAttempt counts attempt and locks key when free attempts run out.
+// Request has already failed or succeeded by now, so errors are only logged.
+func (s *Service) recordAttempt(ctx context.Context, t throttle, id string) {
+	key := t.key(id)
+	attempts, err := s.loginAttemptRepo.IncrAttempts(ctx, key, t.window)
+	if err != nil {
+		s.logger.Warnw("cant count attempt",
+			"key", key,
+			"err", err,
+		)
+		return
+	}
+
+	duration := t.lockDuration(attempts)
+	if duration == 0 {
+		return
+	}
+
+	if err = s.loginAttemptRepo.Lock(ctx, key, duration); err != nil {
+		s.logger.Warnw("cant lock key",
+			"key", key,
+			"err", err,
+		)
+		return
+	}
+
+	s.logger.Infow("locked out",
+		"key", key,
+		"attempts", attempts,
+		"duration", duration,
+	)
+}
+
+func (s *Service) resetAttempts(ctx context.Context, key string) {
+	if err := s.loginAttemptRepo.Reset(ctx, key); err != nil {
+		s.logger.Warnw("cant reset attempts",
+			"key", key,
+			"err", err,
+		)
+	}
+}
+
+// loginUserID is what failed logins are counted by. Usernames differing
+// only in case or confusable characters log into the same account,
+// so they share counter.
+func loginUserID(username string) string {
+	return validation.UsernameKey(username)
+}
+
+// loginThrottleKeys are checked before login, ip is unknown without client info.
+func loginThrottleKeys(username string, client *models.ClientInfo) []string {
+	keys := []string{loginUserThrottle.key(loginUserID(username))}
+	if client != nil && client.IP != "" {
+		keys = append(keys, loginIPThrottle.key(client.IP))
+	}
+	return keys
+}
+
+// recordLoginFailure counts failed login both for username and ip.
+func (s *Service) recordLoginFailure(ctx context.Context, username string, client *models.ClientInfo) {
+	s.recordAttempt(ctx, loginUserThrottle, loginUserID(username))
+	if client != nil && client.IP != "" {
+		s.recordAttempt(ctx, loginIPThrottle, client.IP)
+	}
+}
+
+// UnlockLogin removes lockouts of username and (or) ip, only admins can do it.
+func (s *Service) UnlockLogin(ctx context.Context, adminID, username, ip string) error {
+	if err := s.authorize(ctx, adminID, models.PermissionManageUsers, ""); err != nil {
+		return err
+	}
+	if username == "" && ip == "" {
+		return errhandler.New(http.StatusBadRequest, "username or ip is required", "nothing to unlock", nil)
+	}
+
+	keys := []string{}
+	if username != "" {
+		keys = append(keys, loginUserThrottle.key(loginUserID(username)))
+	}
+	if ip != "" {
+		keys = append(keys, loginIPThrottle.key(ip), registerIPThrottle.key(ip),
+			forgotPasswordThrottle.key(ip), emailLoginThrottle.key(ip))
+	}
+
+	for _, key := range keys {
+		if err := s.loginAttemptRepo.Reset(ctx, key); err != nil {
+			return errhandler.New(http.StatusInternalServerError, "internal error", "cant reset attempts of "+key, err)
+		}
+	}
+
+	s.logger.Infow("unlocked login",
+		"admin_id", adminID,
+		"keys", keys,
+	)
+
+	return nil
+}
@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/myacey/redditclone/internal/customerror/errhandler"
+	"github.com/myacey/redditclone/internal/mailer"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
+	"github.com/myacey/redditclone/internal/validation"
 )
 
 // func (s *Service) AddUserToDB(ctx context.Context, user *models.User) error {
@@ -21,53 +26,138 @@ func (s *Service) GetUserFromDBByUsername(ctx context.Context, username string)
 	return s.userRepo.GetUserByUsername(ctx, username)
 }
 
-func (s *Service) CreateNewUser(ctx context.Context, user *models.User) (*models.Session, error) {
-	err := s.userRepo.CreateUser(ctx, user)
-	if err != nil {
-		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create user in db", err)
+func (s *Service) CreateNewUser(ctx context.Context, user *models.User, client *models.ClientInfo) (*models.Session, error) {
+	// every registration counts, not only failed ones
+	if client != nil && client.IP != "" {
+		if err := s.checkLocked(ctx, registerIPThrottle.key(client.IP)); err != nil {
+			return nil, err
+		}
+		s.recordAttempt(ctx, registerIPThrottle, client.IP)
 	}
 
-	token, err := s.tokenMaker.CreateToken(user)
+	user.Username = validation.NormalizeUsername(user.Username)
+	user.UsernameKey = validation.UsernameKey(user.Username)
+	fields := validation.ValidateUsername(user.Username)
+	fields = append(fields, validation.ValidatePassword(user.Password, user.Username)...)
+	if err := validation.NewError(fields...); err != nil {
+		return nil, err
+	}
+
+	// email is optional, but without verified one user can only read
+	user.Email = normalizeEmail(user.Email)
+	user.EmailVerified = false
+	if user.Email != "" {
+		if mailer.ValidateAddress(user.Email) != nil {
+			return nil, errhandler.New(http.StatusBadRequest, "invalid email", "invalid email: "+user.Email, nil)
+		}
+		_, err := s.userRepo.GetUserByEmail(ctx, user.Email)
+		if err == nil {
+			return nil, errhandler.New(http.StatusConflict, "email already taken", "email already taken: "+user.Email, nil)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get user by email", err)
+		}
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(user.Password)
 	if err != nil {
-		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create token", err)
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant hash password", err)
 	}
-	s.logger.Infow("created token",
-		"username", user.Username,
-		"user_id", user.ID,
-		"exp", time.Now().Add(time.Hour*24),
-	)
-
-	session := models.NewSession(token)
-	err = s.sessionRepo.CreateSession(ctx, session, user.ID, 24*time.Hour)
+	user.Password = passwordHash
+
+	err = s.userRepo.CreateUser(ctx, user)
 	if err != nil {
-		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create token in db", err)
+		// email was checked above, so it is username differing only in case
+		// or look-alike letters, or concurrent registration
+		if errors.Is(err, repository.ErrUserAlreadyExists) {
+			return nil, validation.NewErrorWithStatus(http.StatusConflict, "user already exists",
+				validation.FieldError{Field: "username", Code: validation.CodeTaken, Message: "username is already taken"})
+		}
+		return nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant create user in db", err)
 	}
 
-	return session, nil
+	if user.Email != "" {
+		if err = s.sendVerificationEmail(ctx, user); err != nil {
+			// user can ask for another email later
+			s.logger.Errorw("cant send verification email",
+				"user_id", user.ID,
+				"err", err,
+			)
+		}
+	}
+
+	return s.createSession(ctx, user, client)
 }
 
-func (s *Service) LoginUser(ctx context.Context, username string) (*models.Session, error) {
+// dummyPasswordHash is argon2id hash with default params, unknown usernames
+// are verified against it, so they take as long as wrong passwords
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=1,p=4$zOofYv5ev/HSzsNoo1A3fg$C99MoHaTr73crmyVLh8JigZmEFEwlqKq0mwgL1w11D0"
+
+// LoginUser checks user's password and creates new session.
+// Unknown username and wrong password give the same error
+// so it cant be used to find out registered usernames.
+// If user has 2FA enabled, no session is created: MFAPending token
+// has to be exchanged for session at VerifyMFA.
+// Failed logins are counted per username and ip, both get locked out
+// with exponential backoff when there are too many of them.
+func (s *Service) LoginUser(ctx context.Context, username, password string, client *models.ClientInfo) (*models.Session, *models.MFAPending, error) {
+	if err := s.checkLocked(ctx, loginThrottleKeys(username, client)...); err != nil {
+		return nil, nil, err
+	}
+
 	usr, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_, _ = s.passwordHasher.Verify(password, dummyPasswordHash)
+			s.recordLoginFailure(ctx, username, client)
+			return nil, nil, errhandler.New(http.StatusUnauthorized, "invalid credentials", "user not found: "+username, nil)
+		}
+		return nil, nil, err
 	}
 
-	token, err := s.tokenMaker.CreateToken(usr)
+	needsRehash, err := s.passwordHasher.Verify(password, usr.Password)
 	if err != nil {
-		return nil, err
+		s.recordLoginFailure(ctx, username, client)
+		return nil, nil, errhandler.New(http.StatusUnauthorized, "invalid credentials", "password verification failed: "+err.Error(), nil)
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, usr, password)
 	}
-	s.logger.Infow("created token",
-		"username", usr.Username,
-		"user_id", usr.ID,
-		"exp", time.Now().Add(time.Hour*24),
-	)
 
-	session := models.NewSession(token)
+	mfaEnabled, err := s.mfaEnabled(ctx, usr.ID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant check 2fa", err)
+	}
+	if mfaEnabled {
+		// counter is reset only after second factor, wrong codes are counted in it too
+		pending, err := s.createMFAChallenge(ctx, usr, client)
+		return nil, pending, err
+	}
+
+	// ip counter isnt reset, otherwise attacker could reset it with own account
+	s.resetAttempts(ctx, loginUserThrottle.key(loginUserID(username)))
+
+	session, err := s.createSession(ctx, usr, client)
+	return session, nil, err
+}
 
-	err = s.sessionRepo.UpdateSessionToken(ctx, session, usr.ID, 24*time.Hour)
+// rehashPassword replaces outdated (or legacy plaintext) password hash.
+// Login shouldnt fail because of it, so errors are only logged.
+func (s *Service) rehashPassword(ctx context.Context, usr *models.User, password string) {
+	passwordHash, err := s.passwordHasher.Hash(password)
 	if err != nil {
-		return nil, err
+		s.logger.Warnw("cant rehash password",
+			"user_id", usr.ID,
+			"err", err,
+		)
+		return
 	}
 
-	return session, nil
+	if err = s.userRepo.UpdateUserPassword(ctx, usr.ID, passwordHash); err != nil {
+		s.logger.Warnw("cant update password hash",
+			"user_id", usr.ID,
+			"err", err,
+		)
+		return
+	}
+	usr.Password = passwordHash
 }
@@ -3,8 +3,11 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
 
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
 	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/repository"
 )
 
 var (
@@ -12,83 +15,189 @@ var (
 	ErrVoteAlreadyExists = errors.New("vote already exists")
 )
 
-func (s *Service) updateVoteStat(post *models.Post) {
-	if len(post.Votes) == 0 {
-		post.Score = 0
-		post.UpvotePercentage = 0
-		return
+// VotePostWithID sets user's vote. Vote is saved in votes collection and
+// post counters are changed by the difference in one transaction,
+// so concurrent votes dont lose each other and counters match votes.
+func (s *Service) VotePostWithID(ctx context.Context, postID string, newVote *models.Vote) (*models.Post, error) {
+	if newVote.Vote != 1 && newVote.Vote != -1 {
+		return nil, errhandler.New(http.StatusBadRequest, "invalid vote", "invalid vote value", nil)
+	}
+	if err := s.requirePost(ctx, postID); err != nil {
+		return nil, err
 	}
-	positive := 0
-	score := 0
 
-	for _, v := range post.Votes {
-		score += int(v.Vote)
-		if v.Vote == 1 {
-			positive++
-		}
+	newVote.PostID = postID
+	gotPost, prevVote, err := s.voteRepo.VotePost(ctx, newVote)
+	if err != nil {
+		return nil, voteError(postID, err)
+	}
+	s.addKarma(ctx, gotPost.Author, int(newVote.Vote-prevVote), 0)
+
+	gotPost.Votes = []*models.Vote{newVote}
+	return s.withComments(ctx, newVote.UserID, gotPost)
+}
+
+func (s *Service) UnvotePostWithID(ctx context.Context, postID, userID string) (*models.Post, error) {
+	if err := s.requirePost(ctx, postID); err != nil {
+		return nil, err
 	}
 
-	upvotePercentage := int((float32(positive) / float32(len(post.Votes)) * 100))
+	gotPost, prevVote, err := s.voteRepo.UnvotePost(ctx, postID, userID)
+	if err != nil {
+		return nil, voteError(postID, err)
+	}
+	s.addKarma(ctx, gotPost.Author, -int(prevVote), 0)
 
-	post.UpvotePercentage = upvotePercentage
-	post.Score = score
+	gotPost.Votes = []*models.Vote{}
+	return s.withComments(ctx, userID, gotPost)
 }
 
-func (s *Service) VotePostWithID(ctx context.Context, postID string, newVote *models.Vote) (*models.Post, error) {
-	gotPost, err := s.GetPostByID(ctx, postID, false)
+// VoteCommentWithID sets user's vote on comment the same way as on post,
+// post with comments is returned.
+func (s *Service) VoteCommentWithID(ctx context.Context, postID, commentID string, newVote *models.Vote) (*models.Post, error) {
+	if newVote.Vote != 1 && newVote.Vote != -1 {
+		return nil, errhandler.New(http.StatusBadRequest, "invalid vote", "invalid vote value", nil)
+	}
+	gotPost, _, err := s.requireComment(ctx, postID, commentID)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, v := range gotPost.Votes {
-		if v.UserID != newVote.UserID {
-			continue
-		}
+	newVote.PostID = postID
+	newVote.CommentID = commentID
+	gotComment, prevVote, err := s.voteRepo.VoteComment(ctx, newVote)
+	if err != nil {
+		return nil, voteError(commentID, err)
+	}
+	s.addKarma(ctx, gotComment.Author, 0, int(newVote.Vote-prevVote))
 
-		if v.Vote == newVote.Vote { // post already exists
-			return nil, ErrVoteAlreadyExists
-		}
+	if err = s.withUserVotes(ctx, newVote.UserID, gotPost); err != nil {
+		return nil, err
+	}
+	return s.withComments(ctx, newVote.UserID, gotPost)
+}
 
-		gotPost.Votes[i] = newVote // we just need to change vote/upvote
-		s.updateVoteStat(gotPost)
-		err = s.postRepo.UpdatePostInfo(ctx, gotPost)
-		if err != nil {
-			return nil, err
-		}
+func (s *Service) UnvoteCommentWithID(ctx context.Context, postID, commentID, userID string) (*models.Post, error) {
+	gotPost, _, err := s.requireComment(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
 
-		return gotPost, nil
+	gotComment, prevVote, err := s.voteRepo.UnvoteComment(ctx, commentID, userID)
+	if err != nil {
+		return nil, voteError(commentID, err)
 	}
+	s.addKarma(ctx, gotComment.Author, 0, -int(prevVote))
 
-	gotPost.Votes = append(gotPost.Votes, newVote) // create new vote
+	if err = s.withUserVotes(ctx, userID, gotPost); err != nil {
+		return nil, err
+	}
+	return s.withComments(ctx, userID, gotPost)
+}
 
-	s.updateVoteStat(gotPost)
-	err = s.postRepo.UpdatePostInfo(ctx, gotPost)
+// requirePost keeps votes of missing posts out of votes collection
+func (s *Service) requirePost(ctx context.Context, postID string) error {
+	post, err := s.postRepo.GetPostByID(ctx, postID)
 	if err != nil {
-		return nil, err
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get post", err)
+	}
+	if post == nil {
+		return errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
+	}
+	return nil
+}
+
+// requireComment returns comment with its post, deleted comment and
+// comment of other post look like missing one
+func (s *Service) requireComment(ctx context.Context, postID, commentID string) (*models.Post, *models.Comment, error) {
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get post", err)
+	}
+	if post == nil {
+		return nil, nil, errhandler.New(http.StatusNotFound, "post not found", "post not found: "+postID, nil)
 	}
 
-	return gotPost, nil
+	comment, err := s.commentRepo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return nil, nil, errhandler.New(http.StatusInternalServerError, "internal error", "cant get comment", err)
+	}
+	if comment == nil || comment.DeletedAt != nil || comment.RelatedPostID != postID {
+		return nil, nil, errhandler.New(http.StatusNotFound, "comment not found", "comment "+commentID+" not found in post "+postID, nil)
+	}
+	return post, comment, nil
 }
 
-func (s *Service) UnvotePostWithID(ctx context.Context, postID, userID string) (*models.Post, error) {
-	gotPost, err := s.GetPostByID(ctx, postID, false)
+// withUserVotes leaves only viewer's own vote in posts,
+// anonymous viewer gets none
+func (s *Service) withUserVotes(ctx context.Context, userID string, posts ...*models.Post) error {
+	postIDs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		post.Votes = []*models.Vote{}
+		postIDs = append(postIDs, post.ID)
+	}
+	if userID == "" || len(posts) == 0 {
+		return nil
+	}
+
+	votes, err := s.voteRepo.GetUserVotes(ctx, userID, postIDs)
 	if err != nil {
-		return nil, err
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get user's votes", err)
+	}
+	for _, post := range posts {
+		if vote, ok := votes[post.ID]; ok {
+			post.Votes = []*models.Vote{{PostID: post.ID, UserID: userID, Vote: vote}}
+		}
 	}
 
-	for i, v := range gotPost.Votes {
-		if v.UserID == userID {
-			gotPost.Votes = append(gotPost.Votes[:i], gotPost.Votes[i+1:]...) // delete vote
+	return nil
+}
 
-			s.updateVoteStat(gotPost) // new vote stat to post
+// withCommentVotes leaves only viewer's own votes in comments of the post
+func (s *Service) withCommentVotes(ctx context.Context, userID, postID string, comments []*models.Comment) error {
+	for _, comment := range comments {
+		comment.Votes = []*models.Vote{}
+	}
+	if userID == "" || len(comments) == 0 {
+		return nil
+	}
 
-			err := s.postRepo.UpdatePostInfo(ctx, gotPost) // apply changes to DB
-			if err != nil {
-				return nil, err
-			}
-			return gotPost, nil
+	votes, err := s.voteRepo.GetUserCommentVotes(ctx, userID, postID)
+	if err != nil {
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant get user's comment votes", err)
+	}
+	for _, comment := range comments {
+		if vote, ok := votes[comment.ID]; ok {
+			comment.Votes = []*models.Vote{{PostID: postID, CommentID: comment.ID, UserID: userID, Vote: vote}}
 		}
 	}
 
-	return nil, ErrVoteDontExist
+	return nil
+}
+
+// voteError maps errors of post and comment votes, id is of voted one
+func voteError(id string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrPostDontExists):
+		return errhandler.New(http.StatusNotFound, "post not found", "post not found: "+id, nil)
+	case errors.Is(err, repository.ErrCommentDontExists):
+		return errhandler.New(http.StatusNotFound, "comment not found", "comment not found: "+id, nil)
+	case errors.Is(err, repository.ErrVoteAlreadyExists):
+		return ErrVoteAlreadyExists
+	case errors.Is(err, repository.ErrVoteDontExists):
+		return ErrVoteDontExist
+	default:
+		return errhandler.New(http.StatusInternalServerError, "internal error", "cant vote post", err)
+	}
+}
+
+// withComments loads comment tree of the post with viewer's votes on comments
+func (s *Service) withComments(ctx context.Context, userID string, post *models.Post) (*models.Post, error) {
+	comments, err := s.commentThread(ctx, userID, post.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	post.Comments = comments
+
+	return post, nil
 }
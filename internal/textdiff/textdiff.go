@@ -0,0 +1,102 @@
+// Package textdiff finds line-level differences between two texts.
+package textdiff
+
+import "strings"
+
+// maxTableSize limits cells of LCS table, texts changed more than that
+// are shown as whole text replaced instead of spending memory on table.
+const maxTableSize = 1 << 20
+
+// Op tells what happened to a line.
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpDelete Op = "delete"
+	OpInsert Op = "insert"
+)
+
+// Line is line of either text with its op.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns lines of a and b in order turning a into b: kept lines
+// are equal, replaced lines of a are deleted before lines of b inserted
+// in their place. Empty text has no lines.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// common prefix and suffix dont need the table
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]Line, 0, len(x)+len(y)-prefix-suffix)
+	diff = appendLines(diff, OpEqual, x[:prefix])
+	diff = appendChanged(diff, x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])
+	diff = appendLines(diff, OpEqual, x[len(x)-suffix:])
+
+	return diff
+}
+
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+func appendLines(diff []Line, op Op, lines []string) []Line {
+	for _, line := range lines {
+		diff = append(diff, Line{Op: op, Text: line})
+	}
+	return diff
+}
+
+// appendChanged keeps longest common subsequence of x and y,
+// lcs[i][j] is its length for x[i:] and y[j:].
+func appendChanged(diff []Line, x, y []string) []Line {
+	if len(x) > 0 && len(y) > maxTableSize/len(x) {
+		diff = appendLines(diff, OpDelete, x)
+		return appendLines(diff, OpInsert, y)
+	}
+
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, Line{Op: OpEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, Line{Op: OpDelete, Text: x[i]})
+			i++
+		default:
+			diff = append(diff, Line{Op: OpInsert, Text: y[j]})
+			j++
+		}
+	}
+	diff = appendLines(diff, OpDelete, x[i:])
+	return appendLines(diff, OpInsert, y[j:])
+}
@@ -0,0 +1,80 @@
+package textdiff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/myacey/redditclone/internal/textdiff"
+)
+
+func TestLines(t *testing.T) {
+	eq := func(text string) textdiff.Line { return textdiff.Line{Op: textdiff.OpEqual, Text: text} }
+	del := func(text string) textdiff.Line { return textdiff.Line{Op: textdiff.OpDelete, Text: text} }
+	ins := func(text string) textdiff.Line { return textdiff.Line{Op: textdiff.OpInsert, Text: text} }
+
+	testCases := []struct {
+		name string
+		a, b string
+		want []textdiff.Line
+	}{
+		{
+			name: "Same",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []textdiff.Line{eq("one"), eq("two")},
+		},
+		{
+			name: "Both empty",
+			want: []textdiff.Line{},
+		},
+		{
+			name: "From empty",
+			b:    "one\ntwo",
+			want: []textdiff.Line{ins("one"), ins("two")},
+		},
+		{
+			name: "Replaced line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []textdiff.Line{eq("one"), del("two"), ins("2"), eq("three")},
+		},
+		{
+			name: "Moved line",
+			a:    "a\nb\nc\nd",
+			b:    "b\nc\na\nd",
+			want: []textdiff.Line{del("a"), eq("b"), eq("c"), ins("a"), eq("d")},
+		},
+		{
+			name: "Windows line endings",
+			a:    "one\r\ntwo",
+			b:    "one\nthree",
+			want: []textdiff.Line{eq("one"), del("two"), ins("three")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, textdiff.Lines(tc.a, tc.b))
+		})
+	}
+}
+
+func TestLinesTooManyChanges(t *testing.T) {
+	a := make([]string, 2000)
+	b := make([]string, 2000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+
+	diff := textdiff.Lines("same\n"+strings.Join(a, "\n"), "same\n"+strings.Join(b, "\n"))
+	// 2000x2000 table is too big, whole text is replaced
+	if assert.Len(t, diff, 1+2*2000) {
+		assert.Equal(t, textdiff.Line{Op: textdiff.OpEqual, Text: "same"}, diff[0])
+		assert.Equal(t, textdiff.Line{Op: textdiff.OpDelete, Text: "a1999"}, diff[2000])
+		assert.Equal(t, textdiff.Line{Op: textdiff.OpInsert, Text: "b0"}, diff[2001])
+	}
+}
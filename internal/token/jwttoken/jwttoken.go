@@ -2,7 +2,7 @@ package jwttoken
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -12,11 +12,21 @@ import (
 )
 
 type JWTToken struct {
-	secretKey []byte
+	keyring  *Keyring
+	duration time.Duration
 }
 
-func NewJWTToken(secretKey []byte) token.TokenMaker {
-	jwtToken := &JWTToken{secretKey}
+// NewJWTToken creates maker of HS256 access tokens living for duration.
+func NewJWTToken(secretKey []byte, duration time.Duration) token.TokenMaker {
+	// HMAC key always has private part, so error is impossible
+	keyring, _ := NewKeyring(NewHMACKey(secretKey))
+	return NewJWTTokenWithKeyring(keyring, duration)
+}
+
+// NewJWTTokenWithKeyring creates maker signing tokens with keyring's signing key
+// (RS256 or EdDSA) and accepting tokens signed by any key of the keyring.
+func NewJWTTokenWithKeyring(keyring *Keyring, duration time.Duration) token.TokenMaker {
+	jwtToken := &JWTToken{keyring, duration}
 	return jwtToken
 }
 
@@ -24,10 +34,16 @@ func (t *JWTToken) CreateToken(usr *models.User) (string, error) {
 	claims := jwt.MapClaims{
 		"user": usr,
 		"iat":  time.Now().Unix(),
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
+		"exp":  time.Now().Add(t.duration).Unix(),
+	}
+
+	key := t.keyring.SigningKey()
+	tkn := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		tkn.Header["kid"] = key.ID
 	}
-	tkn := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := tkn.SignedString(t.secretKey)
+
+	tokenString, err := tkn.SignedString(key.private)
 	if err != nil {
 		return "", token.ErrCantCreateToken
 	}
@@ -38,13 +54,26 @@ func (t *JWTToken) CreateToken(usr *models.User) (string, error) {
 func (t *JWTToken) ExtractUserID(tokenString string) (string, error) {
 	claims := jwt.MapClaims{}
 	_, err := jwt.ParseWithClaims(tokenString, claims, func(tok *jwt.Token) (interface{}, error) {
-		if _, ok := tok.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := tok.Header["kid"].(string)
+		key, err := t.keyring.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// algorithm is bound to the key, not taken from the token,
+		// so RS256 public key cant be used as HMAC secret
+		if tok.Method.Alg() != key.Method.Alg() {
 			return nil, token.ErrInvalidTokenMethod
 		}
-		return t.secretKey, nil
+		return key.public, nil
 	})
 	if err != nil {
-		return "", err
+		// expired, but otherwise valid token
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return "", token.ErrTokenExpired
+		}
+		return "", token.ErrTokenInvalid
 	}
 
 	userData, err := json.Marshal(claims["user"])
@@ -57,8 +86,6 @@ func (t *JWTToken) ExtractUserID(tokenString string) (string, error) {
 		return "", token.ErrNoClaims
 	}
 
-	fmt.Printf("TOKEN: %v\n", claims)
-
 	expFloat, ok := claims["exp"].(float64)
 	if !ok {
 		return "", token.ErrCantExtractExpTime
@@ -72,3 +99,8 @@ func (t *JWTToken) ExtractUserID(tokenString string) (string, error) {
 
 	return usr.ID, nil
 }
+
+// JWKS returns public keys tokens can be verified with.
+func (t *JWTToken) JWKS() ([]byte, error) {
+	return t.keyring.JWKS()
+}
@@ -0,0 +1,183 @@
+package jwttoken_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/token/jwttoken"
+)
+
+var mockUser = models.NewUser("testuser", "qwerty123")
+
+func rsaKey(t *testing.T) (*jwttoken.Key, *rsa.PrivateKey) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	key, err := jwttoken.NewKeyFromPrivatePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	return key, private
+}
+
+func ed25519Key(t *testing.T) (*jwttoken.Key, ed25519.PublicKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	key, err := jwttoken.NewKeyFromPrivatePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	return key, public
+}
+
+func publicOnly(t *testing.T, public interface{}) *jwttoken.Key {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	key, err := jwttoken.NewKeyFromPublicPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaSigning, _ := rsaKey(t)
+	edSigning, _ := ed25519Key(t)
+
+	testCases := []struct {
+		name   string
+		key    *jwttoken.Key
+		expAlg string
+	}{
+		{name: "RS256", key: rsaSigning, expAlg: "RS256"},
+		{name: "EdDSA", key: edSigning, expAlg: "EdDSA"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyring, err := jwttoken.NewKeyring(tc.key)
+			require.NoError(t, err)
+			maker := jwttoken.NewJWTTokenWithKeyring(keyring, time.Minute)
+
+			tokenString, err := maker.CreateToken(mockUser)
+			require.NoError(t, err)
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expAlg, parsed.Header["alg"])
+			assert.Equal(t, tc.key.ID, parsed.Header["kid"])
+
+			userID, err := maker.ExtractUserID(tokenString)
+			assert.NoError(t, err)
+			assert.Equal(t, mockUser.ID, userID)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, oldPrivate := rsaKey(t)
+	newKey, _ := ed25519Key(t)
+
+	keyring, err := jwttoken.NewKeyring(oldKey)
+	require.NoError(t, err)
+	maker := jwttoken.NewJWTTokenWithKeyring(keyring, time.Minute)
+
+	oldToken, err := maker.CreateToken(mockUser)
+	require.NoError(t, err)
+
+	require.NoError(t, keyring.Rotate(newKey))
+	newToken, err := maker.CreateToken(mockUser)
+	require.NoError(t, err)
+
+	// both keys are valid during rotation
+	_, err = maker.ExtractUserID(oldToken)
+	assert.NoError(t, err)
+	_, err = maker.ExtractUserID(newToken)
+	assert.NoError(t, err)
+
+	// another instance that knows old key only by its public part
+	restarted, err := jwttoken.NewKeyring(newKey, publicOnly(t, &oldPrivate.PublicKey))
+	require.NoError(t, err)
+	_, err = jwttoken.NewJWTTokenWithKeyring(restarted, time.Minute).ExtractUserID(oldToken)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, keyring.Remove(newKey.ID), jwttoken.ErrCantRemoveSigning)
+	require.NoError(t, keyring.Remove(oldKey.ID))
+	_, err = maker.ExtractUserID(oldToken)
+	assert.Error(t, err)
+	_, err = maker.ExtractUserID(newToken)
+	assert.NoError(t, err)
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	key, private := rsaKey(t)
+	keyring, err := jwttoken.NewKeyring(key)
+	require.NoError(t, err)
+	maker := jwttoken.NewJWTTokenWithKeyring(keyring, time.Minute)
+
+	// HS256 token signed with RSA public key as HMAC secret
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user": mockUser,
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = key.ID
+	forgedString, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	require.NoError(t, err)
+
+	_, err = maker.ExtractUserID(forgedString)
+	assert.Error(t, err)
+}
+
+func TestHMACCompatibility(t *testing.T) {
+	maker := jwttoken.NewJWTToken([]byte("secret"), time.Minute)
+
+	// token issued before kid header existed
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user": mockUser,
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	})
+	legacyString, err := legacy.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	userID, err := maker.ExtractUserID(legacyString)
+	assert.NoError(t, err)
+	assert.Equal(t, mockUser.ID, userID)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaSigning, _ := rsaKey(t)
+	edSigning, _ := ed25519Key(t)
+
+	keyring, err := jwttoken.NewKeyring(rsaSigning, edSigning, jwttoken.NewHMACKey([]byte("secret")))
+	require.NoError(t, err)
+
+	data, err := keyring.JWKS()
+	require.NoError(t, err)
+
+	var set jwttoken.JWKSet
+	require.NoError(t, json.Unmarshal(data, &set))
+
+	kids := map[string]string{}
+	for _, k := range set.Keys {
+		kids[k.Kid] = k.Kty
+		assert.NotEmpty(t, k.X+k.N, "key material should be present")
+	}
+	assert.Equal(t, map[string]string{rsaSigning.ID: "RSA", edSigning.ID: "OKP"}, kids)
+	assert.NotContains(t, string(data), "secret")
+}
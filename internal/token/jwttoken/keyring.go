@@ -0,0 +1,289 @@
+package jwttoken
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrInvalidKey        = errors.New("invalid key")
+	ErrUnsupportedKey    = errors.New("unsupported key type")
+	ErrNoSigningKey      = errors.New("keyring has no signing key")
+	ErrUnknownKeyID      = errors.New("unknown key id")
+	ErrCantRemoveSigning = errors.New("cant remove signing key")
+)
+
+// Key is a single key of the keyring.
+// Verification-only keys (old rotated keys) have no private part.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	private interface{}
+	public  interface{}
+}
+
+// NewHMACKey creates symmetric HS256 key. Its ID is empty,
+// so tokens issued before keyring existed (without kid) are still valid.
+func NewHMACKey(secret []byte) *Key {
+	return &Key{
+		ID:      "",
+		Method:  jwt.SigningMethodHS256,
+		private: secret,
+		public:  secret,
+	}
+}
+
+// NewKeyFromPrivatePEM parses PKCS#8 (RSA or Ed25519) or PKCS#1 (RSA) private key.
+// Key ID is JWK thumbprint (RFC 7638) of the public key.
+func NewKeyFromPrivatePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return newKey(k, &k.PublicKey)
+	case ed25519.PrivateKey:
+		return newKey(k, k.Public())
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// NewKeyFromPublicPEM parses PKIX public key, such key can only verify tokens.
+func NewKeyFromPublicPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return newKey(nil, public)
+}
+
+func newKey(private, public interface{}) (*Key, error) {
+	key := &Key{private: private, public: public}
+
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	jwk, err := key.JWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.thumbprint()
+
+	return key, nil
+}
+
+// JWK returns public part of the key.
+func (k *Key) JWK() (*JWK, error) {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// JWK is JSON Web Key (RFC 7517) with public key only.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// thumbprint is RFC 7638: sha256 of required members in lexicographic order.
+func (j *JWK) thumbprint() string {
+	var canonical string
+	switch j.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Crv, j.X)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKSet is served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// Keyring holds one signing key and any number of verification keys.
+// To rotate keys, new key becomes signing one and the old one is kept
+// for verification until all tokens signed with it expire.
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeyring(signing *Key, verification ...*Key) (*Keyring, error) {
+	if signing == nil || signing.private == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	keyring := &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, k := range verification {
+		keyring.keys[k.ID] = k
+	}
+
+	return keyring, nil
+}
+
+// LoadKeyring reads signing private key and old public keys from PEM files.
+func LoadKeyring(signingKeyFile string, verificationKeyFiles ...string) (*Keyring, error) {
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cant read signing key: %v", err)
+	}
+	signing, err := NewKeyFromPrivatePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("cant parse signing key %s: %v", signingKeyFile, err)
+	}
+
+	verification := make([]*Key, 0, len(verificationKeyFiles))
+	for _, f := range verificationKeyFiles {
+		data, err = os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("cant read verification key: %v", err)
+		}
+		key, err := NewKeyFromPublicPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("cant parse verification key %s: %v", f, err)
+		}
+		verification = append(verification, key)
+	}
+
+	return NewKeyring(signing, verification...)
+}
+
+// Rotate makes newSigning the signing key, previous one stays for verification.
+func (k *Keyring) Rotate(newSigning *Key) error {
+	if newSigning == nil || newSigning.private == nil {
+		return ErrNoSigningKey
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.signing = newSigning
+	k.keys[newSigning.ID] = newSigning
+	return nil
+}
+
+// Remove drops verification key, tokens signed with it become invalid.
+func (k *Keyring) Remove(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.signing.ID == kid {
+		return ErrCantRemoveSigning
+	}
+	delete(k.keys, kid)
+	return nil
+}
+
+func (k *Keyring) SigningKey() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.signing
+}
+
+func (k *Keyring) VerificationKey(kid string) (*Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+// JWKS returns public keys of the keyring, symmetric keys are skipped.
+func (k *Keyring) JWKS() ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: []*JWK{}}
+	for _, key := range k.keys {
+		jwk, err := key.JWK()
+		if errors.Is(err, ErrUnsupportedKey) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return json.Marshal(set)
+}
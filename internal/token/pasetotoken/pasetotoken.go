@@ -0,0 +1,145 @@
+package pasetotoken
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/token"
+)
+
+var (
+	ErrInvalidKeySize = errors.New("invalid paseto key size")
+	ErrInvalidKey     = errors.New("invalid paseto key")
+)
+
+const (
+	modeLocal  = "local"
+	modePublic = "public"
+)
+
+// PasetoToken makes PASETO v4 tokens with claims of JWT maker. Unlike JWT,
+// algorithm is fixed by version and purpose, so token cant choose how it is
+// verified. Footer and implicit assertions arent used.
+type PasetoToken struct {
+	mode     string
+	duration time.Duration
+
+	symmetricKey paseto.V4SymmetricKey        // local
+	secretKey    paseto.V4AsymmetricSecretKey // public
+	publicKey    paseto.V4AsymmetricPublicKey // public
+}
+
+// NewPasetoLocal creates maker of encrypted v4.local tokens.
+func NewPasetoLocal(symmetricKey []byte, duration time.Duration) (token.TokenMaker, error) {
+	if len(symmetricKey) != 32 {
+		return nil, ErrInvalidKeySize
+	}
+
+	key, err := paseto.V4SymmetricKeyFromBytes(symmetricKey)
+	if err != nil {
+		return nil, ErrInvalidKeySize
+	}
+
+	return &PasetoToken{
+		mode:         modeLocal,
+		duration:     duration,
+		symmetricKey: key,
+	}, nil
+}
+
+// NewPasetoPublic creates maker of signed v4.public tokens.
+func NewPasetoPublic(privateKey ed25519.PrivateKey, duration time.Duration) (token.TokenMaker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	secretKey, err := paseto.NewV4AsymmetricSecretKeyFromEd25519(privateKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return &PasetoToken{
+		mode:      modePublic,
+		duration:  duration,
+		secretKey: secretKey,
+		publicKey: secretKey.Public(),
+	}, nil
+}
+
+// LoadPrivateKey reads PKCS#8 Ed25519 private key from PEM file.
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cant read paseto key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	return privateKey, nil
+}
+
+func (t *PasetoToken) CreateToken(usr *models.User) (string, error) {
+	now := time.Now()
+	tkn := paseto.NewToken()
+	tkn.SetIssuedAt(now)
+	tkn.SetExpiration(now.Add(t.duration))
+	if err := tkn.Set("user", usr); err != nil {
+		return "", token.ErrCantCreateToken
+	}
+
+	if t.mode == modePublic {
+		return tkn.V4Sign(t.secretKey, nil), nil
+	}
+	return tkn.V4Encrypt(t.symmetricKey, nil), nil
+}
+
+func (t *PasetoToken) ExtractUserID(tokenString string) (string, error) {
+	// expiration is checked below to tell it from invalid token
+	parser := paseto.NewParserWithoutExpiryCheck()
+
+	var (
+		tkn *paseto.Token
+		err error
+	)
+	if t.mode == modePublic {
+		tkn, err = parser.ParseV4Public(t.publicKey, tokenString, nil)
+	} else {
+		tkn, err = parser.ParseV4Local(t.symmetricKey, tokenString, nil)
+	}
+	if err != nil || len(tkn.Footer()) != 0 {
+		return "", token.ErrTokenInvalid
+	}
+
+	var usr models.User
+	if err = tkn.Get("user", &usr); err != nil || usr.ID == "" {
+		return "", token.ErrNoClaims
+	}
+	expiresAt, err := tkn.GetExpiration()
+	if err != nil {
+		return "", token.ErrCantExtractExpTime
+	}
+	if expiresAt.Before(time.Now()) {
+		return "", token.ErrTokenExpired
+	}
+
+	return usr.ID, nil
+}
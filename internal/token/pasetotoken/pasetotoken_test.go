@@ -0,0 +1,161 @@
+package pasetotoken
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/token"
+)
+
+var mockUser = models.NewUser("testuser", "qwerty123")
+
+// official test vectors, payloads have no user claim, so successfully
+// decrypted or verified token gives ErrNoClaims
+func TestOfficialVectors(t *testing.T) {
+	symmetricKey, err := hex.DecodeString("707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f")
+	require.NoError(t, err)
+	local, err := NewPasetoLocal(symmetricKey, time.Minute)
+	require.NoError(t, err)
+
+	seed, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774")
+	require.NoError(t, err)
+	public, err := NewPasetoPublic(ed25519.NewKeyFromSeed(seed), time.Minute)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		maker  token.TokenMaker
+		token  string
+		expErr error
+	}{
+		{
+			name:   "4-E-1",
+			maker:  local,
+			token:  "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+			expErr: token.ErrNoClaims,
+		},
+		{
+			name:   "4-E-3",
+			maker:  local,
+			token:  "v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t6-tyebyWG6Ov7kKvBdkrrAJ837lKP3iDag2hzUPHuMKA",
+			expErr: token.ErrNoClaims,
+		},
+		{
+			name:   "4-S-1",
+			maker:  public,
+			token:  "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+			expErr: token.ErrNoClaims,
+		},
+		{
+			// valid, but we dont accept footers
+			name:   "4-S-2",
+			maker:  public,
+			token:  "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+			expErr: token.ErrTokenInvalid,
+		},
+		{
+			name:   "local token to public maker",
+			maker:  public,
+			token:  "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+			expErr: token.ErrTokenInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.maker.ExtractUserID(tc.token)
+			assert.ErrorIs(t, err, tc.expErr)
+		})
+	}
+}
+
+func TestTamperedToken(t *testing.T) {
+	local, err := NewPasetoLocal(make([]byte, 32), time.Minute)
+	require.NoError(t, err)
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	public, err := NewPasetoPublic(privateKey, time.Minute)
+	require.NoError(t, err)
+
+	for name, maker := range map[string]token.TokenMaker{"local": local, "public": public} {
+		t.Run(name, func(t *testing.T) {
+			tokenString, err := maker.CreateToken(mockUser)
+			require.NoError(t, err)
+
+			userID, err := maker.ExtractUserID(tokenString)
+			require.NoError(t, err)
+			assert.Equal(t, mockUser.ID, userID)
+
+			// last char is skipped, its low bits can be only padding
+			for _, i := range []int{len("v4.") + 7, len(tokenString) / 2, len(tokenString) - 3} {
+				tampered := []byte(tokenString)
+				if tampered[i] == 'A' {
+					tampered[i] = 'B'
+				} else {
+					tampered[i] = 'A'
+				}
+				_, err = maker.ExtractUserID(string(tampered))
+				assert.ErrorIs(t, err, token.ErrTokenInvalid, "tampered at %d", i)
+			}
+
+			for _, n := range []int{1, 10, len(tokenString) / 2, len(tokenString) - len("v4.public.")} {
+				_, err = maker.ExtractUserID(tokenString[:len(tokenString)-n])
+				assert.ErrorIs(t, err, token.ErrTokenInvalid, "truncated by %d", n)
+			}
+		})
+	}
+}
+
+func TestFooterRejected(t *testing.T) {
+	maker, err := NewPasetoLocal(make([]byte, 32), time.Minute)
+	require.NoError(t, err)
+
+	tokenString, err := maker.CreateToken(mockUser)
+	require.NoError(t, err)
+
+	_, err = maker.ExtractUserID(tokenString + "." + base64.RawURLEncoding.EncodeToString([]byte("footer")))
+	assert.ErrorIs(t, err, token.ErrTokenInvalid)
+}
+
+func TestInvalidKeySize(t *testing.T) {
+	_, err := NewPasetoLocal(make([]byte, 16), time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+
+	_, err = NewPasetoPublic(make([]byte, 16), time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "paseto.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	garbageFile := filepath.Join(dir, "garbage.pem")
+	require.NoError(t, os.WriteFile(garbageFile, []byte("garbage"), 0o600))
+
+	loaded, err := LoadPrivateKey(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, loaded)
+
+	_, err = LoadPrivateKey(garbageFile)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = LoadPrivateKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
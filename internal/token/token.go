@@ -19,3 +19,10 @@ type TokenMaker interface {
 	CreateToken(usr *models.User) (string, error)
 	ExtractUserID(tokenString string) (string, error)
 }
+
+// KeySetProvider is implemented by makers signing tokens with asymmetric keys,
+// so other services can verify tokens without sharing a secret.
+type KeySetProvider interface {
+	// JWKS returns JSON Web Key Set document
+	JWKS() ([]byte, error)
+}
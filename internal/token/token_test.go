@@ -0,0 +1,145 @@
+package token_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/models"
+	"github.com/myacey/redditclone/internal/token"
+	"github.com/myacey/redditclone/internal/token/jwttoken"
+	"github.com/myacey/redditclone/internal/token/pasetotoken"
+)
+
+var mockUser = models.NewUser("testuser", "qwerty123")
+
+// makerFactory creates maker with its own random key,
+// so two makers from one factory dont trust each other.
+type makerFactory func(t *testing.T, duration time.Duration) token.TokenMaker
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+var makers = map[string]makerFactory{
+	"jwt hs256": func(t *testing.T, duration time.Duration) token.TokenMaker {
+		return jwttoken.NewJWTToken(randomBytes(t, 32), duration)
+	},
+	"jwt eddsa": func(t *testing.T, duration time.Duration) token.TokenMaker {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		key, err := jwttoken.NewKeyFromPrivatePEM(ed25519PEM(t, private))
+		require.NoError(t, err)
+		keyring, err := jwttoken.NewKeyring(key)
+		require.NoError(t, err)
+		return jwttoken.NewJWTTokenWithKeyring(keyring, duration)
+	},
+	"paseto v4.local": func(t *testing.T, duration time.Duration) token.TokenMaker {
+		maker, err := pasetotoken.NewPasetoLocal(randomBytes(t, 32), duration)
+		require.NoError(t, err)
+		return maker
+	},
+	"paseto v4.public": func(t *testing.T, duration time.Duration) token.TokenMaker {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		maker, err := pasetotoken.NewPasetoPublic(private, duration)
+		require.NoError(t, err)
+		return maker
+	},
+}
+
+// TestTokenMakerConformance checks that every TokenMaker behaves the same way,
+// so they can be swapped by configuration.
+func TestTokenMakerConformance(t *testing.T) {
+	for name, newMaker := range makers {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Round trip", func(t *testing.T) {
+				maker := newMaker(t, time.Minute)
+
+				tokenString, err := maker.CreateToken(mockUser)
+				require.NoError(t, err)
+				assert.NotEmpty(t, tokenString)
+
+				userID, err := maker.ExtractUserID(tokenString)
+				assert.NoError(t, err)
+				assert.Equal(t, mockUser.ID, userID)
+			})
+
+			t.Run("Expired", func(t *testing.T) {
+				maker := newMaker(t, -time.Minute)
+
+				tokenString, err := maker.CreateToken(mockUser)
+				require.NoError(t, err)
+
+				_, err = maker.ExtractUserID(tokenString)
+				assert.ErrorIs(t, err, token.ErrTokenExpired)
+			})
+
+			t.Run("Tampered", func(t *testing.T) {
+				maker := newMaker(t, time.Minute)
+
+				tokenString, err := maker.CreateToken(mockUser)
+				require.NoError(t, err)
+
+				// flip a char in the middle of the token
+				i := len(tokenString) / 2
+				replacement := "A"
+				if tokenString[i] == 'A' {
+					replacement = "B"
+				}
+				tampered := tokenString[:i] + replacement + tokenString[i+1:]
+
+				_, err = maker.ExtractUserID(tampered)
+				assert.ErrorIs(t, err, token.ErrTokenInvalid)
+			})
+
+			t.Run("Other key", func(t *testing.T) {
+				tokenString, err := newMaker(t, time.Minute).CreateToken(mockUser)
+				require.NoError(t, err)
+
+				_, err = newMaker(t, time.Minute).ExtractUserID(tokenString)
+				assert.ErrorIs(t, err, token.ErrTokenInvalid)
+			})
+
+			t.Run("Garbage", func(t *testing.T) {
+				maker := newMaker(t, time.Minute)
+
+				for _, garbage := range []string{"", "garbage", "a.b.c", "v4.local.", "v4.public." + strings.Repeat("A", 20)} {
+					_, err := maker.ExtractUserID(garbage)
+					assert.ErrorIs(t, err, token.ErrTokenInvalid, garbage)
+				}
+			})
+
+			t.Run("Other maker type", func(t *testing.T) {
+				maker := newMaker(t, time.Minute)
+
+				for otherName, newOther := range makers {
+					if otherName == name {
+						continue
+					}
+					tokenString, err := newOther(t, time.Minute).CreateToken(mockUser)
+					require.NoError(t, err)
+
+					_, err = maker.ExtractUserID(tokenString)
+					assert.ErrorIs(t, err, token.ErrTokenInvalid, otherName)
+				}
+			})
+		})
+	}
+}
+
+func ed25519PEM(t *testing.T, private ed25519.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
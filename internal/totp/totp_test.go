@@ -0,0 +1,74 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myacey/redditclone/internal/totp"
+)
+
+// RFC 6238 appendix B, SHA1 vectors truncated to 6 digits
+func TestCodeVectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range testCases {
+		code, err := totp.Code(secret, totp.Step(time.Unix(tc.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.code, code, tc.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Step(now))
+	require.NoError(t, err)
+
+	step, ok := totp.Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// previous period is still accepted
+	_, ok = totp.Validate(secret, code, now.Add(totp.Period))
+	assert.True(t, ok)
+
+	_, ok = totp.Validate(secret, code, now.Add(3*totp.Period))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(secret, "12345", now)
+	assert.False(t, ok)
+
+	_, ok = totp.Validate("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("redditclone", "testuser", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/redditclone:testuser", parsed.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "redditclone", parsed.Query().Get("issuer"))
+}
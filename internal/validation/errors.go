@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"net/http"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+// FieldError describes why single field of the request is invalid.
+// Code is stable and can be used by clients, Message is for humans.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	CodeRequired         = "required"
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeInvalidChars     = "invalid_chars"
+	CodeMixedScripts     = "mixed_scripts"
+	CodeReserved         = "reserved"
+	CodeTaken            = "taken"
+	CodeCommonPassword   = "common_password"
+	CodeContainsUsername = "contains_username"
+)
+
+// Error is StatusCodedError with list of invalid fields,
+// handlers add them to the error body.
+type Error struct {
+	*errhandler.StatusCodedError
+	Fields []FieldError
+}
+
+func (e *Error) Unwrap() error {
+	return e.StatusCodedError
+}
+
+// NewError returns 400 error with fields, or nil if there are none.
+func NewError(fields ...FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return NewErrorWithStatus(http.StatusBadRequest, "validation failed", fields...)
+}
+
+func NewErrorWithStatus(statusCode int, userAnswer string, fields ...FieldError) error {
+	return &Error{
+		StatusCodedError: &errhandler.StatusCodedError{
+			StatusCode: statusCode,
+			UserAnswer: userAnswer,
+			DebugLog:   "invalid fields",
+		},
+		Fields: fields,
+	}
+}
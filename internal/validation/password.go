@@ -0,0 +1,49 @@
+package validation
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinPasswordLength = 8
+	// MaxPasswordLength keeps hashing cheap for huge inputs
+	MaxPasswordLength = 128
+)
+
+// common_passwords.txt has the most popular passwords from public breach
+// corpora, they are the first ones tried by credential stuffing.
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadList(commonPasswordsFile, strings.ToLower)
+
+// ValidatePassword checks length and rejects common passwords and ones
+// containing username. Username can be empty if it isnt known.
+func ValidatePassword(password, username string) []FieldError {
+	const field = "password"
+
+	if password == "" {
+		return []FieldError{{field, CodeRequired, "password is required"}}
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < MinPasswordLength {
+		return []FieldError{{field, CodeTooShort, fmt.Sprintf("password must be at least %d characters", MinPasswordLength)}}
+	}
+	if length > MaxPasswordLength {
+		return []FieldError{{field, CodeTooLong, fmt.Sprintf("password must be at most %d characters", MaxPasswordLength)}}
+	}
+
+	if commonPasswords[strings.ToLower(password)] {
+		return []FieldError{{field, CodeCommonPassword, "password is too common"}}
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return []FieldError{{field, CodeContainsUsername, "password cant contain username"}}
+	}
+
+	return nil
+}
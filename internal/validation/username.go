@@ -0,0 +1,133 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+)
+
+//go:embed reserved_usernames.txt
+var reservedUsernamesFile string
+
+var reservedUsernames = loadList(reservedUsernamesFile, UsernameKey)
+
+// confusables maps letters looking like latin ones (and digits looking like
+// letters) to them, so "аdmin" with cyrillic "а" and "Admin" are the same user.
+// Only scripts mostly used for impersonation are covered.
+var confusables = map[rune]rune{
+	// cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd',
+	'һ': 'h', 'ӏ': 'l', 'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'ь': 'b',
+	// greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y', 'ζ': 'z', 'μ': 'u',
+	// digits
+	'0': 'o', '1': 'l',
+	// separators
+	'-': '_',
+}
+
+var foldCaser = cases.Fold()
+
+// NormalizeUsername is form username is stored in: NFKC turns
+// fullwidth and other compatibility characters into usual ones.
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(username)
+}
+
+// UsernameKey is used for uniqueness: usernames with the same key
+// differ only in case or confusable characters.
+func UsernameKey(username string) string {
+	folded := foldCaser.String(NormalizeUsername(username))
+
+	var key strings.Builder
+	for _, r := range folded {
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		key.WriteRune(r)
+	}
+	return key.String()
+}
+
+// ValidateUsername checks normalized username: length, charset,
+// mixing of scripts and reserved names.
+func ValidateUsername(username string) []FieldError {
+	const field = "username"
+
+	if username == "" {
+		return []FieldError{{field, CodeRequired, "username is required"}}
+	}
+
+	length := utf8.RuneCountInString(username)
+	if length < MinUsernameLength {
+		return []FieldError{{field, CodeTooShort, fmt.Sprintf("username must be at least %d characters", MinUsernameLength)}}
+	}
+	if length > MaxUsernameLength {
+		return []FieldError{{field, CodeTooLong, fmt.Sprintf("username must be at most %d characters", MaxUsernameLength)}}
+	}
+
+	scripts := map[string]bool{}
+	for i, r := range username {
+		switch {
+		case unicode.IsLetter(r):
+			if script := scriptOf(r); script != "" {
+				scripts[script] = true
+			}
+		case r >= '0' && r <= '9':
+		case (r == '_' || r == '-') && i > 0:
+		default:
+			return []FieldError{{field, CodeInvalidChars, "username can contain only letters, digits, '_' and '-' and must start with letter or digit"}}
+		}
+	}
+	if len(scripts) > 1 {
+		return []FieldError{{field, CodeMixedScripts, "username cant mix latin, cyrillic and greek letters"}}
+	}
+
+	if reservedUsernames[UsernameKey(username)] {
+		return []FieldError{{field, CodeReserved, "username is reserved"}}
+	}
+
+	return nil
+}
+
+// scriptOf returns script of letter if it can be confused with other ones.
+// Other scripts can be mixed with them freely.
+func scriptOf(r rune) string {
+	switch {
+	case unicode.Is(unicode.Latin, r):
+		return "latin"
+	case unicode.Is(unicode.Cyrillic, r):
+		return "cyrillic"
+	case unicode.Is(unicode.Greek, r):
+		return "greek"
+	default:
+		return ""
+	}
+}
+
+// loadList reads embedded list, one entry per line, "#" starts a comment.
+func loadList(data string, key func(string) string) map[string]bool {
+	list := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[key(line)] = true
+	}
+	return list
+}
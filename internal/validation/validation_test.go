@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/myacey/redditclone/internal/customerror/errhandler"
+)
+
+func TestValidateUsername(t *testing.T) {
+	testCases := []struct {
+		username string
+		wantCode string
+	}{
+		{"testuser", ""},
+		{"test_user-1", ""},
+		{"Пользователь", ""},
+		{"", CodeRequired},
+		{"ab", CodeTooShort},
+		{strings.Repeat("a", MaxUsernameLength+1), CodeTooLong},
+		{"test user", CodeInvalidChars},
+		{"_test", CodeInvalidChars},
+		{"test@user", CodeInvalidChars},
+		{"pаypal", CodeMixedScripts}, // cyrillic "а"
+		{"Admin", CodeReserved},
+		{"m0derator", CodeReserved},
+		{"No_Reply", CodeReserved},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.username, func(t *testing.T) {
+			fields := ValidateUsername(NormalizeUsername(tc.username))
+			if tc.wantCode == "" {
+				assert.Empty(t, fields)
+				return
+			}
+			if assert.Len(t, fields, 1) {
+				assert.Equal(t, "username", fields[0].Field)
+				assert.Equal(t, tc.wantCode, fields[0].Code)
+			}
+		})
+	}
+}
+
+func TestUsernameKey(t *testing.T) {
+	assert.Equal(t, UsernameKey("admin"), UsernameKey("Admin"))
+	// fullwidth letters
+	assert.Equal(t, UsernameKey("admin"), UsernameKey("ａｄｍｉｎ"))
+	// cyrillic "а" and "о"
+	assert.Equal(t, UsernameKey("bob_fan"), UsernameKey("bоb_fаn"))
+	assert.Equal(t, UsernameKey("cool-guy"), UsernameKey("COOL_GUY"))
+	assert.NotEqual(t, UsernameKey("alice"), UsernameKey("alicia"))
+}
+
+func TestValidatePassword(t *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		username string
+		wantCode string
+	}{
+		{"Success", "correct-horse-42", "testuser", ""},
+		{"Empty", "", "testuser", CodeRequired},
+		{"Short", "abc123", "testuser", CodeTooShort},
+		{"Long", strings.Repeat("a", MaxPasswordLength+1), "testuser", CodeTooLong},
+		{"Common", "Qwerty123", "testuser", CodeCommonPassword},
+		{"Contains username", "my-TestUser-pass", "testuser", CodeContainsUsername},
+		{"Unknown username", "my-testuser-pass", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := ValidatePassword(tc.password, tc.username)
+			if tc.wantCode == "" {
+				assert.Empty(t, fields)
+				return
+			}
+			if assert.Len(t, fields, 1) {
+				assert.Equal(t, "password", fields[0].Field)
+				assert.Equal(t, tc.wantCode, fields[0].Code)
+			}
+		})
+	}
+}
+
+func TestNewError(t *testing.T) {
+	assert.NoError(t, NewError())
+
+	err := NewError(FieldError{"username", CodeTooShort, "too short"})
+	assert.EqualError(t, err, "validation failed")
+	assert.Equal(t, http.StatusBadRequest, errhandler.GetStatusCode(err))
+
+	var validationErr *Error
+	if assert.True(t, errors.As(err, &validationErr)) {
+		assert.Equal(t, "username", validationErr.Fields[0].Field)
+	}
+}